@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// asciiMode draws only ASCII: a plain logo, and every other glyph (borders, arrows, emoji) replaced on its way
+// to the screen (--ascii, or a non-UTF-8 locale).
+var asciiMode = nonUTF8Locale()
+
+// logoASCII replaces logoAtlas9 in asciiMode.
+const logoASCII = `       _   _             ___
+  __ _| |_| |__ _ ___   / _ \
+ / _` + "`" + ` |  _| / _` + "`" + ` (_-<   \_, /
+ \__,_|\__|_\__,_/__/    /_/
+manage your database schema as code...`
+
+// asciiSpinnerFrames replace spinnerFrames in asciiMode.
+var asciiSpinnerFrames = []rune(`|/-\`)
+
+// nonUTF8Locale reports whether the effective locale (LC_ALL, then LC_CTYPE, then LANG) is set and names a
+// charset other than UTF-8. An unset locale is left alone: most terminals without one still handle UTF-8.
+func nonUTF8Locale() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return !strings.Contains(v, "utf-8") && !strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
+
+// asciiRunes maps the non-ASCII runes atlas9 and tview draw to ASCII look-alikes; anything else becomes '?'.
+var asciiRunes = map[rune]rune{
+	'─': '-', '━': '-', '═': '-', '│': '|', '┃': '|', '║': '|',
+	'┌': '+', '┐': '+', '└': '+', '┘': '+', '├': '+', '┤': '+', '┬': '+', '┴': '+', '┼': '+',
+	'╔': '+', '╗': '+', '╚': '+', '╝': '+', '╭': '+', '╮': '+', '╰': '+', '╯': '+',
+	'→': '>', '←': '<', '↑': '^', '↓': 'v', '▶': '>', '◀': '<', '▲': '^', '▼': 'v',
+	'—': '-', '–': '-', '…': '.', '•': '*', '·': '.', '≈': '~', '✓': '+', '✅': '+', '❌': 'x', '⚠': '!',
+	'¹': '1', '²': '2', '³': '3', '⁴': '4', '⁵': '5',
+	'█': '#', '▒': '#', '░': ' ', '▌': '|', '▾': 'v', '▸': '>', '\u00a0': ' ',
+}
+
+// asciiScreen is a tcell.Screen that replaces non-ASCII runes with asciiRunes.
+type asciiScreen struct {
+	tcell.Screen
+}
+
+func (s asciiScreen) SetContent(x, y int, primary rune, combining []rune, style tcell.Style) {
+	if primary > 0x7e {
+		r, ok := asciiRunes[primary]
+		if !ok {
+			r = '?'
+		}
+		primary, combining = r, nil
+	}
+	s.Screen.SetContent(x, y, primary, combining, style)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"runtime"
+)
+
+// atlasVersionRe finds the version in `atlas version` output, e.g. "atlas version v0.32.1-175b25e-canary".
+var atlasVersionRe = regexp.MustCompile(`\bv\d+\.\d+\.\d+\b`)
+
+// parseAtlasVersion returns the vMAJOR.MINOR.PATCH in `atlas version` output, or "".
+func parseAtlasVersion(out string) string {
+	return atlasVersionRe.FindString(out)
+}
+
+// atlasUpgradeCommand is the suggested way to upgrade the atlas CLI on this machine.
+func atlasUpgradeCommand() string {
+	if runtime.GOOS == "darwin" {
+		if _, err := exec.LookPath("brew"); err == nil {
+			return "brew upgrade ariga/tap/atlas"
+		}
+	}
+	return "curl -sSf https://atlasgo.sh | sh"
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// benchmarkFormat makes `atlas migrate apply` print one line per applied file: name, version, statements and
+// duration in nanoseconds, tab-separated.
+const benchmarkFormat = "{{ range .Applied }}{{ .Name }}\t{{ .Version }}\t{{ len .Applied }}\t{{ (.End.Sub .Start).Nanoseconds }}\n{{ end }}"
+
+// benchmarkRow is the timing of one migration file applied on the clone.
+type benchmarkRow struct {
+	Name       string
+	Version    string
+	Statements int
+	Duration   time.Duration
+	Pending    bool // not applied on the env yet; replayed files only build the schema up to it
+}
+
+// parseBenchmark reads `migrate apply --format benchmarkFormat` output; pending reports whether a version is
+// pending on the env. Lines that are not rows (warnings) are skipped.
+func parseBenchmark(out string, pending func(version string) bool) []benchmarkRow {
+	var rows []benchmarkRow
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Split(strings.TrimSpace(line), "\t")
+		if len(f) != 4 {
+			continue
+		}
+		stmts, err1 := strconv.Atoi(f[2])
+		ns, err2 := strconv.ParseInt(f[3], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		rows = append(rows, benchmarkRow{Name: f[0], Version: f[1], Statements: stmts, Duration: time.Duration(ns), Pending: pending(f[1])})
+	}
+	return rows
+}
+
+// benchmarkClone is how the env's database is cloned for a benchmark.
+type benchmarkClone int
+
+const (
+	cloneSQLiteFile benchmarkClone = iota // copy of the SQLite file, data included
+	cloneDevSchema                        // every migration replayed on the empty dev database
+	cloneDevData                          // a snapshot of the env restored into the dev database
+)
+
+// chooseBenchmarkClone picks the clone for an env with database url and dev database dev. Data needs a dev
+// database that outlives one atlas command, so not a docker:// one, and a driver snapshots support.
+func chooseBenchmarkClone(url, dev string, withData bool) (benchmarkClone, error) {
+	driver := dbDriver(url)
+	switch {
+	case driver == "sqlite":
+		return cloneSQLiteFile, nil
+	case dev == "":
+		return 0, fmt.Errorf("the env has no dev database to clone into")
+	case !withData:
+		return cloneDevSchema, nil
+	case dbDriver(dev) == "docker":
+		return 0, fmt.Errorf("the dev database is a Docker container that only lives for one atlas command; give the env a dev database server to benchmark with data")
+	case snapshotExt(driver) == "":
+		return 0, fmt.Errorf("cloning data is not supported for %q databases", driver)
+	}
+	return cloneDevData, nil
+}
+
+// benchmarkSetup is a benchmark of the pending migrations of Env.
+type benchmarkSetup struct {
+	Env     string
+	URL     string // the env's database
+	Dev     string // the env's dev database
+	Dir     string // migration directory, relative to WorkDir
+	WorkDir string
+	Clone   benchmarkClone
+	Applied []string // versions applied on Env; nil when they could not be read
+	Environ []string
+	// Protected are the URLs of the protected envs by name; the dev database, cleaned by the benchmark, must
+	// not be one of them.
+	Protected map[string]string
+}
+
+// checkDev refuses a dev database that is not one of its own: the env's url or a protected env's, which the
+// benchmark's atlas schema clean would wipe.
+func (s benchmarkSetup) checkDev() error {
+	if s.Clone == cloneSQLiteFile {
+		return nil
+	}
+	if sameDatabase(s.Dev, s.URL) {
+		return fmt.Errorf("the dev database of %s is its url; the benchmark cleans the dev database, so give the env a separate one", s.Env)
+	}
+	for env, url := range s.Protected {
+		if sameDatabase(s.Dev, url) {
+			return fmt.Errorf("the dev database of %s is the database of protected env %s; the benchmark cleans the dev database", s.Env, env)
+		}
+	}
+	return nil
+}
+
+// sameDatabase reports whether database URLs a and b name the same database: the same driver, host, port
+// and path, whatever the credentials and parameters.
+func sameDatabase(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ua, erra := url.Parse(a)
+	ub, errb := url.Parse(b)
+	if erra != nil || errb != nil {
+		return a == b
+	}
+	port := func(u *url.URL) string {
+		if p := u.Port(); p != "" {
+			return p
+		}
+		return map[string]string{"postgres": "5432", "mysql": "3306"}[dbDriver(u.String())]
+	}
+	return dbDriver(a) == dbDriver(b) && strings.EqualFold(ua.Hostname(), ub.Hostname()) && port(ua) == port(ub) &&
+		strings.TrimSuffix(ua.Path, "/") == strings.TrimSuffix(ub.Path, "/") && ua.Opaque == ub.Opaque
+}
+
+// runBenchmark clones the env's database as s.Clone says, applies the migrations there with run (an atlas
+// command) and returns their timings. log says what was done, for the report. A dev database used as the clone
+// is cleaned before and after, so it is refused when it is the env's own or a protected env's (checkDev).
+func runBenchmark(ctx context.Context, s benchmarkSetup, run func(args ...string) (string, string, error)) (rows []benchmarkRow, log string, err error) {
+	if err := s.checkDev(); err != nil {
+		return nil, "", err
+	}
+	var b strings.Builder
+	target := s.Dev
+	switch s.Clone {
+	case cloneSQLiteFile:
+		src, err := sqlitePath(s.URL, s.WorkDir)
+		if err != nil {
+			return nil, "", err
+		}
+		tmp, err := os.MkdirTemp("", "atlas9-benchmark-")
+		if err != nil {
+			return nil, "", err
+		}
+		defer os.RemoveAll(tmp)
+		dst := filepath.Join(tmp, filepath.Base(src))
+		if err := copyFile(src, dst); err != nil {
+			return nil, "", err
+		}
+		target = "sqlite://" + dst
+		if _, query, ok := strings.Cut(s.URL, "?"); ok {
+			target += "?" + query
+		}
+		fmt.Fprintf(&b, "Copied %s (schema and data) to a temporary file.\n", src)
+	case cloneDevSchema, cloneDevData:
+		if dbDriver(s.Dev) != "docker" {
+			clean := func() error {
+				if _, errOut, err := run("schema", "clean", "--url", s.Dev, "--auto-approve"); err != nil {
+					return fmt.Errorf("cleaning the dev database: %v: %s", err, firstLine(errOut))
+				}
+				return nil
+			}
+			if err := clean(); err != nil {
+				return nil, "", err
+			}
+			defer func() {
+				if cerr := clean(); err == nil {
+					err = cerr
+				}
+			}()
+		}
+		if s.Clone == cloneDevData {
+			tmp, err := os.MkdirTemp("", "atlas9-benchmark-")
+			if err != nil {
+				return nil, "", err
+			}
+			defer os.RemoveAll(tmp)
+			path, err := takeSnapshot(ctx, s.URL, s.WorkDir, tmp, s.Environ)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := restoreSnapshot(ctx, s.Dev, s.WorkDir, path, s.Environ); err != nil {
+				return nil, "", err
+			}
+			fmt.Fprintf(&b, "Restored a snapshot of %s (schema and data) into the dev database.\n", s.Env)
+		} else {
+			b.WriteString("Replayed the migration directory on the empty dev database (schema only, no data).\n")
+		}
+	}
+	applied := make(map[string]bool, len(s.Applied))
+	for _, v := range s.Applied {
+		applied[v] = true
+	}
+	pending := func(v string) bool { return s.Applied != nil && !applied[v] }
+	if s.Clone != cloneDevSchema {
+		pending = func(string) bool { return true } // the clone has the env's revisions
+	}
+	out, errOut, err := run("migrate", "apply", "--url", target, "--dir", "file://"+s.Dir, "--format", benchmarkFormat)
+	rows = parseBenchmark(out, pending)
+	if err != nil {
+		return rows, b.String(), fmt.Errorf("migrate apply on the clone: %v\n%s", err, strings.TrimSpace(errOut))
+	}
+	return rows, b.String(), nil
+}
+
+// renderBenchmark is the Benchmark tab: the duration of every pending migration, the slowest highlighted, and
+// their total as an estimate of the maintenance window. Replayed migrations are listed dimmed.
+func renderBenchmark(env, log string, rows []benchmarkRow, versionsKnown bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[::b]Benchmark of the pending migrations of %s[::-]\n\n", tview.Escape(env))
+	b.WriteString(tview.Escape(log))
+	if !versionsKnown {
+		fmt.Fprintf(&b, "[yellow]The applied versions of %s could not be read: every migration counts as pending.[-]\n", tview.Escape(env))
+	}
+	b.WriteString("\n")
+	var total, slowest time.Duration
+	n := 0
+	for _, r := range rows {
+		if r.Pending {
+			total += r.Duration
+			slowest = max(slowest, r.Duration)
+			n++
+		}
+	}
+	if len(rows) == 0 {
+		b.WriteString("Nothing was applied: there are no pending migrations.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%-16s %10s %6s  %s\n", "VERSION", "DURATION", "STMTS", "FILE")
+	for _, r := range rows {
+		line := fmt.Sprintf("%-16s %10s %6d  %s", r.Version, r.Duration.Round(time.Millisecond), r.Statements, tview.Escape(r.Name))
+		switch {
+		case !r.Pending:
+			line = "[gray]" + line + "  (applied on " + tview.Escape(env) + ")[-]"
+		case r.Duration == slowest && n > 1:
+			line = "[yellow]" + line + "  slowest[-]"
+		}
+		b.WriteString(line + "\n")
+	}
+	fmt.Fprintf(&b, "\n[::b]%d pending migration(s): %s[::-]\n", n, total.Round(time.Millisecond))
+	b.WriteString("\n[gray]Measured on a clone without production load; locks, replication and larger tables make the real run slower. Treat it as a lower bound.[-]\n")
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package main
+
+import "sync"
+
+// uiBus funnels UI updates from worker goroutines (runners, watchers, checks) to the tview event loop.
+// Post never blocks, even before app.Run has started; a single dispatcher goroutine hands everything
+// posted since its last pass to the event loop as one batch, so a burst of updates costs one redraw.
+type uiBus struct {
+	mu      sync.Mutex
+	pending []func()
+	wake    chan struct{}
+}
+
+func newUIBus() *uiBus {
+	return &uiBus{wake: make(chan struct{}, 1)}
+}
+
+// Post queues f to run on the UI goroutine. Updates run in the order they were posted.
+func (b *uiBus) Post(f func()) {
+	b.mu.Lock()
+	b.pending = append(b.pending, f)
+	b.mu.Unlock()
+	select {
+	case b.wake <- struct{}{}:
+	default: // dispatcher already signalled; it will pick f up with the batch
+	}
+}
+
+// run dispatches batches through apply (app.QueueUpdateDraw) until stop is closed.
+func (b *uiBus) run(apply func(func()), stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-b.wake:
+		}
+		b.mu.Lock()
+		batch := b.pending
+		b.pending = nil
+		b.mu.Unlock()
+		apply(func() {
+			for _, f := range batch {
+				f()
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+)
+
+// changelogMigration is one migration file of a changelog.
+type changelogMigration struct {
+	Version string
+	File    string
+	SQL     string
+}
+
+// changelogBound is one end of a changelog range as typed: a migration version or a git ref (tag, branch,
+// commit), whose newest migration version is the bound.
+type changelogBound struct {
+	Input   string
+	Version string // "" for the start of the directory
+	Ref     string // git ref the version was read from, "" for a version
+}
+
+// migrationVersion returns the version of a migration file name: the text before the first "_" or ".".
+func migrationVersion(name string) string {
+	if i := strings.IndexAny(name, "_."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// versionLike matches input that is meant as a migration version rather than a git ref.
+var versionLike = regexp.MustCompile(`^\d{1,14}$`)
+
+// gitOutput runs git in workDir and returns its trimmed stdout; stderr becomes the error.
+func gitOutput(ctx context.Context, workDir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = workDir
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitMigrationFiles lists the .sql files of the migration directory dir (relative to workDir) at git ref, in
+// version order.
+func gitMigrationFiles(ctx context.Context, workDir, dir, ref string) ([]string, error) {
+	out, err := gitOutput(ctx, workDir, "ls-tree", "--name-only", ref+":./"+filepath.ToSlash(dir))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Split(out, "\n") {
+		if filepath.Ext(name) == ".sql" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// resolveChangelogBound reads input as a migration version of files (the migration directory's file names),
+// else as a git ref whose newest migration version it stands for. Empty input is "" (open end).
+func resolveChangelogBound(ctx context.Context, workDir, dir, input string, files []string) (changelogBound, error) {
+	b := changelogBound{Input: strings.TrimSpace(input)}
+	if b.Input == "" {
+		return b, nil
+	}
+	for _, f := range files {
+		if migrationVersion(f) == b.Input {
+			b.Version = b.Input
+			return b, nil
+		}
+	}
+	if versionLike.MatchString(b.Input) {
+		b.Version = b.Input // a version without a file, e.g. one squashed away
+		return b, nil
+	}
+	names, err := gitMigrationFiles(ctx, workDir, dir, b.Input)
+	if err != nil {
+		return b, fmt.Errorf("%q is neither a migration version nor a git ref with %s: %v", b.Input, dir, err)
+	}
+	b.Ref = b.Input
+	if len(names) > 0 {
+		b.Version = migrationVersion(names[len(names)-1])
+	}
+	return b, nil
+}
+
+// changelogMigrations returns the migrations after from up to and including to (the newest when to is open).
+// They are read from the working tree, or from git at to's ref when to is a ref.
+func changelogMigrations(ctx context.Context, workDir, dir string, from, to changelogBound) ([]changelogMigration, error) {
+	files := sqlFiles(filepath.Join(workDir, dir))
+	if to.Ref != "" {
+		var err error
+		if files, err = gitMigrationFiles(ctx, workDir, dir, to.Ref); err != nil {
+			return nil, err
+		}
+	}
+	var out []changelogMigration
+	for _, name := range files {
+		v := migrationVersion(name)
+		if from.Version != "" && v <= from.Version || to.Version != "" && v > to.Version {
+			continue
+		}
+		var sql string
+		if to.Ref != "" {
+			text, err := gitOutput(ctx, workDir, "show", to.Ref+":./"+filepath.ToSlash(filepath.Join(dir, name)))
+			if err != nil {
+				return nil, err
+			}
+			sql = text
+		} else {
+			data, err := os.ReadFile(filepath.Join(workDir, dir, name))
+			if err != nil {
+				return nil, err
+			}
+			sql = string(data)
+		}
+		out = append(out, changelogMigration{Version: v, File: name, SQL: sql})
+	}
+	return out, nil
+}
+
+// changelogMarkdown summarizes migs for release notes: the tables created, altered and dropped, other objects,
+// the destructive operations by file, statement counts per migration and the SQL in collapsible blocks.
+// driver decides how statements are read (see diffChanges).
+func changelogMarkdown(driver, title string, migs []changelogMigration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", title)
+	if len(migs) == 0 {
+		b.WriteString("No migrations in this range.\n")
+		return b.String()
+	}
+	tables := map[byte][]string{}
+	var objects, destructive []string
+	seen := map[string]bool{}
+	statements := 0
+	for _, m := range migs {
+		for _, c := range diffChanges(driver, m.SQL) {
+			key := string(c.Op) + c.Kind + "\x00" + c.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			name := markdownCode(c.Name)
+			if c.Detail != "" {
+				name += " (" + c.Detail + ")"
+			}
+			if c.Kind == "TABLE" {
+				tables[c.Op] = append(tables[c.Op], name)
+				continue
+			}
+			verb := map[byte]string{'+': "created", '~': "altered", '-': "dropped"}[c.Op]
+			objects = append(objects, fmt.Sprintf("%s %s %s", strings.ToLower(c.Kind), name, verb))
+		}
+		stmts, err := migrate.Stmts(m.SQL)
+		if err != nil {
+			continue
+		}
+		statements += len(stmts)
+		for _, s := range stmts {
+			if isDestructive(s.Text) {
+				destructive = append(destructive, fmt.Sprintf("- `%s`: %s", m.File, markdownCode(shortStatement(s.Text))))
+			}
+		}
+	}
+	fmt.Fprintf(&b, "%d migration(s), %d statement(s), %d destructive operation(s).\n\n", len(migs), statements, len(destructive))
+	for _, t := range []struct {
+		op    byte
+		label string
+	}{{'+', "Tables created"}, {'~', "Tables altered"}, {'-', "Tables dropped"}} {
+		if len(tables[t.op]) > 0 {
+			fmt.Fprintf(&b, "- **%s:** %s\n", t.label, strings.Join(tables[t.op], ", "))
+		}
+	}
+	if len(objects) > 0 {
+		fmt.Fprintf(&b, "- **Other objects:** %s\n", strings.Join(objects, ", "))
+	}
+	if len(destructive) > 0 {
+		b.WriteString("\n### ⚠ Destructive operations\n\n" + strings.Join(destructive, "\n") + "\n")
+	}
+	b.WriteString("\n### Migrations\n")
+	for _, m := range migs {
+		stats := "unparsable SQL"
+		if stmts, err := migrate.Stmts(m.SQL); err == nil {
+			kinds := map[string]int{}
+			for _, s := range stmts {
+				kinds[statementKind(s.Text)]++
+			}
+			var parts []string
+			for _, k := range statementKinds {
+				if kinds[k] > 0 {
+					parts = append(parts, fmt.Sprintf("%d %s", kinds[k], k))
+				}
+			}
+			stats = fmt.Sprintf("%d statement(s)", len(stmts))
+			if len(parts) > 0 {
+				stats += ": " + strings.Join(parts, ", ")
+			}
+		}
+		fmt.Fprintf(&b, "\n- `%s`: %s\n\n  <details><summary>SQL</summary>\n\n  ```sql\n", m.File, stats)
+		for _, line := range strings.Split(strings.TrimRight(m.SQL, "\n"), "\n") {
+			b.WriteString(strings.TrimRight("  "+line, " ") + "\n")
+		}
+		b.WriteString("  ```\n\n  </details>\n")
+	}
+	return b.String()
+}
+
+// markdownCode renders s as inline code, with a longer fence when s contains backticks (MySQL identifiers).
+func markdownCode(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// changelogTitle names the range of a changelog, e.g. "Database migrations v1.2.0 → v1.3.0".
+func changelogTitle(from, to changelogBound) string {
+	label := func(b changelogBound, open string) string {
+		switch {
+		case b.Input == "":
+			return open
+		case b.Ref != "" && b.Version != "":
+			return fmt.Sprintf("%s (%s)", b.Ref, b.Version)
+		}
+		return b.Input
+	}
+	return fmt.Sprintf("Database migrations %s → %s", label(from, "start"), label(to, "latest"))
+}
+
+// changelogFileName is the file a changelog is written to, in the project directory.
+func changelogFileName(from, to changelogBound) string {
+	part := func(b changelogBound, open string) string {
+		if b.Input == "" {
+			return open
+		}
+		return strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r == ' ' || r == ':' {
+				return '-'
+			}
+			return r
+		}, b.Input)
+	}
+	return fmt.Sprintf("CHANGELOG-db-%s-%s.md", part(from, "start"), part(to, "latest"))
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ciEnv is an atlas.hcl env as a generated CI config runs it.
+type ciEnv struct {
+	Name   string
+	Vars   []string // environment variables the env block reads (getenv / env calls), secrets in CI
+	Docker bool     // the dev database is a docker:// URL
+}
+
+// generateOptions configures `atlas9 generate ci`.
+type generateOptions struct {
+	workDir   string
+	atlasHCL  string
+	envFlag   string // --env: the only env CI runs; also the pre-commit env
+	localEnv  string // env of the pre-commit hook when --env is not given
+	gitlab    bool   // GitLab CI instead of GitHub Actions
+	preCommit bool   // also write .pre-commit-config.yaml
+	force     bool   // overwrite existing files
+	getEnv    func(string) string
+	stdout    io.Writer
+}
+
+// generatedFile is a file written by generate ci.
+type generatedFile struct {
+	Path string // relative to the project directory
+	Text string
+}
+
+// atlas9ReleaseURL is where CI downloads the linux/amd64 atlas9 binary.
+const atlas9ReleaseURL = "https://github.com/sio2boss/atlas9/releases/latest/download/atlas9_linux_amd64.tar.gz"
+
+// ciEnvs returns the envs CI runs: the --env one, else every env of atlas.hcl whose block reads environment
+// variables (a URL CI can be given as a secret), else fallback.
+func ciEnvs(atlasHCL, envFlag, fallback string, getEnv func(string) string) []ciEnv {
+	src, _ := os.ReadFile(atlasHCL)
+	env := func(name string) ciEnv {
+		e := ciEnv{Name: name, Docker: envUsesDocker(atlasHCL, name, getEnv)}
+		for _, m := range hclGetenvRe.FindAllStringSubmatch(atlasHCLEnvBlock(string(src), name), -1) {
+			if !slices.Contains(e.Vars, m[1]) {
+				e.Vars = append(e.Vars, m[1])
+			}
+		}
+		slices.Sort(e.Vars)
+		return e
+	}
+	if envFlag != "" {
+		return []ciEnv{env(envFlag)}
+	}
+	var out []ciEnv
+	for _, name := range parseAtlasHCLEnvs(atlasHCL) {
+		if e := env(name); len(e.Vars) > 0 {
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, env(fallback))
+	}
+	return out
+}
+
+// ciVars is the union of the environment variables of envs, sorted.
+func ciVars(envs []ciEnv) []string {
+	var vars []string
+	for _, e := range envs {
+		for _, v := range e.Vars {
+			if !slices.Contains(vars, v) {
+				vars = append(vars, v)
+			}
+		}
+	}
+	slices.Sort(vars)
+	return vars
+}
+
+func ciEnvNames(envs []ciEnv) []string {
+	names := make([]string, len(envs))
+	for i, e := range envs {
+		names[i] = fmt.Sprintf("%q", e.Name)
+	}
+	return names
+}
+
+// githubWorkflow is a GitHub Actions workflow that runs atlas9 headless lint and dry-run on pull requests
+// touching dir or atlas.hcl, one job per env, with the env variables from repository secrets.
+func githubWorkflow(envs []ciEnv, dir string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by atlas9 generate ci: lint and dry-run the migrations of pull requests.\n")
+	b.WriteString("name: atlas9\n\non:\n  pull_request:\n    paths:\n")
+	fmt.Fprintf(&b, "      - %q\n      - \"atlas.hcl\"\n\n", filepath.ToSlash(dir)+"/**")
+	b.WriteString("jobs:\n  atlas9:\n    runs-on: ubuntu-latest\n    strategy:\n      fail-fast: false\n      matrix:\n")
+	fmt.Fprintf(&b, "        env: [%s]\n", strings.Join(ciEnvNames(envs), ", "))
+	if vars := ciVars(envs); len(vars) > 0 {
+		b.WriteString("    env:\n")
+		for _, v := range vars {
+			fmt.Fprintf(&b, "      %s: ${{ secrets.%s }}\n", v, v)
+		}
+	}
+	b.WriteString("    steps:\n")
+	b.WriteString("      - uses: actions/checkout@v4\n        with:\n          fetch-depth: 0\n")
+	b.WriteString("      - uses: ariga/setup-atlas@v0\n")
+	b.WriteString("      - name: Install atlas9\n        run: |\n")
+	fmt.Fprintf(&b, "          curl -sSfL %s | tar -xz -C \"$RUNNER_TEMP\" atlas9\n", atlas9ReleaseURL)
+	b.WriteString("          echo \"$RUNNER_TEMP\" >> \"$GITHUB_PATH\"\n")
+	b.WriteString("      - name: Lint and dry-run on ${{ matrix.env }}\n")
+	b.WriteString("        run: atlas9 run lint dry-run --env \"${{ matrix.env }}\" --git-base auto --github-summary\n")
+	return b.String()
+}
+
+// gitlabCI is a GitLab CI job for merge requests, the counterpart of githubWorkflow; the env variables come
+// from the project's CI/CD variables. Envs with a docker:// dev database get a Docker-in-Docker service.
+func gitlabCI(envs []ciEnv, dir string) string {
+	var b strings.Builder
+	b.WriteString("# Generated by atlas9 generate ci --gitlab: lint and dry-run the migrations of merge requests.\n")
+	b.WriteString("# Include it from .gitlab-ci.yml:\n#\n#   include:\n#     - local: .gitlab/atlas9.gitlab-ci.yml\n")
+	if vars := ciVars(envs); len(vars) > 0 {
+		fmt.Fprintf(&b, "#\n# Define these CI/CD variables (masked): %s\n", strings.Join(vars, ", "))
+	}
+	b.WriteString("\natlas9:\n  image: arigaio/atlas:latest-alpine\n  entrypoint: [\"\"]\n")
+	if slices.ContainsFunc(envs, func(e ciEnv) bool { return e.Docker }) {
+		b.WriteString("  services:\n    - docker:dind\n  variables:\n    DOCKER_HOST: tcp://docker:2375\n    DOCKER_TLS_CERTDIR: \"\"\n")
+	}
+	b.WriteString("  rules:\n    - if: $CI_PIPELINE_SOURCE == \"merge_request_event\"\n      changes:\n")
+	fmt.Fprintf(&b, "        - %q\n        - \"atlas.hcl\"\n", filepath.ToSlash(dir)+"/**/*")
+	b.WriteString("  parallel:\n    matrix:\n")
+	fmt.Fprintf(&b, "      - ATLAS9_ENV: [%s]\n", strings.Join(ciEnvNames(envs), ", "))
+	b.WriteString("  script:\n")
+	fmt.Fprintf(&b, "    - wget -qO- %s | tar -xz -C /usr/local/bin atlas9\n", atlas9ReleaseURL)
+	b.WriteString("    - git fetch origin \"$CI_MERGE_REQUEST_TARGET_BRANCH_NAME\"\n")
+	b.WriteString("    - atlas9 run lint dry-run --env \"$ATLAS9_ENV\" --git-base \"origin/$CI_MERGE_REQUEST_TARGET_BRANCH_NAME\"\n")
+	return b.String()
+}
+
+// preCommitConfig is a pre-commit configuration that re-hashes the migration directory dir and lints it on env
+// before every commit touching it; a changed atlas.sum fails the commit until it is staged.
+func preCommitConfig(env, dir string) string {
+	files := "^" + strings.ReplaceAll(filepath.ToSlash(dir), ".", `\.`) + "/"
+	var b strings.Builder
+	b.WriteString("# Generated by atlas9 generate ci --pre-commit: re-hash and lint the migration directory.\n")
+	b.WriteString("repos:\n  - repo: local\n    hooks:\n")
+	for _, h := range []struct{ id, name, entry string }{
+		{"atlas-migrate-hash", "atlas migrate hash", "atlas migrate hash --env " + env},
+		{"atlas9-lint", "atlas9 lint", "atlas9 run lint --env " + env},
+	} {
+		fmt.Fprintf(&b, "      - id: %s\n        name: %s\n        entry: %s\n", h.id, h.name, h.entry)
+		fmt.Fprintf(&b, "        language: system\n        files: %q\n        pass_filenames: false\n", files)
+	}
+	return b.String()
+}
+
+// generateCIFiles returns the files generate ci writes for o, running CI on envs.
+func generateCIFiles(o generateOptions, envs []ciEnv) []generatedFile {
+	dir := parseAtlasHCLMigrationDir(o.atlasHCL, envs[0].Name)
+	var files []generatedFile
+	if o.gitlab {
+		files = append(files, generatedFile{".gitlab/atlas9.gitlab-ci.yml", gitlabCI(envs, dir)})
+	} else {
+		files = append(files, generatedFile{".github/workflows/atlas9.yml", githubWorkflow(envs, dir)})
+	}
+	if o.preCommit {
+		env := o.envFlag
+		if env == "" {
+			env = o.localEnv
+		}
+		files = append(files, generatedFile{".pre-commit-config.yaml", preCommitConfig(env, parseAtlasHCLMigrationDir(o.atlasHCL, env))})
+	}
+	return files
+}
+
+// runGenerateCI writes the CI config (and pre-commit config) for the project and returns the exit code.
+// Existing files are kept unless o.force.
+func runGenerateCI(o generateOptions) int {
+	if _, err := os.Stat(o.atlasHCL); err != nil {
+		fmt.Fprintf(os.Stderr, "generate ci: %v\n", err)
+		return 1
+	}
+	envs := ciEnvs(o.atlasHCL, o.envFlag, o.localEnv, o.getEnv)
+	files := generateCIFiles(o, envs)
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(o.workDir, f.Path)); err == nil && !o.force {
+			fmt.Fprintf(os.Stderr, "generate ci: %s exists (--force overwrites it)\n", f.Path)
+			return 1
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "generate ci: %v\n", err)
+			return 1
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(o.workDir, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "generate ci: %v\n", err)
+			return 1
+		}
+		if err := os.WriteFile(path, []byte(f.Text), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "generate ci: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(o.stdout, "wrote %s\n", f.Path)
+	}
+	names := make([]string, len(envs))
+	for i, e := range envs {
+		names[i] = e.Name
+	}
+	fmt.Fprintf(o.stdout, "CI runs lint and dry-run on: %s\n", strings.Join(names, ", "))
+	if vars := ciVars(envs); len(vars) > 0 {
+		where := "repository secrets"
+		if o.gitlab {
+			where = "CI/CD variables"
+		}
+		fmt.Fprintf(o.stdout, "Add these %s: %s\n", where, strings.Join(vars, ", "))
+	}
+	if o.preCommit {
+		fmt.Fprintln(o.stdout, "Install the hooks with: pre-commit install")
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// clipboardCommands are tried in order; the first one found on PATH receives the text on stdin.
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// clipboardWaitDelay bounds how long a clipboard command's stderr is read after it exits: xclip and wl-copy
+// fork a process that keeps serving the selection, with stderr still open, until another app takes it.
+const clipboardWaitDelay = 250 * time.Millisecond
+
+// errNoClipboardCommand is returned by runClipboardCommand when none of the clipboard commands is on PATH; callers
+// then fall back to the terminal's OSC 52 clipboard support (works over SSH in most modern terminals).
+var errNoClipboardCommand = errors.New("no clipboard command on PATH")
+
+// runClipboardCommand copies text with the platform clipboard tool. It returns the last tool's error when every
+// tool found failed, or errNoClipboardCommand. It waits for the tool, so call it off the UI goroutine.
+func runClipboardCommand(text string) error {
+	cmds := clipboardCommands
+	if runtime.GOOS == "windows" {
+		cmds = [][]string{{"clip"}}
+	}
+	err := errNoClipboardCommand
+	for _, c := range cmds {
+		if _, lookErr := exec.LookPath(c[0]); lookErr != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		cmd.WaitDelay = clipboardWaitDelay
+		if err = cmd.Run(); err == nil || errors.Is(err, exec.ErrWaitDelay) {
+			return nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%s: %w: %s", c[0], err, msg)
+		} else {
+			err = fmt.Errorf("%s: %w", c[0], err)
+		}
+	}
+	return err
+}
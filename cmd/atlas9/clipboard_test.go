@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCopyToClipboard(t *testing.T) {
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	if err := runClipboardCommand("x"); !errors.Is(err, errNoClipboardCommand) {
+		t.Errorf("no tool: got %v, want errNoClipboardCommand", err)
+	}
+	if err := os.WriteFile(filepath.Join(bin, "xclip"), []byte("#!/bin/sh\necho 'Error: cannot open display' >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := runClipboardCommand("x"); err == nil || !strings.Contains(err.Error(), "open display") {
+		t.Errorf("failing tool: got %v, want its stderr", err)
+	}
+	out := filepath.Join(bin, "copied")
+	if err := os.WriteFile(filepath.Join(bin, "xsel"), []byte("#!/bin/sh\nIFS= read -r line\nprintf %s \"$line\" > "+out+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := runClipboardCommand("select"); err != nil {
+		t.Fatalf("working tool after a failing one: %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "select" {
+		t.Errorf("copied %q, want %q", data, "select")
+	}
+}
+
+func TestCopyToClipboardForkingTool(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not installed")
+	}
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	// Like xclip: a background child keeps serving the selection, with stderr open, after the tool exits.
+	script := "#!/bin/sh\n" + sleep + " 10 &\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(bin, "xclip"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := runClipboardCommand("x"); err != nil {
+		t.Fatalf("forking tool: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("runClipboardCommand waited %v for the forked child", d)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// atlasHelp is what `atlas <command> --help` lists: the command's subcommands and its flags, local and global
+// (long and short names → whether the flag takes a value).
+type atlasHelp struct {
+	Commands []string
+	Flags    map[string]bool
+}
+
+var (
+	helpCommandRe = regexp.MustCompile(`^  ([\w-]+)\s{2,}`)
+	// helpFlagRe matches a flag line, e.g. "  -u, --url string   select a resource" or "      --allow-dirty   allow".
+	helpFlagRe = regexp.MustCompile(`^\s+(?:(-\w), )?(--[\w-]+)(?: (\S+))?(?:\s{2,}|$)`)
+)
+
+// parseAtlasHelp reads the "Available Commands:", "Flags:" and "Global Flags:" sections of atlas's help output.
+func parseAtlasHelp(out string) atlasHelp {
+	h := atlasHelp{Flags: map[string]bool{}}
+	section := ""
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasSuffix(line, ":") && !strings.HasPrefix(line, " "):
+			section = line
+		case section == "Available Commands:":
+			if m := helpCommandRe.FindStringSubmatch(line); m != nil {
+				h.Commands = append(h.Commands, m[1])
+			}
+		case section == "Flags:" || section == "Global Flags:":
+			if m := helpFlagRe.FindStringSubmatch(line); m != nil {
+				h.Flags[m[2]] = m[3] != ""
+				if m[1] != "" {
+					h.Flags[m[1]] = m[3] != ""
+				}
+			}
+		}
+	}
+	return h
+}
+
+// checkCommand returns what is wrong with args, an edited command after "atlas", before it runs: a command
+// atlas does not have, flags the command does not take, a flag missing its value, an --env with no env block
+// in atlas.hcl (envs). help returns a command's help by its path (nil: atlas itself); ok false when atlas
+// cannot tell, which leaves commands and flags unchecked. version is the detected atlas version, for messages.
+func checkCommand(args []string, help func(path []string) (atlasHelp, bool), envs []string, version string) []string {
+	var problems []string
+	var path []string
+	h, known := help(nil)
+	i := 0
+	for ; known && len(h.Commands) > 0; i++ {
+		if i == len(args) || strings.HasPrefix(args[i], "-") {
+			return append(problems, fmt.Sprintf("%s needs a command: %s", cmdString(path...), strings.Join(h.Commands, ", ")))
+		}
+		if !slices.Contains(h.Commands, args[i]) {
+			return append(problems, fmt.Sprintf("%s has no command %q (it has %s)", cmdString(path...), args[i], strings.Join(h.Commands, ", ")))
+		}
+		path = append(path, args[i])
+		h, known = help(path)
+	}
+	atlas := "atlas"
+	if version != "" {
+		atlas += " " + version
+	}
+	for ; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		if !strings.HasPrefix(name, "-") || name == "-" {
+			continue
+		}
+		if name == "--" {
+			break
+		}
+		takes, ok := h.Flags[name]
+		switch {
+		case !known:
+			takes = name == "--env"
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s does not take %s (%s)", cmdString(path...), name, atlas))
+			continue
+		}
+		if takes && !hasValue {
+			if i+1 == len(args) || strings.HasPrefix(args[i+1], "--") {
+				problems = append(problems, name+" needs a value")
+				continue
+			}
+			i++
+			value = args[i]
+		}
+		if name == "--env" && len(envs) > 0 && !slices.Contains(envs, value) {
+			problems = append(problems, fmt.Sprintf("atlas.hcl has no env %q (it has %s)", value, strings.Join(envs, ", ")))
+		}
+	}
+	return problems
+}
+
+// applyLikeCommands are the atlas commands that change the database unless run with --dry-run.
+var applyLikeCommands = [][2]string{{"migrate", "apply"}, {"migrate", "down"}, {"migrate", "set"}, {"schema", "apply"}, {"schema", "clean"}}
+
+// applyLike reports whether args, an edited command after "atlas", change the database (see applyLikeCommands).
+func applyLike(args []string) bool {
+	if len(args) < 2 || dryRun(args) {
+		return false
+	}
+	return slices.Contains(applyLikeCommands, [2]string{args[0], args[1]})
+}
+
+// dryRun reports whether args ask for a dry run: --dry-run, or --dry-run= with a true value as atlas parses it.
+// --dry-run=false, or a value atlas rejects, is no dry run.
+func dryRun(args []string) bool {
+	for _, a := range args {
+		if a == "--dry-run" {
+			return true
+		}
+		if v, ok := strings.CutPrefix(a, "--dry-run="); ok {
+			b, err := strconv.ParseBool(v)
+			return err == nil && b
+		}
+	}
+	return false
+}
+
+// targetEnv is the env an edited command changes: its --env, else the env of urls (env → url) whose database
+// its --url (or -u) is, else "".
+func targetEnv(args []string, urls map[string]string) string {
+	if env := flagValue(args, "--env"); env != "" {
+		return env
+	}
+	u := flagValue(args, "--url")
+	if u == "" {
+		u = flagValue(args, "-u")
+	}
+	for _, env := range slices.Sorted(maps.Keys(urls)) {
+		if sameDatabase(u, urls[env]) {
+			return env
+		}
+	}
+	return ""
+}
+
+// applyStageRun reads an edited `migrate apply` into the Apply stage run it amounts to, so it goes through the
+// same confirmation: ok when it is one on an --env, with no --url or --dry-run of its own.
+func applyStageRun(args []string) (r stageRun, ok bool) {
+	if len(args) < 2 || args[0] != "migrate" || args[1] != "apply" {
+		return r, false
+	}
+	r.Stage = 4
+	for i := 2; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch name {
+		case "--env", "--tx-mode":
+			if !hasValue {
+				if i+1 == len(args) {
+					return r, false
+				}
+				i++
+				value = args[i]
+			}
+			if name == "--env" {
+				r.Env = value
+			} else {
+				r.TxMode = value
+			}
+		case "--url", "-u", "--dry-run":
+			return r, false
+		default:
+			r.Flags = append(r.Flags, args[i])
+		}
+	}
+	return r, r.Env != ""
+}
+
+// flagValue returns the value of flag name in args ("--env x" or "--env=x"), or "" when it is not set.
+func flagValue(args []string, name string) string {
+	for i, a := range args {
+		if v, ok := strings.CutPrefix(a, name+"="); ok {
+			return v
+		}
+		if a == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// commandWords returns the words of a command line as typed, quotes included (see splitShellWords).
+func commandWords(cmd string) ([]string, error) {
+	words, err := splitShellWords(cmd)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]string, len(words))
+	for i, w := range words {
+		raw[i] = w.Raw
+	}
+	return raw, nil
+}
+
+// splitCommandLines lays a command line out for the multi-line editor: each flag with its value on its own
+// line, continued with a trailing backslash as in a shell script. A command that does not parse (an
+// unterminated quote) is left on one line, as typed.
+func splitCommandLines(cmd string) string {
+	words, err := commandWords(cmd)
+	if err != nil {
+		return cmd
+	}
+	var lines []string
+	for _, f := range words {
+		if len(lines) == 0 || strings.HasPrefix(f, "-") {
+			lines = append(lines, f)
+		} else {
+			lines[len(lines)-1] += " " + f
+		}
+	}
+	return strings.Join(lines, " \\\n  ")
+}
+
+// joinCommandLines turns the multi-line editor's text back into one command line: backslash continuations
+// and line breaks become single spaces, quoted words are kept as typed. Text that does not parse keeps its
+// spacing within lines, so the check before running reports the unterminated quote rather than a value split
+// apart.
+func joinCommandLines(text string) string {
+	words, err := commandWords(strings.ReplaceAll(text, "\\\n", " "))
+	if err != nil {
+		lines := strings.Split(text, "\n")
+		for i, l := range lines {
+			lines[i] = strings.TrimSpace(strings.TrimSuffix(l, "\\"))
+		}
+		return strings.Join(slices.DeleteFunc(lines, func(l string) bool { return l == "" }), " ")
+	}
+	return strings.Join(words, " ")
+}
+
+// pastedCommand cleans up a command pasted into the command line, e.g. from a runbook: a leading "$ " prompt
+// is dropped and continuation lines are joined.
+func pastedCommand(text string) string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	text = strings.TrimPrefix(text, "$ ")
+	return joinCommandLines(text)
+}
+
+// commandLine is the main screen's command input; pastes (bracketed paste) go through pastedCommand so a
+// multi-line command lands as one line. Editing keys are tview's, which already follow readline (Ctrl+A/E/W,
+// Alt+B/F, Ctrl+K) except Ctrl+U: readline deletes only up to the cursor, tview the whole line.
+type commandLine struct {
+	*tview.InputField
+}
+
+func (c commandLine) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	h := c.InputField.InputHandler()
+	return func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Key() == tcell.KeyCtrlU {
+			h(tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModShift), setFocus) // select back to the start
+			h(tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone), setFocus)
+			return
+		}
+		h(event, setFocus)
+	}
+}
+
+func (c commandLine) PasteHandler() func(pastedText string, setFocus func(p tview.Primitive)) {
+	h := c.InputField.PasteHandler()
+	return func(pastedText string, setFocus func(p tview.Primitive)) {
+		h(pastedCommand(pastedText), setFocus)
+	}
+}
+
+// colorizeCommand returns text with tview color tags: flags yellow, the --env value in its env color (envColor
+// returns a tview color name), other flag values plain and the atlas binary bold. Line breaks are kept.
+func colorizeCommand(text string, envColor func(env string) string) string {
+	var b strings.Builder
+	prev := ""
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		rest := line
+		for rest != "" {
+			trimmed := strings.TrimLeft(rest, " \t")
+			b.WriteString(rest[:len(rest)-len(trimmed)])
+			if trimmed == "" {
+				break
+			}
+			end := strings.IndexAny(trimmed, " \t")
+			if end < 0 {
+				end = len(trimmed)
+			}
+			tok := trimmed[:end]
+			rest = trimmed[end:]
+			esc := tview.Escape(tok)
+			switch {
+			case tok == "\\":
+				b.WriteString("[gray]" + esc + "[-]")
+			case tok == "atlas" && prev == "":
+				b.WriteString("[::b]" + esc + "[::-]")
+			case strings.HasPrefix(tok, "-"):
+				name, value, hasValue := strings.Cut(tok, "=")
+				b.WriteString("[yellow]" + tview.Escape(name) + "[-]")
+				if hasValue {
+					b.WriteString("=")
+					if name == "--env" {
+						b.WriteString("[" + envColor(value) + "::b]" + tview.Escape(value) + "[-::-]")
+					} else {
+						b.WriteString(tview.Escape(value))
+					}
+				}
+			case prev == "--env":
+				b.WriteString("[" + envColor(tok) + "::b]" + esc + "[-::-]")
+			default:
+				b.WriteString(esc)
+			}
+			if tok != "\\" {
+				prev = tok
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// envComparison is the applied migrations of two envs side by side (compare action).
+type envComparison struct {
+	A, B    string       // env names
+	Rows    []compareRow // every version applied on either env or present as a file, oldest first
+	Applied [2]int       // versions applied on A and B
+	Latest  [2]string    // latest applied version of A and B, "" if none
+}
+
+// compareRow is one version of an envComparison.
+type compareRow struct {
+	Version string
+	File    string // "" when only a revision table knows the version
+	InA     bool
+	InB     bool
+}
+
+// compareApplied lines up the versions applied on env a and env b (from `migrate status`) with the migration
+// files (version to file name, see migrationFilesByVersion).
+func compareApplied(a, b string, files map[string]string, appliedA, appliedB []string) envComparison {
+	c := envComparison{A: a, B: b}
+	inA, inB := make(map[string]bool), make(map[string]bool)
+	for _, v := range appliedA {
+		inA[v] = true
+	}
+	for _, v := range appliedB {
+		inB[v] = true
+	}
+	versions := make(map[string]bool)
+	for _, m := range []map[string]bool{inA, inB} {
+		for v := range m {
+			versions[v] = true
+		}
+	}
+	for v := range files {
+		versions[v] = true
+	}
+	for _, v := range slices.Sorted(maps.Keys(versions)) {
+		r := compareRow{Version: v, File: files[v], InA: inA[v], InB: inB[v]}
+		c.Rows = append(c.Rows, r)
+		if r.InA {
+			c.Applied[0]++
+			c.Latest[0] = v
+		}
+		if r.InB {
+			c.Applied[1]++
+			c.Latest[1] = v
+		}
+	}
+	return c
+}
+
+// only counts the versions applied on A but not B (ab true) or on B but not A.
+func (c envComparison) only(ab bool) int {
+	n := 0
+	for _, r := range c.Rows {
+		if r.InA && !r.InB && ab || r.InB && !r.InA && !ab {
+			n++
+		}
+	}
+	return n
+}
+
+// verdict answers "is A ahead of B?" in one line (tview tags).
+func (c envComparison) verdict() string {
+	onlyA, onlyB := c.only(true), c.only(false)
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 version"
+		}
+		return fmt.Sprintf("%d versions", n)
+	}
+	a, b := tview.Escape(c.A), tview.Escape(c.B)
+	switch {
+	case onlyA > 0 && onlyB > 0:
+		return fmt.Sprintf("[red]%s and %s diverged: %s applied only on %s, %s only on %s[-]", a, b, plural(onlyA), a, plural(onlyB), b)
+	case onlyA > 0:
+		return fmt.Sprintf("[yellow]%s is %s ahead of %s[-]", a, plural(onlyA), b)
+	case onlyB > 0:
+		return fmt.Sprintf("[yellow]%s is %s ahead of %s[-]", b, plural(onlyB), a)
+	}
+	return fmt.Sprintf("[green]%s and %s have the same %s applied[-]", a, b, plural(c.Applied[0]))
+}
+
+// render formats c for the Compare tab: the verdict, each env's applied count and latest version, then the
+// versions applied on only one of them and the count of those applied on both or neither.
+func (c envComparison) render() string {
+	var b strings.Builder
+	width := max(len(c.A), len(c.B), len("applied"))
+	fmt.Fprintf(&b, "%s\n\n", c.verdict())
+	for i, env := range []string{c.A, c.B} {
+		latest := c.Latest[i]
+		if latest == "" {
+			latest = "none"
+		}
+		fmt.Fprintf(&b, "  %-*s  %d applied, latest %s\n", width, tview.Escape(env), c.Applied[i], latest)
+	}
+	var both, neither int
+	var diff []compareRow
+	for _, r := range c.Rows {
+		switch {
+		case r.InA && r.InB:
+			both++
+		case !r.InA && !r.InB:
+			neither++
+		default:
+			diff = append(diff, r)
+		}
+	}
+	if len(diff) > 0 {
+		fmt.Fprintf(&b, "\n  [::b]%-16s %-*s %-*s file[::-]\n", "version", width, tview.Escape(c.A), width, tview.Escape(c.B))
+		state := func(applied bool) string {
+			if applied {
+				return fmt.Sprintf("[green]%-*s[-]", width, "applied")
+			}
+			return fmt.Sprintf("[gray]%-*s[-]", width, "pending")
+		}
+		for _, r := range diff {
+			file := tview.Escape(r.File)
+			if r.File == "" {
+				file = "[yellow]no file in the migration directory[-]"
+			}
+			fmt.Fprintf(&b, "  %-16s %s %s %s\n", r.Version, state(r.InA), state(r.InB), file)
+		}
+	}
+	fmt.Fprintf(&b, "\n[gray]%d applied on both, %d pending on both.[-]\n", both, neither)
+	return b.String()
+}
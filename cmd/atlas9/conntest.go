@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// connReport is the result of testConnection: how far a connection to an env's database got and how long each
+// step took.
+type connReport struct {
+	Env     string
+	Driver  string
+	Host    string   // host:port
+	Addrs   []string // resolved addresses
+	Resolve time.Duration
+	Connect time.Duration // TCP connect
+	Auth    time.Duration // driver connect: TLS, authentication and first round trip
+	Version string        // server version
+	TLS     string        // e.g. "TLSv1.3"; "" when the connection is not encrypted
+	Step    string        // step that failed: "url", "resolve", "connect" or "auth"
+	Err     error
+}
+
+// OK reports whether the database was reached and authenticated.
+func (r connReport) OK() bool { return r.Err == nil }
+
+// defaultPorts are the ports used when an env URL has none.
+var defaultPorts = map[string]string{"postgres": "5432", "mysql": "3306"}
+
+// testConnection connects to the database at rawURL step by step (DNS, TCP, driver) and reads the server
+// version and TLS state. Postgres and MySQL are supported, and SQLite files (relative to workDir) are checked.
+func testConnection(ctx context.Context, env, rawURL, workDir string) connReport {
+	r := connReport{Env: env, Driver: dbDriver(rawURL)}
+	fail := func(step string, err error) connReport {
+		r.Step, r.Err = step, err
+		return r
+	}
+	if err := validateDBURL(rawURL); err != nil {
+		return fail("url", err)
+	}
+	if r.Driver == "sqlite" {
+		return testSQLiteFile(r, rawURL, workDir)
+	}
+	port, ok := defaultPorts[r.Driver]
+	if !ok {
+		return fail("url", fmt.Errorf("connection tests are not supported for %q", r.Driver))
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fail("url", err)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	if host := u.Hostname(); host != "" {
+		r.Host = net.JoinHostPort(host, port)
+		start := time.Now()
+		r.Addrs, err = net.DefaultResolver.LookupHost(ctx, host)
+		r.Resolve = time.Since(start)
+		if err != nil {
+			return fail("resolve", err)
+		}
+		start = time.Now()
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(r.Addrs[0], port))
+		r.Connect = time.Since(start)
+		if err != nil {
+			return fail("connect", err)
+		}
+		conn.Close()
+	}
+	start := time.Now()
+	db, _, err := openTarget(ctx, rawURL)
+	r.Auth = time.Since(start)
+	if err != nil {
+		return fail("auth", err)
+	}
+	defer db.Close()
+	r.Version, r.TLS = serverInfo(ctx, db, r.Driver)
+	return r
+}
+
+// testSQLiteFile checks the database file of a sqlite:// URL, the only thing there is to reach. A file that
+// does not exist yet is fine: atlas creates it on the first apply.
+func testSQLiteFile(r connReport, rawURL, workDir string) connReport {
+	path, err := sqlitePath(rawURL, workDir)
+	if err != nil {
+		r.Version = "SQLite, in memory"
+		return r
+	}
+	r.Host = path
+	start := time.Now()
+	info, err := os.Stat(path)
+	r.Connect = time.Since(start)
+	switch {
+	case os.IsNotExist(err):
+		r.Version = "SQLite, file not created yet"
+	case err != nil:
+		r.Step, r.Err = "connect", err
+	case info.IsDir():
+		r.Step, r.Err = "connect", fmt.Errorf("%s is a directory", path)
+	default:
+		r.Version = "SQLite, " + formatBytes(info.Size())
+	}
+	return r
+}
+
+// serverInfo reads the server version and the TLS version of the session ("" if unencrypted); best effort.
+func serverInfo(ctx context.Context, db *sql.DB, driver string) (version, tls string) {
+	switch driver {
+	case "postgres":
+		db.QueryRowContext(ctx, `SHOW server_version`).Scan(&version)
+		var ssl sql.NullBool
+		var v sql.NullString
+		if db.QueryRowContext(ctx, `SELECT ssl, version FROM pg_stat_ssl WHERE pid = pg_backend_pid()`).Scan(&ssl, &v) == nil && ssl.Bool {
+			tls = v.String
+		}
+	case "mysql":
+		db.QueryRowContext(ctx, `SELECT VERSION()`).Scan(&version)
+		var name string
+		db.QueryRowContext(ctx, `SHOW SESSION STATUS LIKE 'Ssl_version'`).Scan(&name, &tls)
+	}
+	return version, tls
+}
+
+// connLock marks an encrypted connection in the top panel: " 🔒" (" tls" in asciiMode), "" if not encrypted.
+func connLock(r connReport) string {
+	switch {
+	case !r.OK() || r.TLS == "":
+		return ""
+	case asciiMode:
+		return " tls"
+	}
+	return " 🔒"
+}
+
+// unreachable reports whether r is a failed attempt to reach the database, not a URL the test could not use.
+func (r connReport) unreachable() bool { return r.Err != nil && r.Step != "url" }
+
+// renderConnReport formats r for the Connection tab.
+func renderConnReport(r connReport) string {
+	var b strings.Builder
+	mark := "[green]✓[-]"
+	if !r.OK() {
+		mark = "[red]✗[-]"
+	}
+	fmt.Fprintf(&b, "%s [::b]%s[::-] (%s)\n", mark, r.Env, r.Driver)
+	row := func(label, value string) { fmt.Fprintf(&b, "    %-9s %s\n", label, value) }
+	switch {
+	case r.Host != "" && r.Driver == "sqlite":
+		row("file", tview.Escape(r.Host))
+	case r.Host != "":
+		row("host", r.Host)
+	}
+	if r.Resolve > 0 || r.Step == "resolve" {
+		row("resolve", fmt.Sprintf("%s  %s", r.Resolve.Round(time.Microsecond), strings.Join(r.Addrs, ", ")))
+	}
+	if r.Connect > 0 || r.Step == "connect" {
+		row("connect", r.Connect.Round(time.Microsecond).String())
+	}
+	if r.Auth > 0 || r.Step == "auth" {
+		row("auth", r.Auth.Round(time.Microsecond).String())
+	}
+	if r.Err != nil {
+		row("error", fmt.Sprintf("[red]%s failed: %s[-]", r.Step, tview.Escape(r.Err.Error())))
+		return b.String()
+	}
+	row("server", tview.Escape(r.Version))
+	switch {
+	case r.Driver == "sqlite":
+	case r.TLS != "":
+		row("tls", "[green]"+r.TLS+"[-]")
+	default:
+		row("tls", "[yellow]not encrypted[-]")
+	}
+	return b.String()
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+	"github.com/rivo/tview"
+)
+
+// diffChange is one schema object a migration creates, alters (or renames) or drops.
+type diffChange struct {
+	Op     byte   // '+' create, '~' alter, '-' drop
+	Kind   string // TABLE, INDEX, TYPE, MATERIALIZED VIEW, ...
+	Name   string
+	Detail string // e.g. "ENGINE=InnoDB", "enum", "→ accounts"
+}
+
+// identPattern matches a possibly schema-qualified identifier with the quoting driver accepts: "x" on
+// Postgres, `x` on MySQL, both on ClickHouse, [x] and "x" on SQL Server and all three on SQLite.
+func identPattern(driver string) string {
+	var quoted []string
+	switch driver {
+	case "postgres", "redshift":
+		quoted = []string{`"(?:[^"]|"")*"`}
+	case "mysql":
+		quoted = []string{"`(?:[^`]|``)*`"}
+	case "clickhouse":
+		quoted = []string{"`(?:[^`]|``)*`", `"(?:[^"]|"")*"`}
+	case "sqlserver":
+		quoted = []string{`\[(?:[^\]]|\]\])*\]`, `"(?:[^"]|"")*"`}
+	default:
+		quoted = []string{`"(?:[^"]|"")*"`, "`(?:[^`]|``)*`", `\[[^\]]*\]`}
+	}
+	part := `(?:` + strings.Join(quoted, "|") + `|[^\s"` + "`" + `\[(;.,]+)`
+	return part + `(?:\s*\.\s*` + part + `)*`
+}
+
+// diffPatterns are the statement patterns of one driver, see newDiffPatterns.
+type diffPatterns struct {
+	object, on, renameTo, renameTable, engine, cluster, typeAs, typeAlter *regexp.Regexp
+}
+
+// newDiffPatterns compiles the patterns parseDiffSummary uses for driver.
+func newDiffPatterns(driver string) diffPatterns {
+	id := identPattern(driver)
+	return diffPatterns{
+		object: regexp.MustCompile(`(?is)^(CREATE|ALTER|DROP)\s+(?:OR\s+REPLACE\s+)?(?:(?:UNIQUE|TEMPORARY|TEMP|FULLTEXT|SPATIAL)\s+)*` +
+			`(MATERIALIZED\s+VIEW|TABLE|VIEW|INDEX|TYPE|SCHEMA|DATABASE|DICTIONARY|EXTENSION|SEQUENCE|DOMAIN|FUNCTION|PROCEDURE|TRIGGER)\s+` +
+			`(?:CONCURRENTLY\s+)?(?:IF\s+(?:NOT\s+)?EXISTS\s+)?(?:ONLY\s+)?(` + id + `)`),
+		on:          regexp.MustCompile(`(?is)\bON\s+(?:ONLY\s+)?(` + id + `)`),
+		renameTo:    regexp.MustCompile(`(?is)\bRENAME\s+TO\s+(` + id + `)`),
+		renameTable: regexp.MustCompile(`(?is)^RENAME\s+TABLE\s+(` + id + `)\s+TO\s+(` + id + `)`),
+		engine:      regexp.MustCompile(`(?is)\bENGINE\s*=?\s*(\w+)`),
+		cluster:     regexp.MustCompile(`(?is)\bON\s+CLUSTER\s+(` + id + `)`),
+		typeAs:      regexp.MustCompile(`(?is)\bAS\s+(ENUM|RANGE|\()`),
+		typeAlter:   regexp.MustCompile(`(?is)\b(ADD|RENAME)\s+VALUE\s+(?:IF\s+NOT\s+EXISTS\s+)?('(?:[^']|'')*')`),
+	}
+}
+
+// diffChanges lists the objects the statements in sql create, alter or drop, reading identifiers and
+// driver-specific statements (CREATE TYPE, ENGINE, ON CLUSTER, RENAME TABLE) the way driver writes them.
+func diffChanges(driver, sql string) []diffChange {
+	var texts []string
+	if stmts, err := migrate.Stmts(sql); err == nil {
+		for _, s := range stmts {
+			texts = append(texts, s.Text)
+		}
+	} else {
+		texts = strings.Split(sql, ";")
+	}
+	p := newDiffPatterns(driver)
+	var changes []diffChange
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if m := p.renameTable.FindStringSubmatch(text); m != nil {
+			changes = append(changes, diffChange{Op: '~', Kind: "TABLE", Name: unquoteIdent(m[1]), Detail: "→ " + unquoteIdent(m[2])})
+			continue
+		}
+		m := p.object.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		c := diffChange{Op: map[string]byte{"CREATE": '+', "ALTER": '~', "DROP": '-'}[strings.ToUpper(m[1])],
+			Kind: strings.Join(strings.Fields(strings.ToUpper(m[2])), " "), Name: unquoteIdent(m[3])}
+		rest := text[len(m[0]):]
+		var details []string
+		switch c.Kind {
+		case "INDEX":
+			if on := p.on.FindStringSubmatch(rest); on != nil && !strings.EqualFold(on[1], "CLUSTER") {
+				details = append(details, "on "+unquoteIdent(on[1]))
+			}
+		case "TYPE":
+			if as := p.typeAs.FindStringSubmatch(rest); as != nil && c.Op == '+' {
+				details = append(details, map[string]string{"ENUM": "enum", "RANGE": "range", "(": "composite"}[strings.ToUpper(as[1])])
+			}
+			if v := p.typeAlter.FindStringSubmatch(rest); v != nil {
+				details = append(details, strings.ToLower(v[1])+" value "+v[2])
+			}
+		}
+		if to := p.renameTo.FindStringSubmatch(rest); to != nil && c.Op == '~' {
+			details = append(details, "→ "+unquoteIdent(to[1]))
+		}
+		if driver == "mysql" || driver == "clickhouse" {
+			if e := p.engine.FindStringSubmatch(rest); e != nil && c.Op == '+' {
+				details = append(details, "ENGINE="+e[1])
+			}
+		}
+		if driver == "clickhouse" {
+			if cl := p.cluster.FindStringSubmatch(rest); cl != nil {
+				details = append(details, "cluster "+unquoteIdent(cl[1]))
+			}
+		}
+		c.Detail = strings.Join(details, ", ")
+		changes = append(changes, c)
+	}
+	if driver == "sqlite" {
+		changes = collapseSQLiteRecreate(changes)
+	}
+	return changes
+}
+
+// collapseSQLiteRecreate turns the table copy SQLite needs for most ALTERs (CREATE TABLE new_t, DROP TABLE
+// t, ALTER TABLE new_t RENAME TO t) into one alter of t.
+func collapseSQLiteRecreate(changes []diffChange) []diffChange {
+	recreated := make(map[string]bool)
+	for _, c := range changes {
+		if c.Op == '~' && c.Kind == "TABLE" && strings.HasPrefix(c.Name, "new_") && c.Detail == "→ "+strings.TrimPrefix(c.Name, "new_") {
+			recreated[strings.TrimPrefix(c.Name, "new_")] = true
+		}
+	}
+	var out []diffChange
+	for _, c := range changes {
+		switch {
+		case c.Kind != "TABLE":
+		case recreated[strings.TrimPrefix(c.Name, "new_")] && strings.HasPrefix(c.Name, "new_"):
+			if c.Op == '+' {
+				out = append(out, diffChange{Op: '~', Kind: "TABLE", Name: strings.TrimPrefix(c.Name, "new_"), Detail: "recreated"})
+			}
+			continue
+		case recreated[c.Name] && c.Op == '-':
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// parseDiffSummary returns a git-like summary of the objects the migration SQL changes, e.g.
+// "+++ users  (CREATE TABLE, ENGINE=InnoDB)", "~~~ posts  (ALTER TABLE)" or "--- old  (DROP TABLE)".
+// driver (see envDriver) decides how identifiers are quoted and which dialect statements are known.
+func parseDiffSummary(driver, sql string) string {
+	var creates, alters, drops []string
+	seen := make(map[string]bool)
+	for _, c := range diffChanges(driver, sql) {
+		label := c.Kind
+		if c.Detail != "" {
+			label += ", " + c.Detail
+		}
+		name := tview.Escape(c.Name)
+		switch c.Op {
+		case '+':
+			creates = append(creates, fmt.Sprintf("[green]+++ %s[-]  (CREATE %s)", name, tview.Escape(label)))
+		case '-':
+			drops = append(drops, fmt.Sprintf("[red]--- %s[-]  (DROP %s)", name, tview.Escape(label)))
+		default:
+			if key := c.Kind + "\x00" + c.Name + "\x00" + c.Detail; !seen[key] {
+				seen[key] = true
+				alters = append(alters, fmt.Sprintf("[yellow]~~~ %s[-]  (ALTER %s)", name, tview.Escape(label)))
+			}
+		}
+	}
+	lines := append(append(creates, alters...), drops...)
+	if len(lines) == 0 {
+		return "[green]No schema changes detected.[-]"
+	}
+	return strings.Join(lines, "\n")
+}
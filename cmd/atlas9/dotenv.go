@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// envTemplateNames are the .env templates looked for in the project directory, first found wins.
+var envTemplateNames = []string{".env.example", ".env.template", ".env.sample"}
+
+// envTemplateKey is a variable listed in the .env template.
+type envTemplateKey struct {
+	Name     string
+	Optional bool // marked with an "optional" comment on its line or the line before
+}
+
+// parseEnvTemplate reads the keys of a .env template. A key is optional when its line ends in a comment
+// containing "optional" (KEY= # optional) or the comment line right before it does.
+func parseEnvTemplate(path string) ([]envTemplateKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var keys []envTemplateKey
+	prevOptional := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") {
+			prevOptional = strings.Contains(strings.ToLower(line), "optional")
+			continue
+		}
+		eq := strings.Index(line, "=")
+		if eq <= 0 {
+			prevOptional = false
+			continue
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(line[:eq], "export "))
+		_, comment, _ := strings.Cut(line[eq+1:], "#")
+		keys = append(keys, envTemplateKey{Name: name, Optional: prevOptional || strings.Contains(strings.ToLower(comment), "optional")})
+		prevOptional = false
+	}
+	return keys, s.Err()
+}
+
+// dotenvReport compares the .env overlay with the project's .env template.
+type dotenvReport struct {
+	Template string   // template file name; "" when the project has none
+	Missing  []string // required keys set neither in .env nor in the process environment
+	Empty    []string // required keys set, but empty
+	Extra    []string // keys of .env the template does not list (typos, leftovers)
+	OK       int      // template keys with a value
+	Err      error    // the template could not be read
+}
+
+// Problems counts the findings worth a warning: missing and empty required keys.
+func (r dotenvReport) Problems() int { return len(r.Missing) + len(r.Empty) }
+
+// checkDotenv compares overlay (the parsed .env) with the first template of envTemplateNames in workDir;
+// lookup reads the process environment, which satisfies keys .env leaves out.
+func checkDotenv(workDir string, overlay map[string]string, lookup func(string) (string, bool)) dotenvReport {
+	var r dotenvReport
+	for _, name := range envTemplateNames {
+		if _, err := os.Stat(filepath.Join(workDir, name)); err == nil {
+			r.Template = name
+			break
+		}
+	}
+	if r.Template == "" {
+		return r
+	}
+	keys, err := parseEnvTemplate(filepath.Join(workDir, r.Template))
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	listed := map[string]bool{}
+	for _, k := range keys {
+		listed[k.Name] = true
+		v, ok := overlay[k.Name]
+		if !ok {
+			v, ok = lookup(k.Name)
+		}
+		switch {
+		case ok && v != "":
+			r.OK++
+		case k.Optional:
+		case ok:
+			r.Empty = append(r.Empty, k.Name)
+		default:
+			r.Missing = append(r.Missing, k.Name)
+		}
+	}
+	for k := range overlay {
+		if !listed[k] {
+			r.Extra = append(r.Extra, k)
+		}
+	}
+	sort.Strings(r.Extra)
+	return r
+}
+
+// renderDotenv is the .env section of the Diagnostics tab.
+func renderDotenv(r dotenvReport) string {
+	var b strings.Builder
+	b.WriteString("[::b].env[::-]\n")
+	switch {
+	case r.Template == "":
+		fmt.Fprintf(&b, "[gray]No %s (or %s) in the project: nothing to check .env against.[-]\n", envTemplateNames[0], strings.Join(envTemplateNames[1:], ", "))
+		return b.String()
+	case r.Err != nil:
+		fmt.Fprintf(&b, "[red]Could not read %s: %s[-]\n", r.Template, tview.Escape(r.Err.Error()))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Compared with %s: %d set", r.Template, r.OK)
+	if r.Problems() == 0 && len(r.Extra) == 0 {
+		b.WriteString(", nothing missing.\n")
+		return b.String()
+	}
+	b.WriteString(".\n")
+	for _, list := range []struct {
+		keys  []string
+		color string
+		what  string
+	}{
+		{r.Missing, "red", "missing — set in neither .env nor the environment"},
+		{r.Empty, "yellow", "empty"},
+		{r.Extra, "gray", "in .env but not in " + r.Template},
+	} {
+		if len(list.keys) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n[%s]%d %s:[-]\n", list.color, len(list.keys), list.what)
+		for _, k := range list.keys {
+			fmt.Fprintf(&b, "  %s\n", tview.Escape(k))
+		}
+	}
+	return b.String()
+}
+
+// setDotenvKey sets key to value in the .env file at path: the line assigning key is replaced, else one is
+// appended. A missing file is created readable by its owner only, as .env files hold credentials.
+func setDotenvKey(path, key, value string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	line := key + "=" + value
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	found := false
+	for i, l := range lines {
+		name, _, ok := strings.Cut(strings.TrimSpace(l), "=")
+		if ok && !found && strings.TrimSpace(strings.TrimPrefix(name, "export ")) == key {
+			lines[i], found = line, true
+		}
+	}
+	if !found {
+		lines = append(lines, line)
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), mode)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// lastDryRunPath is where the output of env's last dry-run is kept, for the next one to be diffed against.
+func lastDryRunPath(workDir, env string) string {
+	return filepath.Join(workDir, ".atlas9", "dry-runs", env+".txt")
+}
+
+// swapLastDryRun keeps out as env's last dry-run and returns the one it replaces and when that ran; prev is ""
+// when there was none. Best effort: a write error only costs the next diff.
+func swapLastDryRun(workDir, env, out string) (prev string, at time.Time) {
+	path := lastDryRunPath(workDir, env)
+	if data, err := os.ReadFile(path); err == nil {
+		prev = string(data)
+		if info, err := os.Stat(path); err == nil {
+			at = info.ModTime()
+		}
+	}
+	if os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		os.WriteFile(path, []byte(out), 0644)
+	}
+	return prev, at
+}
+
+// dryRunDiffContext is how many unchanged lines dryRunDiff keeps around each change.
+const dryRunDiffContext = 3
+
+// dryRunDiff renders what changed from the dry-run output prev to out, unified-diff style: timing lines left
+// out, changed lines with dryRunDiffContext lines around them and longer unchanged stretches collapsed.
+func dryRunDiff(prev, out string) string {
+	d, ok := diffLines(withoutTimings(prev), withoutTimings(out))
+	if !ok {
+		return "[yellow]The dry-runs are too long to diff.[-]\n"
+	}
+	lines := strings.Split(strings.TrimRight(d, "\n"), "\n")
+	changed := make([]bool, len(lines))
+	some := false
+	for i, l := range lines {
+		changed[i] = !strings.HasPrefix(l, "  ")
+		some = some || changed[i]
+	}
+	if !some {
+		return "[green]No change: the plan is the same as the previous dry-run's.[-]\n"
+	}
+	near := func(i int) bool {
+		for j := max(0, i-dryRunDiffContext); j <= min(len(lines)-1, i+dryRunDiffContext); j++ {
+			if changed[j] {
+				return true
+			}
+		}
+		return false
+	}
+	var b strings.Builder
+	skipped := 0
+	for i, l := range lines {
+		if near(i) {
+			if skipped > 0 {
+				fmt.Fprintf(&b, "[gray]@@ %d unchanged line(s) @@[-]\n", skipped)
+				skipped = 0
+			}
+			b.WriteString(l + "\n")
+			continue
+		}
+		skipped++
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&b, "[gray]@@ %d unchanged line(s) @@[-]\n", skipped)
+	}
+	return b.String()
+}
+
+// withoutTimings drops the lines of dry-run output that change on every run (see dryRunTimingRe).
+func withoutTimings(out string) string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if !dryRunTimingRe.MatchString(strings.TrimSpace(line)) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
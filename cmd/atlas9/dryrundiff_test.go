@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSwapLastDryRun(t *testing.T) {
+	work := t.TempDir()
+	if prev, at := swapLastDryRun(work, "dev", "first"); prev != "" || !at.IsZero() {
+		t.Errorf("first dry-run: prev = %q, at = %v, want none", prev, at)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(lastDryRunPath(work, "dev"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if prev, at := swapLastDryRun(work, "dev", "second"); prev != "first" || !at.Equal(old) {
+		t.Errorf("second dry-run: prev = %q, at = %v, want %q at %v", prev, at, "first", old)
+	}
+	if prev, _ := swapLastDryRun(work, "prod", "other env"); prev != "" {
+		t.Errorf("another env: prev = %q, want none", prev)
+	}
+	if data, _ := os.ReadFile(lastDryRunPath(work, "dev")); string(data) != "second" {
+		t.Errorf("kept %q, want the latest dry-run", data)
+	}
+
+	// A state directory that cannot be created only costs the next diff.
+	file := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if prev, at := swapLastDryRun(file, "dev", "out"); prev != "" || !at.IsZero() {
+		t.Errorf("unwritable: prev = %q, at = %v", prev, at)
+	}
+}
+
+func TestDryRunDiff(t *testing.T) {
+	prev := "-- Planned Changes:\n-- Create table\n-> CREATE TABLE t (id int);\n-- ok (1.2ms)\n"
+	if got := dryRunDiff(prev, strings.Replace(prev, "1.2ms", "3.4ms", 1)); !strings.Contains(got, "No change") {
+		t.Errorf("timings only: got %q", got)
+	}
+	out := strings.Replace(prev, "id int", "id bigint", 1)
+	got := dryRunDiff(prev, out)
+	for _, w := range []string{"CREATE TABLE t (id int);", "CREATE TABLE t (id bigint);"} {
+		if !strings.Contains(got, w) {
+			t.Errorf("no %q in\n%s", w, got)
+		}
+	}
+}
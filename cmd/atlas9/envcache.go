@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// envSnapshot is the .env overlay of the TUI and the environment atlas, hooks and tools run with: the process
+// environment with .env applied on top. The merged environment is built once and rebuilt only after Load,
+// which the .env watcher calls, instead of copying and scanning os.Environ for every command.
+type envSnapshot struct {
+	mu        sync.Mutex
+	overrides map[string]string
+	environ   []string // nil until built; Load resets it
+}
+
+// Load reads path as .env (a missing file means no overrides) and invalidates the merged environment.
+func (s *envSnapshot) Load(path string) {
+	parsed, _ := parseEnvFile(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides, s.environ = parsed, nil
+}
+
+// Get returns the .env value of key, else the process value.
+func (s *envSnapshot) Get(key string) string {
+	s.mu.Lock()
+	v, ok := s.overrides[key]
+	s.mu.Unlock()
+	if ok {
+		return v
+	}
+	return os.Getenv(key)
+}
+
+// Overrides returns a copy of the .env values.
+func (s *envSnapshot) Overrides() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]string, len(s.overrides))
+	for k, v := range s.overrides {
+		out[k] = v
+	}
+	return out
+}
+
+// Environ returns the merged environment. The slice is shared: its capacity is clipped so appending copies,
+// but callers must not modify its entries.
+func (s *envSnapshot) Environ() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.environ == nil {
+		s.environ = mergeEnviron(os.Environ(), s.overrides)
+	}
+	return s.environ[:len(s.environ):len(s.environ)]
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeDotEnv writes a .env file with content to a temporary directory and returns its path.
+func writeDotEnv(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// environValues returns the values of key in environ, in order (more than one means a duplicate entry).
+func environValues(environ []string, key string) []string {
+	var vals []string
+	for _, e := range environ {
+		if v, ok := strings.CutPrefix(e, key+"="); ok {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+func TestEnvSnapshotPrecedence(t *testing.T) {
+	t.Setenv("ATLAS9_TEST_BOTH", "process")
+	t.Setenv("ATLAS9_TEST_PROCESS", "process")
+	s := &envSnapshot{}
+	s.Load(writeDotEnv(t, "ATLAS9_TEST_BOTH=dotenv\nATLAS9_TEST_DOTENV=\"quoted value\"\n"))
+	for _, tc := range []struct{ key, want string }{
+		{"ATLAS9_TEST_BOTH", "dotenv"}, // .env overrides the process
+		{"ATLAS9_TEST_PROCESS", "process"},
+		{"ATLAS9_TEST_DOTENV", "quoted value"},
+		{"ATLAS9_TEST_UNSET", ""},
+	} {
+		if got := s.Get(tc.key); got != tc.want {
+			t.Errorf("Get(%s) = %q, want %q", tc.key, got, tc.want)
+		}
+		vals := environValues(s.Environ(), tc.key)
+		switch {
+		case tc.want == "" && len(vals) != 0:
+			t.Errorf("Environ has %s=%q, want it unset", tc.key, vals)
+		case tc.want != "" && (len(vals) != 1 || vals[0] != tc.want):
+			t.Errorf("Environ has %s=%q, want exactly [%q]", tc.key, vals, tc.want)
+		}
+	}
+}
+
+func TestResolveEnvNamePrecedence(t *testing.T) {
+	for _, tc := range []struct {
+		name, flag, process, dotEnv, lastEnv, defaultEnv, want string
+	}{
+		{"flag wins", "ci", "staging", "ENVIRONMENT=dev\n", "qa", "prod", "ci"},
+		{".env over process", "", "staging", "ENVIRONMENT=dev\n", "qa", "prod", "dev"},
+		{"process", "", "staging", "", "qa", "prod", "staging"},
+		{"last used", "", "", "", "qa", "prod", "qa"},
+		{"configured default", "", "", "", "", "prod", "prod"},
+		{"local", "", "", "", "", "", "local"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("ENVIRONMENT", tc.process)
+			s := &envSnapshot{}
+			s.Load(writeDotEnv(t, tc.dotEnv))
+			if got := resolveEnvName(tc.flag, tc.lastEnv, tc.defaultEnv, s.Get); got != tc.want {
+				t.Errorf("resolveEnvName = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnvSnapshotCachesUntilLoad(t *testing.T) {
+	path := writeDotEnv(t, "ATLAS9_TEST_VAR=one\n")
+	s := &envSnapshot{}
+	s.Load(path)
+	first := s.Environ()
+	_ = append(first, "ATLAS9_TEST_EXTRA=x") // callers append (e.g. hooks); that must not touch the cache
+	if second := s.Environ(); &second[0] != &first[0] || len(environValues(second, "ATLAS9_TEST_EXTRA")) != 0 {
+		t.Fatal("Environ rebuilt or changed without a Load")
+	}
+	if err := os.WriteFile(path, []byte("ATLAS9_TEST_VAR=two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := environValues(s.Environ(), "ATLAS9_TEST_VAR"); got[0] != "one" {
+		t.Errorf("before Load: ATLAS9_TEST_VAR=%q, want the cached one", got)
+	}
+	s.Load(path)
+	if got := environValues(s.Environ(), "ATLAS9_TEST_VAR"); len(got) != 1 || got[0] != "two" {
+		t.Errorf("after Load: ATLAS9_TEST_VAR=%q, want [two]", got)
+	}
+}
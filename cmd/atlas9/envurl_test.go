@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveHCLString(t *testing.T) {
+	src := `variable "db" {
+  default = "postgres://${getenv("DB_HOST")}/app"
+}
+variable "nodefault" {
+  type = string
+}`
+	getEnv := func(name string) string { return map[string]string{"DB_HOST": "db.internal", "PW": "p{w}"}[name] }
+	for _, tc := range []struct{ expr, want, err string }{
+		{`"sqlite://file.db?_fk=1"`, "sqlite://file.db?_fk=1", ""},
+		{`"postgres://u:${getenv("PW")}@h/app?search_path={x}"`, "postgres://u:p{w}@h/app?search_path={x}", ""},
+		{`getenv("DB_HOST")`, "db.internal", ""},
+		{`var.db`, "postgres://db.internal/app", ""},
+		{`"${var.db}?sslmode=disable"`, "postgres://db.internal/app?sslmode=disable", ""},
+		{`"literal $${x}"`, "literal ${x}", ""},
+		{`"${getenv("UNSET")}"`, "", "UNSET is not set"},
+		{`var.nodefault`, "", "var.nodefault has no default"},
+		{`"${upper("x")}"`, "", "cannot evaluate"},
+		{`data.remote_dir.url`, "", "cannot evaluate"},
+	} {
+		got, err := resolveHCLString(src, tc.expr, getEnv)
+		switch {
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("resolveHCLString(%s): error %v, want one with %q", tc.expr, err, tc.err)
+		case tc.err == "" && (err != nil || got != tc.want):
+			t.Errorf("resolveHCLString(%s) = %q, %v; want %q", tc.expr, got, err, tc.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"prod", "prod", 0},
+		{"prd", "prod", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1}, // runes, not bytes
+	} {
+		if got := levenshtein(tc.a, tc.b); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+		if got := levenshtein(tc.b, tc.a); got != tc.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tc.b, tc.a, got, tc.want)
+		}
+	}
+}
+
+func TestClosestEnv(t *testing.T) {
+	envs := []string{"local", "dev", "prod", "integration"}
+	for _, tc := range []struct{ name, want string }{
+		{"prd", "prod"},
+		{"PROD", "prod"},               // case-insensitive
+		{"production", "prod"},         // prefix: one edit
+		{"develop", "dev"},             // prefix the other way
+		{"dve", "dev"},                 // 2 edits: the minimum allowance
+		{"lcl", "local"},               // 2 edits
+		{"xyz", ""},                    // 3 edits on a 3-letter name
+		{"qa", ""},                     // nothing close
+		{"integratoin", "integration"}, // 2 edits, 11 letters allow 3
+		{"intgrtn", ""},                // 4 edits: 7 letters allow 2
+	} {
+		if got := closestEnv(tc.name, envs); got != tc.want {
+			t.Errorf("closestEnv(%q) = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	if got := closestEnv("prod", nil); got != "" {
+		t.Errorf("closestEnv with no envs = %q", got)
+	}
+	if got := closestEnv("stag", []string{"stage", "staging"}); got != "stage" {
+		t.Errorf("closestEnv tie = %q, want the first", got)
+	}
+}
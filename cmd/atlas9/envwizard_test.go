@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderEnvBlock(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		spec newEnvSpec
+		want []string // substrings of the block
+		err  string
+	}{
+		{name: "literal URLs", spec: newEnvSpec{Name: "dev", URL: "postgres://u:p@localhost:5432/app", DevURL: "docker://postgres/16/dev", Dir: "./db/migrations"},
+			want: []string{`env "dev" {`, `url = "postgres://u:p@localhost:5432/app"`, `dev = "docker://postgres/16/dev"`, `dir = "file://db/migrations"`}},
+		{name: "$VAR", spec: newEnvSpec{Name: "prod", URL: "$PROD_DB_URL", DevURL: "${DEV_URL}"},
+			want: []string{`url = getenv("PROD_DB_URL")`, `dev = getenv("DEV_URL")`}},
+		{name: "SQLite dev default", spec: newEnvSpec{Name: "local", URL: "sqlite://app.db"},
+			want: []string{`url = "sqlite://app.db"`, `dev = "` + sqliteDevURL + `"`}},
+		{name: "SQLite dev given", spec: newEnvSpec{Name: "local", URL: "sqlite://app.db", DevURL: "sqlite://dev.db"},
+			want: []string{`dev = "sqlite://dev.db"`}},
+		{name: "dir URL kept", spec: newEnvSpec{Name: "dev", URL: "mysql://localhost/app", Dir: "atlas://app"},
+			want: []string{`dir = "atlas://app"`}},
+		{name: "lint preset", spec: newEnvSpec{Name: "dev", URL: "mysql://localhost/app", Lint: map[string]string{"destructive": "error", "naming": "warning"}},
+			want: []string{"lint {", "destructive {\n      error = true", "naming {\n      error = false"}},
+		{name: "invalid name", spec: newEnvSpec{Name: "my env", URL: "sqlite://app.db"}, err: "use letters, digits"},
+		{name: "name starting with a digit", spec: newEnvSpec{Name: "1dev", URL: "sqlite://app.db"}, err: "use letters, digits"},
+		{name: "no URL", spec: newEnvSpec{Name: "dev", URL: "  "}, err: "URL is required"},
+	} {
+		got, err := renderEnvBlock(tc.spec)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: err = %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		for _, w := range tc.want {
+			if !strings.Contains(string(got), w) {
+				t.Errorf("%s: no %q in\n%s", tc.name, w, got)
+			}
+		}
+		if tc.spec.DevURL == "" && !strings.HasPrefix(tc.spec.URL, "sqlite") && strings.Contains(string(got), "dev =") {
+			t.Errorf("%s: dev URL added to a non-SQLite env:\n%s", tc.name, got)
+		}
+	}
+}
+
+func TestAppendEnvBlock(t *testing.T) {
+	src := "env \"dev\" {\n  url = \"sqlite://dev.db\"\n}\n\n\n"
+	for _, tc := range []struct {
+		name, src string
+		spec      newEnvSpec
+		want, err string
+	}{
+		{name: "appended", src: src, spec: newEnvSpec{Name: "prod", URL: "$PROD_DB_URL"},
+			want: "env \"dev\" {\n  url = \"sqlite://dev.db\"\n}\n\nenv \"prod\" {\n  url = getenv(\"PROD_DB_URL\")\n}\n"},
+		{name: "empty file", src: "", spec: newEnvSpec{Name: "dev", URL: "sqlite://app.db"},
+			want: "env \"dev\" {\n  url = \"sqlite://app.db\"\n  dev = \"" + sqliteDevURL + "\"\n}\n"},
+		{name: "duplicate env", src: src, spec: newEnvSpec{Name: "dev", URL: "sqlite://other.db"}, err: `env "dev" already exists`},
+		{name: "invalid name", src: src, spec: newEnvSpec{Name: "a.b", URL: "sqlite://app.db"}, err: "use letters, digits"},
+		{name: "file that does not parse", src: "env \"dev\" {\n  url = \n", spec: newEnvSpec{Name: "prod", URL: "sqlite://app.db"}, err: "does not parse, fix it first"},
+	} {
+		got, err := appendEnvBlock([]byte(tc.src), tc.spec)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: err = %v, want %q", tc.name, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || string(got) != tc.want {
+			t.Errorf("%s: got %v\n%s\nwant\n%s", tc.name, err, got, tc.want)
+		}
+	}
+}
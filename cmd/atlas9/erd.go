@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// erdTokenRe matches characters Mermaid/DOT identifiers and attribute types cannot contain.
+var erdTokenRe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+func erdToken(s string) string {
+	t := strings.Trim(erdTokenRe.ReplaceAllString(s, "_"), "_")
+	if t == "" {
+		return "_"
+	}
+	return t
+}
+
+// erdTables flattens the realm; table names are schema-qualified only when there is more than one schema.
+// refs holds, per table, the names of the tables its foreign keys reference, named the same way.
+func erdTables(r inspectedRealm) (names []string, tables []inspectedTable, refs [][]string) {
+	multi := len(r.Schemas) > 1
+	for _, s := range r.Schemas {
+		for _, t := range s.Tables {
+			name := t.Name
+			if multi {
+				name = s.Name + "." + t.Name
+			}
+			var tableRefs []string
+			for _, f := range t.ForeignKeys {
+				tableRefs = append(tableRefs, erdRefName(r, s.Name, f.References.Table))
+			}
+			names = append(names, name)
+			tables = append(tables, t)
+			refs = append(refs, tableRefs)
+		}
+	}
+	return names, tables, refs
+}
+
+// erdRefName names a referenced table as erdTables names tables. atlas gives it as "table" when it is in
+// schema, the referencing table's schema, and as "schema.table" otherwise.
+func erdRefName(r inspectedRealm, schema, table string) string {
+	if len(r.Schemas) <= 1 {
+		return table
+	}
+	if refSchema, _, ok := strings.Cut(table, "."); ok {
+		for _, s := range r.Schemas {
+			if s.Name == refSchema {
+				return table
+			}
+		}
+	}
+	return schema + "." + table
+}
+
+func pkColumns(t inspectedTable) map[string]bool {
+	pk := make(map[string]bool)
+	if t.PrimaryKey != nil {
+		for _, p := range t.PrimaryKey.Parts {
+			pk[p.Column] = true
+		}
+	}
+	return pk
+}
+
+func fkColumns(t inspectedTable) map[string]bool {
+	fk := make(map[string]bool)
+	for _, f := range t.ForeignKeys {
+		for _, c := range f.Columns {
+			fk[c] = true
+		}
+	}
+	return fk
+}
+
+// mermaidERD renders the realm as a Mermaid erDiagram.
+func mermaidERD(r inspectedRealm) string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+	names, tables, refs := erdTables(r)
+	for i, t := range tables {
+		pk, fk := pkColumns(t), fkColumns(t)
+		fmt.Fprintf(&b, "    %s {\n", erdToken(names[i]))
+		for _, c := range t.Columns {
+			var keys []string
+			if pk[c.Name] {
+				keys = append(keys, "PK")
+			}
+			if fk[c.Name] {
+				keys = append(keys, "FK")
+			}
+			fmt.Fprintf(&b, "        %s %s %s\n", erdToken(c.Type), erdToken(c.Name), strings.Join(keys, ","))
+		}
+		b.WriteString("    }\n")
+	}
+	for i, t := range tables {
+		for j, f := range t.ForeignKeys {
+			fmt.Fprintf(&b, "    %s }o--|| %s : %q\n", erdToken(names[i]), erdToken(refs[i][j]), strings.Join(f.Columns, ", "))
+		}
+	}
+	return b.String()
+}
+
+// dotERD renders the realm as a Graphviz DOT graph with record-shaped tables.
+func dotERD(r inspectedRealm) string {
+	var b strings.Builder
+	b.WriteString("digraph erd {\n  rankdir=LR;\n  node [shape=record, fontname=\"Helvetica\"];\n")
+	names, tables, refs := erdTables(r)
+	for i, t := range tables {
+		pk := pkColumns(t)
+		var fields []string
+		for _, c := range t.Columns {
+			f := c.Name + " : " + c.Type
+			if pk[c.Name] {
+				f += " (PK)"
+			}
+			fields = append(fields, strings.NewReplacer("{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`, `"`, `\"`).Replace(f))
+		}
+		fmt.Fprintf(&b, "  %s [label=\"{%s|%s}\"];\n", erdToken(names[i]), names[i], strings.Join(fields, `\l`)+`\l`)
+	}
+	for i, t := range tables {
+		for j, f := range t.ForeignKeys {
+			fmt.Fprintf(&b, "  %s -> %s [label=%q];\n", erdToken(names[i]), erdToken(refs[i][j]), strings.Join(f.Columns, ", "))
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// asciiERD renders each table as a text box followed by a list of relationships.
+func asciiERD(r inspectedRealm) string {
+	var b strings.Builder
+	names, tables, refs := erdTables(r)
+	for i, t := range tables {
+		pk, fk := pkColumns(t), fkColumns(t)
+		rows := make([]string, 0, len(t.Columns))
+		width := len(names[i])
+		for _, c := range t.Columns {
+			row := c.Name + " " + c.Type
+			if pk[c.Name] {
+				row += " PK"
+			}
+			if fk[c.Name] {
+				row += " FK"
+			}
+			rows = append(rows, row)
+			if len(row) > width {
+				width = len(row)
+			}
+		}
+		border := "+" + strings.Repeat("-", width+2) + "+\n"
+		b.WriteString(border)
+		fmt.Fprintf(&b, "| %-*s |\n", width, names[i])
+		b.WriteString(border)
+		for _, row := range rows {
+			fmt.Fprintf(&b, "| %-*s |\n", width, row)
+		}
+		b.WriteString(border + "\n")
+	}
+	var rels []string
+	for i, t := range tables {
+		for j, f := range t.ForeignKeys {
+			rels = append(rels, fmt.Sprintf("%s(%s) >--| %s(%s)", names[i], strings.Join(f.Columns, ", "),
+				refs[i][j], strings.Join(f.References.Columns, ", ")))
+		}
+	}
+	if len(rels) > 0 {
+		b.WriteString("Relationships:\n  " + strings.Join(rels, "\n  ") + "\n")
+	}
+	return b.String()
+}
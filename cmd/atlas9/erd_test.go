@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestERDMultiSchema(t *testing.T) {
+	r, err := parseInspectJSON(`{"schemas": [
+		{"name": "auth", "tables": [{"name": "users", "columns": [{"name": "id", "type": "int"}]}]},
+		{"name": "billing", "tables": [
+			{"name": "users", "columns": [{"name": "id", "type": "int"}]},
+			{"name": "invoices", "columns": [{"name": "user_id", "type": "int"}, {"name": "owner_id", "type": "int"}],
+			 "foreign_keys": [
+				{"name": "a", "columns": ["user_id"], "references": {"table": "users", "columns": ["id"]}},
+				{"name": "b", "columns": ["owner_id"], "references": {"table": "auth.users", "columns": ["id"]}}]}]}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ name, got, want string }{
+		{"mermaid", mermaidERD(r), `billing_invoices }o--|| billing_users : "user_id"`},
+		{"mermaid", mermaidERD(r), `billing_invoices }o--|| auth_users : "owner_id"`},
+		{"dot", dotERD(r), `billing_invoices -> billing_users [label="user_id"]`},
+		{"dot", dotERD(r), `billing_invoices -> auth_users [label="owner_id"]`},
+		{"ascii", asciiERD(r), "billing.invoices(user_id) >--| billing.users(id)"},
+		{"ascii", asciiERD(r), "billing.invoices(owner_id) >--| auth.users(id)"},
+	} {
+		if !strings.Contains(tc.got, tc.want) {
+			t.Errorf("%s ERD: no %q in\n%s", tc.name, tc.want, tc.got)
+		}
+	}
+}
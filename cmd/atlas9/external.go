@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/rivo/tview"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// externalSchema is a data "external_schema" block of atlas.hcl: a program, usually an ORM loader such as
+// atlas-provider-gorm or sqlc, whose stdout atlas reads as the desired schema (SQL).
+type externalSchema struct {
+	Name    string
+	Program []string
+	Dir     string // working_dir relative to atlas.hcl; "" runs it next to atlas.hcl
+	Err     error  // program could not be evaluated outside atlas (e.g. it uses var.*)
+}
+
+// parseExternalSchemas returns the data "external_schema" blocks of src. Program arguments may call
+// getenv("X"); other references make the block's Err non-nil.
+func parseExternalSchemas(src []byte, getEnv func(string) string) []externalSchema {
+	f, diags := hclsyntax.ParseConfig(src, "atlas.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil
+	}
+	getenv := function.New(&function.Spec{
+		Params: []function.Parameter{{Name: "name", Type: cty.String}},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, _ cty.Type) (cty.Value, error) {
+			return cty.StringVal(getEnv(args[0].AsString())), nil
+		},
+	})
+	ctx := &hcl.EvalContext{Functions: map[string]function.Function{"getenv": getenv}}
+	var out []externalSchema
+	for _, b := range f.Body.(*hclsyntax.Body).Blocks {
+		if b.Type != "data" || len(b.Labels) != 2 || b.Labels[0] != "external_schema" {
+			continue
+		}
+		ext := externalSchema{Name: b.Labels[1]}
+		if attr, ok := b.Body.Attributes["working_dir"]; ok {
+			if v, diags := attr.Expr.Value(ctx); !diags.HasErrors() && v.Type() == cty.String {
+				ext.Dir = v.AsString()
+			}
+		}
+		attr, ok := b.Body.Attributes["program"]
+		if !ok {
+			ext.Err = fmt.Errorf("no program attribute")
+			out = append(out, ext)
+			continue
+		}
+		v, diags := attr.Expr.Value(ctx)
+		switch {
+		case diags.HasErrors():
+			ext.Err = fmt.Errorf("program: %s", diags[0].Summary)
+		case !v.Type().IsTupleType() && !v.Type().IsListType():
+			ext.Err = fmt.Errorf("program is not a list")
+		default:
+			for it := v.ElementIterator(); it.Next(); {
+				_, el := it.Element()
+				if el.IsNull() || !el.IsKnown() || el.Type() != cty.String {
+					ext.Err = fmt.Errorf("program arguments must be strings")
+					break
+				}
+				ext.Program = append(ext.Program, el.AsString())
+			}
+			if ext.Err == nil && len(ext.Program) == 0 {
+				ext.Err = fmt.Errorf("program is empty")
+			}
+		}
+		out = append(out, ext)
+	}
+	return out
+}
+
+// externalSchemaLabel is the top panel line for exts, e.g. "ext: gorm", short enough for the panel; ok is false
+// when a program cannot be evaluated outside atlas.
+func externalSchemaLabel(exts []externalSchema) (label string, ok bool) {
+	names := make([]string, len(exts))
+	ok = true
+	for i, e := range exts {
+		names[i] = e.Name
+		ok = ok && e.Err == nil
+	}
+	label = "ext: " + strings.Join(names, ",")
+	if r := []rune(label); len(r) > 22 {
+		label = string(r[:21]) + "…"
+	}
+	return label, ok
+}
+
+// runExternalSchema runs e's program like atlas does (in workDir, or its working_dir) and returns its output.
+func runExternalSchema(ctx context.Context, e externalSchema, workDir string, environ []string) (stdout, stderr string, err error) {
+	if e.Err != nil {
+		return "", "", e.Err
+	}
+	cmd := exec.CommandContext(ctx, e.Program[0], e.Program[1:]...)
+	cmd.Dir = workDir
+	if e.Dir != "" {
+		cmd.Dir = filepath.Join(workDir, e.Dir)
+	}
+	cmd.Env = environ
+	var out, errOut strings.Builder
+	cmd.Stdout, cmd.Stderr = &out, &errOut
+	err = cmd.Run()
+	return out.String(), errOut.String(), err
+}
+
+// externalSchemaFailures re-runs the external_schema programs after a failed Diff and reports the ones that
+// fail, with their stderr, so a crashed ORM loader is not buried in atlas's error. "" if all succeed. Programs
+// atlas9 cannot evaluate are skipped.
+func externalSchemaFailures(ctx context.Context, exts []externalSchema, workDir string, environ []string) string {
+	var b strings.Builder
+	for _, e := range exts {
+		if e.Err != nil {
+			continue
+		}
+		_, stderr, err := runExternalSchema(ctx, e, workDir, environ)
+		if err == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "[red::b]External schema %q failed: %s[-::-]\n", e.Name, tview.Escape(err.Error()))
+		if len(e.Program) > 0 {
+			fmt.Fprintf(&b, "> %s\n", tview.Escape(strings.Join(e.Program, " ")))
+		}
+		if s := strings.TrimSpace(stderr); s != "" {
+			b.WriteString("\n[red]" + tview.Escape(s) + "[-]\n")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
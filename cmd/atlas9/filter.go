@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// problemRe matches output lines that report an error or a warning.
+var problemRe = regexp.MustCompile(`(?i)\b(errors?|fatal|fail(ed|ure)?|warn(ings?)?)\b`)
+
+// outputFilter is a filter applied to the output pane. The unfiltered text and scroll position come back when
+// it is cleared, unless the pane was written to since (Shown no longer matches).
+type outputFilter struct {
+	Pattern     string // the regex as typed; "" when Problems is set
+	Problems    bool   // errors and warnings only
+	Full, Plain string // the pane's text with and without color tags
+	Row, Col    int
+	Shown       string // what the filter put in the pane
+	Kept, Total int
+}
+
+// compileFilter compiles a filter regex; without upper-case letters it ignores case.
+func compileFilter(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil || strings.ToLower(pattern) != pattern {
+		return re, err
+	}
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// filterLines returns the lines of plain (output without color tags) that match the regex pattern, with the
+// matches highlighted, or with problems set the lines reporting errors and warnings: lint findings (after the
+// "analyzing version" line of their file) and lines saying error, fatal, failed or warning. kept counts the
+// lines returned of total.
+func filterLines(plain, pattern string, problems bool) (text string, kept, total int, err error) {
+	var re *regexp.Regexp
+	if !problems {
+		if re, err = compileFilter(pattern); err != nil {
+			return "", 0, 0, err
+		}
+	}
+	lines := strings.Split(strings.TrimRight(plain, "\n"), "\n")
+	var b strings.Builder
+	version := "" // the last "analyzing version" line, shown before its first finding
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case re != nil:
+			m := re.FindAllStringIndex(line, -1)
+			if m == nil {
+				continue
+			}
+			last := 0
+			for _, loc := range m {
+				b.WriteString(tview.Escape(line[last:loc[0]]) + "[black:yellow]" + tview.Escape(line[loc[0]:loc[1]]) + "[-:-]")
+				last = loc[1]
+			}
+			b.WriteString(tview.Escape(line[last:]) + "\n")
+		case lintVersionRe.MatchString(trimmed):
+			version = line
+			continue
+		case lintFindingRe.MatchString(trimmed):
+			if version != "" {
+				b.WriteString("[gray]" + tview.Escape(version) + "[-]\n")
+				version = ""
+			}
+			b.WriteString("[yellow]" + tview.Escape(line) + "[-]\n")
+		case problemRe.MatchString(line):
+			color := "red"
+			if m := problemRe.FindString(line); strings.HasPrefix(strings.ToLower(m), "warn") {
+				color = "yellow"
+			}
+			b.WriteString("[" + color + "]" + tview.Escape(line) + "[-]\n")
+		default:
+			continue
+		}
+		kept++
+	}
+	return b.String(), kept, len(lines), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestFilterLines(t *testing.T) {
+	out := "Migrating to version 2 (1 migrations in total):\n" +
+		"  -- analyzing version 20250101120000\n" +
+		"    -- L2: Dropping table \"users\" https://atlasgo.io/lint/analyzers#DS102\n" +
+		"  -- analyzing version 20250102120000\n" +
+		"Error: sql/migrate: execution failed\n" +
+		"WARNING: [brackets] are escaped\n" +
+		"  -- ok (1ms)\n"
+	for _, tc := range []struct {
+		name, pattern string
+		problems      bool
+		want          string
+		kept          int
+		err           bool
+	}{
+		{name: "lower case ignores case", pattern: "error",
+			want: "[black:yellow]Error[-:-]: sql/migrate: execution failed\n", kept: 1},
+		{name: "upper case matches case", pattern: "Error",
+			want: "[black:yellow]Error[-:-]: sql/migrate: execution failed\n", kept: 1},
+		{name: "every match highlighted", pattern: "L2|DS102",
+			want: "    -- [black:yellow]L2[-:-]: Dropping table \"users\" https://atlasgo.io/lint/analyzers#[black:yellow]DS102[-:-]\n", kept: 1},
+		{name: "text escaped", pattern: "brackets",
+			want: "WARNING: [[black:yellow]brackets[-:-]] are escaped\n", kept: 1},
+		{name: "no match", pattern: "nothing", want: "", kept: 0},
+		{name: "invalid regex", pattern: "(", err: true},
+		{name: "problems", problems: true,
+			want: "[gray]  -- analyzing version 20250101120000[-]\n" +
+				"[yellow]    -- L2: Dropping table \"users\" https://atlasgo.io/lint/analyzers#DS102[-]\n" +
+				"[red]Error: sql/migrate: execution failed[-]\n" +
+				"[yellow]WARNING: [brackets[] are escaped[-]\n", kept: 3},
+	} {
+		text, kept, total, err := filterLines(out, tc.pattern, tc.problems)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: no error", tc.name)
+			}
+			continue
+		}
+		if err != nil || text != tc.want || kept != tc.kept || total != 7 {
+			t.Errorf("%s: got %q, %d of %d, %v\nwant %q, %d of 7", tc.name, text, kept, total, err, tc.want, tc.kept)
+		}
+	}
+}
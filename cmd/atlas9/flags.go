@@ -0,0 +1,90 @@
+package main
+
+import "strings"
+
+// stageFlag is an optional atlas flag offered in the flags panel (f) of a stage.
+type stageFlag struct {
+	Name    string   // e.g. "--allow-dirty"
+	Help    string   // placeholder of a value field
+	Bool    bool     // a toggle; otherwise the flag takes a value
+	Choices []string // allowed values (a drop-down) when non-empty
+}
+
+// stageFlagDefs are the flags offered per stage flag group (see flagGroup).
+var stageFlagDefs = map[string][]stageFlag{
+	"diff": {
+		{Name: "--to", Help: "desired state URL instead of the env's src"},
+	},
+	"lint": {
+		{Name: "--latest", Help: "lint only the latest N files"},
+		{Name: "--git-base", Help: "lint only files new since this git ref; auto: the default branch"},
+	},
+	"apply": {
+		{Name: "--allow-dirty", Bool: true},
+		{Name: "--baseline", Help: "version already in a non-empty database"},
+		{Name: "--to-version", Help: "last version to apply"},
+		{Name: "--exec-order", Choices: []string{"linear", "linear-skip", "non-linear"}},
+		{Name: "--lock-timeout", Help: "wait for the lock, e.g. 30s"},
+	},
+}
+
+// flagGroup returns the flag group of a stage index: Dry-Run and Apply share one so the preview matches
+// what gets applied. Status has none.
+func flagGroup(stageIdx int) string {
+	switch stageIdx {
+	case 1:
+		return "diff"
+	case 2:
+		return "lint"
+	case 3, 4:
+		return "apply"
+	}
+	return ""
+}
+
+// withDefaultFlags returns defaults, a stage's default flags (config default_flags), followed by set, the args
+// of the flags panel; a default flag the panel sets too is left out with its value.
+func withDefaultFlags(defaults, set []string) []string {
+	setNames := map[string]bool{}
+	for _, a := range set {
+		if strings.HasPrefix(a, "-") {
+			setNames[flagName(a)] = true
+		}
+	}
+	var args []string
+	skip := false
+	for _, a := range defaults {
+		if strings.HasPrefix(a, "-") {
+			skip = setNames[flagName(a)]
+		}
+		if !skip {
+			args = append(args, a)
+		}
+	}
+	return append(args, set...)
+}
+
+// flagName returns the name of a flag arg, without a "=value".
+func flagName(arg string) string {
+	name, _, _ := strings.Cut(arg, "=")
+	return name
+}
+
+// stageFlagArgs returns the command-line args for the values set in vals (flag name → value; "true" for a set
+// toggle), in definition order. Empty values are left out.
+func stageFlagArgs(group string, vals map[string]string) []string {
+	var args []string
+	for _, f := range stageFlagDefs[group] {
+		v := vals[f.Name]
+		switch {
+		case v == "":
+		case f.Bool:
+			if v == "true" {
+				args = append(args, f.Name)
+			}
+		default:
+			args = append(args, f.Name, v)
+		}
+	}
+	return args
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// prettyJSON re-indents out when it is a JSON object or array, as printed by atlas --format '{{ json . }}';
+// ok is false for anything else.
+func prettyJSON(out string) (pretty string, ok bool) {
+	trimmed := strings.TrimSpace(out)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return "", false
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return "", false
+	}
+	return buf.String() + "\n", true
+}
+
+// formattedOutput returns the output of a command run with a --format template for the output pane: JSON
+// pretty-printed and highlighted, anything else as printed.
+func formattedOutput(out string) string {
+	if pretty, ok := prettyJSON(out); ok {
+		return tviewText(highlightJSON(pretty))
+	}
+	return tview.Escape(out)
+}
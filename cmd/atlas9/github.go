@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// githubSummaryMarkdown renders headless results as a GitHub Actions job summary: a status table,
+// lint findings and the dry-run SQL in a collapsible block.
+func githubSummaryMarkdown(env string, results []stageResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## atlas9 — env `%s`\n\n", env)
+	b.WriteString("| Stage | Command | Result |\n|---|---|---|\n")
+	for _, r := range results {
+		result := "✅ ok"
+		if r.Err != nil {
+			result = "❌ failed"
+		}
+		fmt.Fprintf(&b, "| %s | `%s` | %s |\n", r.Stage, r.Command, result)
+	}
+	b.WriteString("\n")
+	for _, r := range results {
+		switch r.Stage {
+		case "lint":
+			b.WriteString("### Lint findings\n\n")
+			if len(r.Findings) == 0 {
+				b.WriteString("No findings.\n\n")
+				break
+			}
+			for _, f := range r.Findings {
+				fmt.Fprintf(&b, "- `%s:%d` %s", f.File, f.Line, f.Message)
+				if f.Section != "" {
+					fmt.Fprintf(&b, " _(%s)_", f.Section)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		case "dry-run":
+			b.WriteString("<details><summary>Dry-run SQL</summary>\n\n```sql\n")
+			b.WriteString(strings.TrimRight(r.Output, "\n"))
+			b.WriteString("\n```\n\n</details>\n\n")
+		default:
+			fmt.Fprintf(&b, "### %s\n\n```text\n%s\n```\n\n", r.Stage, strings.TrimRight(r.Output, "\n"))
+		}
+	}
+	return b.String()
+}
+
+// githubAnnotations returns workflow commands (::error / ::warning) for lint findings. Findings are errors
+// when lint failed and warnings otherwise.
+func githubAnnotations(results []stageResult) []string {
+	var out []string
+	for _, r := range results {
+		level := "warning"
+		if r.Err != nil {
+			level = "error"
+		}
+		for _, f := range r.Findings {
+			out = append(out, fmt.Sprintf("::%s file=%s,line=%d,title=atlas lint::%s", level, f.File, f.Line, escapeGitHubData(f.Message)))
+		}
+		if r.Stage == "lint" && r.Err != nil && len(r.Findings) == 0 {
+			out = append(out, "::error title=atlas lint::"+escapeGitHubData(firstLine(r.Output)))
+		}
+	}
+	return out
+}
+
+// escapeGitHubData escapes a workflow command message (%, CR and LF must be percent-encoded).
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// gutterMark is what the preview gutter shows beside a line.
+type gutterMark int
+
+const (
+	gutterNone        gutterMark = iota
+	gutterLock                   // the line is part of a statement taking an exclusive lock or rewriting its table
+	gutterDestructive            // the line is part of a statement dropping objects or deleting data
+)
+
+// previewMarks returns the gutter mark of each line of dry-run output out, by the statement the line is part
+// of (see dryRunStatements for how statements span lines).
+func previewMarks(driver, out string) []gutterMark {
+	lines := strings.Split(out, "\n")
+	marks := make([]gutterMark, len(lines))
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		var stmt []string
+		for _, l := range lines[start:end] {
+			stmt = append(stmt, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "->")))
+		}
+		im := analyzeStatement(driver, strings.Join(stmt, "\n"))
+		upper := strings.ToUpper(im.Statement)
+		mark := gutterNone
+		switch {
+		case im.Note == "destructive" || strings.HasPrefix(upper, "DROP ") || strings.HasPrefix(upper, "DELETE "):
+			mark = gutterDestructive
+		case impactRisky(im):
+			mark = gutterLock
+		}
+		for i := start; i < end; i++ {
+			marks[i] = mark
+		}
+		start = -1
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "->"):
+			flush(i)
+			start = i
+		case strings.HasPrefix(trimmed, "--") || trimmed == "":
+			flush(i)
+		}
+	}
+	flush(len(lines))
+	return marks
+}
+
+// withGutter prefixes each line of text (tview-tagged, one line per line of the previewed output) with its
+// line number, counting from first, and the mark marks gives the line.
+func withGutter(text string, marks []gutterMark, first int) string {
+	width := len(strconv.Itoa(len(marks)))
+	var b strings.Builder
+	n := first
+	for line := range strings.SplitAfterSeq(text, "\n") {
+		if line == "" {
+			break
+		}
+		bar := " "
+		if n-1 < len(marks) {
+			switch marks[n-1] {
+			case gutterDestructive:
+				bar = "[red]▌[-]"
+			case gutterLock:
+				bar = "[yellow]▌[-]"
+			}
+		}
+		fmt.Fprintf(&b, "[gray]%*d[-]%s %s", width, n, bar, line)
+		n++
+	}
+	return b.String()
+}
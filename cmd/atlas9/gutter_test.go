@@ -0,0 +1,44 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPreviewMarks(t *testing.T) {
+	out := "-- Planned Changes:\n" +
+		"-- Drop table\n" +
+		"-> DROP TABLE \"old\";\n" +
+		"-- Modify table\n" +
+		"-> ALTER TABLE \"users\"\n" +
+		"   ALTER COLUMN \"email\" TYPE text;\n" +
+		"-- Create table\n" +
+		"-> CREATE TABLE \"t\" (\"id\" int);\n" +
+		"-- ok (1ms)"
+	want := []gutterMark{gutterNone, gutterNone, gutterDestructive, gutterNone, gutterLock, gutterLock, gutterNone, gutterNone, gutterNone}
+	if got := previewMarks("postgres", out); !slices.Equal(got, want) {
+		t.Errorf("previewMarks = %v, want %v", got, want)
+	}
+}
+
+func TestWithGutter(t *testing.T) {
+	marks := []gutterMark{gutterNone, gutterDestructive, gutterLock}
+	for _, tc := range []struct {
+		name, text string
+		first      int
+		want       string
+	}{
+		{"all lines", "a\nb\nc\n", 1, "[gray]1[-]  a\n[gray]2[-][red]▌[-] b\n[gray]3[-][yellow]▌[-] c\n"},
+		{"no trailing newline", "a\nb", 1, "[gray]1[-]  a\n[gray]2[-][red]▌[-] b"},
+		{"from a later line", "c\nd\n", 3, "[gray]3[-][yellow]▌[-] c\n[gray]4[-]  d\n"},
+		{"empty", "", 1, ""},
+	} {
+		if got := withGutter(tc.text, marks, tc.first); got != tc.want {
+			t.Errorf("%s: withGutter = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	// Line numbers are padded to the width of the last one.
+	if got := withGutter("x\n", make([]gutterMark, 12), 1); got != "[gray] 1[-]  x\n" {
+		t.Errorf("padding: withGutter = %q", got)
+	}
+}
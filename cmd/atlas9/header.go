@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+)
+
+// migrationHeader is the data of the migration header template (config migrations.header_template).
+type migrationHeader struct {
+	Author  string // git config user.name
+	Email   string // git config user.email
+	Date    string
+	Ticket  string // asked for before Diff
+	Version string // atlas9 version
+	File    string
+}
+
+// gitIdentity returns git's user.name and user.email for dir ("" for what is not configured).
+func gitIdentity(dir string) (name, email string) {
+	get := func(key string) string {
+		cmd := exec.Command("git", "config", key)
+		cmd.Dir = dir
+		out, _ := cmd.Output()
+		return strings.TrimSpace(string(out))
+	}
+	return get("user.name"), get("user.email")
+}
+
+// renderMigrationHeader executes tmpl for h. Every rendered line becomes a SQL comment ("-- " is added where
+// missing) and blank lines are dropped, so the header cannot change what the migration does.
+func renderMigrationHeader(tmpl string, h migrationHeader) (string, error) {
+	t, err := template.New("header").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, h); err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			line = "-- " + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// prependMigrationHeader writes header at the top of the migration file at path, after any atlas file directives
+// ("-- atlas:txmode none" and the like), which atlas only reads in the file's first comment block.
+func prependMigrationHeader(path, header string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	src := string(data)
+	directives := ""
+	if strings.HasPrefix(src, "-- atlas:") {
+		if i := strings.Index(src, "\n\n"); i >= 0 {
+			directives, src = src[:i+2], src[i+2:]
+		}
+	}
+	return os.WriteFile(path, []byte(directives+header+"\n"+src), 0644)
+}
+
+// parseMigrationHeader returns the "-- key: value" lines of src's leading comments (e.g. author, date, ticket)
+// by lower-cased key; atlas directives are skipped.
+func parseMigrationHeader(src string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		k, v, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "--")), ":")
+		if ok && !strings.ContainsAny(k, " \t") && !strings.HasPrefix(k, "atlas") {
+			fields[strings.ToLower(k)] = strings.TrimSpace(v)
+		}
+	}
+	return fields
+}
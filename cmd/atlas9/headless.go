@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"atlas9/internal/config"
+)
+
+// headlessStageNames are the stage names accepted by `atlas9 run`, in stage order.
+var headlessStageNames = stageIDs
+
+// defaultHeadlessStages run when `atlas9 run` is given no stages (read-only checks suitable for CI).
+var defaultHeadlessStages = []string{"status", "lint", "dry-run"}
+
+// Exit codes of `atlas9 run`, documented in the README (Headless / CI) for scripts and CI gates.
+const (
+	exitOK          = 0
+	exitFailed      = 1 // a stage failed for another reason, or bad arguments
+	exitLint        = 2 // lint failed (findings above the policy's threshold, or lint errors)
+	exitPending     = 3 // status found pending migrations (checks without dry-run or apply only)
+	exitDrift       = 4 // atlas.sum checksum mismatch, or apply ran other statements than its dry-run
+	exitConnection  = 5 // a database could not be reached or authenticated
+	exitNotApproved = 6 // apply or a confirming custom stage needed --yes
+	exitVerify      = 7 // apply succeeded but a verification check failed
+)
+
+// exitMeanings name the exit codes in the --quiet summary.
+var exitMeanings = map[int]string{
+	exitOK: "ok", exitFailed: "failed", exitLint: "lint failed", exitPending: "pending migrations", exitDrift: "drift",
+	exitConnection: "connection failed", exitNotApproved: "not approved", exitVerify: "verification failed",
+}
+
+// exitPriority orders the exit codes when a run has several outcomes; the first one found is returned.
+var exitPriority = []int{exitConnection, exitDrift, exitVerify, exitFailed, exitNotApproved, exitLint, exitPending}
+
+// connErrorRe matches the errors of drivers and atlas when a database is unreachable or rejects the login. A
+// bare "context deadline exceeded" is not one: a slow statement that timed out ends the same way.
+var connErrorRe = regexp.MustCompile(`(?i)connection refused|no such host|i/o timeout|no route to host|network is unreachable|` +
+	`dial tcp|could not connect|failed to connect|password authentication failed|access denied for user|server closed the connection`)
+
+// checksumErrorRe matches atlas's errors for a migration directory that no longer matches atlas.sum ("You have a
+// checksum error in your migration directory", "checksum mismatch"), not every mention of the file.
+var checksumErrorRe = regexp.MustCompile(`(?i)checksum (mismatch|error)`)
+
+// stageResult is the outcome of one headless stage.
+type stageResult struct {
+	Stage    string
+	Command  string
+	Output   string
+	Err      error
+	Exit     int // exit code of the stage's outcome (see exitPriority); exitOK when it succeeded
+	Duration time.Duration
+	Findings []lintFinding // lint stage only
+}
+
+// classify sets r.Exit from r.Err and the stage's output unless it is already set.
+func (r *stageResult) classify() {
+	switch {
+	case r.Exit != exitOK || r.Err == nil:
+	case connErrorRe.MatchString(r.Output + r.Err.Error()):
+		r.Exit = exitConnection
+	case checksumErrorRe.MatchString(r.Output):
+		r.Exit = exitDrift
+	case r.Stage == "lint":
+		r.Exit = exitLint
+	default:
+		r.Exit = exitFailed
+	}
+}
+
+// runExitCode is the exit code of a run with results: the highest-priority outcome of its stages, and
+// exitPending when status found pending migrations and neither dry-run nor apply ran.
+func runExitCode(results []stageResult) int {
+	outcomes := map[int]bool{}
+	pending, applying := false, false
+	for _, r := range results {
+		outcomes[r.Exit] = true
+		switch r.Stage {
+		case "status":
+			if m := pendingFilesRe.FindStringSubmatch(r.Output); r.Err == nil && m != nil && m[1] != "0" {
+				pending = true
+			}
+		case "dry-run", "apply":
+			applying = true
+		}
+	}
+	outcomes[exitPending] = pending && !applying
+	for _, code := range exitPriority {
+		if outcomes[code] {
+			return code
+		}
+	}
+	return exitOK
+}
+
+// headlessOptions configures `atlas9 run`.
+type headlessOptions struct {
+	workDir       string
+	atlasHCL      string
+	env           string
+	environ       []string
+	stages        []string
+	githubSummary bool
+	yes           bool          // approve apply without checking the policy
+	policy        confirmPolicy // used for apply when yes is false
+	hooks         config.Hooks
+	verify        config.Verify         // checks run after a successful apply
+	timeout       time.Duration         // of checks without their own, and of their database connection
+	getEnv        func(string) string   // resolves env() in atlas.hcl, for the database of SQL checks
+	seed          config.Seed           // the seed stage, when enabled
+	customStages  []config.Stage        // run by name after the built-in stage names
+	formats       map[string]string     // stage -> atlas --format template (config formats)
+	defaultFlags  []config.DefaultFlags // extra flags per stage and env (config default_flags)
+	note          string                // recorded in the apply history and passed to hooks
+	gitBase       string                // lint only migrations new since this git ref (gitBaseAuto: the default branch)
+	metricsFile   string                // Prometheus textfile the run's metrics are merged into ("" = none)
+	statsd        string                // StatsD host:port the run's metrics are sent to ("" = none)
+	quiet         bool                  // print a line per stage instead of the commands' output
+	logFile       string                // the full transcript is appended here ("" = none)
+	runner        commandRunner
+	stdout        io.Writer
+}
+
+// runHeadless runs the requested stages without the TUI, printing each command and its output,
+// and returns the process exit code.
+func runHeadless(o headlessOptions) int {
+	stages := o.stages
+	if len(stages) == 0 {
+		stages = defaultHeadlessStages
+	}
+	for _, s := range stages {
+		if _, ok := findCustomStage(o.customStages, s); !containsString(headlessStageNames, s) && !ok && (s != "seed" || !o.seed.Enabled()) {
+			names := append([]string{}, headlessStageNames...)
+			if o.seed.Enabled() {
+				names = append(names, "seed")
+			}
+			for _, c := range o.customStages {
+				names = append(names, c.Name)
+			}
+			fmt.Fprintf(os.Stderr, "unknown stage %q (want one of: %s)\n", s, strings.Join(names, ", "))
+			return exitFailed
+		}
+	}
+	var results []stageResult
+	out := o.stdout
+	if o.quiet {
+		out = io.Discard
+	}
+	if o.logFile != "" {
+		f, err := os.OpenFile(o.logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--log-file: %v\n", err)
+			return exitFailed
+		}
+		defer f.Close()
+		fmt.Fprintf(f, "=== atlas9 run %s --env %s at %s\n\n", strings.Join(stages, " "), o.env, time.Now().Format(time.RFC3339))
+		out = io.MultiWriter(out, f)
+		defer func() { fmt.Fprintf(f, "=== exit %d\n\n", runExitCode(results)) }()
+	}
+	run := func(args ...string) (string, error) {
+		res, err := o.runner.Run(context.Background(), args, o.environ)
+		return res.Stdout + res.Stderr, err
+	}
+	conf := config.Config{Formats: o.formats, DefaultFlags: o.defaultFlags}
+	formatArgs, stageFlags := conf.FormatArgs, func(group string) []string { return conf.StageFlags(group, o.env) }
+	dir := parseAtlasHCLMigrationDir(o.atlasHCL, o.env)
+	for _, s := range stages {
+		r := stageResult{Stage: s}
+		start := time.Now()
+		switch s {
+		case "status":
+			args := append([]string{"migrate", "status", "--env", o.env}, formatArgs(s)...)
+			r.Command = cmdString(args...)
+			if out, err := run("migrate", "hash", "--env", o.env); err != nil {
+				r.Output, r.Err = out, err
+				break
+			}
+			r.Output, r.Err = run(args...)
+		case "diff":
+			args := append([]string{"migrate", "diff", "--env", o.env}, stageFlags("diff")...)
+			r.Command = cmdString(args...)
+			r.Output, r.Err = run(args...)
+		case "lint":
+			var set []string
+			if o.gitBase != "" {
+				set = []string{"--git-base", o.gitBase}
+			}
+			args := slices.Concat([]string{"migrate", "lint", "--env", o.env}, withDefaultFlags(stageFlags("lint"), set), formatArgs(s))
+			args = resolveGitBase(args, func() (string, error) {
+				return defaultGitBase(context.Background(), o.workDir)
+			})
+			r.Command = cmdString(args...)
+			if out, err := run("migrate", "hash", "--env", o.env); err != nil {
+				r.Output, r.Err = out, err
+				break
+			}
+			r.Output, r.Err = run(args...)
+			r.Findings = parseLintFindings(r.Output, dir, migrationFilesByVersion(filepath.Join(o.workDir, dir)))
+		case "dry-run":
+			args := slices.Concat([]string{"migrate", "apply", "--env", o.env, "--dry-run"}, stageFlags("apply"), formatArgs(s))
+			r.Command = cmdString(args...)
+			r.Output, r.Err = run(args...)
+		case "apply":
+			args := append([]string{"migrate", "apply", "--env", o.env}, stageFlags("apply")...)
+			r.Command = cmdString(args...)
+			approval, dry := "", ""
+			if !o.yes {
+				var err error
+				dry, err = run(append(slices.Clip(args), "--dry-run")...)
+				if err != nil {
+					r.Output, r.Err = dry, err
+					break
+				}
+				ok, why := o.policy.autoApprove(o.env, dryRunStatements(dry))
+				if !ok {
+					r.Err, r.Exit = fmt.Errorf("apply not approved (%s); pass --yes to apply anyway", why), exitNotApproved
+					break
+				}
+				approval = "auto-approved: " + why + "\n"
+			}
+			pre, err := runHooks(o.workDir, o.environ, o.env, o.note, o.hooks.PreApply)
+			if err != nil {
+				r.Output, r.Err = approval+pre, err
+				break
+			}
+			r.Output, r.Err = run(args...)
+			rec := applyRecord{Time: time.Now(), Env: o.env, Command: r.Command, Success: r.Err == nil, URLs: extractURLs(r.Output), Note: o.note}
+			if r.Err == nil && !o.yes {
+				rec.Divergence = verifyApplied(dryRunStatements(dry), dryRunStatements(r.Output))
+			}
+			if r.Err != nil {
+				rec.Error = r.Err.Error()
+			}
+			r.Output = approval + pre + r.Output
+			if len(rec.Divergence) > 0 {
+				r.Output += "\n" + divergenceReport(rec.Divergence)
+				r.Exit = exitDrift
+			}
+			if r.Err == nil {
+				post, err := runHooks(o.workDir, o.environ, o.env, o.note, o.hooks.PostApply)
+				r.Output, r.Err = r.Output+post, err
+			}
+			if checks := o.verify.For(o.env); r.Err == nil && len(checks) > 0 {
+				url, _ := resolveEnvURL(o.atlasHCL, o.env, "url", o.getEnv)
+				results := runVerifyChecks(context.Background(), checks, o.workDir, o.environ, o.env, url, o.timeout)
+				r.Output += "\n" + renderChecks(results, false)
+				if rec.FailedChecks = failedChecks(results); len(rec.FailedChecks) > 0 {
+					r.Err = fmt.Errorf("%w: %s", errVerifyFailed, strings.Join(rec.FailedChecks, ", "))
+					if r.Exit == exitOK {
+						r.Exit = exitVerify
+					}
+				}
+			}
+			if err := appendApplyRecord(historyPath(o.workDir), rec); err != nil {
+				fmt.Fprintf(os.Stderr, "could not record apply history: %v\n", err)
+			}
+		case "seed":
+			switch {
+			case containsString(o.policy.Protected, o.env):
+				r.Command, r.Output = "seed", "skipped: "+o.env+" is a protected env"
+			case o.seed.Command != "":
+				r.Command, r.Err = seedCommand(o.seed, o.env)
+				if r.Err == nil {
+					r.Output, r.Err = runShellStage(context.Background(), o.workDir, o.environ, o.env, r.Command, nil)
+				}
+			default:
+				args := seedApplyArgs(o.seed, o.env, false)
+				r.Command = cmdString(args...)
+				if out, err := run(seedHashArgs(o.seed)...); err != nil {
+					r.Output, r.Err = out, err
+					break
+				}
+				r.Output, r.Err = run(args...)
+			}
+		default:
+			c, _ := findCustomStage(o.customStages, s)
+			command, err := c.Render(o.env)
+			r.Command, r.Err = command, err
+			switch {
+			case err != nil:
+			case (c.Confirm || containsString(o.policy.Protected, o.env)) && !o.yes:
+				r.Err, r.Exit = fmt.Errorf("stage %q asks for confirmation; pass --yes to run it", c.Name), exitNotApproved
+			default:
+				r.Output, r.Err = runShellStage(context.Background(), o.workDir, o.environ, o.env, command, nil)
+			}
+		}
+		r.Duration = time.Since(start)
+		r.classify()
+		fmt.Fprintf(out, "> %s\n%s\n", r.Command, strings.TrimRight(r.Output, "\n"))
+		if r.Err != nil {
+			fmt.Fprintf(out, "Error: %v\n", r.Err)
+		}
+		fmt.Fprintln(out)
+		if o.quiet {
+			fmt.Fprintln(o.stdout, quietLine(r))
+		}
+		results = append(results, r)
+		if r.Err != nil && s != "lint" {
+			break // later stages depend on earlier ones; lint failures still let CI see the dry-run
+		}
+	}
+	if o.metricsFile != "" || o.statsd != "" {
+		samples := headlessMetrics(o.env, results, time.Now())
+		if o.metricsFile != "" {
+			if err := writeMetricsTextfile(o.metricsFile, samples); err != nil {
+				fmt.Fprintf(os.Stderr, "could not write metrics: %v\n", err)
+			}
+		}
+		if o.statsd != "" {
+			if err := sendStatsd(o.statsd, samples); err != nil {
+				fmt.Fprintf(os.Stderr, "could not send metrics to statsd: %v\n", err)
+			}
+		}
+	}
+	if o.githubSummary {
+		for _, a := range githubAnnotations(results) {
+			fmt.Fprintln(o.stdout, a)
+		}
+		if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+			if err := appendFile(path, githubSummaryMarkdown(o.env, results)); err != nil {
+				fmt.Fprintf(os.Stderr, "could not write GITHUB_STEP_SUMMARY: %v\n", err)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "--github-summary: GITHUB_STEP_SUMMARY is not set; skipping summary")
+		}
+	}
+	code := runExitCode(results)
+	if o.quiet && code != exitOK {
+		fmt.Fprintf(o.stdout, "exit %d: %s\n", code, exitMeanings[code])
+	}
+	return code
+}
+
+// quietLine is the --quiet summary of r, e.g. "lint      lint failed (exit 2)  812ms  <first line of the error>".
+func quietLine(r stageResult) string {
+	if r.Exit == exitOK {
+		return fmt.Sprintf("%-9s ok  %s", r.Stage, r.Duration.Round(time.Millisecond))
+	}
+	line := fmt.Sprintf("%-9s %s (exit %d)  %s", r.Stage, exitMeanings[r.Exit], r.Exit, r.Duration.Round(time.Millisecond))
+	if r.Err != nil {
+		detail := firstLine(r.Err.Error())
+		if lines := strings.Split(strings.TrimSpace(r.Output), "\n"); strings.HasPrefix(detail, "exit status") && lines[0] != "" {
+			detail = strings.TrimSpace(lines[len(lines)-1]) // atlas's error is the last line of its output
+		}
+		line += "  " + detail
+	}
+	return line
+}
+
+// cmdString returns the shell form of an atlas invocation, e.g. "atlas migrate status --env local", args
+// quoted where a shell needs it.
+func cmdString(args ...string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.TrimSpace("atlas " + strings.Join(quoted, " "))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// appendFile appends text to path, creating it if needed.
+func appendFile(path, text string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
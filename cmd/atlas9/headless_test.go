@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHeadlessExitCodes(t *testing.T) {
+	for _, tc := range []struct {
+		stage, output, err string
+		exit               int // already set before classify
+		want               int
+	}{
+		{"status", "Migration Status: OK", "", 0, exitOK},
+		{"apply", "", "apply not approved", exitNotApproved, exitNotApproved},
+		{"status", "", "dial tcp 10.0.0.1:5432: connect: connection refused", 0, exitConnection},
+		{"status", "Error: failed to connect to `host=db user=app`: context deadline exceeded", "exit status 1", 0, exitConnection},
+		{"apply", "Error: executing statement \"CREATE INDEX …\": context deadline exceeded", "exit status 1", 0, exitFailed},
+		{"lint", "You have a checksum error in your migration directory.", "exit status 1", 0, exitDrift},
+		{"dry-run", "Error: checksum mismatch", "exit status 1", 0, exitDrift},
+		{"status", "Error: open migrations/atlas.sum: permission denied", "exit status 1", 0, exitFailed},
+		{"lint", "Error: checksum mismatch (dial tcp: no such host)", "exit status 1", 0, exitConnection},
+		{"lint", "Analyzing changes … 2 issues", "exit status 1", 0, exitLint},
+		{"seed", "syntax error", "exit status 1", 0, exitFailed},
+	} {
+		r := stageResult{Stage: tc.stage, Output: tc.output, Exit: tc.exit}
+		if tc.err != "" {
+			r.Err = errors.New(tc.err)
+		}
+		r.classify()
+		if r.Exit != tc.want {
+			t.Errorf("classify(%s, %q, %q) = %d, want %d", tc.stage, tc.output, tc.err, r.Exit, tc.want)
+		}
+	}
+	// Every outcome beats the ones after it in exitPriority.
+	for i, want := range exitPriority {
+		var results []stageResult
+		for _, code := range exitPriority[i:] {
+			if code != exitPending {
+				results = append(results, stageResult{Stage: "lint", Exit: code, Err: errors.New("failed")})
+			}
+		}
+		results = append(results, stageResult{Stage: "status", Output: "Pending Files: 2"})
+		if got := runExitCode(results); got != want {
+			t.Errorf("runExitCode with outcomes %v = %d, want %d", exitPriority[i:], got, want)
+		}
+	}
+	for _, tc := range []struct {
+		results []stageResult
+		want    int
+	}{
+		{nil, exitOK},
+		{[]stageResult{{Stage: "status", Output: "Pending Files: 0"}}, exitOK},
+		{[]stageResult{{Stage: "status", Output: "Pending Files: 2"}, {Stage: "dry-run"}}, exitOK},
+		{[]stageResult{{Stage: "status", Output: "Pending Files: 2", Err: errors.New("exit status 1"), Exit: exitFailed}}, exitFailed},
+	} {
+		if got := runExitCode(tc.results); got != tc.want {
+			t.Errorf("runExitCode(%+v) = %d, want %d", tc.results, got, tc.want)
+		}
+	}
+}
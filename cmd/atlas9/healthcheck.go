@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+
+	"atlas9/internal/config"
+)
+
+// errVerifyFailed is returned by an apply that succeeded but whose verification checks did not all pass.
+var errVerifyFailed = errors.New("verification failed")
+
+// checkResult is the outcome of one verification check.
+type checkResult struct {
+	Name   string
+	Output string // command output, or the query's first value
+	Err    error
+	Took   time.Duration
+}
+
+// runVerifyChecks runs checks for env after an apply: commands with sh -c in dir, queries on the database at
+// url. Every check runs even after one fails, so the summary shows them all. Each check gets its own timeout,
+// timeout when it sets none.
+func runVerifyChecks(ctx context.Context, checks []config.Check, dir string, environ []string, env, url string, timeout time.Duration) []checkResult {
+	var db *sql.DB
+	var dbErr error
+	defer func() {
+		if db != nil {
+			db.Close()
+		}
+	}()
+	results := make([]checkResult, 0, len(checks))
+	for _, c := range checks {
+		t := c.Timeout.Duration
+		if t == 0 {
+			t = timeout
+		}
+		cctx, cancel := context.WithTimeout(ctx, t)
+		start := time.Now()
+		r := checkResult{Name: c.Name}
+		if c.Command != "" {
+			command, err := (config.Stage{Name: c.Name, Command: c.Command}).Render(env)
+			if err == nil {
+				r.Output, err = runShellStage(cctx, dir, environ, env, command, nil)
+			}
+			r.Err = err
+		} else {
+			if db == nil && dbErr == nil {
+				db, _, dbErr = openTarget(cctx, url)
+			}
+			if r.Err = dbErr; r.Err == nil {
+				r.Output, r.Err = querySmokeTest(cctx, db, c.SQL)
+			}
+		}
+		if r.Err != nil && cctx.Err() == context.DeadlineExceeded {
+			r.Err = fmt.Errorf("timed out after %s", t)
+		}
+		cancel()
+		r.Took = time.Since(start)
+		results = append(results, r)
+	}
+	return results
+}
+
+// querySmokeTest runs query and returns its first value; a false, 0 or NULL value, or no row, is an error.
+func querySmokeTest(ctx context.Context, db *sql.DB, query string) (string, error) {
+	var v sql.NullString
+	if err := db.QueryRowContext(ctx, query).Scan(&v); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", errors.New("the query returned no row")
+		}
+		return "", err
+	}
+	switch strings.ToLower(strings.TrimSpace(v.String)) {
+	case "0", "f", "false":
+		return v.String, fmt.Errorf("the query returned %s", v.String)
+	}
+	if !v.Valid {
+		return "NULL", errors.New("the query returned NULL")
+	}
+	return v.String, nil
+}
+
+// failedChecks returns the names of the checks in results that failed.
+func failedChecks(results []checkResult) []string {
+	var out []string
+	for _, r := range results {
+		if r.Err != nil {
+			out = append(out, r.Name)
+		}
+	}
+	return out
+}
+
+// renderChecks is the Verification section of the apply summary; color adds tview tags.
+func renderChecks(results []checkResult, color bool) string {
+	var b strings.Builder
+	tag := func(t string) string {
+		if color {
+			return t
+		}
+		return ""
+	}
+	esc := func(s string) string {
+		if color {
+			return tview.Escape(s)
+		}
+		return s
+	}
+	fmt.Fprintf(&b, "%sVerification%s\n", tag("[::b]"), tag("[::-]"))
+	for _, r := range results {
+		took := r.Took.Round(time.Millisecond)
+		if r.Err != nil {
+			fmt.Fprintf(&b, "  %sFAIL%s %s (%s): %s\n", tag("[red]"), tag("[-]"), esc(r.Name), took, esc(r.Err.Error()))
+		} else {
+			fmt.Fprintf(&b, "  %sPASS%s %s (%s)\n", tag("[green]"), tag("[-]"), esc(r.Name), took)
+		}
+		if out := strings.TrimSpace(r.Output); out != "" && (r.Err != nil || !strings.Contains(out, "\n")) {
+			for _, line := range strings.Split(out, "\n") {
+				fmt.Fprintf(&b, "       %s%s%s\n", tag("[gray]"), esc(line), tag("[-]"))
+			}
+		}
+	}
+	return b.String()
+}
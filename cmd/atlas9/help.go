@@ -0,0 +1,86 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// helpRow is one entry of the help table: the keys (or subcommand / flag) and what they do. A row without Text
+// is a section heading.
+type helpRow struct {
+	Keys string
+	Text string
+}
+
+// usageEntryRe splits a "Commands:" / "Options:" line of usageDoc into its name and description.
+var usageEntryRe = regexp.MustCompile(`^  (\S.*?)(?:\s{2,}(.*))?$`)
+
+// usageRows returns the Commands and Options sections of doc (usageDoc) as help rows, each section led by its
+// heading; wrapped description lines are joined.
+func usageRows(doc string) []helpRow {
+	var rows []helpRow
+	section := false
+	for _, line := range strings.Split(doc, "\n") {
+		switch {
+		case line == "Commands:" || line == "Options:":
+			section = true
+			rows = append(rows, helpRow{Keys: strings.TrimSuffix(line, ":")})
+		case !section || strings.TrimSpace(line) == "":
+			section = section && line != ""
+		case strings.HasPrefix(line, "   "):
+			if last := &rows[len(rows)-1]; last.Text != "" {
+				last.Text += " " + strings.TrimSpace(line)
+			}
+		default:
+			if m := usageEntryRe.FindStringSubmatch(line); m != nil {
+				rows = append(rows, helpRow{Keys: m[1], Text: m[2]})
+			} else {
+				section = false
+			}
+		}
+	}
+	return rows
+}
+
+// filterHelpRows returns the rows whose keys or text contain query (case-insensitive), each under its section
+// heading; headings without a match are dropped.
+func filterHelpRows(rows []helpRow, query string) []helpRow {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return rows
+	}
+	var out []helpRow
+	var heading *helpRow
+	for i, r := range rows {
+		if r.Text == "" {
+			heading = &rows[i]
+			continue
+		}
+		if !strings.Contains(strings.ToLower(r.Keys+" "+r.Text), query) {
+			continue
+		}
+		if heading != nil {
+			out = append(out, *heading)
+			heading = nil
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// wrapWords breaks s into lines of at most width runes at spaces (a longer word gets a line of its own).
+func wrapWords(s string, width int) []string {
+	var lines []string
+	line := ""
+	for _, w := range strings.Fields(s) {
+		if line != "" && len([]rune(line))+1+len([]rune(w)) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += w
+	}
+	return append(lines, line)
+}
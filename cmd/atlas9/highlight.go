@@ -0,0 +1,49 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// ansiSeqRe matches the ANSI escape sequences chroma's terminal formatter writes.
+var ansiSeqRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// tviewText turns the output of a highlighter (text with ANSI colors, from unescaped source) into tview
+// text: the source between the escape sequences is escaped, so brackets in it (int[3], T-SQL [dbo].[users])
+// are not read as tags, and the sequences become color tags.
+func tviewText(highlighted string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range ansiSeqRe.FindAllStringIndex(highlighted, -1) {
+		b.WriteString(tview.Escape(highlighted[last:loc[0]]))
+		b.WriteString(highlighted[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(tview.Escape(highlighted[last:]))
+	return tview.TranslateANSI(b.String())
+}
+
+// highlightChunks splits text into pieces of about lines lines for highlighting in the background. It cuts
+// after a blank line or one ending a statement (";") where it can, so chroma sees whole statements, and
+// after 4×lines lines regardless.
+func highlightChunks(text string, lines int) []string {
+	var chunks []string
+	var cur strings.Builder
+	n := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		cur.WriteString(line)
+		n++
+		t := strings.TrimSpace(line)
+		if n >= lines && (t == "" || strings.HasSuffix(t, ";") || n >= 4*lines) {
+			chunks = append(chunks, cur.String())
+			cur.Reset()
+			n = 0
+		}
+	}
+	if cur.Len() > 0 {
+		chunks = append(chunks, cur.String())
+	}
+	return chunks
+}
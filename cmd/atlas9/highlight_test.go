@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func TestHighlightBrackets(t *testing.T) {
+	for _, tc := range []struct{ driver, sql string }{
+		{"postgres", "ALTER TABLE t ADD COLUMN scores int[3];"},
+		{"sqlserver", "SELECT * FROM [dbo].[users] WHERE [name] = '[red]';"},
+		{"mysql", "SELECT JSON_EXTRACT(doc, '$.tags[0]') FROM t;"},
+	} {
+		screen := tcell.NewSimulationScreen("UTF-8")
+		if err := screen.Init(); err != nil {
+			t.Fatal(err)
+		}
+		screen.SetSize(80, 1)
+		view := tview.NewTextView().SetDynamicColors(true).SetText(tviewText(highlightSQL(tc.driver, tc.sql)))
+		view.SetRect(0, 0, 80, 1)
+		view.Draw(screen)
+		screen.Show()
+		var b strings.Builder
+		screenText(&b, screen)
+		if got := strings.TrimSpace(b.String()); got != tc.sql {
+			t.Errorf("%s: shows %q, want %q", tc.driver, got, tc.sql)
+		}
+		screen.Fini()
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// applyRecord is one entry in the project's apply history (.atlas9/history.jsonl).
+type applyRecord struct {
+	Time    time.Time `json:"time"`
+	Env     string    `json:"env"`
+	Command string    `json:"command"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+	URLs    []string  `json:"urls,omitempty"` // e.g. Atlas Cloud report links found in the output
+	Note    string    `json:"note,omitempty"` // ticket or change reason given with the apply
+	TxMode  string    `json:"tx_mode,omitempty"`
+	// Divergence lists how the executed statements differed from the reviewed dry-run (see verifyApplied).
+	Divergence []string `json:"divergence,omitempty"`
+	// Stopped says why a step-mode apply stopped before the last pending file.
+	Stopped string `json:"stopped,omitempty"`
+	// FailedChecks are the verification checks that failed after the apply (see runVerifyChecks).
+	FailedChecks []string `json:"failed_checks,omitempty"`
+}
+
+// historyPath is the apply history log inside the project.
+func historyPath(workDir string) string {
+	return filepath.Join(workDir, ".atlas9", "history.jsonl")
+}
+
+// appendApplyRecord appends r as one JSON line to the history log at path.
+func appendApplyRecord(path string, r applyRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return appendFile(path, string(data)+"\n")
+}
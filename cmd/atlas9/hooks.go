@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// runHooks runs each command with sh -c in dir, with ATLAS9_ENV set to env and ATLAS9_APPLY_NOTE to note, and
+// returns their combined output, each prefixed by "> hook: <command>". It stops at the first failing command.
+func runHooks(dir string, environ []string, env, note string, cmds []string) (string, error) {
+	var b strings.Builder
+	for _, c := range cmds {
+		cmd := exec.Command("sh", "-c", c)
+		cmd.Dir = dir
+		cmd.Env = append(append([]string{}, environ...), "ATLAS9_ENV="+env, "ATLAS9_APPLY_NOTE="+note)
+		out, err := cmd.CombinedOutput()
+		fmt.Fprintf(&b, "> hook: %s\n%s", c, out)
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			b.WriteByte('\n')
+		}
+		if err != nil {
+			return b.String(), fmt.Errorf("hook %q: %w", c, err)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// stmtImpact is the estimated lock/rewrite impact of one pending statement.
+type stmtImpact struct {
+	Statement string
+	Table     string
+	Lock      string // strongest lock taken, e.g. "ACCESS EXCLUSIVE"
+	Rewrite   bool   // table rewrite or full scan likely
+	Rows      int64  // approximate rows in Table; -1 when unknown
+	Note      string
+}
+
+// dryRunStatements extracts the SQL statements ("-> ..." lines, with continuation lines) from dry-run output.
+func dryRunStatements(out string) []string {
+	var stmts []string
+	var cur []string
+	flush := func() {
+		if len(cur) > 0 {
+			stmts = append(stmts, strings.Join(cur, "\n"))
+			cur = nil
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "->"):
+			flush()
+			cur = append(cur, strings.TrimSpace(strings.TrimPrefix(trimmed, "->")))
+		case strings.HasPrefix(trimmed, "--") || trimmed == "":
+			flush()
+		case len(cur) > 0:
+			cur = append(cur, trimmed)
+		}
+	}
+	flush()
+	return stmts
+}
+
+var (
+	ddlTableRe = regexp.MustCompile(`(?i)^(?:ALTER|DROP|TRUNCATE)\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([^\s(;]+)`)
+	ddlIndexRe = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?\S*\s*ON\s+(?:ONLY\s+)?([^\s(]+)`)
+	ddlDMLRe   = regexp.MustCompile(`(?i)^(?:UPDATE\s+([^\s]+)|DELETE\s+FROM\s+([^\s;]+)|INSERT\s+INTO\s+([^\s(]+))`)
+)
+
+// unquoteIdent strips identifier quoting ("x", `x`, [x]) from each dot-separated part. Quoted parts may
+// contain dots and doubled quotes ("a""b").
+func unquoteIdent(s string) string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch q := quoteEnd(s[i]); {
+		case q != 0:
+			for i++; i < len(s); i++ {
+				if s[i] == q && i+1 < len(s) && s[i+1] == q {
+					cur.WriteByte(q)
+					i++
+				} else if s[i] == q {
+					break
+				} else {
+					cur.WriteByte(s[i])
+				}
+			}
+		case s[i] == '.':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		case s[i] != ' ' && s[i] != '\t' && s[i] != '\n':
+			cur.WriteByte(s[i])
+		}
+	}
+	return strings.Join(append(parts, cur.String()), ".")
+}
+
+// quoteEnd returns the closing quote for an identifier opening quote, or 0.
+func quoteEnd(c byte) byte {
+	switch c {
+	case '"', '`':
+		return c
+	case '[':
+		return ']'
+	}
+	return 0
+}
+
+// analyzeStatement classifies stmt by the lock it takes and whether it rewrites/scans the table.
+// Rules follow Postgres semantics; MySQL gets the closest equivalent (metadata lock / table copy).
+func analyzeStatement(driver, stmt string) stmtImpact {
+	im := stmtImpact{Statement: stmt, Rows: -1}
+	upper := strings.ToUpper(stmt)
+	mysql := driver == "mysql"
+	switch {
+	case strings.HasPrefix(upper, "CREATE TABLE"), strings.HasPrefix(upper, "CREATE SCHEMA"),
+		strings.HasPrefix(upper, "CREATE TYPE"), strings.HasPrefix(upper, "CREATE EXTENSION"):
+		im.Lock = "none"
+		im.Note = "new object"
+	case ddlIndexRe.MatchString(stmt):
+		m := ddlIndexRe.FindStringSubmatch(stmt)
+		im.Table = unquoteIdent(m[2])
+		im.Rewrite = true
+		if m[1] != "" {
+			im.Lock = "SHARE UPDATE EXCLUSIVE"
+			im.Note = "concurrent build; writes allowed"
+		} else if mysql {
+			im.Lock = "metadata (online)"
+			im.Note = "index build scans table"
+		} else {
+			im.Lock = "SHARE"
+			im.Note = "blocks writes for the whole build; consider CONCURRENTLY"
+		}
+	case ddlTableRe.MatchString(stmt):
+		im.Table = unquoteIdent(ddlTableRe.FindStringSubmatch(stmt)[1])
+		im.Lock = "ACCESS EXCLUSIVE"
+		if mysql {
+			im.Lock = "metadata (exclusive)"
+		}
+		switch {
+		case strings.HasPrefix(upper, "DROP TABLE"), strings.HasPrefix(upper, "TRUNCATE"):
+			im.Note = "destructive"
+		case strings.Contains(upper, " TYPE ") || strings.Contains(upper, "MODIFY COLUMN") || strings.Contains(upper, "CHANGE COLUMN"):
+			im.Rewrite = true
+			im.Note = "column type change rewrites the table"
+		case strings.Contains(upper, "SET NOT NULL"):
+			im.Rewrite = true
+			im.Note = "full scan to validate NOT NULL"
+		case strings.Contains(upper, "FOREIGN KEY") && !strings.Contains(upper, "NOT VALID"):
+			im.Lock = "SHARE ROW EXCLUSIVE"
+			im.Rewrite = true
+			im.Note = "validates existing rows; consider NOT VALID + VALIDATE"
+		case strings.Contains(upper, "ADD CONSTRAINT") && strings.Contains(upper, "CHECK") && !strings.Contains(upper, "NOT VALID"):
+			im.Rewrite = true
+			im.Note = "full scan to validate CHECK"
+		case strings.Contains(upper, "DROP COLUMN"):
+			im.Note = "destructive"
+		case strings.Contains(upper, "ADD COLUMN") && strings.Contains(upper, "DEFAULT") &&
+			(strings.Contains(upper, "()") || strings.Contains(upper, "RANDOM") || strings.Contains(upper, "NOW")):
+			im.Rewrite = true
+			im.Note = "volatile default rewrites the table"
+		case mysql:
+			im.Rewrite = true
+			im.Note = "may copy the table unless ALGORITHM=INSTANT/INPLACE applies"
+		default:
+			im.Note = "brief lock"
+		}
+	case ddlDMLRe.MatchString(stmt):
+		m := ddlDMLRe.FindStringSubmatch(stmt)
+		im.Table = unquoteIdent(m[1] + m[2] + m[3])
+		im.Lock = "ROW EXCLUSIVE"
+		im.Note = "data change"
+	default:
+		im.Lock = "?"
+	}
+	return im
+}
+
+// analyzeDryRun estimates the impact of every statement in dry-run output. When db is non-nil, row counts
+// of the affected tables are looked up (approximate, from catalog statistics).
+func analyzeDryRun(ctx context.Context, db *sql.DB, driver, out string) []stmtImpact {
+	var impacts []stmtImpact
+	counts := make(map[string]int64)
+	for _, stmt := range dryRunStatements(out) {
+		im := analyzeStatement(driver, stmt)
+		if db != nil && im.Table != "" {
+			n, ok := counts[im.Table]
+			if !ok {
+				n = approxRowCount(ctx, db, driver, im.Table)
+				counts[im.Table] = n
+			}
+			im.Rows = n
+		}
+		impacts = append(impacts, im)
+	}
+	return impacts
+}
+
+// impactRisky reports whether im takes an exclusive lock or rewrites the table.
+func impactRisky(im stmtImpact) bool {
+	return im.Rewrite || strings.Contains(im.Lock, "EXCLUSIVE") || im.Lock == "SHARE"
+}
+
+// renderImpactTable renders impacts as a tview-colored risk table.
+func renderImpactTable(impacts []stmtImpact) string {
+	if len(impacts) == 0 {
+		return "[green]No pending statements.[-]\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[::b]%-3s %-5s %-24s %-22s %-8s %-14s %s[::-]\n", "#", "risk", "table", "lock", "rewrite", "rows", "note")
+	for i, im := range impacts {
+		color, risk := "green", "LOW"
+		if impactRisky(im) {
+			color, risk = "yellow", "MED"
+		}
+		if im.Rewrite && strings.Contains(im.Lock, "EXCLUSIVE") || strings.Contains(im.Note, "destructive") {
+			color, risk = "red", "HIGH"
+		}
+		table := im.Table
+		if table == "" {
+			table = "-"
+		}
+		rewrite := "no"
+		if im.Rewrite {
+			rewrite = "yes"
+		}
+		fmt.Fprintf(&b, "[%s]%-3d %-5s %-24s %-22s %-8s %-14s %s[-]\n", color, i+1, risk, table, im.Lock, rewrite, formatRows(im.Rows), im.Note)
+	}
+	return b.String()
+}
+
+// impactSummary is a one-line summary for the confirmation modal.
+func impactSummary(impacts []stmtImpact) string {
+	exclusive, rewrites := 0, 0
+	for _, im := range impacts {
+		if strings.Contains(im.Lock, "EXCLUSIVE") {
+			exclusive++
+		}
+		if im.Rewrite {
+			rewrites++
+		}
+	}
+	return fmt.Sprintf("%d statements: %d take exclusive locks, %d rewrite/scan tables", len(impacts), exclusive, rewrites)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// urlRe matches http(s) links in command output (e.g. Atlas Cloud lint/apply reports).
+var urlRe = regexp.MustCompile(`https?://[^\s"'<>\[\]()]+`)
+
+// extractURLs returns the distinct URLs in text, in order of first appearance.
+func extractURLs(text string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, u := range urlRe.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:")
+		if !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+	return out
+}
+
+// openBrowser opens url in the platform's default browser without waiting for it.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
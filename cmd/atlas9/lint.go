@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// Lines emitted by `atlas migrate lint` in its default text format, e.g.
+//
+//	-- analyzing version 20240102
+//	  -- destructive changes detected:
+//	    -- L2: Dropping table "users" https://atlasgo.io/lint/analyzers#DS102
+var (
+	lintVersionRe = regexp.MustCompile(`^--\s*analyzing version\s+(\S+)`)
+	lintFindingRe = regexp.MustCompile(`^--\s*L(\d+):\s*(.*)$`)
+	lintSectionRe = regexp.MustCompile(`^--\s*(.+):$`)
+)
+
+// gitBaseAuto as the --git-base value stands for the repository's default branch (see defaultGitBase).
+const gitBaseAuto = "auto"
+
+// defaultGitBase returns the ref branches are compared with for `migrate lint --git-base`: the remote's default
+// branch (origin/HEAD), else the first of origin/main, origin/master, main and master that exists.
+func defaultGitBase(ctx context.Context, workDir string) (string, error) {
+	if ref, err := gitOutput(ctx, workDir, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"); err == nil && ref != "" {
+		return ref, nil
+	}
+	for _, ref := range []string{"origin/main", "origin/master", "main", "master"} {
+		if _, err := gitOutput(ctx, workDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("no default branch found (origin/HEAD, main or master)")
+}
+
+// resolveGitBase replaces --git-base auto in args with defaultGitBase; without a default branch args stay as
+// they are, so atlas reports the unknown ref.
+func resolveGitBase(args []string, base func() (string, error)) []string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "--git-base" && args[i+1] == gitBaseAuto {
+			if ref, err := base(); err == nil {
+				args = append(args[:i+1:i+1], append([]string{ref}, args[i+2:]...)...)
+			}
+		}
+	}
+	return args
+}
+
+// lintFinding is one diagnostic reported by atlas migrate lint.
+type lintFinding struct {
+	File    string // migration file relative to the project (falls back to the version)
+	Line    int
+	Section string // e.g. "destructive changes detected"
+	Message string
+}
+
+// parseLintFindings extracts findings from lint output; migrationsDir (relative to the project) is used to
+// resolve versions to file names.
+func parseLintFindings(out, migrationsDir string, files map[string]string) []lintFinding {
+	var findings []lintFinding
+	scanLintOutput(out, migrationsDir, files, func(_ int, f lintFinding) { findings = append(findings, f) })
+	return findings
+}
+
+// scanLintOutput calls onFinding with the index of each finding's line in out (split on newlines).
+func scanLintOutput(out, migrationsDir string, files map[string]string, onFinding func(i int, f lintFinding)) {
+	file, section := "", ""
+	for i, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if m := lintVersionRe.FindStringSubmatch(trimmed); m != nil {
+			file = m[1]
+			if f, ok := files[m[1]]; ok {
+				file = filepath.Join(migrationsDir, f)
+			}
+			section = ""
+			continue
+		}
+		if m := lintFindingRe.FindStringSubmatch(trimmed); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			onFinding(i, lintFinding{File: file, Line: n, Section: section, Message: m[2]})
+			continue
+		}
+		if m := lintSectionRe.FindStringSubmatch(trimmed); m != nil {
+			section = m[1]
+		}
+	}
+}
+
+// lintCodeRe matches the analyzer code in a finding's documentation link, e.g. "…/analyzers#DS102".
+var lintCodeRe = regexp.MustCompile(`#([A-Z]+[0-9]+)\s*$`)
+
+// Code returns the analyzer code of f (e.g. "DS102"), or "" if its message has no documentation link.
+func (f lintFinding) Code() string {
+	if m := lintCodeRe.FindStringSubmatch(f.Message); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Text returns f's message without its documentation link.
+func (f lintFinding) Text() string {
+	msg := f.Message
+	if i := strings.Index(msg, " https://"); i >= 0 {
+		msg = msg[:i]
+	}
+	return strings.TrimSpace(msg)
+}
+
+// ackKey identifies f across lint runs by file, code and text but not line, which edits above the statement move.
+func (f lintFinding) ackKey() string { return lintAckKey(f.File, f.Code(), f.Text()) }
+
+func lintAckKey(file, code, text string) string { return file + "\x00" + code + "\x00" + text }
+
+// lintAck records that someone reviewed a lint finding and accepted it. Acknowledged findings are dimmed in the
+// Lint output; atlas still reports them (an atlas:nolint directive silences one for atlas too).
+type lintAck struct {
+	File    string    `json:"file"`
+	Code    string    `json:"code"`
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
+
+func lintAcksPath(workDir string) string {
+	return filepath.Join(workDir, ".atlas9", "lint-acks.json")
+}
+
+// loadLintAcks reads the acknowledged findings keyed by lintFinding.ackKey; a missing file is no error.
+func loadLintAcks(path string) (map[string]lintAck, error) {
+	acks := map[string]lintAck{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return acks, nil
+	}
+	if err != nil {
+		return acks, err
+	}
+	var list []lintAck
+	if err := json.Unmarshal(data, &list); err != nil {
+		return acks, err
+	}
+	for _, a := range list {
+		acks[lintAckKey(a.File, a.Code, a.Message)] = a
+	}
+	return acks, nil
+}
+
+func saveLintAcks(path string, acks map[string]lintAck) error {
+	list := make([]lintAck, 0, len(acks))
+	for _, a := range acks {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].File+list[i].Message < list[j].File+list[j].Message })
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// newLintAck acknowledges f now.
+func newLintAck(f lintFinding) lintAck {
+	return lintAck{File: f.File, Code: f.Code(), Message: f.Text(), At: time.Now()}
+}
+
+// dimAcknowledged renders the finding lines of lint output out whose findings are in acks in gray with an
+// "acknowledged" mark (tview tags; out is escaped) and returns how many it dimmed. Findings with a row-count
+// hint (see lintRowHints) get it appended.
+func dimAcknowledged(out, migrationsDir string, files map[string]string, acks map[string]lintAck, hints map[string]string) (string, int) {
+	lines := strings.Split(out, "\n")
+	dimmed := map[int]bool{}
+	hinted := map[int]string{}
+	scanLintOutput(out, migrationsDir, files, func(i int, f lintFinding) {
+		if _, ok := acks[f.ackKey()]; ok {
+			dimmed[i] = true
+		}
+		if h, ok := hints[f.pos()]; ok {
+			hinted[i] = h
+		}
+	})
+	for i, line := range lines {
+		lines[i] = tview.Escape(line)
+		if h, ok := hinted[i]; ok && !dimmed[i] {
+			lines[i] += " [yellow]" + tview.Escape(h) + "[-]"
+		}
+		if dimmed[i] {
+			lines[i] = "[gray]" + lines[i] + " (acknowledged)[-]"
+		}
+	}
+	return strings.Join(lines, "\n"), len(dimmed)
+}
+
+// pos identifies f within one lint run: file and line.
+func (f lintFinding) pos() string { return fmt.Sprintf("%s:%d", f.File, f.Line) }
+
+// lintBigTableRows is the row count from which a row-count hint also suggests a safer way to migrate.
+const lintBigTableRows = 100_000
+
+// lintRowHintKind tells the findings whose cost grows with the table: "not_null" for a column added or made
+// NOT NULL (data_depend MF103/MF104), "index" for an index built without CONCURRENTLY (PG101); else "".
+func lintRowHintKind(f lintFinding) string {
+	switch f.Code() {
+	case "MF103", "MF104":
+		return "not_null"
+	case "PG101":
+		return "index"
+	}
+	text := strings.ToLower(f.Text())
+	switch {
+	case strings.Contains(text, "non-nullable") || strings.Contains(text, "not null"):
+		return "not_null"
+	case strings.Contains(text, "index") && strings.Contains(text, "concurrently"):
+		return "index"
+	}
+	return ""
+}
+
+// lintTableRe reads the table out of a finding message such as `… causes write locks on the "users" table`.
+var lintTableRe = regexp.MustCompile(`(?:table "([^"]+)"|"([^"]+)" table)`)
+
+// lintFindingTable returns the table f is about: from the statement at f's line of its migration file, else
+// from the message.
+func lintFindingTable(workDir string, f lintFinding) string {
+	if data, err := os.ReadFile(filepath.Join(workDir, f.File)); err == nil {
+		lines := strings.Split(string(data), "\n")
+		if f.Line >= 1 && f.Line <= len(lines) {
+			stmt := strings.Join(lines[f.Line-1:], "\n")
+			if i := strings.Index(stmt, ";"); i >= 0 {
+				stmt = stmt[:i]
+			}
+			if t := analyzeStatement("", strings.TrimSpace(stmt)).Table; t != "" {
+				return t
+			}
+		}
+	}
+	if m := lintTableRe.FindStringSubmatch(f.Text()); m != nil {
+		return m[1] + m[2]
+	}
+	return ""
+}
+
+// lintRowHints looks up on db the approximate row count of the table of each finding lintRowHintKind knows and
+// returns hints keyed by lintFinding.pos, e.g. "≈4.2M rows — consider CONCURRENTLY".
+func lintRowHints(ctx context.Context, db *sql.DB, driver, workDir string, findings []lintFinding) map[string]string {
+	hints := map[string]string{}
+	counts := map[string]int64{}
+	for _, f := range findings {
+		kind := lintRowHintKind(f)
+		if kind == "" {
+			continue
+		}
+		table := lintFindingTable(workDir, f)
+		if table == "" {
+			continue
+		}
+		n, ok := counts[table]
+		if !ok {
+			n = approxRowCount(ctx, db, driver, table)
+			counts[table] = n
+		}
+		if n < 0 {
+			continue
+		}
+		hint := formatRows(n)
+		if n >= lintBigTableRows {
+			switch {
+			case kind == "index" && driver == "postgres":
+				hint += " — consider CONCURRENTLY"
+			case kind == "index":
+				hint += " — consider ALGORITHM=INPLACE, LOCK=NONE"
+			default:
+				hint += " — backfill first"
+			}
+		}
+		hints[f.pos()] = hint
+	}
+	return hints
+}
+
+// addNolintDirective inserts "-- atlas:nolint <code>" above line (1-based) of the migration file at path, with
+// the statement's indentation, so atlas migrate lint skips that check for the statement.
+func addNolintDirective(path string, line int, code string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	if line < 1 || line > len(lines) {
+		return fmt.Errorf("%s has no line %d", path, line)
+	}
+	stmt := lines[line-1]
+	indent := stmt[:len(stmt)-len(strings.TrimLeft(stmt, " \t"))]
+	directive := strings.TrimSpace("-- atlas:nolint " + code)
+	if line >= 2 && strings.TrimSpace(lines[line-2]) == directive {
+		return nil // already there
+	}
+	lines = append(lines[:line-1], append([]string{indent + directive}, lines[line-1:]...)...)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// lintAnalyzers are the atlas migrate lint analyzers the lint rules panel configures, in display order.
+var lintAnalyzers = []struct{ Name, Desc string }{
+	{"destructive", "destructive changes (drop schema, table or column)"},
+	{"data_depend", "changes that depend on existing data (e.g. a new unique index)"},
+	{"incompatible", "backward-incompatible changes (renames)"},
+	{"concurrent_index", "indexes created without CONCURRENTLY (PostgreSQL)"},
+	{"naming", "object names not matching the naming pattern"},
+}
+
+// lintSeverities are the choices for an analyzer's error attribute: "default" leaves it out (atlas decides),
+// "warning" is error = false, "error" is error = true (lint fails).
+var lintSeverities = []string{"default", "warning", "error"}
+
+// lintRules is the part of an atlas.hcl lint block the panel edits.
+type lintRules struct {
+	Severity      map[string]string // analyzer name → lintSeverities value
+	NamingMatch   string            // naming { match = "..." }: regexp object names must match
+	NamingMessage string            // naming { message = "..." }
+}
+
+// lintBlock returns the lint block env's settings come from: the env block's own, else the top-level one.
+// With create, a missing top-level block is appended. scope describes where the block is (or would be).
+func lintBlock(f *hclwrite.File, env string, create bool) (block *hclwrite.Block, scope string) {
+	if eb := f.Body().FirstMatchingBlock("env", []string{env}); eb != nil {
+		if lb := eb.Body().FirstMatchingBlock("lint", nil); lb != nil {
+			return lb, fmt.Sprintf("env %q", env)
+		}
+	}
+	lb := f.Body().FirstMatchingBlock("lint", nil)
+	if lb == nil && create {
+		f.Body().AppendNewline()
+		lb = f.Body().AppendNewBlock("lint", nil)
+	}
+	return lb, "top level (all envs)"
+}
+
+// attrString evaluates a literal attribute (string or bool) to its string form; "" if absent or not literal.
+func attrString(body *hclwrite.Body, name string) string {
+	attr := body.GetAttribute(name)
+	if attr == nil {
+		return ""
+	}
+	expr, diags := hclsyntax.ParseExpression(attr.Expr().BuildTokens(nil).Bytes(), "atlas.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return ""
+	}
+	v, diags := expr.Value(nil)
+	switch {
+	case diags.HasErrors() || v.IsNull():
+		return ""
+	case v.Type() == cty.String:
+		return v.AsString()
+	case v.Type() == cty.Bool:
+		return fmt.Sprint(v.True())
+	}
+	return ""
+}
+
+// readLintRules reads the lint rules that apply to env from atlas.hcl source.
+func readLintRules(src []byte, env string) (lintRules, string, error) {
+	f, diags := hclwrite.ParseConfig(src, "atlas.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return lintRules{}, "", fmt.Errorf("atlas.hcl does not parse: %v", diags)
+	}
+	rules := lintRules{Severity: map[string]string{}}
+	lb, scope := lintBlock(f, env, false)
+	for _, a := range lintAnalyzers {
+		rules.Severity[a.Name] = "default"
+		if lb == nil {
+			continue
+		}
+		ab := lb.Body().FirstMatchingBlock(a.Name, nil)
+		if ab == nil {
+			continue
+		}
+		switch attrString(ab.Body(), "error") {
+		case "true":
+			rules.Severity[a.Name] = "error"
+		case "false":
+			rules.Severity[a.Name] = "warning"
+		}
+		if a.Name == "naming" {
+			rules.NamingMatch = attrString(ab.Body(), "match")
+			rules.NamingMessage = attrString(ab.Body(), "message")
+		}
+	}
+	return rules, scope, nil
+}
+
+// writeLintRules returns src with rules written into the lint block that applies to env (see lintBlock).
+// Other attributes and blocks, comments and formatting are kept; analyzer blocks left empty are removed.
+func writeLintRules(src []byte, env string, rules lintRules) ([]byte, error) {
+	f, diags := hclwrite.ParseConfig(src, "atlas.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("atlas.hcl does not parse: %v", diags)
+	}
+	lb, _ := lintBlock(f, env, true)
+	for _, a := range lintAnalyzers {
+		ab := lb.Body().FirstMatchingBlock(a.Name, nil)
+		if ab == nil {
+			ab = lb.Body().AppendNewBlock(a.Name, nil)
+		}
+		body := ab.Body()
+		switch rules.Severity[a.Name] {
+		case "error":
+			body.SetAttributeValue("error", cty.True)
+		case "warning":
+			body.SetAttributeValue("error", cty.False)
+		default:
+			body.RemoveAttribute("error")
+		}
+		if a.Name == "naming" {
+			for _, attr := range [][2]string{{"match", rules.NamingMatch}, {"message", rules.NamingMessage}} {
+				if v := strings.TrimSpace(attr[1]); v != "" {
+					body.SetAttributeValue(attr[0], cty.StringVal(v))
+				} else {
+					body.RemoveAttribute(attr[0])
+				}
+			}
+		}
+		if len(body.Attributes()) == 0 && len(body.Blocks()) == 0 {
+			lb.Body().RemoveBlock(ab)
+		}
+	}
+	out := f.Bytes()
+	if _, diags := hclsyntax.ParseConfig(out, "atlas.hcl", hcl.InitialPos); diags.HasErrors() {
+		return nil, fmt.Errorf("updated atlas.hcl does not parse: %v", diags)
+	}
+	return out, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// macroKeys maps the names of special keys in a macro (tcell's names: Enter, Down, Ctrl-X) to the keys.
+var macroKeys = func() map[string]tcell.Key {
+	m := make(map[string]tcell.Key, len(tcell.KeyNames))
+	for k, name := range tcell.KeyNames {
+		m[name] = k
+	}
+	return m
+}()
+
+// macroMods are the modifier prefixes of a key name in a macro, e.g. <Alt-x>, <Ctrl-Left>, <Shift-Tab>.
+var macroMods = []struct {
+	prefix string
+	mod    tcell.ModMask
+}{{"Ctrl-", tcell.ModCtrl}, {"Alt-", tcell.ModAlt}, {"Shift-", tcell.ModShift}}
+
+// encodeMacro writes keys as the macro string saved in the config: runes as typed, other keys by name in angle
+// brackets (<Enter>, <Down>, <Ctrl-X>) and '<' as <lt>, e.g. "e<Down><Enter> 1 4". Modifiers are written as
+// prefixes, e.g. <Alt-x> or <Ctrl-Right>; Shift on a rune is its case.
+func encodeMacro(keys []*tcell.EventKey) string {
+	var b strings.Builder
+	for _, ev := range keys {
+		mods := ev.Modifiers()
+		name := ""
+		if ev.Key() == tcell.KeyRune {
+			mods &^= tcell.ModShift
+			if mods == 0 && ev.Rune() != '<' {
+				b.WriteRune(ev.Rune())
+				continue
+			}
+			switch name = string(ev.Rune()); name {
+			case "<":
+				name = "lt"
+			case ">":
+				name = "gt"
+			}
+		} else {
+			var ok bool
+			if name, ok = tcell.KeyNames[ev.Key()]; !ok {
+				continue
+			}
+			if strings.HasPrefix(name, "Ctrl-") {
+				mods &^= tcell.ModCtrl // part of the key (Ctrl-A … Ctrl-Z)
+			}
+		}
+		b.WriteString("<")
+		for _, m := range macroMods {
+			if mods&m.mod != 0 {
+				b.WriteString(m.prefix)
+			}
+		}
+		b.WriteString(name + ">")
+	}
+	return b.String()
+}
+
+// parseMacro reads a macro string written by encodeMacro into the key events to replay.
+func parseMacro(s string) ([]*tcell.EventKey, error) {
+	var keys []*tcell.EventKey
+	for len(s) > 0 {
+		if s[0] != '<' {
+			r := []rune(s)[0]
+			keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+			s = s[len(string(r)):]
+			continue
+		}
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated key name %q (write < as <lt>)", s)
+		}
+		name := s[1:end]
+		s = s[end+1:]
+		ev, err := parseMacroKey(name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, ev)
+	}
+	return keys, nil
+}
+
+// parseMacroKey reads one key name of a macro (without the angle brackets), modifier prefixes included.
+func parseMacroKey(name string) (*tcell.EventKey, error) {
+	mods, rest := tcell.ModNone, name
+	for {
+		if k, ok := macroKeys[rest]; ok {
+			if strings.HasPrefix(rest, "Ctrl-") {
+				mods |= tcell.ModCtrl
+			}
+			return tcell.NewEventKey(k, 0, mods), nil
+		}
+		switch rest {
+		case "lt":
+			return tcell.NewEventKey(tcell.KeyRune, '<', mods), nil
+		case "gt":
+			return tcell.NewEventKey(tcell.KeyRune, '>', mods), nil
+		}
+		if r := []rune(rest); len(r) == 1 && mods != 0 {
+			return tcell.NewEventKey(tcell.KeyRune, r[0], mods), nil
+		}
+		peeled := false
+		for _, m := range macroMods {
+			if after, ok := strings.CutPrefix(rest, m.prefix); ok {
+				mods, rest, peeled = mods|m.mod, after, true
+				break
+			}
+		}
+		if !peeled {
+			return nil, fmt.Errorf("unknown key <%s>", name)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestMacroEncoding(t *testing.T) {
+	keys := []*tcell.EventKey{
+		tcell.NewEventKey(tcell.KeyRune, 'e', tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyRune, '<', tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt),
+		tcell.NewEventKey(tcell.KeyRune, '>', tcell.ModAlt),
+		tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone),
+		tcell.NewEventKey(tcell.KeyRight, 0, tcell.ModCtrl),
+		tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModShift|tcell.ModAlt),
+		tcell.NewEventKey(tcell.KeyCtrlU, 0, tcell.ModCtrl),
+	}
+	const want = "e<lt><Alt-x><Alt-gt><Down><Ctrl-Right><Alt-Shift-Left><Ctrl-U>"
+	got := encodeMacro(keys)
+	if got != want {
+		t.Fatalf("encodeMacro = %q, want %q", got, want)
+	}
+	parsed, err := parseMacro(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(keys) {
+		t.Fatalf("parseMacro(%q): %d keys, want %d", got, len(parsed), len(keys))
+	}
+	for i, ev := range parsed {
+		if ev.Key() != keys[i].Key() || ev.Rune() != keys[i].Rune() || ev.Modifiers() != keys[i].Modifiers() {
+			t.Errorf("key %d: got %s, want %s", i, ev.Name(), keys[i].Name())
+		}
+	}
+	if _, err := parseMacro("<Hyper-x>"); err == nil {
+		t.Error("parseMacro(<Hyper-x>): want an error")
+	}
+}
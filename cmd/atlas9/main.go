@@ -5,36 +5,47 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/alecthomas/chroma/v2/formatters"
-	"github.com/fsnotify/fsnotify"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/docopt/docopt-go"
+	"github.com/fsnotify/fsnotify"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+
+	"atlas9/internal/config"
+	"atlas9/internal/i18n"
 )
 
-// overlayRoot draws content full-screen and optionally an overlay primitive (e.g. modal) on top.
+// overlayRoot draws content full-screen and optionally an overlay primitive (e.g. modal) on top, then toasts.
 // overlay is a pointer so it can be set to nil when the modal closes.
 type overlayRoot struct {
 	*tview.Box
 	content tview.Primitive
 	overlay *tview.Primitive
+	toasts  *toastStack
 }
 
-func newOverlayRoot(content tview.Primitive, overlay *tview.Primitive) *overlayRoot {
+func newOverlayRoot(content tview.Primitive, overlay *tview.Primitive, toasts *toastStack) *overlayRoot {
 	return &overlayRoot{
 		Box:     tview.NewBox(),
 		content: content,
 		overlay: overlay,
+		toasts:  toasts,
 	}
 }
 
@@ -48,6 +59,9 @@ func (o *overlayRoot) Draw(screen tcell.Screen) {
 	if o.overlay != nil && *o.overlay != nil {
 		(*o.overlay).Draw(screen)
 	}
+	if o.toasts != nil {
+		o.toasts.Draw(screen)
+	}
 }
 
 func (o *overlayRoot) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
@@ -64,6 +78,20 @@ func (o *overlayRoot) InputHandler() func(event *tcell.EventKey, setFocus func(p
 	}
 }
 
+func (o *overlayRoot) PasteHandler() func(pastedText string, setFocus func(p tview.Primitive)) {
+	return func(pastedText string, setFocus func(p tview.Primitive)) {
+		if o.overlay != nil && *o.overlay != nil {
+			if h := (*o.overlay).PasteHandler(); h != nil {
+				h(pastedText, setFocus)
+				return
+			}
+		}
+		if h := o.content.PasteHandler(); h != nil {
+			h(pastedText, setFocus)
+		}
+	}
+}
+
 func (o *overlayRoot) Focus(delegate func(p tview.Primitive)) {
 	if o.overlay != nil && *o.overlay != nil {
 		delegate(*o.overlay)
@@ -88,11 +116,52 @@ const usageDoc = `atlas9 — TUI for Atlas workflow.
 
 Usage:
   atlas9 [options]
+  atlas9 run [<stage>...] [options]
+  atlas9 generate ci [options]
+  atlas9 tour
+  atlas9 self-update
+
+Commands:
+  run                 Run stages headless (no TUI) and exit; stages: status diff lint dry-run apply seed
+                      and custom stages (default: status lint dry-run).
+  generate ci         Write a GitHub Actions workflow (or with --gitlab a GitLab CI job) that runs run
+                      lint dry-run on pull requests for the atlas.hcl envs with URLs from variables.
+  tour                Walk through the stages on a throwaway SQLite project (no atlas.hcl needed).
+  self-update         Replace this binary with the latest GitHub release (checksum verified).
 
 Options:
   -h, --help          Show this help.
   -v, --version       Show version.
-  -e, --env <env>     Override environment (default: from .env ENVIRONMENT or local)`
+  -e, --env <env>     Override environment (default: from .env ENVIRONMENT or local)
+  --ascii             Draw only ASCII (default with a non-UTF-8 locale).
+  --no-color          No colors, syntax highlighting or emoji (also set by the NO_COLOR env var).
+  --no-connect        Never connect to databases directly (skips row counts in the Apply impact table).
+  -y, --yes           With run: approve apply without prompting.
+  --auto-approve <n>  Apply without confirmation when the plan has at most <n> statements and no
+                      destructive operations (TUI and run; -1 = never, the default).
+  --github-summary    With run: write a Markdown summary to $GITHUB_STEP_SUMMARY and emit
+                      ::error annotations for lint failures.
+  --refresh <sec>     Re-run Status every <sec> seconds while the Status stage is selected
+                      (0 = off, the default).
+  --note <text>       With run: note (ticket, change reason) recorded with the apply.
+  --git-base <ref>    With run: lint only the migrations added since git <ref> (auto: the default
+                      branch, e.g. origin/main), like atlas migrate lint in CI.
+  --metrics-file <path>  With run: merge stage durations, results, apply counts and pending migrations
+                      into a Prometheus textfile (for node_exporter's textfile collector).
+  --quiet             With run: print one line per stage instead of the atlas output.
+  --log-file <path>   With run: append the full transcript (commands and output) to <path>.
+  --statsd <addr>     With run: send the same metrics to a StatsD server (host:port, UDP).
+  --notify-after <sec>  Send a desktop notification when a command runs longer than <sec> seconds
+                      and no key was pressed meanwhile (0 = off; default 30).
+  --fresh             Start without restoring the project's last session (.atlas9/session.json).
+  --record <file>     Record the session: an asciicast (asciinema play) when <file> ends in .cast,
+                      else a transcript of the keys pressed and every atlas command with its output.
+  --gitlab            With generate ci: write .gitlab/atlas9.gitlab-ci.yml instead of the workflow.
+  --pre-commit        With generate ci: also write .pre-commit-config.yaml (migrate hash and lint).
+  --force             With generate ci: overwrite existing files.
+
+Preferences are read from ~/.config/atlas9/config.toml and the project's .atlas9.toml;
+options above override them.`
 
 // High ASCII block-art "atlas9" (4 lines) + tagline.
 const logoAtlas9 = `   ▐  ▜       ▞▀▖
@@ -101,14 +170,21 @@ const logoAtlas9 = `   ▐  ▜       ▞▀▖
 ▝▀▘ ▀  ▘▝▀▘▀▀ ▝▀ 
 manage your database schema as code...`
 
-var stages = []string{"Status", "Diff", "Lint", "Dry-Run", "Apply"}
-var stageDescriptions = []string{
-	"Show applied vs pending",
-	"Generate migration file",
-	"Hash + safety checks",
-	"Preview pending SQL",
-	"Apply pending changes",
-}
+// statusRecheckInterval is how often the docker and Atlas Cloud login checks re-run in the background.
+const statusRecheckInterval = 30 * time.Second
+
+// spinnerFrames animate background checks in the top-right panel.
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// skeletonLines stand in for the output until the startup Status run starts (after the first frame).
+var skeletonLines = []int{24, 0, 40, 32, 36}
+
+// stageIDs name the stages in order; their display names and descriptions are the "stage" and "stage_desc"
+// catalog entries.
+var stageIDs = []string{"status", "diff", "lint", "dry-run", "apply"}
+
+// stageDigits mark each stage in the strip with the number key that jumps to it.
+var stageDigits = []rune("¹²³⁴⁵⁶⁷⁸⁹")
 
 // parseEnvFile reads a .env file (KEY=VALUE per line) and returns a map. Returns nil map on error (e.g. file not found).
 func parseEnvFile(path string) (map[string]string, error) {
@@ -141,17 +217,57 @@ func parseEnvFile(path string) (map[string]string, error) {
 	return out, nil
 }
 
-// loadEnv reads path as .env and updates overrides (guarded by envMu). Call from watcher or once at start of watcher goroutine.
-func loadEnv(path string, overrides map[string]string, envMu *sync.Mutex) {
-	parsed, _ := parseEnvFile(path)
-	envMu.Lock()
-	defer envMu.Unlock()
-	for k := range overrides {
-		delete(overrides, k)
+// mergeEnviron returns a copy of base (KEY=VALUE entries, e.g. os.Environ()) with overrides applied.
+func mergeEnviron(base []string, overrides map[string]string) []string {
+	out := make([]string, len(base))
+	copy(out, base)
+	for k, v := range overrides {
+		kv := k + "=" + v
+		found := false
+		for i, e := range out {
+			if strings.HasPrefix(e, k+"=") {
+				out[i] = kv
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, kv)
+		}
+	}
+	return out
+}
+
+// resolveEnvName picks the atlas env: --env flag, then ENVIRONMENT (from .env overlay or process), then the
+// project's last successfully used env (lastEnv, see loadLastEnv), then the configured default env, then "local".
+func resolveEnvName(flag, lastEnv, defaultEnv string, getEnv func(string) string) string {
+	if flag != "" {
+		return flag
+	}
+	if v := getEnv("ENVIRONMENT"); v != "" {
+		return v
+	}
+	if lastEnv != "" {
+		return lastEnv
+	}
+	if defaultEnv != "" {
+		return defaultEnv
+	}
+	return "local"
+}
+
+// secondsFlag reads an option given in whole seconds, returning fallback when it is not set.
+func secondsFlag(opts docopt.Opts, name string, fallback time.Duration) time.Duration {
+	v, _ := opts.String(name)
+	if v == "" {
+		return fallback
 	}
-	for k, v := range parsed {
-		overrides[k] = v
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		fmt.Fprintf(os.Stderr, "%s: %q is not a number of seconds\n", name, v)
+		os.Exit(1)
 	}
+	return time.Duration(n) * time.Second
 }
 
 // parseAtlasHCLEnvs reads atlas.hcl and returns the names of env blocks (e.g. ["localdev", "dev", "prod"]).
@@ -179,72 +295,93 @@ func parseAtlasHCLEnvs(path string) []string {
 	return names
 }
 
-// parseDiffSummary parses SQL diff output and returns a git-like summary.
-// Returns lines like "+++ users (CREATE TABLE)" or "--- old_table (DROP TABLE)" or "~~~ posts (ALTER TABLE)"
-func parseDiffSummary(sql string) string {
-	var lines []string
-	var creates, drops, alters []string
-
-	for _, line := range strings.Split(sql, "\n") {
-		trimmed := strings.TrimSpace(line)
-		upper := strings.ToUpper(trimmed)
+// atlasHCLEnvBlock returns the body of env "name" { ... } in src (without the outer braces), or "" if absent.
+func atlasHCLEnvBlock(src, name string) string {
+	return hclBlock(src, `env "`+name+`"`)
+}
 
-		// CREATE TABLE
-		if strings.HasPrefix(upper, "CREATE TABLE") {
-			// Extract table name: CREATE TABLE "tablename" or CREATE TABLE tablename
-			parts := strings.Fields(trimmed)
-			if len(parts) >= 3 {
-				tableName := strings.Trim(parts[2], "\"(`")
-				creates = append(creates, tableName)
+// hclBlock returns the body of the first block of src starting with header (e.g. `variable "url"`), without its
+// braces ("" if absent).
+func hclBlock(src, header string) string {
+	i := strings.Index(src, header)
+	if i < 0 {
+		return ""
+	}
+	open := strings.Index(src[i:], "{")
+	if open < 0 {
+		return ""
+	}
+	start := i + open + 1
+	depth := 1
+	for j := start; j < len(src); j++ {
+		switch src[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return src[start:j]
 			}
 		}
-		// DROP TABLE
-		if strings.HasPrefix(upper, "DROP TABLE") {
-			parts := strings.Fields(trimmed)
-			if len(parts) >= 3 {
-				tableName := strings.Trim(parts[2], "\"(`")
-				drops = append(drops, tableName)
+	}
+	return ""
+}
+
+// parseAtlasHCLMigrationDir returns the local migration directory for env (from `dir = "file://..."`),
+// falling back to any dir in the file and then to atlas's default "migrations".
+func parseAtlasHCLMigrationDir(path, env string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "migrations"
+	}
+	for _, s := range []string{atlasHCLEnvBlock(string(data), env), string(data)} {
+		for _, line := range strings.Split(s, "\n") {
+			k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+			if !ok || strings.TrimSpace(k) != "dir" {
+				continue
 			}
-		}
-		// ALTER TABLE
-		if strings.HasPrefix(upper, "ALTER TABLE") {
-			parts := strings.Fields(trimmed)
-			if len(parts) >= 3 {
-				tableName := strings.Trim(parts[2], "\"(`")
-				// Avoid duplicates
-				found := false
-				for _, t := range alters {
-					if t == tableName {
-						found = true
-						break
-					}
-				}
-				if !found {
-					alters = append(alters, tableName)
-				}
+			v = strings.Trim(strings.TrimSpace(v), `"`)
+			if strings.HasPrefix(v, "file://") {
+				return strings.TrimPrefix(v, "file://")
 			}
 		}
 	}
+	return "migrations"
+}
 
-	// Build summary
-	for _, t := range creates {
-		lines = append(lines, fmt.Sprintf("[green]+++ %s[-]  (CREATE TABLE)", t))
-	}
-	for _, t := range alters {
-		lines = append(lines, fmt.Sprintf("[yellow]~~~ %s[-]  (ALTER TABLE)", t))
+// parseAtlasHCLSrcPaths returns the local files referenced by env's `src` attribute (file:// URLs, as a string or list).
+func parseAtlasHCLSrcPaths(path, env string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
 	}
-	for _, t := range drops {
-		lines = append(lines, fmt.Sprintf("[red]--- %s[-]  (DROP TABLE)", t))
+	var out []string
+	expr := strings.Trim(hclAttr(atlasHCLEnvBlock(string(data), env), "src"), "[]")
+	for _, part := range strings.Split(expr, ",") {
+		v := strings.Trim(strings.TrimSpace(part), `"`)
+		if strings.HasPrefix(v, "file://") {
+			out = append(out, strings.TrimPrefix(v, "file://"))
+		}
 	}
+	return out
+}
 
-	if len(lines) == 0 {
-		return "[green]No schema changes detected.[-]"
+// envColor is the tview color used to show an env name: red for production, yellow for staging, else green.
+func envColor(env string) string {
+	switch env = strings.ToLower(env); {
+	case slices.Contains(config.ProductionEnvs, env):
+		return "red"
+	case env == "staging" || env == "stage" || env == "preprod" || env == "uat":
+		return "yellow"
+	default:
+		return "green"
 	}
-
-	return strings.Join(lines, "\n")
 }
 
 func highlightWithLexer(lexerName, text string) string {
+	if noColor {
+		return text
+	}
 	lexer := lexers.Get(lexerName)
 	if lexer == nil {
 		lexer = lexers.Fallback
@@ -268,8 +405,14 @@ func highlightWithLexer(lexerName, text string) string {
 	return buf.String()
 }
 
-// highlightSQL returns SQL with ANSI color codes for terminal display.
-func highlightSQL(sql string) string {
+// sqlLexers maps drivers to the chroma lexer for their dialect; other drivers (SQLite, ...) get generic SQL.
+var sqlLexers = map[string]string{"postgres": "postgresql", "redshift": "postgresql", "mysql": "mysql", "clickhouse": "mysql", "sqlserver": "tsql"}
+
+// highlightSQL returns SQL with ANSI color codes for terminal display, in driver's dialect (see envDriver).
+func highlightSQL(driver, sql string) string {
+	if lexer, ok := sqlLexers[driver]; ok {
+		return highlightWithLexer(lexer, sql)
+	}
 	return highlightWithLexer("sql", sql)
 }
 
@@ -278,6 +421,11 @@ func highlightHCL(hcl string) string {
 	return highlightWithLexer("hcl", hcl)
 }
 
+// highlightJSON returns JSON (atlas --format output) with ANSI color codes for terminal display.
+func highlightJSON(js string) string {
+	return highlightWithLexer("json", js)
+}
+
 // visiblePosition returns the index in highlighted (which may contain ANSI codes) where
 // the nth visible character (0-based) appears. Used to insert a cursor marker.
 func visiblePosition(highlighted string, n int) int {
@@ -315,6 +463,7 @@ func main() {
 	workDir, _ := os.Getwd()
 	envPath := filepath.Join(workDir, ".env")
 	atlasHCL := filepath.Join(workDir, "atlas.hcl")
+	runner := sdkRunner{dir: workDir, next: execRunner{dir: workDir}}
 
 	opts, err := docopt.ParseArgs(usageDoc, os.Args[1:], version)
 	if err != nil {
@@ -329,28 +478,296 @@ func main() {
 		fmt.Println(usageDoc)
 		os.Exit(0)
 	}
+	if ok, _ := opts.Bool("--ascii"); ok {
+		asciiMode = true
+	}
+	if ok, _ := opts.Bool("--no-color"); ok {
+		noColor = true
+		os.Setenv("NO_COLOR", "1") // tcell (and atlas) honor it
+	}
+	if ok, _ := opts.Bool("self-update"); ok {
+		if err := selfUpdate(context.Background(), os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "self-update: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	autoApprove := -2 // unset: the preferences decide
+	if v, _ := opts.String("--auto-approve"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--auto-approve: %q is not a number\n", v)
+			os.Exit(1)
+		}
+		autoApprove = n
+	}
+	policyFor := func(conf config.Config) confirmPolicy {
+		p := confirmPolicy{AutoApproveMax: conf.Confirm.AutoApproveMax, Protected: conf.ProtectedEnvs}
+		if autoApprove != -2 {
+			p.AutoApproveMax = autoApprove
+		}
+		return p
+	}
+	conf, confErr := config.Load(workDir)
+	runner.connectTimeout = conf.Timeouts.Connect.Duration
+	policy := policyFor(conf)
+
+	if ok, _ := opts.Bool("run"); ok {
+		if confErr != nil {
+			fmt.Fprintf(os.Stderr, "config: %v\n", confErr)
+			os.Exit(1)
+		}
+		parsed, _ := parseEnvFile(envPath)
+		getEnv := func(key string) string {
+			if v, ok := parsed[key]; ok {
+				return v
+			}
+			return os.Getenv(key)
+		}
+		envFlag, _ := opts.String("--env")
+		stages, _ := opts["<stage>"].([]string)
+		githubSummary, _ := opts.Bool("--github-summary")
+		yes, _ := opts.Bool("--yes")
+		note, _ := opts.String("--note")
+		gitBase, _ := opts.String("--git-base")
+		metricsFile, _ := opts.String("--metrics-file")
+		statsd, _ := opts.String("--statsd")
+		quiet, _ := opts.Bool("--quiet")
+		logFile, _ := opts.String("--log-file")
+		os.Exit(runHeadless(headlessOptions{
+			workDir:       workDir,
+			atlasHCL:      atlasHCL,
+			env:           resolveEnvName(envFlag, "", conf.DefaultEnv, getEnv), // never the remembered env: CI must be explicit
+			environ:       mergeEnviron(os.Environ(), parsed),
+			stages:        stages,
+			githubSummary: githubSummary,
+			yes:           yes,
+			policy:        policy,
+			hooks:         conf.Hooks,
+			verify:        conf.Verify,
+			timeout:       conf.Timeouts.Connect.Duration,
+			getEnv:        getEnv,
+			seed:          conf.Seed,
+			customStages:  conf.Stages,
+			formats:       conf.Formats,
+			defaultFlags:  conf.DefaultFlags,
+			note:          note,
+			gitBase:       gitBase,
+			metricsFile:   metricsFile,
+			statsd:        statsd,
+			quiet:         quiet,
+			logFile:       logFile,
+			runner:        runner,
+			stdout:        os.Stdout,
+		}))
+	}
+
+	if ok, _ := opts.Bool("generate"); ok {
+		parsed, _ := parseEnvFile(envPath)
+		getEnv := func(key string) string {
+			if v, ok := parsed[key]; ok {
+				return v
+			}
+			return os.Getenv(key)
+		}
+		envFlag, _ := opts.String("--env")
+		gitlab, _ := opts.Bool("--gitlab")
+		preCommit, _ := opts.Bool("--pre-commit")
+		force, _ := opts.Bool("--force")
+		os.Exit(runGenerateCI(generateOptions{
+			workDir:   workDir,
+			atlasHCL:  atlasHCL,
+			envFlag:   envFlag,
+			localEnv:  resolveEnvName("", "", conf.DefaultEnv, getEnv),
+			gitlab:    gitlab,
+			preCommit: preCommit,
+			force:     force,
+			getEnv:    getEnv,
+			stdout:    os.Stdout,
+		}))
+	}
+
+	envFlag, _ := opts.String("--env")
+	noConnect, _ := opts.Bool("--no-connect")
+	fresh, _ := opts.Bool("--fresh")
+	var recorder sessionRecorder
+	if path, _ := opts.String("--record"); path != "" {
+		if recorder, err = newSessionRecorder(path); err != nil {
+			fmt.Fprintf(os.Stderr, "--record: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	exit := func(code int) {
+		if recorder != nil {
+			if err := recorder.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "--record: %v\n", err)
+			}
+		}
+		os.Exit(code)
+	}
+	tuiFor := func(dir string, conf config.Config, confErr error) tuiConfig {
+		r := sdkRunner{dir: dir, next: execRunner{dir: dir}, connectTimeout: conf.Timeouts.Connect.Duration}
+		return tuiConfig{
+			workDir:     dir,
+			envFlag:     envFlag,
+			noConnect:   noConnect,
+			fresh:       fresh,
+			policy:      policyFor(conf),
+			runner:      r,
+			refresh:     secondsFlag(opts, "--refresh", conf.Timeouts.Refresh.Duration),
+			notifyAfter: secondsFlag(opts, "--notify-after", conf.Timeouts.NotifyAfter.Duration),
+			conf:        conf,
+			confErr:     confErr,
+			projectRunner: func(dir string) commandRunner {
+				return sdkRunner{dir: dir, next: execRunner{dir: dir}, connectTimeout: conf.Timeouts.Connect.Duration}
+			},
+			recorder: recorder,
+		}
+	}
+	if ok, _ := opts.Bool("tour"); ok {
+		dir, err := writeTourProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "tour: %v\n", err)
+			exit(1)
+		}
+		conf, confErr := config.Load(dir)
+		cfg := tuiFor(dir, conf, confErr)
+		cfg.envFlag, cfg.fresh, cfg.tour = "local", true, true
+		err = runTUI(cfg)
+		os.RemoveAll(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		exit(0)
+	}
+	ws, err := config.FindWorkspace(workDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "workspace: %v\n", err)
+		exit(1)
+	}
+	current, inProject := 0, false
+	if ws != nil {
+		current, inProject = ws.ProjectAt(workDir)
+	}
+	// Outside the workspace's projects atlas9 runs where it was started, except at the workspace root when that
+	// is no project of its own: then it opens the first one.
+	_, hclErr := os.Stat(atlasHCL)
+	if ws == nil || !inProject && (workDir != ws.Dir || hclErr == nil) {
+		if err := runTUI(tuiFor(workDir, conf, confErr)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		exit(0)
+	}
+	// Workspace: run the TUI for one project at a time; switching projects returns from runTUI with Next set.
+	session := &workspaceSession{Workspace: ws, Current: current, States: map[int]projectState{}}
+	first := session.Current
+	for session.Current >= 0 {
+		dir := ws.ProjectDir(session.Current)
+		conf, confErr := config.Load(dir)
+		cfg := tuiFor(dir, conf, confErr)
+		if session.Current != first {
+			cfg.envFlag = "" // --env only applies to the project atlas9 started in
+		}
+		cfg.workspace = session
+		session.Next = -1
+		if err := runTUI(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		session.Current = session.Next
+	}
+	exit(0)
+}
+
+// tuiConfig configures runTUI.
+type tuiConfig struct {
+	workDir     string
+	envFlag     string // --env
+	noConnect   bool   // --no-connect
+	fresh       bool   // --fresh: do not restore the last session
+	policy      confirmPolicy
+	runner      commandRunner
+	refresh     time.Duration                // Status auto-refresh interval; 0 disables it
+	notifyAfter time.Duration                // desktop notification for commands running at least this long; 0 disables it
+	conf        config.Config                // keymap, timeouts, protected envs, hooks (flags already applied above)
+	confErr     error                        // config problems, shown instead of the first Status run
+	screen      tcell.Screen                 // nil uses the terminal; tests pass a tcell.SimulationScreen
+	started     func(app *tview.Application) // called before the event loop starts, e.g. so tests can Stop it
+	// workspace is set when atlas9 runs in a multi-project workspace (config.WorkspaceFile); nil otherwise.
+	workspace *workspaceSession
+	// projectRunner runs atlas in another workspace project's directory (workspace status); nil uses runner.
+	projectRunner func(dir string) commandRunner
+	recorder      sessionRecorder // --record: gets keys, frames and commands; nil when not recording
+	tour          bool            // atlas9 tour: show the tour callouts (tourSteps) above the status bar
+}
+
+// runTUI runs the interactive UI until the user quits.
+func runTUI(cfg tuiConfig) error {
+	workDir := cfg.workDir
+	if cfg.recorder != nil {
+		cfg.runner = recordingRunner{next: cfg.runner, rec: cfg.recorder}
+	}
+	envPath := filepath.Join(workDir, ".env")
+	atlasHCL := filepath.Join(workDir, "atlas.hcl")
+	policy := cfg.policy
+	// An unknown language falls back to English; config.Validate already reported a configured one.
+	msg, _ := i18n.Load(i18n.Lang(cfg.conf.Language, os.Getenv))
+	// Seed ([seed] in the preferences) follows Apply when configured, then the custom stages ([[stage]]).
+	seedStage, firstCustom := -1, len(stageIDs)
+	if cfg.conf.Seed.Enabled() {
+		seedStage, firstCustom = len(stageIDs), len(stageIDs)+1
+	}
+	stageCount := firstCustom + len(cfg.conf.Stages)
+	// stageID is the catalog id of built-in stage i ("" for custom stages).
+	stageID := func(i int) string {
+		switch {
+		case i == seedStage:
+			return "seed"
+		case i < len(stageIDs):
+			return stageIDs[i]
+		}
+		return ""
+	}
+	stageName := func(i int) string {
+		if s, ok := customStage(cfg.conf.Stages, firstCustom, i); ok {
+			return tview.Escape(s.Name)
+		}
+		return msg.T("stage." + stageID(i))
+	}
 
 	// In-memory env overlay from .env (updated by watcher); all env reads go through getEnv so UI and atlas see .env values.
-	var envOverrides = make(map[string]string)
-	var envMu sync.Mutex
-	getEnv := func(key string) string {
-		envMu.Lock()
-		v, ok := envOverrides[key]
-		envMu.Unlock()
-		if ok {
-			return v
-		}
-		return os.Getenv(key)
-	}
-	// Current environment: --env flag overrides, then .env overlay (ENVIRONMENT), then process, then "local"
+	envSnap := &envSnapshot{}
+	getEnv := envSnap.Get
+	// The env of the project's last successful run; a protected env is never picked without being asked for.
+	lastEnv := loadLastEnv(workDir)
+	if cfg.conf.Protected(lastEnv) {
+		lastEnv = ""
+	}
+	// Current environment: --env flag overrides, then .env overlay (ENVIRONMENT), then process, then the last
+	// used env, then "local"
 	getCurrentEnvName := func() string {
-		if e, _ := opts.String("--env"); e != "" {
-			return e
+		return resolveEnvName(cfg.envFlag, lastEnv, cfg.conf.DefaultEnv, getEnv)
+	}
+	// envColorOf is envColor, with protected envs (config protected_envs) always red.
+	envColorOf := func(env string) string {
+		if cfg.conf.Protected(env) {
+			return "red"
 		}
-		if v := getEnv("ENVIRONMENT"); v != "" {
-			return v
+		return envColor(env)
+	}
+	// actionKey is the key bound to a main-screen action (config keymap, see config.Actions).
+	actionKey := func(action string) rune {
+		k := cfg.conf.Keymap[action]
+		if k == "" {
+			k = config.Default().Keymap[action]
 		}
-		return "local"
+		return []rune(k)[0]
+	}
+	hint := func(action string) string {
+		return string(actionKey(action)) + ":" + msg.T("action."+action)
 	}
 
 	// Use terminal's native background color (don't draw any background)
@@ -359,6 +776,21 @@ func main() {
 	tview.Styles.MoreContrastBackgroundColor = tcell.ColorDefault
 
 	app := tview.NewApplication()
+	// Bracketed paste: a pasted command arrives as one event (see commandLine), not as keystrokes bound to actions.
+	app.EnablePaste(true)
+	// All UI updates from goroutines go through bus; see uiBus.
+	bus := newUIBus()
+	stopBus := make(chan struct{})
+	defer close(stopBus)
+	go bus.run(func(f func()) { app.QueueUpdateDraw(f) }, stopBus)
+
+	// Toasts: short messages in the top-right corner for background events (see toastStack).
+	toasts := &toastStack{}
+	// showToast shows text until it expires; call on the UI goroutine.
+	showToast := func(text string) {
+		toasts.Add(text)
+		time.AfterFunc(toastDuration, func() { bus.Post(toasts.Prune) })
+	}
 	logoColor := hexToTCell(logoColorHex)
 
 	// Use single-line borders when a box has focus (output box and modals).
@@ -371,30 +803,139 @@ func main() {
 
 	// State
 	var (
-		stageIndex    int
-		dockerOK      bool
-		atlasLoggedIn bool
-		statusMu      sync.Mutex
-		running       bool
-		inOverlay     bool // true when config/modal/preview is showing (Esc closes it instead of quitting)
-		editMode      bool // true when editing the command line (vim-like: 'i' to enter, Esc to exit)
+		stageIndex      int
+		dockerOK        bool
+		dockerChecked   bool // dockerOK has been set at least once (later flips show a toast)
+		checking        bool // docker/login checks are running (top-right shows a spinner)
+		atlasLoggedIn   bool
+		connReports     = map[string]connReport{} // last connection test per env (top right, connection action); statusMu
+		statusMu        sync.Mutex
+		ui              uiState                          // mode state machine: editing / running / overlays
+		lintPassedEnv   string                           // env whose last Lint succeeded (gates push to registry); UI goroutine only
+		schemaChanged   bool                             // schema source (env src) edited since the last Diff; UI goroutine only
+		appScreen       tcell.Screen                     // captured after the first draw (used for OSC 52 clipboard)
+		refreshEnvModal func()                           // set while the env modal is open; re-renders it after atlas.hcl changes
+		nextRefresh     time.Time                        // when Status auto-refreshes next (with --refresh); UI goroutine only
+		lastKey         time.Time                        // last key press, to guess whether the user is watching; UI goroutine only
+		outOfOrder      []string                         // unapplied migration files older than the latest applied one (last Status); UI goroutine only
+		applyNote       string                           // note for the next Apply stage run (confirm.ask_note); UI goroutine only
+		diffTicket      string                           // ticket for the headers of the next Diff run (migrations.header); UI goroutine only
+		applyPlanned    []string                         // dry-run statements confirmed for the next Apply stage run; UI goroutine only
+		applyStep       bool                             // run the next Apply stage run in step mode (one file at a time); UI goroutine only
+		txMode          = txModes[0]                     // --tx-mode for Dry-Run and Apply (x cycles); UI goroutine only
+		stageFlagValues = map[string]map[string]string{} // flags panel values per flag group; UI goroutine only
+		updateAvailable string                           // newer atlas9 release tag, if any (footer badge); UI goroutine only
+		atlasVersion    string                           // from `atlas version` at startup ("" until known); UI goroutine only
+		atlasMissing    bool                             // `atlas version` failed; UI goroutine only
+		tabs            outputTabs                       // open output tabs, Current shown in outputView; UI goroutine only
+		rerun           func()                           // repeats the last stage run or edited command (rerun action); UI goroutine only
+		lastRuns        = map[int]stageStatus{}          // last run of each stage by index (status bar); UI goroutine only
+		scheduled       *scheduledApply                  // armed scheduled apply (schedule action), nil if none; UI goroutine only
+		lintFindings    []lintFinding                    // findings of the last Lint run (lint_findings list); UI goroutine only
+		lintAcks        = map[string]lintAck{}           // acknowledged lint findings by ackKey (.atlas9/lint-acks.json); UI goroutine only
+		renderLint      func() string                    // renders the last Lint output with lintAcks dimmed; UI goroutine only
+		cmdHistory      []string                         // commands run from edit mode, oldest first (Up/Down recall them); UI goroutine only
+		historyPos      = -1                             // cmdHistory entry shown while recalling, -1 when not recalling; UI goroutine only
+		historyDraft    string                           // command line text before recalling started; UI goroutine only
 	)
+	// notify shows body as a desktop notification, else through the terminal (notifyTerminal) on the UI
+	// goroutine, between frames. Call from any goroutine.
+	notify := func(body string) {
+		go func() {
+			if !notifyDesktop("atlas9", body) {
+				bus.Post(func() { notifyTerminal("atlas9", body, appScreen) })
+			}
+		}()
+	}
+
+	// copyText copies text to the clipboard off the UI goroutine, through OSC 52 when there is no clipboard tool,
+	// then shows toast, or the error when copying failed. Call from the UI goroutine.
+	copyText := func(text, toast string) {
+		go func() {
+			err := runClipboardCommand(text)
+			bus.Post(func() {
+				if errors.Is(err, errNoClipboardCommand) && appScreen != nil {
+					appScreen.SetClipboard([]byte(text))
+					err = nil
+				}
+				if err != nil {
+					showToast(msg.T("toast.copy_error", tview.Escape(err.Error())))
+					return
+				}
+				showToast(toast)
+			})
+		}()
+	}
 
+	if acks, err := loadLintAcks(lintAcksPath(workDir)); err == nil {
+		lintAcks = acks
+	}
+	// In a workspace, a project switched back to looks as it was left instead of starting with a Status run.
+	restored := false
+	if ws := cfg.workspace; ws != nil {
+		if st, ok := ws.States[ws.Current]; ok {
+			stageIndex, txMode, stageFlagValues, tabs, lastRuns = st.Stage, st.TxMode, st.Flags, st.Tabs, st.LastRuns
+			restored = true
+		}
+	}
+	// Otherwise the project's last session (.atlas9/session.json) is restored, unless --fresh.
+	var resumed *savedSession
+	wrapOutput := true // off: long lines run past the pane and ←/→ scroll sideways
+	if !restored && !cfg.fresh {
+		if s, err := loadSession(sessionPath(workDir)); err == nil && s != nil {
+			resumed, restored = s, true
+			stageIndex, tabs, cmdHistory = s.Stage, outputTabs{Tabs: s.Tabs, Current: s.Current}, s.History
+			if slices.Contains(txModes, s.TxMode) {
+				txMode = s.TxMode
+			}
+			if s.Flags != nil {
+				stageFlagValues = s.Flags
+			}
+			wrapOutput = !s.NoWrap
+			for i, r := range s.Runs {
+				lastRuns[i] = r.status()
+			}
+			switch {
+			case cfg.envFlag != "" || s.Env == "":
+			case cfg.conf.Protected(s.Env):
+				showToast(msg.T("toast.session_protected", s.Env))
+			default:
+				cfg.envFlag = s.Env
+			}
+		} else if err != nil {
+			showToast(msg.T("toast.session_error", err))
+		}
+	}
+	if stageIndex < 0 || stageIndex >= stageCount { // the project's custom stages changed meanwhile
+		stageIndex = 0
+	}
+	if !restored {
+		tabs.open(stageName(0)) // the startup Status run
+	}
 	// Logo (top left)
+	logo := logoAtlas9
+	if asciiMode {
+		logo = logoASCII
+	}
 	logoView := tview.NewTextView().
-		SetText(logoAtlas9).
+		SetText(logo).
 		SetTextColor(logoColor).
 		SetDynamicColors(false)
 	logoView.SetBorder(false)
-	// Top right: docker, atlas.hcl env match, env name (from .env ENVIRONMENT), APP_DB_URL (from .env or process)
+	// firstDraw is closed after the first frame: the startup checks and the auto Status run wait for it.
+	firstDraw := make(chan struct{})
+	var firstDrawOnce sync.Once
+	// Top right: docker, atlas.hcl env match, env name (from .env ENVIRONMENT), the env's database URL (masked)
 	topRightView := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignRight)
 	topRightView.SetBorder(false)
+	var dbURLShown, dbURLProblem string // the current env's masked URL and what is wrong with it, for the connection action
 	updateTopRight := func() {
+		currentEnvName := getCurrentEnvName()
 		statusMu.Lock()
-		dockerStatus := dockerOK
+		dockerStatus, dockerKnown, checkingNow := dockerOK, dockerChecked, checking
+		connRep, connTested := connReports[currentEnvName]
 		statusMu.Unlock()
 
-		currentEnvName := getCurrentEnvName()
 		atlasEnvs := parseAtlasHCLEnvs(atlasHCL)
 		hasAtlasEnv := false
 		for _, n := range atlasEnvs {
@@ -403,28 +944,68 @@ func main() {
 				break
 			}
 		}
-		appDBURLSet := getEnv("APP_DB_URL") != ""
+		rawDBURL, dbErr := resolveEnvURL(atlasHCL, currentEnvName, "url", getEnv)
+		if dbErr != nil && getEnv("APP_DB_URL") != "" {
+			rawDBURL, dbErr = getEnv("APP_DB_URL"), nil
+		}
 
 		var dockerStr string
-		if dockerStatus {
-			dockerStr = "docker  [green]✅[-]"
-		} else {
-			dockerStr = "docker  [red]❌[-]"
-		}
-		var atlasHCLStr string
-		if hasAtlasEnv {
-			atlasHCLStr = fmt.Sprintf("atlas.hcl: %s  [green]✅[-]", currentEnvName)
-		} else {
-			atlasHCLStr = fmt.Sprintf("atlas.hcl: %s  [red]❌[-]", currentEnvName)
+		switch {
+		case !envUsesDocker(atlasHCL, currentEnvName, getEnv):
+			dockerStr = "docker  [gray]not needed[-]"
+		case checkingNow:
+			frames := spinnerFrames
+			if asciiMode {
+				frames = asciiSpinnerFrames
+			}
+			dockerStr = "docker  [yellow]" + string(frames[time.Now().UnixMilli()/100%int64(len(frames))]) + "[-]"
+		case !dockerKnown: // startup, before the first check
+			dockerStr = "docker  [gray]…[-]"
+		default:
+			dockerStr = "docker  " + statusMark(dockerStatus)
 		}
-		envStr := fmt.Sprintf("env: %s  [green]✅[-]", currentEnvName)
+		atlasHCLStr := fmt.Sprintf("atlas.hcl: %s  %s", currentEnvName, statusMark(hasAtlasEnv))
+		envStr := fmt.Sprintf("env: %s  %s", currentEnvName, statusMark(true))
+		// Unset: red; malformed: yellow; probed and unreachable (see checkConnection): red.
+		masked := tview.Escape(maskDBURL(rawDBURL, 14))
+		dbURLShown, dbURLProblem = maskDBURL(rawDBURL, 60), ""
 		var appDBStr string
-		if appDBURLSet {
-			appDBStr = "APP_DB_URL  [green]✅[-]"
-		} else {
-			appDBStr = "APP_DB_URL  [red]❌[-]"
+		switch verr := validateDBURL(rawDBURL); {
+		case dbErr != nil:
+			appDBStr, dbURLProblem = "db  "+statusMark(false), dbErr.Error()
+			if src, err := os.ReadFile(atlasHCL); err == nil {
+				if refs := envVarRefs(string(src), currentEnvName, envSnap.Overrides(), os.LookupEnv); len(unsetRefs(refs)) > 0 {
+					appDBStr = "db $" + tview.Escape(unsetRefs(refs)[0]) + "  " + statusMark(false)
+					dbURLProblem = describeRefs(currentEnvName, refs)
+				}
+			}
+		case verr != nil:
+			appDBStr, dbURLProblem = "db "+masked+"  "+warnMark(), "malformed: "+verr.Error()
+		case connTested && connRep.unreachable():
+			appDBStr, dbURLProblem = "db "+masked+"  "+statusMark(false), connRep.Step+" failed: "+connRep.Err.Error()
+		default:
+			appDBStr = "db " + masked + connLock(connRep) + "  " + statusMark(true)
+		}
+		var atlasStr string
+		switch {
+		case atlasMissing:
+			atlasStr = "atlas  " + statusMark(false)
+		case atlasVersion == "":
+			atlasStr = "atlas  [gray]?[-]"
+		case cfg.conf.MinAtlasVersion != "" && newerVersion(atlasVersion, cfg.conf.MinAtlasVersion):
+			atlasStr = "atlas " + atlasVersion + "  [yellow]⚠[-]"
+		default:
+			atlasStr = "atlas " + atlasVersion + "  " + statusMark(true)
+		}
+		text := dockerStr + "\n" + atlasHCLStr + "\n" + envStr + "\n" + appDBStr + "\n" + atlasStr
+		if src, err := os.ReadFile(atlasHCL); err == nil {
+			if exts := parseExternalSchemas(src, getEnv); len(exts) > 0 {
+				// ORM-driven schema: name the data "external_schema" source(s) Diff runs.
+				label, ok := externalSchemaLabel(exts)
+				text += "\n" + tview.Escape(label) + "  " + statusMark(ok)
+			}
 		}
-		topRightView.SetText(dockerStr + "\n" + atlasHCLStr + "\n" + envStr + "\n" + appDBStr)
+		topRightView.SetText(text)
 	}
 	updateTopRight()
 
@@ -436,7 +1017,9 @@ func main() {
 	stageRowView := tview.NewTextView().SetDynamicColors(true)
 	buildStageRowText := func(highlightIdx int, underline bool) string {
 		var parts []string
-		for i, name := range stages {
+		for i := range stageCount {
+			name := stageName(i)
+			digit := "[gray]" + string(stageDigits[i]) + "[-]"
 			if i == highlightIdx {
 				// Only the selected stage name gets highlight (blue+bold) and optionally underline.
 				// Explicitly turn off bold (B) and underline (U) after the word so the rest of the line stays plain.
@@ -446,9 +1029,12 @@ func main() {
 				} else {
 					seg += name + "[::B][-]"
 				}
-				parts = append(parts, seg)
+				parts = append(parts, digit+seg)
 			} else {
-				parts = append(parts, name)
+				parts = append(parts, digit+name)
+			}
+			if i == 0 && len(outOfOrder) > 0 {
+				parts[i] += " [yellow]⚠[-]"
 			}
 		}
 		return strings.Join(parts, " → ")
@@ -470,83 +1056,300 @@ func main() {
 		return atlasLoggedIn
 	}
 
+	// gitBase is the default branch --git-base auto stands for, looked up once: flagArgs runs whenever the
+	// command line is redrawn.
+	gitBase := sync.OnceValues(func() (string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return defaultGitBase(ctx, workDir)
+	})
+	// flagArgs returns the flag args of a stage on env: the config's default_flags, then the flags panel's.
+	// UI goroutine only.
+	flagArgs := func(stageIdx int, env string) []string {
+		group := flagGroup(stageIdx)
+		return resolveGitBase(withDefaultFlags(cfg.conf.StageFlags(group, env), stageFlagArgs(group, stageFlagValues[group])), gitBase)
+	}
+
 	// projectedCommand returns the exact atlas command for the given stage and env.
 	projectedCommand := func(stageIdx int, env string) string {
+		extra := flagArgs(stageIdx, env)
 		switch stageIdx {
 		case 0:
-			return "atlas migrate status --env " + env
+			return cmdString(append([]string{"migrate", "status", "--env", env}, cfg.conf.FormatArgs("status")...)...)
 		case 1:
-			return "atlas migrate diff --env " + env
+			return cmdString(append([]string{"migrate", "diff", "--env", env}, extra...)...)
 		case 2:
-			return "atlas migrate hash --env " + env + " && atlas migrate lint --env " + env
+			return "atlas migrate hash --env " + env + " && " + cmdString(slices.Concat([]string{"migrate", "lint", "--env", env}, extra, cfg.conf.FormatArgs("lint"))...)
 		case 3:
-			return "atlas migrate apply --env " + env + " --dry-run"
+			return cmdString(slices.Concat([]string{"migrate", "apply", "--env", env, "--dry-run"}, txModeArgs(txMode), extra, cfg.conf.FormatArgs("dry-run"))...)
 		case 4:
-			return "atlas migrate apply --env " + env
-		default:
-			return "atlas"
+			return cmdString(append(append([]string{"migrate", "apply", "--env", env}, txModeArgs(txMode)...), extra...)...)
 		}
+		if stageIdx == seedStage {
+			if cfg.conf.Seed.Command == "" {
+				return cmdString(seedApplyArgs(cfg.conf.Seed, env, false)...)
+			}
+			if command, err := seedCommand(cfg.conf.Seed, env); err == nil {
+				return command
+			}
+			return cfg.conf.Seed.Command
+		}
+		if s, ok := customStage(cfg.conf.Stages, firstCustom, stageIdx); ok {
+			if command, err := s.Render(env); err == nil {
+				return command
+			}
+			return s.Command
+		}
+		return "atlas"
 	}
 
 	// Body: description (first line) + "> " command input + scrollable output
 	descriptionView := tview.NewTextView().SetDynamicColors(true)
 	descriptionView.SetBorder(false)
-	commandInput := tview.NewInputField().
+	commandInput := commandLine{tview.NewInputField().
 		SetLabel("> ").
 		SetLabelColor(logoColor).
 		SetFieldTextColor(logoColor).
-		SetFieldBackgroundColor(tcell.ColorDefault)
+		SetFieldBackgroundColor(tcell.ColorDefault)}
 	commandInput.SetBorder(false)
+	// tx-mode selector right of the description, above the command (Dry-Run and Apply only)
+	txModeView := tview.NewTextView().SetDynamicColors(true).SetTextAlign(tview.AlignRight)
 	// Underline shown under the "> command" line when that line has focus
 	commandUnderlineView := tview.NewTextView().SetDynamicColors(true)
 	commandUnderlineView.SetBorder(false)
 	outputView := tview.NewTextView().
 		SetDynamicColors(true).
 		SetScrollable(true).
+		SetWrap(wrapOutput).
 		SetChangedFunc(func() { app.Draw() })
 	outputView.SetBorder(false)
 
 	updateDescriptionAndCommand := func() {
-		desc := ""
-		if stageIndex < len(stageDescriptions) {
-			desc = stageDescriptions[stageIndex]
+		var desc string
+		if s, ok := customStage(cfg.conf.Stages, firstCustom, stageIndex); ok {
+			desc = tview.Escape(s.Description)
+		} else {
+			desc = msg.T("stage_desc." + stageID(stageIndex))
 		}
 		if stageIndex == 2 && !isLintAvailable() {
 			desc += "  [yellow](not logged in — may fail; run 'atlas login')[-]"
 		}
 		descriptionView.SetText("[#98E0EA::b]" + desc + "[-]")
 		commandInput.SetText(projectedCommand(stageIndex, getCurrentEnvName()))
+		if stageIndex == 3 || stageIndex == 4 {
+			txModeView.SetText(txModeSelector(txMode))
+		} else {
+			txModeView.SetText("")
+		}
 	}
 
 	bodyFlex := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(descriptionView, 1, 0, false).
+		AddItem(tview.NewFlex().
+			AddItem(descriptionView, 0, 1, false).
+			AddItem(txModeView, 24, 0, false), 1, 0, false).
 		AddItem(commandInput, 1, 0, true).
 		AddItem(commandUnderlineView, 1, 0, false).
 		AddItem(outputView, 0, 1, true)
-	bodyFlex.SetBorder(true).SetTitle(" Output ").
+	bodyFlex.SetBorder(true).SetTitle(tabs.title()).
 		SetBorderColor(logoColor).SetTitleColor(logoColor)
 
+	// outFilter is the filter hiding lines of the current tab (filter actions), nil when the whole output shows.
+	var outFilter *outputFilter
+	// selection is the line selection of visual mode (select action), nil outside it.
+	var selection *outputSelection
+	// sectionTabs holds the sections of the tabs whose output is made of several commands, by tab label.
+	sectionTabs := map[string]*sectionedOutput{}
+	// sectionText renders the sections of tab label, keeping the fold states of its previous render.
+	sectionText := func(label string, secs []outputSection, outro string) string {
+		s := newSectionedOutput(secs, outro, sectionTabs[label])
+		sectionTabs[label] = s
+		text, _ := s.render()
+		return text
+	}
+	// currentSections returns the sections of the current tab while it shows them.
+	currentSections := func() *sectionedOutput {
+		if s := sectionTabs[tabs.Tabs[tabs.Current].Label]; s != nil && outputView.GetText(false) == s.Shown {
+			return s
+		}
+		return nil
+	}
+	// selectTab shows tab i in outputView, keeping the current tab's text and scroll position.
+	selectTab := func(i int) {
+		if i < 0 || i >= len(tabs.Tabs) || i == tabs.Current {
+			return
+		}
+		cur := &tabs.Tabs[tabs.Current]
+		cur.Text = outputView.GetText(false)
+		cur.Row, cur.Col = outputView.GetScrollOffset()
+		if f := outFilter; f != nil && cur.Text == f.Shown {
+			cur.Text, cur.Row, cur.Col = f.Full, f.Row, f.Col
+		}
+		outFilter = nil
+		if s := sectionTabs[cur.Label]; s != nil && s.Selected >= 0 && cur.Text == s.Shown {
+			s.Selected = -1
+			cur.Text, _ = s.render()
+		}
+		tabs.Current = i
+		next := tabs.Tabs[i]
+		outputView.SetText(next.Text)
+		outputView.ScrollTo(next.Row, next.Col)
+		bodyFlex.SetTitle(tabs.title())
+	}
+	// showTab switches to the tab labelled label, opening it if needed, before a run writes its result there.
+	showTab := func(label string) {
+		if tabs.index(label) == tabs.Current {
+			outFilter = nil // the caller replaces the output
+		}
+		selectTab(tabs.open(label))
+		bodyFlex.SetTitle(tabs.title())
+	}
+	// switchTab is the tab key handler: tabs are left alone while a command streams into outputView.
+	switchTab := func(i int) {
+		if mode, _ := ui.Mode(); mode == modeRunning {
+			showToast(msg.T("toast.tabs_running"))
+			return
+		}
+		selectTab(i)
+	}
+
+	// Status bar: when the selected stage last ran, how long it took, its exit code and env
+	statusBarView := tview.NewTextView().SetDynamicColors(true)
+	updateStatusBar := func() {
+		text := statusBarText(stageName(stageIndex), lastRuns[stageIndex], getCurrentEnvName(), time.Now())
+		if ws := cfg.workspace; ws != nil {
+			text = "[::b]" + tview.Escape(ws.Workspace.Projects[ws.Current].Name) + "[::-] • " + text
+		}
+		statusBarView.SetText(" " + text)
+	}
 	// Footer: key hints only (docker + env moved to top right), same blue as output border
 	footerView := tview.NewTextView().SetDynamicColors(true).SetTextColor(logoColor)
 	footerView.SetBorder(false)
-	const footerKeysNormal = "  tab/shift+tab:stage • ↓/↑:scroll • enter:run • i:edit cmd • e:env • c:config • h:help • q:quit"
-	const footerKeysEdit = "  [edit mode — Esc to exit, Enter to run]"
+	// Macros: the macro key starts recording every key that follows and stops at the next macro key; pressed
+	// twice in a row it replays instead. A replay feeds the keys in one at a time (macroNext, acknowledged on
+	// macroAck by the input capture) until it ends or macroStop is closed.
+	var macroRec, lastMacro []*tcell.EventKey
+	macroRecording := false
+	var macroNext *tcell.EventKey
+	var macroAck, macroStop chan struct{}
+	// footerHints builds the key hints for the current stage and focus; the active env is shown first in its color.
+	footerHints := func() string {
+		env := getCurrentEnvName()
+		hints := []string{"[" + envColorOf(env) + "::b]" + env + "[-::-]"}
+		switch stageIndex {
+		case 0:
+			hints = append(hints, msg.T("footer.enter_status"))
+			if cfg.refresh > 0 && !nextRefresh.IsZero() {
+				left := time.Until(nextRefresh).Round(time.Second)
+				if left < 0 {
+					left = 0
+				}
+				hints = append(hints, msg.T("footer.auto_refresh", left))
+			}
+		case 1:
+			if schemaChanged {
+				hints = append(hints, msg.T("footer.enter_rediff"))
+			} else {
+				hints = append(hints, msg.T("footer.enter_diff"))
+			}
+		case 2:
+			hints = append(hints, msg.T("footer.enter_lint"))
+			if lintPassedEnv == env && isLintAvailable() {
+				hints = append(hints, hint("push"))
+			}
+			if lintPassedEnv == env {
+				hints = append(hints, hint("pull_request"))
+			}
+		case 3:
+			hints = append(hints, msg.T("footer.enter_dry_run"), hint("tx_mode"))
+		case 4:
+			hints = append(hints, msg.T("footer.enter_apply"), hint("tx_mode"))
+			if _, err := os.Stat(planPath(workDir, env)); err == nil {
+				hints = append(hints, hint("apply_plan"))
+			}
+		default:
+			if stageIndex == seedStage {
+				hints = append(hints, msg.T("footer.enter_seed"))
+			} else {
+				hints = append(hints, msg.T("footer.enter_run"))
+			}
+		}
+		if flagGroup(stageIndex) != "" {
+			hints = append(hints, hint("flags"))
+		}
+		if mode, _ := ui.Mode(); mode == modeRunning {
+			if ui.Queued() {
+				hints = append(hints[:1], msg.T("footer.running_queued"))
+			} else {
+				hints = append(hints[:1], msg.T("footer.running"))
+			}
+		}
+		if f := outFilter; f != nil {
+			hints = slices.Insert(hints, 1, msg.T("footer.filtered", f.Kept, f.Total))
+		}
+		if s := currentSections(); s != nil && s.Selected >= 0 {
+			hints = slices.Insert(hints, 1, msg.T("footer.section_selected"))
+		} else if s != nil {
+			hints = append(hints, msg.T("footer.sections", string(actionKey("prev_section")), string(actionKey("next_section"))))
+		}
+		if macroRecording {
+			hints = slices.Insert(hints, 1, msg.T("footer.macro_recording", len(macroRec), string(actionKey("macro"))))
+		}
+		if s := scheduled; s != nil {
+			hints = append(hints, msg.T("footer.scheduled", s.Run.Env, s.At.Format("15:04"), countdown(s.At, time.Now())))
+		}
+		hints = append(hints, msg.T("footer.cycle_stages"), hint("refresh"))
+		if rerun != nil {
+			hints = append(hints, hint("rerun"))
+		}
+		if app.GetFocus() == outputView {
+			if wrapOutput {
+				hints = append(hints, msg.T("footer.scroll"), hint("links"))
+			} else {
+				hints = append(hints, msg.T("footer.scroll_sideways"), hint("links"))
+			}
+		}
+		hints = append(hints, hint("tables"), hint("migrations"), hint("edit"), hint("env"), hint("config"), hint("help"), hint("quit"))
+		if updateAvailable != "" {
+			hints = append(hints, msg.T("footer.update_available", updateAvailable))
+		}
+		return "  " + strings.Join(hints, " • ")
+	}
+	// runLeader is set after the run_stage key, until the stage digit that completes the chord (or another key).
+	runLeader := false
 	updateFooter := func() {
-		if editMode {
-			footerView.SetText(footerKeysEdit)
-		} else {
-			footerView.SetText(footerKeysNormal)
+		switch {
+		case ui.Editing():
+			footerView.SetText(msg.T("footer.edit_mode"))
+		case runLeader:
+			footerView.SetText(msg.T("footer.run_leader", fmt.Sprintf("1–%d", min(stageCount, len(stageDigits)))))
+		case selection != nil:
+			lo, hi := selection.bounds()
+			footerView.SetText(msg.T("footer.selecting", lo+1, hi+1, hi-lo+1))
+		default:
+			footerView.SetText(footerHints())
 		}
+		updateStatusBar()
 		updateTopRight()
 	}
 
-	// updateUI refreshes stage row and command underline based on editMode
+	// cmdProblems is what checkCommand found wrong with the edited command, shown under it until it changes.
+	var cmdProblems []string
+	commandInput.SetChangedFunc(func(string) {
+		if cmdProblems != nil {
+			cmdProblems = nil
+			commandUnderlineView.SetText("[#98E0EA]" + strings.Repeat("─", 120) + "[-]")
+		}
+	})
+	// updateUI refreshes stage row and command underline based on the edit mode
 	updateUI := func() {
 		// Stage row always shows current stage highlighted (no underline needed since we use Tab now)
 		stageRowView.SetText(buildStageRowText(stageIndex, false))
-		if editMode {
+		switch {
+		case ui.Editing() && cmdProblems != nil:
+			commandUnderlineView.SetText("[red]⚠ " + tview.Escape(strings.Join(cmdProblems, "; ")) + "[-]")
+		case ui.Editing():
 			commandUnderlineView.SetText("[#98E0EA]" + strings.Repeat("─", 120) + "[-]")
-		} else {
+		default:
 			commandUnderlineView.SetText("")
 		}
 		updateFooter()
@@ -561,52 +1364,231 @@ func main() {
 	highlightStage := func(idx int) {
 		highlightStageOnly(idx)
 		updateDescriptionAndCommand()
-		outputView.SetText("")
+		// Show the stage's last result if it has a tab; the current tab stays otherwise (and while a run streams).
+		if mode, _ := ui.Mode(); mode != modeRunning {
+			if i := tabs.index(stageName(idx)); i >= 0 {
+				selectTab(i)
+			}
+		}
+		updateFooter()
+	}
+	if restored {
+		cur := tabs.Tabs[tabs.Current]
+		outputView.SetText(cur.Text)
+		outputView.ScrollTo(cur.Row, cur.Col)
+	}
+	// keepStageRun adds the finished run of stage idx, with the output in its tab, to the previous runs (runs action).
+	keepStageRun := func(idx int, st stageStatus) {
+		i := tabs.index(stageName(idx))
+		if i < 0 {
+			return
+		}
+		output := tabs.Tabs[i].Text
+		if i == tabs.Current {
+			output = outputView.GetText(false)
+		}
+		key := stageID(idx)
+		if key == "" {
+			key = stageRunKey(stageName(idx))
+		}
+		savePastRun(runsDir(workDir), key, pastRun{sessionRun: newSessionRun(st), Stage: stageName(idx)}, output)
+	}
+	// saveSessionState writes the UI state to .atlas9/session.json for the next launch; best effort. Nothing is
+	// saved before the first run, so quitting during startup keeps the previous session.
+	saveSessionState := func() {
+		if len(lastRuns) == 0 && len(cmdHistory) == 0 {
+			return
+		}
+		cur := &tabs.Tabs[tabs.Current]
+		cur.Text = outputView.GetText(false)
+		cur.Row, cur.Col = outputView.GetScrollOffset()
+		if f := outFilter; f != nil && cur.Text == f.Shown {
+			cur.Text, cur.Row, cur.Col = f.Full, f.Row, f.Col
+		}
+		saveSession(sessionPath(workDir), savedSession{Saved: time.Now(), Env: cfg.envFlag, Stage: stageIndex, TxMode: txMode,
+			Flags: stageFlagValues, Tabs: slices.Clone(tabs.Tabs), Current: tabs.Current, Runs: newSessionRuns(lastRuns), History: cmdHistory,
+			NoWrap: !wrapOutput})
 	}
-	highlightStage(0)
+	highlightStage(stageIndex)
 	updateFooter()
+	// Mode changes (run start/finish, overlays) refresh the footer; queued from a goroutine so Fire is safe anywhere.
+	ui.OnChange = func() {
+		bus.Post(func() {
+			if mode, _ := ui.Mode(); mode == modeRunning {
+				outFilter = nil // the run replaces the output
+			}
+			updateFooter()
+		})
+	}
+	// A run queued while busy starts once the current one finishes, unless an overlay (e.g. a confirmation) opened meanwhile.
+	ui.Schedule = func(f func()) {
+		bus.Post(func() {
+			if !ui.InOverlay() {
+				f()
+			}
+		})
+	}
 
-	// Check Docker availability (non-blocking)
+	// Check Docker availability (non-blocking); skipped for envs whose dev database is not a container.
 	checkDocker := func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if !envUsesDocker(atlasHCL, getCurrentEnvName(), getEnv) {
+			bus.Post(updateTopRight)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Docker.Duration)
 		defer cancel()
 		cmd := exec.CommandContext(ctx, "docker", "info")
 		cmd.Stdout = nil
 		cmd.Stderr = nil
 		err := cmd.Run()
+		ok := err == nil
 		statusMu.Lock()
-		dockerOK = (err == nil)
+		changed := dockerChecked && dockerOK != ok
+		dockerOK, dockerChecked = ok, true
 		statusMu.Unlock()
-		app.QueueUpdate(func() { updateFooter() })
+		bus.Post(func() {
+			updateFooter()
+			if changed && ok {
+				showToast(msg.T("toast.docker_online"))
+			} else if changed {
+				showToast(msg.T("toast.docker_offline"))
+			}
+		})
 	}
-	go checkDocker()
 
 	// Check Atlas Cloud login status (non-blocking)
 	checkAtlasLogin := func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Login.Duration)
 		defer cancel()
-		cmd := exec.CommandContext(ctx, "atlas", "whoami")
-		cmd.Stdout = nil
-		cmd.Stderr = nil
-		err := cmd.Run()
+		_, err := cfg.runner.Run(ctx, []string{"whoami"}, os.Environ())
 		statusMu.Lock()
 		atlasLoggedIn = (err == nil)
 		statusMu.Unlock()
-		app.QueueUpdate(func() {
+		bus.Post(func() {
 			updateTopRight()
 			highlightStageOnly(stageIndex) // Re-highlight to update Lint visibility
 		})
 	}
-	go checkAtlasLogin()
+
+	// checkConnection tests the connection to the current env's database for the top-right panel.
+	checkConnection := func() {
+		if cfg.noConnect {
+			return
+		}
+		env := getCurrentEnvName()
+		rep := connReport{Env: env, Step: "url"}
+		if rawURL, err := resolveEnvURL(atlasHCL, env, "url", getEnv); err != nil {
+			rep.Err = err
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Connect.Duration)
+			rep = testConnection(ctx, env, rawURL, workDir)
+			cancel()
+		}
+		statusMu.Lock()
+		connReports[env] = rep
+		statusMu.Unlock()
+		bus.Post(updateTopRight)
+	}
+
+	// recheckStatus runs the docker and login checks, animating a spinner in the top-right panel meanwhile; with
+	// probe it also tests the current env's database connection, unless the env is protected (b tests those).
+	// It blocks until the checks finish; a call while checks are already running returns at once.
+	recheckStatus := func(probe bool) {
+		statusMu.Lock()
+		if checking {
+			statusMu.Unlock()
+			return
+		}
+		checking = true
+		statusMu.Unlock()
+		done := make(chan struct{})
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					bus.Post(updateTopRight)
+				}
+			}
+		}()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); checkDocker() }()
+		go func() { defer wg.Done(); checkAtlasLogin() }()
+		if probe && !cfg.conf.Protected(getCurrentEnvName()) {
+			wg.Add(1)
+			go func() { defer wg.Done(); checkConnection() }()
+		}
+		wg.Wait()
+		close(done)
+		statusMu.Lock()
+		checking = false
+		statusMu.Unlock()
+		bus.Post(updateTopRight)
+	}
+	go func() {
+		select { // paint first: the checks start processes and the network
+		case <-firstDraw:
+		case <-stopBus:
+			return
+		}
+		ticker := time.NewTicker(statusRecheckInterval)
+		defer ticker.Stop()
+		// The database is only probed at startup, not every interval: an open atlas9 should not keep
+		// connecting to the env's database.
+		for probe := true; ; probe = false {
+			recheckStatus(probe)
+			bus.Post(updateStatusBar) // "… ago"
+			select {
+			case <-stopBus:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	// One release check per start; failures (offline, rate limits) are silent.
+	if cfg.conf.CheckUpdates {
+		go func() {
+			select {
+			case <-firstDraw:
+			case <-stopBus:
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			rel, err := latestRelease(ctx)
+			if err != nil || !newerVersion(version, rel.TagName) {
+				return
+			}
+			bus.Post(func() {
+				updateAvailable = rel.TagName
+				updateFooter()
+			})
+		}()
+	}
 
 	// .env watcher: keep env overlay in sync and refresh UI when .env changes
 	go func() {
-		loadEnv(envPath, envOverrides, &envMu)
-		app.QueueUpdateDraw(func() {
-			updateTopRight()
-			updateDescriptionAndCommand()
-			highlightStageOnly(stageIndex)
-		})
+		lastDotenv := ""
+		refreshEnv := func() {
+			envSnap.Load(envPath)
+			rep := checkDotenv(workDir, envSnap.Overrides(), os.LookupEnv)
+			summary := strings.Join(append(rep.Missing, rep.Empty...), ",")
+			changed := summary != lastDotenv
+			lastDotenv = summary
+			bus.Post(func() {
+				updateTopRight()
+				updateDescriptionAndCommand()
+				highlightStageOnly(stageIndex)
+				if changed && rep.Problems() > 0 {
+					showToast(msg.T("toast.env_problems", rep.Problems(), rep.Template, actionKey("diagnostics")))
+				}
+			})
+		}
+		refreshEnv()
 		watcher, err := fsnotify.NewWatcher()
 		if err != nil {
 			return
@@ -615,187 +1597,835 @@ func main() {
 		if err := watcher.Add(workDir); err != nil {
 			return
 		}
+		files := newFileWatch()
+		files.Prime(envPath)
+		files.Prime(atlasHCL)
+		srcPaths := func() []string {
+			var paths []string
+			for _, src := range parseAtlasHCLSrcPaths(atlasHCL, getCurrentEnvName()) {
+				paths = append(paths, filepath.Join(workDir, src))
+			}
+			return paths
+		}
+		for _, p := range srcPaths() {
+			files.Prime(p)
+		}
+		// The migration directory is watched too (it moves when atlas.hcl changes the env's dir).
+		migrationsDir := func() string {
+			return filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, getCurrentEnvName()))
+		}
+		watchedDir := migrationsDir()
+		_ = watcher.Add(watchedDir)
+		files.Prime(watchedDir)
+		// Schema sources may sit in subdirectories (src = "file://schema"), which the workDir watch does not see;
+		// watchSources watches their directories and is re-run when atlas.hcl or .env may have moved them.
+		var srcDirs []string
+		watchSources := func() {
+			dirs := sourceDirs(srcPaths())
+			for _, d := range srcDirs {
+				if !slices.Contains(dirs, d) && d != workDir && d != watchedDir {
+					_ = watcher.Remove(d)
+				}
+			}
+			for _, d := range dirs {
+				_ = watcher.Add(d)
+			}
+			srcDirs = dirs
+		}
+		watchSources()
+		refreshConfig := func() {
+			bus.Post(func() {
+				updateTopRight()
+				updateDescriptionAndCommand()
+				highlightStageOnly(stageIndex)
+				if refreshEnvModal != nil {
+					refreshEnvModal()
+				}
+				showToast(msg.T("toast.hcl_reloaded"))
+			})
+		}
 		for {
 			select {
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
-				if (event.Op&(fsnotify.Write|fsnotify.Create) != 0) && filepath.Base(event.Name) == ".env" {
-					loadEnv(envPath, envOverrides, &envMu)
-					app.QueueUpdateDraw(func() {
-						updateTopRight()
-						updateDescriptionAndCommand()
-						highlightStageOnly(stageIndex)
+				if event.Op&watchOps == 0 {
+					continue
+				}
+				name := filepath.Clean(event.Name)
+				switch {
+				case name == envPath:
+					files.Event(name, func() {
+						refreshEnv()
+						bus.Post(func() { showToast(msg.T("toast.env_reloaded")) })
+					})
+					watchSources() // ENVIRONMENT may pick an env with other sources
+				case name == atlasHCL:
+					if d := migrationsDir(); d != watchedDir {
+						_ = watcher.Remove(watchedDir)
+						watchedDir = d
+						_ = watcher.Add(watchedDir)
+						files.Prime(watchedDir)
+					}
+					watchSources()
+					for _, p := range srcPaths() {
+						files.Prime(p)
+					}
+					files.Event(name, refreshConfig)
+				case filepath.Dir(name) == watchedDir:
+					files.Event(watchedDir, func() { bus.Post(updateUI) })
+				}
+				if src, ok := sourceOf(srcPaths(), name); ok {
+					files.Event(src, func() {
+						bus.Post(func() {
+							schemaChanged = true
+							updateFooter()
+						})
 					})
 				}
 			case _, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
+			case <-stopBus: // runTUI returned, e.g. for a workspace project switch
+				return
 			}
 		}
 	}()
 
 	// envForAtlas returns os.Environ() with .env overlay (so atlas subprocess sees ENVIRONMENT/APP_DB_URL from .env).
-	envForAtlas := func() []string {
-		envMu.Lock()
-		overrides := make(map[string]string, len(envOverrides))
-		for k, v := range envOverrides {
-			overrides[k] = v
-		}
-		envMu.Unlock()
-		base := make([]string, len(os.Environ()))
-		copy(base, os.Environ())
-		for k, v := range overrides {
-			kv := k + "=" + v
-			found := false
-			for i, e := range base {
-				if strings.HasPrefix(e, k+"=") {
-					base[i] = kv
-					found = true
-					break
-				}
-			}
-			if !found {
-				base = append(base, kv)
-			}
+	envForAtlas := envSnap.Environ
+	runAtlas := func(args ...string) (stdout, stderr string, err error) {
+		res, err := cfg.runner.Run(context.Background(), args, envForAtlas())
+		return res.Stdout, res.Stderr, err
+	}
+	// runAtlasStreaming is runAtlas but calls onLine for every stdout line as it arrives.
+	runAtlasStreaming := func(onLine func(string), args ...string) (stdout, stderr string, err error) {
+		res, err := runStreaming(context.Background(), cfg.runner, args, envForAtlas(), onLine)
+		return res.Stdout, res.Stderr, err
+	}
+
+	// Tour callout (atlas9 tour only): the current tourSteps text, advanced by advanceTour as stages run.
+	tourView := tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
+	tourView.SetBorder(true).SetBorderColor(tcell.ColorYellow).SetTitleAlign(tview.AlignLeft)
+	tourAt := 0
+	showTourStep := func() {
+		step := tourSteps[tourAt]
+		text := msg.T("tour." + step.Key)
+		if step.Until < 0 {
+			text = msg.T("tour."+step.Key, workDir)
 		}
-		return base
+		tourView.SetTitle(msg.T("title.tour", tourAt+1, len(tourSteps)))
+		tourView.SetText(text)
 	}
-	runAtlas := func(args ...string) (stdout, stderr string, err error) {
-		cmd := exec.Command("atlas", args...)
-		cmd.Dir = workDir
-		cmd.Env = envForAtlas()
-		cmd.Stdin = nil // don't attach terminal stdin; child gets EOF so it never blocks on read
-		var out, errOut strings.Builder
-		cmd.Stdout = &out
-		cmd.Stderr = &errOut
-		err = cmd.Run()
-		return out.String(), errOut.String(), err
-	}
-
-	// Root layout: top (logo + docker/env) | strip (indented) | spacer | body | footer
+	advanceTour := func(idx int) {
+		if cfg.tour && tourSteps[tourAt].Until == idx {
+			tourAt++
+			showTourStep()
+		}
+	}
+
+	// Root layout: top (logo + docker/env) | strip (indented) | spacer | body | [tour] | footer
 	root := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(topFlex, 6, 0, false).
 		AddItem(stageStripRow, 1, 0, false).
 		AddItem(spacerBelowStages, 1, 0, false).
-		AddItem(bodyFlex, 0, 1, true).
+		AddItem(bodyFlex, 0, 1, true)
+	if cfg.tour {
+		showTourStep()
+		root.AddItem(tourView, 6, 0, false)
+	}
+	root.AddItem(statusBarView, 1, 0, false).
 		AddItem(footerView, 1, 0, false)
 	// Floating overlay for Apply confirmation (drawn on top of root instead of replacing screen)
 	var applyOverlay tview.Primitive
-	rootWithOverlay := newOverlayRoot(root, &applyOverlay)
-	// cmdLine returns the exact shell command for display (e.g. "atlas schema inspect --env local").
-	cmdLine := func(args ...string) string { return "atlas " + strings.Join(args, " ") }
+	rootWithOverlay := newOverlayRoot(root, &applyOverlay, toasts)
 
-	// runCommandFromInput runs the command line from the input field (e.g. "atlas migrate status --env local").
-	runCommandFromInput := func() {
-		if running {
+	// confirmEditedApply asks before an edited command that changes the database (applyLike) runs: an apply on an
+	// env goes through the Apply stage's confirmation and policies, anything else calls run once confirmed, with
+	// the protected confirmation when its --env or --url is a protected env's (targetEnv). Set once the stages
+	// can start.
+	var confirmEditedApply func(args []string, run func())
+	// runCommandText runs a command line from the input field (e.g. "atlas migrate status --env local").
+	runCommandText := func(text string) {
+		if text == "" {
 			return
 		}
-		text := strings.TrimSpace(commandInput.GetText())
-		if text == "" {
+		words, err := splitShellWords(text)
+		if err != nil {
+			outputView.SetText(msg.T("output.cannot_run", err))
+			outputView.ScrollToBeginning()
 			return
 		}
-		parts := strings.Fields(text)
+		parts := shellValues(words)
 		if len(parts) < 1 || parts[0] != "atlas" {
-			outputView.SetText("Command must start with 'atlas' (e.g. atlas migrate status --env local)")
+			outputView.SetText(msg.T("output.not_atlas"))
 			outputView.ScrollToBeginning()
 			return
 		}
 		args := parts[1:]
-		running = true
-		outputView.SetText("Running...")
-		outputView.ScrollToBeginning()
-		go func() {
-			defer func() { running = false }()
-			out, errOut, err := runAtlas(args...)
-			app.QueueUpdate(func() {
-				if err != nil {
-					outputView.SetText(fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", err, errOut, out))
-				} else {
-					outputView.SetText(out + errOut)
-				}
+		run := func() {
+			if !ui.Fire(evRunStart, overlayNone) {
+				return
+			}
+			showTab(msg.T("tabs.command"))
+			outputView.SetText(msg.T("output.running"))
+			outputView.ScrollToBeginning()
+			go func() {
+				defer ui.Fire(evRunDone, overlayNone)
+				out, errOut, err := runAtlas(args...)
+				bus.Post(func() {
+					if err != nil {
+						outputView.SetText(msg.T("output.error", err, errOut, out))
+					} else {
+						outputView.SetText(out + errOut)
+					}
+					outputView.ScrollToBeginning()
+				})
+			}()
+		}
+		if applyLike(args) {
+			confirmEditedApply(args, run)
+			return
+		}
+		run()
+	}
+	// atlasHelpFor returns the parsed `atlas <path> --help` for checkCommand, asked once per command; ok is false
+	// when the help lists neither commands nor flags. Safe from any goroutine.
+	var helpMu sync.Mutex
+	helpCache := map[string]atlasHelp{}
+	atlasHelpFor := func(path []string) (atlasHelp, bool) {
+		helpMu.Lock()
+		defer helpMu.Unlock()
+		key := strings.Join(path, " ")
+		h, ok := helpCache[key]
+		if !ok {
+			out, errOut, err := runAtlas(append(slices.Clone(path), "--help")...)
+			if h = parseAtlasHelp(out + errOut); err != nil {
+				h = atlasHelp{}
+			}
+			helpCache[key] = h
+		}
+		return h, len(h.Commands) > 0 || len(h.Flags) > 0
+	}
+	// submitCommand checks the edited command (checkCommand) and runs it, or keeps edit mode with the problems
+	// under the command line; set once edit mode can be left.
+	var submitCommand func()
+	// runCommandFromInput runs the edited command, or queues it if a command is still running.
+	runCommandFromInput := func() {
+		text := strings.TrimSpace(commandInput.GetText())
+		cmdHistory, historyPos = pushHistory(cmdHistory, text), -1
+		rerun = func() { runCommandText(text) }
+		if !ui.Enqueue(func() { runCommandText(text) }) {
+			runCommandText(text)
+		}
+	}
+
+	// showCommandEditor edits the command line in a multi-line, soft-wrapping text area (one flag per line) with
+	// a colored preview. Esc puts the joined command back into the command line, Ctrl+R runs it.
+	showCommandEditor := func() {
+		ta := tview.NewTextArea().SetWrap(true).SetText(splitCommandLines(commandInput.GetText()), true)
+		ta.SetBorder(true).SetTitle(msg.T("cmdedit.title")).SetTitleAlign(tview.AlignLeft)
+		preview := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+		preview.SetBorder(true).SetTitle(msg.T("cmdedit.preview")).SetTitleAlign(tview.AlignLeft)
+		updatePreview := func() { preview.SetText(colorizeCommand(ta.GetText(), envColorOf)) }
+		ta.SetChangedFunc(updatePreview)
+		updatePreview()
+		closeEditor := func(run bool) {
+			commandInput.SetText(joinCommandLines(ta.GetText()))
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true)
+			ui.Fire(evEditStart, overlayNone)
+			app.SetFocus(commandInput)
+			updateUI()
+			if run {
+				submitCommand()
+			}
+		}
+		ta.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape:
+				closeEditor(false)
+				return nil
+			case tcell.KeyCtrlR:
+				closeEditor(true)
+				return nil
+			}
+			return event
+		})
+		footer := tview.NewTextView().SetText(msg.T("cmdedit.footer")).SetTextAlign(tview.AlignCenter)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+					AddItem(ta, 0, 3, true).
+					AddItem(preview, 0, 2, false).
+					AddItem(footer, 1, 0, false), 0, 8, true).
+				AddItem(nil, 0, 1, false), 0, 4, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayCommand)
+		app.SetRoot(wrap, true).SetFocus(ta)
+	}
+
+	// applyMigrations runs `atlas migrate apply` for env, streaming per-migration progress into outputView.
+	// header is prepended to the final output (e.g. which plan was applied); note is recorded in the history.
+	// Call from a worker goroutine.
+	// expected are the reviewed dry-run statements: the apply is aborted if the pending set no longer matches
+	// them, and the executed statements are checked against them afterwards (nil skips both checks).
+	// With pause set (step mode) the files are applied one atlas run each, and after each but the last pause is
+	// asked whether to go on; it blocks until the operator answers.
+	// After a complete apply the env's verification checks run; errVerifyFailed is returned if any fails.
+	applyMigrations := func(env, header, note, txMode string, flags []string, expected []string, pause func(done, total int, file string) bool) error {
+		args := append(append([]string{"migrate", "apply", "--env", env}, txModeArgs(txMode)...), flags...)
+		var dryOut string
+		if expected != nil || pause != nil {
+			var dryErrOut string
+			var err error
+			dryOut, dryErrOut, err = runAtlas(append(append([]string{}, args...), "--dry-run")...)
+			if err != nil {
+				bus.Post(func() {
+					outputView.SetText(header + msg.T("output.apply_recheck_failed", err, dryOut, dryErrOut))
+					outputView.ScrollToBeginning()
+				})
+				return err
+			}
+			if drift := verifyApplied(expected, dryRunStatements(dryOut)); expected != nil && len(drift) > 0 {
+				bus.Post(func() {
+					outputView.SetText(header + "[red]" + tview.Escape(driftReport(drift)) + "[-]")
+					outputView.ScrollToBeginning()
+					showToast(msg.T("toast.apply_aborted"))
+				})
+				return errPendingChanged
+			}
+		}
+		pre, err := runHooks(workDir, envForAtlas(), env, note, cfg.conf.Hooks.PreApply)
+		if err != nil {
+			bus.Post(func() {
+				outputView.SetText(header + pre + msg.T("output.pre_apply_failed", err))
 				outputView.ScrollToBeginning()
 			})
-		}()
+			return err
+		}
+		header += pre
+		progress := newApplyProgress(filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, env)))
+		onLine := func(line string) {
+			if !progress.Feed(line) {
+				return
+			}
+			text := progress.Render()
+			bus.Post(func() {
+				outputView.SetText(text)
+				outputView.ScrollToBeginning()
+			})
+		}
+		var out, errOut, stopped string
+		total := 0
+		if m := applyTotalRe.FindStringSubmatch(dryOut); m != nil {
+			total, _ = strconv.Atoi(m[1])
+		}
+		if pause == nil || total < 2 {
+			out, errOut, err = runAtlasStreaming(onLine, args...)
+		} else {
+			progress.Feed(applyTotalRe.FindString(dryOut))
+			for done := 0; done < total; {
+				o, e, rerr := runAtlasStreaming(func(line string) {
+					if !applyTotalRe.MatchString(line) { // each run has one file in total
+						onLine(line)
+					}
+				}, append(append([]string{}, args...), "1")...)
+				out, errOut = out+o, errOut+e
+				if err = rerr; err != nil {
+					break
+				}
+				if done++; done < total && !pause(done, total, progress.Last()) {
+					stopped = fmt.Sprintf("stopped after %d of %d files (step mode)", done, total)
+					break
+				}
+			}
+		}
+		summary := ""
+		if progress.Started() {
+			summary = progress.Render() + "\n"
+		}
+		var divergence []string
+		if err == nil && expected != nil && stopped == "" {
+			divergence = verifyApplied(expected, dryRunStatements(out))
+		}
+		rec := applyRecord{
+			Time:       time.Now(),
+			Env:        env,
+			Command:    cmdString(args...),
+			Success:    err == nil,
+			URLs:       extractURLs(out + errOut),
+			Note:       note,
+			TxMode:     txMode,
+			Divergence: divergence,
+			Stopped:    stopped,
+		}
+		if err != nil {
+			rec.Error = err.Error()
+		}
+		if err == nil && stopped == "" && len(cfg.conf.Hooks.PostApply) > 0 {
+			post, hookErr := runHooks(workDir, envForAtlas(), env, note, cfg.conf.Hooks.PostApply)
+			errOut += "\n" + post
+			if hookErr != nil {
+				errOut += fmt.Sprintf("[red]post_apply %v[-]\n", hookErr)
+			}
+		}
+		verification := ""
+		if checks := cfg.conf.Verify.For(env); err == nil && stopped == "" && len(checks) > 0 {
+			url, _ := resolveEnvURL(atlasHCL, env, "url", getEnv)
+			results := runVerifyChecks(context.Background(), checks, workDir, envForAtlas(), env, url, cfg.conf.Timeouts.Connect.Duration)
+			verification = renderChecks(results, true) + "\n"
+			rec.FailedChecks = failedChecks(results)
+		}
+		if hErr := appendApplyRecord(historyPath(workDir), rec); hErr != nil {
+			errOut += fmt.Sprintf("\n(could not record apply history: %v)", hErr)
+		}
+		bus.Post(func() {
+			if err != nil {
+				outputView.SetText(header + summary + msg.T("output.error", err, errOut, out))
+				outputView.ScrollToBeginning()
+				return
+			}
+			warning := ""
+			if len(divergence) > 0 {
+				warning = "[yellow]" + tview.Escape(divergenceReport(divergence)) + "[-]\n"
+				showToast(msg.T("toast.apply_diverged"))
+			}
+			if stopped != "" {
+				outputView.SetText(header + msg.T("output.apply_stopped", stopped) + summary + out + errOut)
+				outputView.ScrollToBeginning()
+				return
+			}
+			if len(rec.FailedChecks) > 0 {
+				showToast(msg.T("toast.verify_failed", len(rec.FailedChecks)))
+			}
+			outputView.SetText(header + msg.T("output.apply_done") + verification + warning + summary + out + errOut)
+			outputView.ScrollToBeginning()
+		})
+		if err == nil && len(rec.FailedChecks) > 0 {
+			return fmt.Errorf("%w: %s", errVerifyFailed, strings.Join(rec.FailedChecks, ", "))
+		}
+		return err
+	}
+
+	// sqlDriver is the database driver of the current env, for SQL highlighting and the Diff summary.
+	sqlDriver := func() string { return envDriver(atlasHCL, getCurrentEnvName(), getEnv) }
+
+	// migrationText renders a migration file for display: statistics header, then the highlighted SQL.
+	migrationText := func(dir, name string) string {
+		sqlText, st, err := readMigration(dir, name)
+		if err != nil && sqlText == "" {
+			return msg.T("output.read_failed", name, err)
+		}
+		text := st.header(name)
+		if err != nil {
+			text += msg.T("output.split_failed", err)
+		}
+		return text + "\n" + tviewText(highlightSQL(sqlDriver(), sqlText))
+	}
+
+	// downText renders the reverse SQL of migration name of env's directory dir, for reviewing how it would be
+	// undone. It runs atlas on the env's dev database: call from a worker goroutine.
+	downText := func(env, dir, name string) string {
+		dev, err := resolveEnvURL(atlasHCL, env, "dev", getEnv)
+		if err != nil || dev == "" {
+			return msg.T("output.down_no_dev", tview.Escape(env))
+		}
+		down, err := reverseMigration(dir, name, dev, runAtlas)
+		if err != nil {
+			return msg.T("output.down_failed", tview.Escape(err.Error()))
+		}
+		text := msg.T("output.down_title", tview.Escape(name))
+		if !strings.Contains(down, ";") { // "Schemas are synced": the file changes nothing atlas models
+			return text + "\n[gray]" + tview.Escape(strings.TrimSpace(down)) + "[-]"
+		}
+		text += msg.T("output.down_data_loss")
+		return text + tviewText(highlightSQL(sqlDriver(), down))
 	}
 
-	runStage := func() {
-		if running {
+	// notifyIfAway sends a desktop notification when a command ran for at least --notify-after and no key was
+	// pressed since it started (the terminal is then likely unfocused). Safe to call from workers.
+	notifyIfAway := func(what string, start time.Time, err error) {
+		if cfg.notifyAfter <= 0 || time.Since(start) < cfg.notifyAfter {
 			return
 		}
-		running = true
+		result := "success"
+		if err != nil {
+			result = "failed"
+		}
+		bus.Post(func() {
+			if !lastKey.After(start) {
+				notify(fmt.Sprintf("%s finished (%s)", what, result))
+			}
+		})
+	}
+
+	// showSafeSuggestions lists safer patterns for the risky statements of a dry-run with the selected one's SQL
+	// below; Enter copies the SQL, Esc goes back (calls back).
+	showSafeSuggestions := func(sugs []safeSuggestion, back func()) {
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.safer_patterns")).SetTitleAlign(tview.AlignLeft)
+		sqlView := tview.NewTextView().SetDynamicColors(true).SetScrollable(true)
+		sqlView.SetBorder(true).SetTitleAlign(tview.AlignLeft)
+		showSQL := func(i int) {
+			s := sugs[i]
+			text := msg.T("safer.instead_of", strings.ReplaceAll(s.Statement, "\n", "\n-- ")) + s.SQL
+			if noColor {
+				sqlView.SetText(tview.Escape(text))
+			} else {
+				sqlView.SetText(tviewText(highlightSQL(sqlDriver(), text)))
+			}
+			sqlView.SetTitle(" " + tview.Escape(s.Table) + " ")
+			sqlView.ScrollToBeginning()
+		}
+		for _, s := range sugs {
+			secondary := "  [gray]" + tview.Escape(s.Table) + "[-]"
+			if s.Rows >= 0 {
+				secondary += "  [yellow]" + formatRows(s.Rows) + "[-]"
+			}
+			list.AddItem(tview.Escape(msg.T("safer."+s.Kind, s.Table, s.Column)), secondary, 0, nil)
+		}
+		list.SetChangedFunc(func(i int, _, _ string, _ rune) { showSQL(i) })
+		list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+			copyText(sugs[i].SQL, msg.T("toast.pattern_copied"))
+		})
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+				(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+				back()
+				return nil
+			}
+			return event
+		})
+		showSQL(0)
+		flex := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(list, 0, 1, true).
+			AddItem(sqlView, 0, 2, false)
+		app.SetRoot(flex, true).SetFocus(list)
+	}
+
+	// askStepContinue pauses a step-mode Apply after each file; set with the confirmations below.
+	var askStepContinue func(env string, done, total int, file string) bool
+	// offerRollback offers the verify.rollback_stage after failed verification checks; set once stages can start.
+	var offerRollback func(env string, failed error)
+
+	// currentStageRun captures the selected stage with the current env, tx mode and flags.
+	currentStageRun := func() stageRun {
 		env := getCurrentEnvName()
+		return stageRun{Stage: stageIndex, Env: env, TxMode: txMode, Flags: flagArgs(stageIndex, env)}
+	}
+	// runStage runs r in a worker goroutine. Call from the UI goroutine.
+	runStage := func(r stageRun) {
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		env, idx, tx, flags := r.Env, r.Stage, r.TxMode, r.Flags
+		note := applyNote
+		applyNote = ""
+		ticket := diffTicket
+		diffTicket = ""
+		planned := applyPlanned
+		applyPlanned = nil
+		step := applyStep
+		applyStep = false
+		if idx == 0 && cfg.refresh > 0 {
+			nextRefresh = time.Now().Add(cfg.refresh)
+		}
 		go func() {
-			defer func() { running = false }()
-			switch stageIndex {
+			defer ui.Fire(evRunDone, overlayNone)
+			start := time.Now()
+			var runErr error
+			defer func() {
+				what := stageName(idx) + " on " + env
+				if idx == 4 {
+					what = "Apply to " + env
+				}
+				if r.Scheduled {
+					// Nobody may be watching at the maintenance window: always notify.
+					result := "success"
+					if runErr != nil {
+						result = "failed"
+					}
+					notify(fmt.Sprintf("Scheduled %s finished (%s)", what, result))
+				} else {
+					notifyIfAway(what, start, runErr)
+				}
+				status := stageStatus{Env: env, Start: start, Duration: time.Since(start), Err: runErr}
+				if runErr == nil && !cfg.conf.Protected(env) && env != loadLastEnv(workDir) {
+					saveLastEnv(workDir, env)
+				}
+				bus.Post(func() {
+					lastRuns[idx] = status
+					updateStatusBar()
+					keepStageRun(idx, status)
+					saveSessionState()
+					advanceTour(idx)
+				})
+			}()
+			switch idx {
 			case 0: // Status - run hash first, then show applied vs pending
 				hashOut, hashErrOut, hashErr := runAtlas("migrate", "hash", "--env", env)
 				if hashErr != nil {
-					app.QueueUpdate(func() {
-						outputView.SetText(fmt.Sprintf("Hash failed: %v\n\nStderr:\n%s\nStdout:\n%s", hashErr, hashErrOut, hashOut))
+					runErr = hashErr
+					bus.Post(func() {
+						outputView.SetText(msg.T("output.hash_failed", hashErr, hashErrOut, hashOut))
 						outputView.ScrollToBeginning()
 					})
 					return
 				}
-				out, errOut, err := runAtlas("migrate", "status", "--env", env)
-				app.QueueUpdate(func() {
+				statusFormat := cfg.conf.FormatArgs("status")
+				out, errOut, err := runAtlas(append([]string{"migrate", "status", "--env", env}, statusFormat...)...)
+				runErr = err
+				// Out-of-order check: compare the directory with the applied versions (best effort).
+				var late []string
+				latest := ""
+				if err == nil {
+					if appliedOut, _, aerr := runAtlas("migrate", "status", "--env", env, "--format", appliedStatusFormat); aerr == nil {
+						if applied, perr := appliedVersions(appliedOut); perr == nil {
+							dir := filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, env))
+							late = outOfOrderFiles(migrationFilesByVersion(dir), applied)
+							for _, v := range applied {
+								latest = max(latest, v)
+							}
+						}
+					}
+				}
+				bus.Post(func() {
 					if err != nil {
-						outputView.SetText(fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", err, errOut, out))
+						outputView.SetText(msg.T("output.error", err, errOut, out))
 						outputView.ScrollToBeginning()
 						return
 					}
-					outputView.SetText(out + errOut)
+					outOfOrder = late
+					highlightStageOnly(stageIndex)
+					text := out + errOut
+					if statusFormat != nil {
+						text = formattedOutput(out) + tview.Escape(errOut)
+					}
+					if len(late) > 0 {
+						text += "\n\n" + outOfOrderGuidance(late, latest, env)
+					}
+					outputView.SetText(text)
 					outputView.ScrollToBeginning()
 				})
 			case 1: // Diff - generate migration file
-				out, errOut, err := runAtlas("migrate", "diff", "--env", env)
-				app.QueueUpdate(func() {
+				dir := filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, env))
+				before := migrationFilesByVersion(dir)
+				out, errOut, err := runAtlas(append([]string{"migrate", "diff", "--env", env}, flags...)...)
+				runErr = err
+				var created []string
+				for v, f := range migrationFilesByVersion(dir) {
+					if _, ok := before[v]; !ok {
+						created = append(created, f)
+					}
+				}
+				sort.Strings(created)
+				// Header the new files, then re-hash: atlas.sum holds the checksums of what diff wrote.
+				var headerErr error
+				if err == nil && len(created) > 0 && cfg.conf.Migrations.Header {
+					author, email := gitIdentity(workDir)
+					for _, name := range created {
+						h, herr := renderMigrationHeader(cfg.conf.Migrations.HeaderTemplate, migrationHeader{
+							Author: author, Email: email, Date: time.Now().Format("2006-01-02 15:04"),
+							Ticket: ticket, Version: version, File: name,
+						})
+						if herr == nil {
+							herr = prependMigrationHeader(filepath.Join(dir, name), h)
+						}
+						headerErr = errors.Join(headerErr, herr)
+					}
+					if _, hashErrOut, hashErr := runAtlas("migrate", "hash", "--env", env); hashErr != nil {
+						headerErr = errors.Join(headerErr, fmt.Errorf("migrate hash: %v: %s", hashErr, strings.TrimSpace(hashErrOut)))
+					}
+				}
+				// Summarize what the new files change, reading identifiers and dialect statements per driver.
+				var createdSQL strings.Builder
+				for _, name := range created {
+					if data, rerr := os.ReadFile(filepath.Join(dir, name)); rerr == nil {
+						createdSQL.Write(data)
+						createdSQL.WriteString("\n")
+					}
+				}
+				diffSummary := parseDiffSummary(envDriver(atlasHCL, env, getEnv), createdSQL.String())
+				// The down preview: how each new file would be undone, for the reviewer.
+				downs := make([]string, len(created))
+				for i, name := range created {
+					downs[i] = downText(env, dir, name)
+				}
+				// A crashed schema generator (data "external_schema") only shows up deep in atlas's error: run the
+				// programs again to show their own stderr first.
+				extFailures := ""
+				if src, rerr := os.ReadFile(atlasHCL); err != nil && rerr == nil {
+					if exts := parseExternalSchemas(src, getEnv); len(exts) > 0 {
+						ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+						extFailures = externalSchemaFailures(ctx, exts, workDir, envForAtlas())
+						cancel()
+					}
+				}
+				bus.Post(func() {
 					if err != nil {
-						outputView.SetText(fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", err, errOut, out))
+						outputView.SetText(extFailures + msg.T("output.error", err, errOut, out))
 						outputView.ScrollToBeginning()
 						return
 					}
-					outputView.SetText(out + errOut + "\n\n[gray]Tab to move to next stage.[-]")
+					schemaChanged = false
+					updateFooter()
+					text := out + errOut
+					if len(created) > 0 {
+						showToast(msg.T("toast.diff_created", strings.Join(created, ", ")))
+						text += "\n\n" + diffSummary
+						for i, name := range created {
+							text += "\n\n" + migrationText(dir, name) + "\n\n" + downs[i]
+						}
+					} else {
+						showToast(msg.T("toast.diff_no_changes"))
+					}
+					if headerErr != nil {
+						text += "\n\n[yellow]Migration header not written: " + tview.Escape(headerErr.Error()) + "[-]"
+					}
+					outputView.SetText(text + msg.T("output.next_stage"))
 					outputView.ScrollToBeginning()
 				})
 			case 2: // Lint (includes Hash)
 				hashOut, hashErrOut, hashErr := runAtlas("migrate", "hash", "--env", env)
-				lintCmdStr := cmdLine("migrate", "lint", "--env", env)
-				lintOut, lintErrOut, lintErr := runAtlas("migrate", "lint", "--env", env)
-				app.QueueUpdate(func() {
+				lintFormat := cfg.conf.FormatArgs("lint")
+				lintArgs := slices.Concat([]string{"migrate", "lint", "--env", env}, flags, lintFormat)
+				lintCmdStr := cmdString(lintArgs...)
+				lintOut, lintErrOut, lintErr := runAtlas(lintArgs...)
+				runErr = errors.Join(hashErr, lintErr)
+				dir := parseAtlasHCLMigrationDir(atlasHCL, env)
+				files := migrationFilesByVersion(filepath.Join(workDir, dir))
+				var findings []lintFinding // read from atlas's default output only
+				if lintFormat == nil {
+					findings = parseLintFindings(lintOut, dir, files)
+				}
+				// Row counts of the tables behind NOT NULL and index findings, from the target (best effort).
+				var hints map[string]string
+				if url, uerr := resolveEnvURL(atlasHCL, env, "url", getEnv); !cfg.noConnect && uerr == nil &&
+					slices.ContainsFunc(findings, func(f lintFinding) bool { return lintRowHintKind(f) != "" }) {
+					ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Connect.Duration)
+					if db, driver, derr := openTarget(ctx, url); derr == nil {
+						hints = lintRowHints(ctx, db, driver, workDir, findings)
+						db.Close()
+					}
+					cancel()
+				}
+				bus.Post(func() {
 					if hashErr != nil {
-						outputView.SetText(fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", hashErr, hashErrOut, hashOut))
+						outputView.SetText(msg.T("output.error", hashErr, hashErrOut, hashOut))
 						outputView.ScrollToBeginning()
 						return
 					}
-					outputView.SetText(hashOut + hashErrOut + "\n\n> " + lintCmdStr + "\n\n" + lintOut + lintErrOut)
+					lintFindings = findings
+					renderLint = func() string {
+						out, dimmed := dimAcknowledged(lintOut, dir, files, lintAcks, hints)
+						if lintFormat != nil {
+							out = formattedOutput(lintOut)
+						}
+						body := out + lintErrOut
+						if lintErr != nil {
+							body = msg.T("output.error", lintErr, lintErrOut, out)
+						}
+						notes := ""
+						switch {
+						case dimmed > 0:
+							notes += msg.T("output.lint_acknowledged", dimmed, len(lintFindings), actionKey("lint_findings"))
+						case len(lintFindings) > 0:
+							notes += msg.T("output.lint_findings_hint", actionKey("lint_findings"))
+						}
+						if lintErr == nil && isLintAvailable() {
+							notes += msg.T("output.push_hint", actionKey("push"))
+						}
+						return sectionText(stageName(2), []outputSection{
+							{Command: cmdString("migrate", "hash", "--env", env), Body: hashOut + hashErrOut},
+							{Command: lintCmdStr, Exit: exitCode(lintErr), Body: body},
+						}, notes)
+					}
+					delete(sectionTabs, stageName(2)) // a new run starts with the default folds
+					outputView.SetText(renderLint())
 					if lintErr != nil {
-						outputView.SetText(hashOut + hashErrOut + "\n\n> " + lintCmdStr + "\n\n" +
-							fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", lintErr, lintErrOut, lintOut))
+						lintPassedEnv = ""
+					} else {
+						lintPassedEnv = env
+						updateFooter()
 					}
 					outputView.ScrollToBeginning()
 				})
 			case 3: // Preview (dry-run)
-				cmdStr := cmdLine("migrate", "apply", "--env", env, "--dry-run")
-				out, errOut, err := runAtlas("migrate", "apply", "--env", env, "--dry-run")
-				app.QueueUpdate(func() {
+				dryFormat := cfg.conf.FormatArgs("dry-run")
+				args := slices.Concat([]string{"migrate", "apply", "--env", env, "--dry-run"}, txModeArgs(tx), flags, dryFormat)
+				cmdStr := cmdString(args...)
+				out, errOut, err := runAtlas(args...)
+				runErr = err
+				var prevDry string
+				var prevAt time.Time
+				if err == nil && dryFormat == nil {
+					prevDry, prevAt = swapLastDryRun(workDir, env, out+errOut)
+				}
+				// Safer patterns for the risky statements, except on tables the target says are small.
+				var sugs []safeSuggestion
+				if dryFormat == nil {
+					sugs = safeSuggestions(envDriver(atlasHCL, env, getEnv), out+errOut)
+				}
+				if url, uerr := resolveEnvURL(atlasHCL, env, "url", getEnv); len(sugs) > 0 && !cfg.noConnect && uerr == nil {
+					ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Connect.Duration)
+					if db, driver, derr := openTarget(ctx, url); derr == nil {
+						for i := range sugs {
+							sugs[i].Rows = approxRowCount(ctx, db, driver, sugs[i].Table)
+						}
+						db.Close()
+					}
+					cancel()
+					sugs = keepSafeSuggestions(sugs)
+				}
+				bus.Post(func() {
 					if err != nil {
-						outputView.SetText(fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", err, errOut, out))
+						outputView.SetText(msg.T("output.error", err, errOut, out))
+						outputView.ScrollToBeginning()
+						return
+					}
+					if dryFormat != nil {
+						// The preview, its plan and gutter read SQL: formatted output only shows in the tab.
+						outputView.SetText(tview.Escape("> "+cmdStr+"\n\n") + formattedOutput(out) + tview.Escape(errOut))
 						outputView.ScrollToBeginning()
 						return
 					}
 					previewText := out + errOut
 					prefix := "> " + cmdStr + "\n\n"
-					highlighted := highlightSQL(prefix + previewText)
-					// Show in modal with scrollable TextView
-					tv := tview.NewTextView().SetText(highlighted).SetScrollable(true).SetDynamicColors(false)
-					tv.SetBorder(true).SetTitle(" Preview (dry-run) ").SetTitleAlign(tview.AlignLeft)
-					previewFooter := tview.NewTextView().SetText(" Esc / q / Ctrl+C to close ").SetTextAlign(tview.AlignCenter)
+					tabText := tview.Escape(prefix + previewText)
+					if len(sugs) > 0 {
+						tabText += fmt.Sprintf("\n[yellow]%d risky statement(s) have a safer multi-step pattern: press p in the preview (Enter on Dry-Run) to see them.[-]", len(sugs))
+					}
+					outputView.SetText(tabText) // kept in the Dry-Run tab after the preview closes
+					outputView.ScrollToBeginning()
+					// Show in modal with scrollable TextView: plain at once, highlighted in the background (below).
+					title := msg.T("title.preview")
+					driver := envDriver(atlasHCL, env, getEnv)
+					marks := previewMarks(driver, prefix+previewText)
+					previewShown := withGutter(tview.Escape(prefix+previewText), marks, 1)
+					showingDiff := false // d toggles the diff against the previous dry-run
+					tv := tview.NewTextView().SetText(previewShown).SetScrollable(true).SetDynamicColors(true)
+					tv.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+					stopHighlight := make(chan struct{})
+					footerText := msg.T("footer.preview")
+					if len(sugs) > 0 {
+						footerText = msg.T("footer.preview_safer", len(sugs))
+					}
+					if prevDry != "" {
+						footerText = msg.T("footer.preview_diff") + footerText
+					}
+					if slices.ContainsFunc(marks, func(m gutterMark) bool { return m != gutterNone }) {
+						footerText = msg.T("footer.preview_legend") + footerText
+					}
+					previewFooter := tview.NewTextView().SetDynamicColors(true).SetText(footerText).SetTextAlign(tview.AlignCenter)
 					previewFooter.SetBorder(false)
 					closePreview := func() {
-						inOverlay = false
+						close(stopHighlight)
+						ui.Fire(evOverlayClose, overlayNone)
 						app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
 						updateUI()
 						// No auto-advance - user manually moves with arrow keys
@@ -816,369 +2446,3445 @@ func main() {
 							closePreview()
 							return nil
 						}
+						if event.Key() == tcell.KeyRune && event.Rune() == 'd' && prevDry != "" {
+							if showingDiff = !showingDiff; showingDiff {
+								tv.SetText(msg.T("output.preview_diff", prevAt.Format("2006-01-02 15:04"), ago(prevAt, time.Now())) +
+									dryRunDiff(prevDry, previewText))
+								tv.SetTitle(msg.T("title.preview_diff"))
+							} else {
+								tv.SetText(previewShown)
+								tv.SetTitle(title)
+							}
+							tv.ScrollToBeginning()
+							return nil
+						}
+						if event.Key() == tcell.KeyRune && event.Rune() == 'p' && len(sugs) > 0 {
+							showSafeSuggestions(sugs, func() { app.SetRoot(flex, true).SetFocus(tv) })
+							return nil
+						}
+						if event.Key() == tcell.KeyRune && (event.Rune() == 's' || event.Rune() == 'S') {
+							// Save plan: reviewed SQL + metadata so 'a' can later apply exactly this plan
+							path := planPath(workDir, env)
+							p := newPlan(env, filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, env)), previewText)
+							if err := savePlan(path, p); err != nil {
+								previewFooter.SetText(msg.T("title.plan_save_failed", err))
+							} else {
+								rel, _ := filepath.Rel(workDir, path)
+								previewFooter.SetText(msg.T("title.plan_saved", rel))
+							}
+							return nil
+						}
 						return event
 					}
 					flex.SetInputCapture(captureClose)
 					tv.SetInputCapture(captureClose) // focus is on tv so capture there too
-					inOverlay = true
+					ui.Fire(evOverlayOpen, overlayPreview)
 					app.SetRoot(flex, true).SetFocus(tv)
-				})
-			case 4: // Apply
-				out, errOut, err := runAtlas("migrate", "apply", "--env", env)
-				app.QueueUpdate(func() {
-					if err != nil {
-						outputView.SetText(fmt.Sprintf("Error: %v\n\nStderr:\n%s\nStdout:\n%s", err, errOut, out))
-						outputView.ScrollToBeginning()
+					if noColor {
 						return
 					}
-					outputView.SetText("Apply completed successfully.\n\n" + out + errOut)
-					outputView.ScrollToBeginning()
+					// Tokenizing a large plan takes a while: highlight screen-sized chunks in a worker and swap
+					// them in (at most every 100ms), so keys keep working and the first screen colors at once.
+					rows := 40
+					if appScreen != nil {
+						_, rows = appScreen.Size()
+					}
+					chunks := highlightChunks(prefix+previewText, rows)
+					if len(chunks) > 1 {
+						tv.SetTitle(title + msg.T("title.highlighting"))
+					}
+					go func() {
+						var done strings.Builder
+						var posted time.Time
+						line := 1 // of the next chunk, for the gutter
+						for i, c := range chunks {
+							select {
+							case <-stopHighlight:
+								return
+							default:
+							}
+							done.WriteString(withGutter(tviewText(highlightSQL(driver, c)), marks, line))
+							line += strings.Count(c, "\n")
+							last := i == len(chunks)-1
+							if !last && i > 0 && time.Since(posted) < 100*time.Millisecond {
+								continue
+							}
+							posted = time.Now()
+							text := done.String() + withGutter(tview.Escape(strings.Join(chunks[i+1:], "")), marks, line)
+							pct := (i + 1) * 100 / len(chunks)
+							bus.Post(func() {
+								select {
+								case <-stopHighlight:
+									return
+								default:
+								}
+								previewShown = text
+								if showingDiff {
+									return
+								}
+								tv.SetText(text)
+								if last {
+									tv.SetTitle(title)
+								} else {
+									tv.SetTitle(title + msg.T("title.highlighting_pct", pct))
+								}
+							})
+						}
+					}()
+				})
+			case 4: // Apply
+				var pause func(done, total int, file string) bool
+				if step {
+					pause = func(done, total int, file string) bool { return askStepContinue(env, done, total, file) }
+				}
+				runErr = applyMigrations(env, "", note, tx, flags, planned, pause)
+				if failed := runErr; errors.Is(failed, errVerifyFailed) && cfg.conf.Verify.RollbackStage != "" {
+					bus.Post(func() { offerRollback(env, failed) })
+				}
+			default:
+				if idx == seedStage && cfg.conf.Seed.Command == "" { // seed directory
+					args := seedApplyArgs(cfg.conf.Seed, env, false)
+					out, errOut, err := runAtlas(seedHashArgs(cfg.conf.Seed)...)
+					if err == nil {
+						out, errOut, err = runAtlas(args...)
+					}
+					runErr = err
+					bus.Post(func() {
+						text := tview.Escape("> " + cmdString(args...) + "\n\n" + out + errOut)
+						if err != nil {
+							text += fmt.Sprintf("\n[red]Seed failed: %s[-]", tview.Escape(err.Error()))
+						}
+						outputView.SetText(text)
+						outputView.ScrollToEnd()
+					})
+					break
+				}
+				// Seed command or custom stage: the shell command's output streams in like Apply's.
+				name, command, err := "Seed", "", error(nil)
+				if idx == seedStage {
+					command, err = seedCommand(cfg.conf.Seed, env)
+				} else {
+					s, _ := customStage(cfg.conf.Stages, firstCustom, idx)
+					name = s.Name
+					command, err = s.Render(env)
+				}
+				if err != nil {
+					runErr = err
+					bus.Post(func() { outputView.SetText("[red]" + tview.Escape(err.Error()) + "[-]") })
+					break
+				}
+				var live strings.Builder
+				live.WriteString(tview.Escape("> "+command) + "\n\n")
+				out, err := runShellStage(context.Background(), workDir, envForAtlas(), env, command, func(line string) {
+					live.WriteString(tview.Escape(line) + "\n")
+					text := live.String()
+					bus.Post(func() {
+						outputView.SetText(text)
+						outputView.ScrollToEnd()
+					})
+				})
+				runErr = err
+				bus.Post(func() {
+					text := tview.Escape("> "+command+"\n\n") + formattedOutput(out)
+					if err != nil {
+						text += fmt.Sprintf("\n[red]%s failed: %s[-]", tview.Escape(name), tview.Escape(err.Error()))
+					}
+					outputView.SetText(text)
+					outputView.ScrollToEnd()
 				})
 			}
 			// No auto-advance - user manually moves between stages with arrow keys
 		}()
 	}
 
-	// Global key capture
-	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		switch event.Key() {
-		case tcell.KeyEscape:
-			// Exit edit mode if in it
-			if editMode {
-				editMode = false
-				app.SetFocus(outputView)
-				updateUI()
+	// confirmChoice shows a floating confirmation with the buttons labels and calls onChoice with the index of
+	// the one pressed; Esc, q and Ctrl+C pick the last (cancel) one. The border is red for protected / production
+	// envs.
+	confirmChoice := func(text string, labels []string, onChoice func(i int)) {
+		if macroStop != nil {
+			// Confirmations are answered by hand: a macro (possibly from a shared .atlas9.toml) must not approve
+			// an apply. macroNext stays set, so the input capture drops a replayed key already queued.
+			close(macroStop)
+			macroStop = nil
+			showToast(msg.T("toast.macro_stopped_confirm"))
+		}
+		closeModal := func(i int) {
+			applyOverlay = nil
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetFocus(outputView)
+			updateUI()
+			onChoice(i)
+		}
+		cancel := len(labels) - 1
+		modal := tview.NewModal().
+			SetText(text).
+			AddButtons(labels).
+			SetDoneFunc(func(buttonIndex int, _ string) {
+				if buttonIndex < 0 {
+					buttonIndex = cancel
+				}
+				closeModal(buttonIndex)
+			})
+		if envColorOf(getCurrentEnvName()) == "red" {
+			modal.SetBorderColor(tcell.ColorRed)
+		}
+		modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape:
+				closeModal(cancel)
+				return nil
+			case tcell.KeyCtrlC:
+				closeModal(cancel)
+				return nil
+			case tcell.KeyLeft:
+				return tcell.NewEventKey(tcell.KeyUp, 0, event.Modifiers())
+			case tcell.KeyRight:
+				return tcell.NewEventKey(tcell.KeyDown, 0, event.Modifiers())
+			case tcell.KeyUp, tcell.KeyDown:
 				return nil
 			}
-			// When inOverlay, let overlay handle Esc
-			if inOverlay {
-				return event
+			if event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q') {
+				closeModal(cancel)
+				return nil
 			}
-			return nil // Do nothing on main screen (use 'q' to quit)
-		case tcell.KeyTab:
-			// Next stage
-			if inOverlay || editMode {
-				return event
+			return event
+		})
+		applyOverlay = modal
+		ui.Fire(evOverlayOpen, overlayConfirm)
+		app.SetFocus(modal)
+	}
+	// confirmAction shows a two-button confirmation and calls onOK or onCancel (may be nil).
+	confirmAction := func(text, okLabel, cancelLabel string, onOK, onCancel func()) {
+		confirmChoice(text, []string{okLabel, cancelLabel}, func(i int) {
+			if i == 0 {
+				onOK()
+			} else if onCancel != nil {
+				onCancel()
 			}
-			if stageIndex < len(stages)-1 {
-				stageIndex++
-			} else {
-				stageIndex = 0 // wrap around
+		})
+	}
+
+	// askNote asks for a free-text note under title: the Apply note (ticket, change reason) for the history and
+	// hooks, or the ticket for Diff's migration headers. Enter continues (an empty note is fine), Esc cancels.
+	askNote := func(title string, onDone func(note string)) {
+		input := tview.NewInputField().SetLabel(msg.T("confirm.note_label"))
+		input.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignLeft)
+		input.SetDoneFunc(func(key tcell.Key) {
+			if key != tcell.KeyEnter && key != tcell.KeyEscape {
+				return
 			}
-			highlightStage(stageIndex)
-			return nil
-		case tcell.KeyBacktab:
-			// Previous stage (Shift+Tab)
-			if inOverlay || editMode {
-				return event
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+			if key == tcell.KeyEnter {
+				onDone(strings.TrimSpace(input.GetText()))
 			}
-			if stageIndex > 0 {
-				stageIndex--
-			} else {
-				stageIndex = len(stages) - 1 // wrap around
+		})
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(input, 0, 4, true).
+				AddItem(nil, 0, 1, false), 3, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayNote)
+		app.SetRoot(wrap, true).SetFocus(input)
+	}
+
+	// askScheduleTime asks when to run a scheduled apply (see parseScheduleTime); an invalid time is shown in the
+	// title and the field stays open. Esc cancels.
+	askScheduleTime := func(onDone func(at time.Time)) {
+		input := tview.NewInputField().SetLabel(msg.T("confirm.schedule_label"))
+		input.SetBorder(true).SetTitle(msg.T("confirm.schedule_title")).SetTitleAlign(tview.AlignLeft)
+		input.SetDoneFunc(func(key tcell.Key) {
+			if key != tcell.KeyEnter && key != tcell.KeyEscape {
+				return
 			}
-			highlightStage(stageIndex)
-			return nil
-		case tcell.KeyDown:
-			// Scroll output down
-			if inOverlay || editMode {
-				return event
+			var at time.Time
+			if key == tcell.KeyEnter {
+				var err error
+				if at, err = parseScheduleTime(input.GetText(), time.Now()); err != nil {
+					input.SetTitle(" [red]" + tview.Escape(err.Error()) + "[-] ")
+					return
+				}
 			}
-			row, col := outputView.GetScrollOffset()
-			outputView.ScrollTo(row+1, col)
-			return nil
-		case tcell.KeyUp:
-			// Scroll output up
-			if inOverlay || editMode {
-				return event
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+			if key == tcell.KeyEnter {
+				onDone(at)
 			}
-			row, col := outputView.GetScrollOffset()
-			if row > 0 {
-				outputView.ScrollTo(row-1, col)
+		})
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(input, 0, 4, true).
+				AddItem(nil, 0, 1, false), 3, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlaySchedule)
+		app.SetRoot(wrap, true).SetFocus(input)
+	}
+
+	// startScheduled runs the scheduled apply s once it is due. The env must still exist; the confirmed
+	// statements go to applyPlanned, so applyMigrations aborts if the pending set changed in the meantime.
+	startScheduled := func(s *scheduledApply) {
+		showTab(stageName(4))
+		outputView.ScrollToBeginning()
+		if envs := parseAtlasHCLEnvs(atlasHCL); len(envs) > 0 && !containsString(envs, s.Run.Env) {
+			outputView.SetText(msg.T("output.schedule_env_missing", tview.Escape(s.Run.Env)))
+			notify("Scheduled apply to " + s.Run.Env + " aborted (env missing)")
+			return
+		}
+		outputView.SetText(msg.T("output.schedule_starting", s.At.Format("2006-01-02 15:04")))
+		applyNote, applyPlanned = s.Note, s.Planned
+		r := s.Run
+		r.Scheduled = true
+		runStage(r)
+	}
+	// scheduleApply arms s: a ticker keeps the footer countdown current and starts s when it is due, as soon as
+	// no other command runs. Closing s.cancel disarms it.
+	scheduleApply := func(s *scheduledApply) {
+		s.cancel = make(chan struct{})
+		scheduled = s
+		updateFooter()
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-s.cancel:
+					return
+				case <-stopBus:
+					return
+				case <-ticker.C:
+				}
+				bus.Post(func() {
+					if scheduled != s {
+						return
+					}
+					if time.Now().Before(s.At) || ui.Running() {
+						updateFooter()
+						return
+					}
+					scheduled = nil
+					close(s.cancel)
+					startScheduled(s)
+					updateFooter()
+				})
 			}
-			return nil
-		case tcell.KeyLeft, tcell.KeyRight:
-			// In edit mode, let commandInput handle left/right
-			if editMode {
-				return event
+		}()
+	}
+	// unschedule asks whether to cancel the armed scheduled apply.
+	unschedule := func() {
+		s := scheduled
+		confirmAction(msg.T("confirm.cancel_schedule", s.Run.Env, s.At.Format("2006-01-02 15:04")), msg.T("confirm.unschedule"), msg.T("confirm.keep"), func() {
+			if scheduled == s {
+				scheduled = nil
+				close(s.cancel)
+				showToast(msg.T("toast.schedule_cancelled"))
+				updateFooter()
 			}
-			// In overlay, let overlay handle
-			if inOverlay {
-				return event
+		}, nil)
+	}
+
+	// confirmApply shows the floating Apply/Cancel confirmation and calls onApply if confirmed, with the note
+	// asked for afterwards when confirm.ask_note is set. With offerStep there is a Step button too, and onApply
+	// gets step true when it was pressed.
+	confirmApply := func(text string, offerStep bool, onApply func(note string, step bool)) {
+		labels := []string{msg.T("confirm.apply"), msg.T("confirm.cancel")}
+		if offerStep {
+			labels = []string{msg.T("confirm.apply"), msg.T("confirm.step"), msg.T("confirm.cancel")}
+		}
+		confirmChoice(text, labels, func(i int) {
+			if i == len(labels)-1 {
+				return
 			}
-			return nil // consume on main screen
-		case tcell.KeyEnter:
-			if inOverlay {
-				return event // let modal (e.g. help) handle Enter
+			step := offerStep && i == 1
+			if cfg.conf.Confirm.AskNote {
+				askNote(msg.T("confirm.note_title"), func(note string) { onApply(note, step) })
+			} else {
+				onApply("", step)
 			}
-			if running {
-				return nil
+		})
+	}
+
+	// askStepContinue pauses a step-mode apply on env after done of total files (file the last one applied)
+	// until the operator continues, e.g. after checking the application's health, or stops.
+	askStepContinue = func(env string, done, total int, file string) bool {
+		answer := make(chan bool, 1)
+		bus.Post(func() {
+			confirmAction(msg.T("confirm.step_next", file, done, total, env), msg.T("confirm.continue"), msg.T("confirm.stop"),
+				func() { answer <- true }, func() { answer <- false })
+		})
+		return <-answer
+	}
+
+	// estimateImpact dry-runs env and estimates each pending statement's lock/rewrite impact. With confirm.connect
+	// (and no --no-connect), it connects to the target to look up approximate row counts and long-running
+	// transactions on the affected tables (warnings). flags are the Apply flags panel args. Call from a worker
+	// goroutine.
+	estimateImpact := func(env string, flags []string) (impacts []stmtImpact, warnings, note string, err error) {
+		out, errOut, err := runAtlas(append([]string{"migrate", "apply", "--env", env, "--dry-run"}, flags...)...)
+		if err != nil {
+			return nil, "", "", fmt.Errorf("%v\n\nStderr:\n%s\nStdout:\n%s", err, errOut, out)
+		}
+		var db *sql.DB
+		driver := ""
+		if cfg.noConnect {
+			note = "(row counts and lock check skipped: --no-connect)"
+		} else if !cfg.conf.Confirm.Connect {
+			note = "(row counts and lock check off: set connect = true under [confirm])"
+		} else if url, uerr := resolveEnvURL(atlasHCL, env, "url", getEnv); uerr != nil {
+			note = fmt.Sprintf("(row counts and lock check unavailable: %v)", uerr)
+		} else {
+			driver = dbDriver(url)
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Connect.Duration)
+			defer cancel()
+			if db, _, err = openTarget(ctx, url); err != nil {
+				note = fmt.Sprintf("(row counts and lock check unavailable: %v)", err)
+				db = nil
+			} else {
+				defer db.Close()
 			}
-			// If in edit mode, run the command and exit edit mode
-			if editMode {
-				editMode = false
-				app.SetFocus(outputView)
-				updateUI()
-				runCommandFromInput()
-				return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		impacts = analyzeDryRun(ctx, db, driver, out+errOut)
+		if db != nil {
+			var tables []string
+			for _, im := range impacts {
+				if im.Table != "" && impactRisky(im) && !containsString(tables, im.Table) {
+					tables = append(tables, im.Table)
+				}
 			}
-			// From main screen: run current stage
-			// For Apply stage, show confirmation (floating over the window)
-			if stageIndex == 4 {
-				closeApplyModal := func() {
-					applyOverlay = nil
-					inOverlay = false
-					app.SetFocus(outputView)
-					updateUI()
+			acts, aerr := blockingActivity(ctx, db, driver, tables)
+			if aerr != nil {
+				note += fmt.Sprintf(" (lock check failed: %v)", aerr)
+			}
+			warnings = renderActivity(acts)
+		}
+		return impacts, warnings, strings.TrimSpace(note), nil
+	}
+
+	// confirmApplyStage shows the estimated impact (risk table) of the pending statements, then asks for confirmation.
+	// With a non-zero at, the confirmed statements are scheduled to be applied then instead (never auto-approved).
+	confirmApplyStage := func(r stageRun, at time.Time) {
+		env, flags := r.Env, r.Flags
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		showTab(stageName(4))
+		outputView.SetText(msg.T("output.estimating"))
+		outputView.ScrollToBeginning()
+		go func() {
+			impacts, warnings, note, err := estimateImpact(env, flags)
+			bus.Post(func() {
+				ui.Fire(evRunDone, overlayNone)
+				text := msg.T("confirm.apply_changes")
+				if err != nil {
+					outputView.SetText(msg.T("output.estimate_failed", err))
+				} else {
+					outputView.SetText(warnings + msg.T("output.impact", env, renderImpactTable(impacts), note))
+					text = msg.T("confirm.apply_changes_env", env, impactSummary(impacts))
+					if warnings != "" {
+						text += "\n\n⚠ Long-running transactions on affected tables — see output before applying."
+					}
 				}
-				modal := tview.NewModal().
-					SetText("Apply changes to database?").
-					AddButtons([]string{"Apply", "Cancel"}).
-					SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-						applyOverlay = nil
-						inOverlay = false
-						app.SetFocus(outputView)
-						updateUI()
-						if buttonLabel == "Apply" {
-							outputView.SetText("Running...")
-							outputView.ScrollToBeginning()
-							go runStage()
+				outputView.ScrollToBeginning()
+				stmts := []string{} // non-nil: an apply that runs anything after an empty dry-run diverged
+				for _, im := range impacts {
+					stmts = append(stmts, im.Statement)
+				}
+				if !at.IsZero() {
+					if err != nil {
+						return // nothing confirmed to schedule; the output shows why
+					}
+					text = msg.T("confirm.schedule_apply", env, at.Format("2006-01-02 15:04"), impactSummary(impacts))
+					confirmAction(text, msg.T("confirm.schedule"), msg.T("confirm.cancel"), func() {
+						arm := func(note string) { scheduleApply(&scheduledApply{At: at, Run: r, Planned: stmts, Note: note}) }
+						if cfg.conf.Confirm.AskNote {
+							askNote(msg.T("confirm.note_title"), arm)
+						} else {
+							arm("")
 						}
-					})
-				if getCurrentEnvName() == "prod" {
-					modal.SetBorderColor(tcell.ColorRed)
+					}, nil)
+					return
 				}
-				modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-					switch event.Key() {
-					case tcell.KeyEscape:
-						closeApplyModal()
-						return nil
-					case tcell.KeyCtrlC:
-						closeApplyModal()
-						return nil
-					case tcell.KeyLeft:
-						return tcell.NewEventKey(tcell.KeyUp, 0, event.Modifiers())
-					case tcell.KeyRight:
-						return tcell.NewEventKey(tcell.KeyDown, 0, event.Modifiers())
-					case tcell.KeyUp, tcell.KeyDown:
-						return nil
+				if err == nil {
+					if ok, why := policy.autoApprove(env, stmts); ok && warnings == "" {
+						outputView.SetText(msg.T("output.auto_approved", why))
+						applyPlanned = stmts
+						runStage(r)
+						return
 					}
-					if event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q') {
-						closeApplyModal()
-						return nil
+				}
+				confirmApply(text, r.TxMode != "all", func(note string, step bool) {
+					applyNote, applyStep = note, step
+					if err == nil {
+						applyPlanned = stmts
 					}
-					return event
+					outputView.SetText(msg.T("output.running"))
+					outputView.ScrollToBeginning()
+					runStage(r)
 				})
-				applyOverlay = modal
-				inOverlay = true
-				app.SetFocus(modal)
-				return nil
-			}
-			// Update UI on main thread (do NOT call app.Draw() here — it deadlocks). Event loop will redraw after we return.
-			outputView.SetText("Running...")
-			outputView.ScrollToBeginning()
-			go runStage()
-			return nil
-		case tcell.KeyCtrlC:
-			// Exit edit mode if in it
-			if editMode {
-				editMode = false
-				app.SetFocus(outputView)
-				updateUI()
-				return nil
-			}
-			// When in overlay, let overlay handle (close); otherwise quit
-			if !inOverlay {
-				app.Stop()
-				return nil
+			})
+		}()
+	}
+
+	// confirmSeedStage previews what Seed would do on r.Env (a dry-run of the seed directory, or the command it
+	// runs) and asks before running it. Protected envs are never seeded.
+	confirmSeedStage := func(r stageRun) {
+		env, seed := r.Env, cfg.conf.Seed
+		showTab(stageName(seedStage))
+		outputView.ScrollToBeginning()
+		run := func() {
+			outputView.SetText(msg.T("output.running"))
+			runStage(r)
+		}
+		if cfg.conf.Protected(env) {
+			outputView.SetText(msg.T("output.seed_protected", env))
+			return
+		}
+		if seed.Command != "" {
+			command, err := seedCommand(seed, env)
+			if err != nil {
+				outputView.SetText("[red]" + tview.Escape(err.Error()) + "[-]")
+				return
 			}
-			return event
-		case tcell.KeyRune:
-			// When in edit mode, let all characters pass through to commandInput
-			if editMode {
-				return event
+			outputView.SetText(tview.Escape("> " + command))
+			confirmAction(msg.T("confirm.seed_command", env, command), msg.T("confirm.seed"), msg.T("confirm.cancel"), run, nil)
+			return
+		}
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		outputView.SetText(msg.T("output.seed_previewing"))
+		go func() {
+			args := seedApplyArgs(seed, env, true)
+			out, errOut, err := runAtlas(seedHashArgs(seed)...)
+			if err == nil {
+				out, errOut, err = runAtlas(args...)
 			}
-			switch event.Rune() {
-			case 'q', 'Q':
-				if inOverlay {
-					return event // let config/preview/help close on q
-				}
-				app.Stop()
-				return nil
-			case 'i', 'I':
-				// Enter edit mode (vim-like)
-				if inOverlay {
-					return event
+			bus.Post(func() {
+				ui.Fire(evRunDone, overlayNone)
+				text := tview.Escape("> " + cmdString(args...) + "\n\n" + out + errOut)
+				if err != nil {
+					outputView.SetText(text + msg.T("output.seed_preview_failed", tview.Escape(err.Error())))
+					return
 				}
-				editMode = true
-				app.SetFocus(commandInput)
-				updateUI()
-				return nil
-			case 'e', 'E':
-				// Show current environment (from .env ENVIRONMENT)
-				closeEnvModal := func() {
-					applyOverlay = nil
-					inOverlay = false
-					app.SetFocus(stageRowView)
-					updateUI()
+				stmts := dryRunStatements(out)
+				if len(stmts) == 0 {
+					outputView.SetText(text + msg.T("output.seed_none"))
+					return
 				}
-				currentEnv := getCurrentEnvName()
-				modal := tview.NewModal().
-					SetText(fmt.Sprintf("Current environment: %s\n\n(from .env ENVIRONMENT)\nEdit .env to change.", currentEnv)).
-					AddButtons([]string{"OK"}).
-					SetDoneFunc(func(buttonIndex int, buttonLabel string) {
-						closeEnvModal()
-					})
-				modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-					switch event.Key() {
-					case tcell.KeyEscape:
-						closeEnvModal()
-						return nil
-					case tcell.KeyCtrlC:
-						closeEnvModal()
-						return nil
-					case tcell.KeyLeft:
-						return tcell.NewEventKey(tcell.KeyUp, 0, event.Modifiers())
-					case tcell.KeyRight:
-						return tcell.NewEventKey(tcell.KeyDown, 0, event.Modifiers())
-					case tcell.KeyUp, tcell.KeyDown:
-						return nil // consume so only ←/→ move between buttons
-					}
-					if event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q') {
-						closeEnvModal()
-						return nil
+				outputView.SetText(text)
+				confirmAction(msg.T("confirm.seed_dir", env, len(stmts)), msg.T("confirm.seed"), msg.T("confirm.cancel"), run, nil)
+			})
+		}()
+	}
+
+	// applySavedPlan re-runs the dry-run for the current env and, only if it still matches the plan saved
+	// from the Dry-Run preview, asks for confirmation and applies it (plan/apply review workflow).
+	applySavedPlan := func() {
+		if ui.Running() {
+			return
+		}
+		env := getCurrentEnvName()
+		path := planPath(workDir, env)
+		rel, _ := filepath.Rel(workDir, path)
+		approved, err := loadPlan(path)
+		if err != nil {
+			outputView.SetText(msg.T("output.no_plan", env, rel))
+			outputView.ScrollToBeginning()
+			return
+		}
+		flags := flagArgs(4, env)
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		showTab(stageName(4))
+		outputView.SetText(msg.T("output.plan_verifying", rel))
+		outputView.ScrollToBeginning()
+		go func() {
+			dir := filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, env))
+			out, errOut, err := runAtlas(append([]string{"migrate", "apply", "--env", env, "--dry-run"}, flags...)...)
+			if err != nil {
+				ui.Fire(evRunDone, overlayNone)
+				bus.Post(func() {
+					outputView.SetText(msg.T("output.error", err, errOut, out))
+					outputView.ScrollToBeginning()
+				})
+				return
+			}
+			if why := planMismatch(approved, newPlan(env, dir, out+errOut)); why != "" {
+				ui.Fire(evRunDone, overlayNone)
+				bus.Post(func() {
+					outputView.SetText(msg.T("output.plan_refused", rel, why))
+					outputView.ScrollToBeginning()
+				})
+				return
+			}
+			bus.Post(func() {
+				ui.Fire(evRunDone, overlayNone)
+				text := msg.T("confirm.apply_plan", env, approved.CreatedAt.Format("2006-01-02 15:04"))
+				confirmApply(text, false, func(note string, _ bool) {
+					if !ui.Fire(evRunStart, overlayNone) {
+						return
 					}
-					return event
+					tx := txMode
+					outputView.SetText(msg.T("output.running"))
+					outputView.ScrollToBeginning()
+					go func() {
+						defer ui.Fire(evRunDone, overlayNone)
+						start := time.Now()
+						err := applyMigrations(env, "Applied plan "+rel+"\n\n", note, tx, flags, dryRunStatements(approved.SQL), nil)
+						if err == nil || errors.Is(err, errVerifyFailed) {
+							os.Remove(path) // a plan is consumed by applying it
+						}
+						if errors.Is(err, errVerifyFailed) && cfg.conf.Verify.RollbackStage != "" {
+							bus.Post(func() { offerRollback(env, err) })
+						}
+						notifyIfAway("Apply to "+env, start, err)
+					}()
 				})
-				applyOverlay = modal
-				inOverlay = true
-				app.SetFocus(modal)
-				return nil
-			case 'c', 'C':
-				// Config: in-app editor for atlas.hcl
-				content, err := os.ReadFile(atlasHCL)
+			})
+		}()
+	}
+
+	// browseSchema runs inspect (a schema inspect printing inspectFormat) in the background and shows schemas →
+	// tables → columns/indexes/foreign keys as an expandable tree (Enter toggles a node) titled name; ERDs are
+	// written as erd-<name>.<ext>.
+	browseSchema := func(name string, inspect func() (stdout, stderr string, err error)) {
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		outputView.SetText(msg.T("output.inspecting"))
+		outputView.ScrollToBeginning()
+		go func() {
+			defer ui.Fire(evRunDone, overlayNone)
+			out, errOut, err := inspect()
+			realm, perr := parseInspectJSON(out)
+			bus.Post(func() {
 				if err != nil {
-					// Don't use setBody here (uses QueueUpdate which can hang)
-					outputView.SetText(fmt.Sprintf("Could not read atlas.hcl: %v", err))
+					outputView.SetText(msg.T("output.error", err, errOut, out))
 					outputView.ScrollToBeginning()
-					return nil
+					return
 				}
-				ta := tview.NewTextArea()
-				ta.SetText(string(content), false)
-				ta.SetOffset(0, 0)
-				ta.SetBorder(true).SetTitle(" atlas.hcl ")
-				ta.SetTitleAlign(tview.AlignLeft)
-				saveAndClose := func() {
-					newContent := ta.GetText()
-					var msg string
-					if err := os.WriteFile(atlasHCL, []byte(newContent), 0644); err != nil {
-						msg = fmt.Sprintf("Could not write atlas.hcl: %v", err)
-					} else {
-						msg = "atlas.hcl saved."
-						go checkDocker()
-					}
-					inOverlay = false
-					app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
-					outputView.SetText(msg)
+				if perr != nil {
+					outputView.SetText(msg.T("output.inspect_parse_failed", perr, out))
 					outputView.ScrollToBeginning()
-					updateUI()
+					return
 				}
-				closeEditorWithoutSave := func() {
-					inOverlay = false
+				outputView.SetText("")
+				closeBrowser := func() {
+					ui.Fire(evOverlayClose, overlayNone)
 					app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
 					updateUI()
 				}
-				ta.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-					switch event.Key() {
-					case tcell.KeyEscape:
-						saveAndClose()
-						return nil
-					case tcell.KeyCtrlC:
-						closeEditorWithoutSave()
-						return nil
+				group := func(label string, n int) *tview.TreeNode {
+					return tview.NewTreeNode(fmt.Sprintf("[gray]%s (%d)[-]", label, n)).SetExpanded(false)
+				}
+				root := tview.NewTreeNode("[#98E0EA::b]" + tview.Escape(name) + "[-::-]")
+				for _, sch := range realm.Schemas {
+					schNode := tview.NewTreeNode(fmt.Sprintf("%s  [gray]%d tables[-]", sch.Name, len(sch.Tables)))
+					for _, t := range sch.Tables {
+						tNode := tview.NewTreeNode(t.Name).SetExpanded(false)
+						cols := group("columns", len(t.Columns))
+						for _, c := range t.Columns {
+							cols.AddChild(tview.NewTreeNode(c.describe()))
+						}
+						tNode.AddChild(cols.SetExpanded(true))
+						if t.PrimaryKey != nil {
+							tNode.AddChild(tview.NewTreeNode("[gray]primary key[-] (" + t.PrimaryKey.columns() + ")"))
+						}
+						if len(t.Indexes) > 0 {
+							ixs := group("indexes", len(t.Indexes))
+							for _, ix := range t.Indexes {
+								ixs.AddChild(tview.NewTreeNode(ix.describe()))
+							}
+							tNode.AddChild(ixs)
+						}
+						if len(t.ForeignKeys) > 0 {
+							fks := group("foreign keys", len(t.ForeignKeys))
+							for _, fk := range t.ForeignKeys {
+								fks.AddChild(tview.NewTreeNode(fk.describe()))
+							}
+							tNode.AddChild(fks)
+						}
+						schNode.AddChild(tNode)
 					}
-					return event
+					root.AddChild(schNode)
+				}
+				tree := tview.NewTreeView().SetRoot(root).SetCurrentNode(root)
+				tree.SetSelectedFunc(func(node *tview.TreeNode) {
+					node.SetExpanded(!node.IsExpanded())
 				})
-				editorFooter := tview.NewTextView().SetText(" Esc Save & exit   Ctrl+C Cancel ").SetTextAlign(tview.AlignCenter)
-				editorFooter.SetBorder(false)
-				editorFlex := tview.NewFlex().SetDirection(tview.FlexRow).
-					AddItem(ta, 0, 1, true).
-					AddItem(editorFooter, 1, 0, false)
-				inOverlay = true
-				app.SetRoot(editorFlex, true).SetFocus(ta)
-				return nil
-			case 'h', 'H':
-				// Help dialog — fixed 80 columns (custom layout so width is respected)
-				helpText := `Keys:
-  Tab / Shift+Tab  — cycle through stages
-  ↓/↑              — scroll output
-  Enter            — run current stage command
-  i                — edit command (vim-like: Esc to exit edit mode)
-  e                — show current environment (from .env)
-  c                — edit atlas.hcl config file
-  h                — this help
-  q                — quit
-
-Stages: Status → Diff → Lint → Dry-Run → Apply
-  Lint may fail if not logged in to Atlas Cloud (run 'atlas login')
-
-Apply asks for confirmation (Apply or Cancel) before running.`
-				closeHelp := func() {
-					inOverlay = false
-					app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
-					updateUI()
+				tree.SetBorder(true).SetTitle(msg.T("title.tables", tview.Escape(name))).SetTitleAlign(tview.AlignLeft)
+				browserFooter := tview.NewTextView().SetText(msg.T("footer.table_browser")).SetTextAlign(tview.AlignCenter)
+				browserFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+					AddItem(tree, 0, 1, true).
+					AddItem(browserFooter, 1, 0, false)
+				// writeERD writes an ERD next to atlas.hcl so it can be attached to design docs
+				writeERD := func(ext, content string) {
+					path := filepath.Join(workDir, "erd-"+name+"."+ext)
+					if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+						browserFooter.SetText(msg.T("title.erd_failed", err))
+						return
+					}
+					browserFooter.SetText(msg.T("title.erd_written", filepath.Base(path)))
 				}
-				helpTV := tview.NewTextView().SetText(helpText).SetDynamicColors(false)
-				helpOK := tview.NewButton("OK").SetSelectedFunc(closeHelp)
-				helpBox := tview.NewFlex().SetDirection(tview.FlexRow).
-					AddItem(helpTV, 0, 1, false).
-					AddItem(helpOK, 1, 0, true)
-				helpBox.SetBorder(true).SetTitle(" Help ")
-				helpBox.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+				showASCIIERD := func() {
+					erdView := tview.NewTextView().SetText(asciiERD(realm)).SetScrollable(true).SetDynamicColors(false)
+					erdView.SetBorder(true).SetTitle(msg.T("title.erd", tview.Escape(name))).SetTitleAlign(tview.AlignLeft)
+					erdView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+						if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+							(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+							app.SetRoot(browserFlex, true).SetFocus(tree)
+							return nil
+						}
+						return event
+					})
+					app.SetRoot(erdView, true).SetFocus(erdView)
+				}
+				tree.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 					switch event.Key() {
-					case tcell.KeyEscape:
-						closeHelp()
-						return nil
-					case tcell.KeyEnter:
-						closeHelp()
-						return nil
-					case tcell.KeyCtrlC:
-						closeHelp()
+					case tcell.KeyEscape, tcell.KeyCtrlC:
+						closeBrowser()
 						return nil
 					}
-					if event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q') {
-						closeHelp()
-						return nil
+					if event.Key() == tcell.KeyRune {
+						switch event.Rune() {
+						case 'q', 'Q':
+							closeBrowser()
+							return nil
+						case 'm', 'M':
+							writeERD("mmd", mermaidERD(realm))
+							return nil
+						case 'g', 'G':
+							writeERD("dot", dotERD(realm))
+							return nil
+						case 'd', 'D':
+							showASCIIERD()
+							return nil
+						}
 					}
 					return event
 				})
-				const helpWidth = 80
-				helpWrap := tview.NewFlex().SetDirection(tview.FlexColumn).
-					AddItem(nil, 0, 1, false).
-					AddItem(helpBox, helpWidth, 0, true).
-					AddItem(nil, 0, 1, false)
-				inOverlay = true
-				app.SetRoot(helpWrap, true).SetFocus(helpBox)
-				return nil
-			}
-		}
-		return event
-	})
+				ui.Fire(evOverlayOpen, overlayTables)
+				app.SetRoot(browserFlex, true).SetFocus(tree)
+			})
+		}()
+	}
 
-	app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
-	updateUI()
-	// Run status automatically on start (must queue from a goroutine so main can enter Run() first; QueueUpdate blocks until the event loop runs the callback)
-	go func() {
-		app.QueueUpdate(func() {
-			outputView.SetText("Running...")
+	// showTableBrowser browses the current env's schema.
+	showTableBrowser := func() {
+		env := getCurrentEnvName()
+		browseSchema(env, func() (string, string, error) {
+			return runAtlas("schema", "inspect", "--env", env, "--format", inspectFormat)
+		})
+	}
+	// showSchemaAt browses the schema the migrations of dir up to version produce: atlas replays a copy of them on
+	// env's dev database (a temporary container for docker:// dev URLs) and inspects the result.
+	showSchemaAt := func(env, dir, version string) {
+		dev, err := resolveEnvURL(atlasHCL, env, "dev", getEnv)
+		if err != nil || dev == "" {
+			showToast(msg.T("toast.schema_at_no_dev", version, env))
+			return
+		}
+		browseSchema(env+"@"+version, func() (string, string, error) {
+			tmp, err := migrationDirAt(dir, version)
+			if err != nil {
+				return "", "", err
+			}
+			defer os.RemoveAll(tmp)
+			return runAtlas("schema", "inspect", "--url", "file://"+filepath.ToSlash(tmp), "--dev-url", dev, "--format", inspectFormat)
+		})
+	}
+
+	// squashMigrations replaces the migration files in names (the selected file through the newest) with a single
+	// file regenerated by `atlas migrate diff`: the files are moved to a backup dir, the directory is re-hashed and
+	// the diff against the dev database recreates their combined effect. The result is previewed with Keep / Undo;
+	// Undo (or any failure) moves the originals back and re-hashes.
+	squashMigrations := func(env, dir string, names []string) {
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		outputView.SetText(msg.T("output.squashing", len(names)))
+		outputView.ScrollToBeginning()
+		go func() {
+			backup := squashBackupDir(workDir, time.Now())
+			relBackup, _ := filepath.Rel(workDir, backup)
+			restore := func(created []string) error {
+				for _, n := range created {
+					if err := os.Remove(filepath.Join(dir, n)); err != nil {
+						return err
+					}
+				}
+				if err := moveFiles(backup, dir, names); err != nil {
+					return err
+				}
+				_, errOut, err := runAtlas("migrate", "hash", "--env", env)
+				if err != nil {
+					return fmt.Errorf("%v: %s", err, strings.TrimSpace(errOut))
+				}
+				return nil
+			}
+			fail := func(text string, created []string) {
+				if err := restore(created); err != nil {
+					text += fmt.Sprintf("\n\n[red]Could not restore the original files: %v[-]\nThey are in %s.", err, relBackup)
+				} else {
+					text += "\n\nThe original files were restored."
+				}
+				bus.Post(func() {
+					ui.Fire(evRunDone, overlayNone)
+					outputView.SetText(text)
+					outputView.ScrollToBeginning()
+				})
+			}
+			if err := moveFiles(dir, backup, names); err != nil {
+				fail(fmt.Sprintf("[red]Could not move migrations to %s: %v[-]", relBackup, err), nil)
+				return
+			}
+			before := sqlFiles(dir)
+			if out, errOut, err := runAtlas("migrate", "hash", "--env", env); err != nil {
+				fail("> "+cmdString("migrate", "hash", "--env", env)+"\n\n"+msg.T("output.error", err, errOut, out), nil)
+				return
+			}
+			cmdStr := cmdString("migrate", "diff", "squashed", "--env", env)
+			out, errOut, err := runAtlas("migrate", "diff", "squashed", "--env", env)
+			created := newFiles(before, sqlFiles(dir))
+			if err != nil {
+				fail("> "+cmdStr+"\n\n"+msg.T("output.error", err, errOut, out), created)
+				return
+			}
+			if len(created) != 1 {
+				fail(fmt.Sprintf("> %s\n\n%s%s\n[yellow]Expected one new migration file, got %d.[-]", cmdStr, out, errOut, len(created)), created)
+				return
+			}
+			name := created[0]
+			bus.Post(func() {
+				ui.Fire(evRunDone, overlayNone)
+				outputView.SetText("> " + cmdStr + "\n\n" + out + errOut + "\n" + migrationText(dir, name))
+				outputView.ScrollToBeginning()
+				text := msg.T("confirm.squash_keep", len(names), name)
+				confirmAction(text, msg.T("confirm.keep"), msg.T("confirm.undo"), func() {
+					showToast(msg.T("toast.squash_kept", relBackup))
+				}, func() {
+					if !ui.Fire(evRunStart, overlayNone) {
+						return
+					}
+					outputView.SetText(msg.T("output.squash_restoring"))
+					go func() {
+						text := "Squash undone; the original migrations were restored."
+						if err := restore(created); err != nil {
+							text = fmt.Sprintf("[red]Could not restore the original files: %v[-]\nThey are in %s.", err, relBackup)
+						}
+						bus.Post(func() {
+							ui.Fire(evRunDone, overlayNone)
+							outputView.SetText(text)
+							outputView.ScrollToBeginning()
+						})
+					}()
+				})
+			})
+		}()
+	}
+
+	// showMigrations lists the env's migration files; Enter opens one with its statement statistics.
+	showMigrations := func() {
+		env := getCurrentEnvName()
+		rel := parseAtlasHCLMigrationDir(atlasHCL, env)
+		dir := filepath.Join(workDir, rel)
+		names := sqlFiles(dir)
+		if len(names) == 0 {
+			outputView.SetText(msg.T("output.no_migrations", rel))
+			outputView.ScrollToBeginning()
+			return
+		}
+		closeMigrations := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.migrations", rel)).SetTitleAlign(tview.AlignLeft)
+		for _, n := range names {
+			name := n
+			src, st, _ := readMigration(dir, name)
+			secondary := fmt.Sprintf("  %d statements · atlas.sum %s", st.Statements, st.SumStatus)
+			if hdr := parseMigrationHeader(src); hdr["author"] != "" || hdr["date"] != "" {
+				author, _, _ := strings.Cut(hdr["author"], " <")
+				secondary += " · " + strings.TrimSpace(strings.Join([]string{tview.Escape(author), hdr["date"]}, " "))
+			}
+			if st.Destructive > 0 {
+				secondary += fmt.Sprintf(" · [red]%d destructive[-]", st.Destructive)
+			}
+			list.AddItem(name, secondary, 0, func() {
+				viewer := tview.NewTextView().SetDynamicColors(true).SetScrollable(true).SetText(migrationText(dir, name))
+				viewer.SetBorder(true).SetTitle(msg.T("title.migration", name)).SetTitleAlign(tview.AlignLeft)
+				down := tview.NewTextView().SetDynamicColors(true).SetScrollable(true).SetText(msg.T("output.computing_down"))
+				down.SetBorder(true).SetTitle(msg.T("title.down")).SetTitleAlign(tview.AlignLeft)
+				closed := false
+				go func() {
+					text := downText(env, dir, name)
+					bus.Post(func() {
+						if !closed {
+							down.SetText(text)
+						}
+					})
+				}()
+				for _, pane := range []*tview.TextView{viewer, down} {
+					other := down
+					if pane == down {
+						other = viewer
+					}
+					pane.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+						switch {
+						case event.Key() == tcell.KeyTab || event.Key() == tcell.KeyBacktab:
+							app.SetFocus(other)
+							return nil
+						case event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+							(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')):
+							closed = true
+							app.SetRoot(list, true).SetFocus(list)
+							return nil
+						}
+						return event
+					})
+				}
+				panes := tview.NewFlex().AddItem(viewer, 0, 1, true).AddItem(down, 0, 1, false)
+				app.SetRoot(panes, true).SetFocus(viewer)
+			})
+		}
+		list.SetCurrentItem(-1) // newest last; start there
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+				(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+				closeMigrations()
+				return nil
+			}
+			if event.Key() == tcell.KeyRune && (event.Rune() == 's' || event.Rune() == 'S') {
+				tail := names[list.GetCurrentItem():]
+				if len(tail) < 2 {
+					return nil // nothing newer to squash into this file
+				}
+				closeMigrations()
+				text := msg.T("confirm.squash_prompt", len(tail), tail[0], tail[len(tail)-1])
+				confirmAction(text, msg.T("confirm.squash"), msg.T("confirm.cancel"), func() { squashMigrations(env, dir, tail) }, nil)
+				return nil
+			}
+			if event.Key() == tcell.KeyRune && (event.Rune() == 't' || event.Rune() == 'T') {
+				closeMigrations()
+				showSchemaAt(env, dir, migrationVersion(names[list.GetCurrentItem()]))
+				return nil
+			}
+			return event
+		})
+		ui.Fire(evOverlayOpen, overlayMigrations)
+		app.SetRoot(list, true).SetFocus(list)
+	}
+
+	// setVersion runs `atlas migrate set <version>` for env (after confirmation in showVersions).
+	setVersion := func(env, version string) {
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		cmdStr := cmdString("migrate", "set", version, "--env", env)
+		outputView.SetText("> " + cmdStr + "\n\n" + msg.T("output.running"))
+		outputView.ScrollToBeginning()
+		go func() {
+			out, errOut, err := runAtlas("migrate", "set", version, "--env", env)
+			bus.Post(func() {
+				ui.Fire(evRunDone, overlayNone)
+				if err != nil {
+					outputView.SetText("> " + cmdStr + "\n\n" + msg.T("output.error", err, errOut, out))
+					outputView.ScrollToBeginning()
+					return
+				}
+				outputView.SetText("> " + cmdStr + "\n\n" + out + errOut)
+				outputView.ScrollToBeginning()
+				showToast(msg.T("toast.version_set", env, version))
+			})
+		}()
+	}
+
+	// showVersions lists the env's migration versions with their applied state (from `migrate status`); Enter on
+	// one asks to move the revision table to it with `atlas migrate set`, e.g. after a manual hotfix or a
+	// partially applied migration.
+	showVersions := func() {
+		env := getCurrentEnvName()
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		outputView.SetText(msg.T("output.reading_versions"))
+		outputView.ScrollToBeginning()
+		go func() {
+			out, errOut, err := runAtlas("migrate", "status", "--env", env, "--format", appliedStatusFormat)
+			var applied []string
+			if err == nil {
+				applied, err = appliedVersions(out)
+			}
+			rows := versionRows(migrationFilesByVersion(filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, env))), applied)
+			bus.Post(func() {
+				ui.Fire(evRunDone, overlayNone)
+				if err != nil {
+					outputView.SetText(msg.T("output.error", err, errOut, out))
+					outputView.ScrollToBeginning()
+					return
+				}
+				if len(rows) == 0 {
+					outputView.SetText(msg.T("output.no_versions", env))
+					return
+				}
+				outputView.SetText("")
+				closeVersions := func() {
+					ui.Fire(evOverlayClose, overlayNone)
+					app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+					updateUI()
+				}
+				list := tview.NewList()
+				list.SetBorder(true).SetTitle(msg.T("title.versions", env)).SetTitleAlign(tview.AlignLeft)
+				for _, r := range rows {
+					row := r
+					secondary := "  [gray]pending[-]"
+					if row.Applied {
+						secondary = "  [green]applied[-]"
+					}
+					main := row.Version + "  " + row.File
+					if row.File == "" {
+						main = row.Version + "  [yellow](no file)[-]"
+					}
+					list.AddItem(main, secondary, 0, func() {
+						closeVersions()
+						text := msg.T("confirm.set_version", env, row.Version, row.Version)
+						confirmAction(text, msg.T("confirm.set"), msg.T("confirm.cancel"), func() { setVersion(env, row.Version) }, nil)
+					})
+				}
+				list.SetCurrentItem(-1)
+				list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+					if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+						(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+						closeVersions()
+						return nil
+					}
+					return event
+				})
+				ui.Fire(evOverlayOpen, overlayVersions)
+				app.SetRoot(list, true).SetFocus(list)
+			})
+		}()
+	}
+
+	// switchProject leaves this project for workspace project i: runTUI returns and main starts it again there.
+	// This project's UI state is kept for when the user comes back. Refused while a command runs or an apply
+	// is scheduled, which leaving would drop.
+	switchProject := func(i int) {
+		ws := cfg.workspace
+		switch {
+		case i == ws.Current:
+			return
+		case ui.Running():
+			showToast(msg.T("toast.workspace_running"))
+			return
+		case scheduled != nil:
+			showToast(msg.T("toast.workspace_scheduled"))
+			return
+		}
+		cur := &tabs.Tabs[tabs.Current]
+		cur.Text = outputView.GetText(false)
+		cur.Row, cur.Col = outputView.GetScrollOffset()
+		ws.States[ws.Current] = projectState{Stage: stageIndex, TxMode: txMode, Flags: stageFlagValues, Tabs: tabs, LastRuns: lastRuns}
+		ws.Next = i
+		app.Stop()
+	}
+
+	// compareEnvs reads the applied versions of envs a and b (migrate status, both at once) and shows which versions
+	// one has applied and the other has not in the Compare tab.
+	compareEnvs := func(a, b string) {
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		showTab(msg.T("tabs.compare"))
+		outputView.SetText(msg.T("output.comparing", a, b))
+		outputView.ScrollToBeginning()
+		go func() {
+			defer ui.Fire(evRunDone, overlayNone)
+			var applied [2][]string
+			var errs [2]error
+			var wg sync.WaitGroup
+			for i, env := range []string{a, b} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					out, errOut, err := runAtlas("migrate", "status", "--env", env, "--format", appliedStatusFormat)
+					if err == nil {
+						applied[i], err = appliedVersions(out)
+					}
+					if err != nil {
+						errs[i] = fmt.Errorf("%s: %v\n%s", env, err, strings.TrimSpace(errOut))
+					}
+				}()
+			}
+			wg.Wait()
+			files := migrationFilesByVersion(filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, a)))
+			maps.Copy(files, migrationFilesByVersion(filepath.Join(workDir, parseAtlasHCLMigrationDir(atlasHCL, b))))
+			text := compareApplied(a, b, files, applied[0], applied[1]).render()
+			if err := errors.Join(errs[0], errs[1]); err != nil {
+				text = "[red]Could not read the applied versions:[-]\n\n" + tview.Escape(err.Error())
+			}
+			bus.Post(func() {
+				outputView.SetText(text)
+				outputView.ScrollToBeginning()
+			})
+		}()
+	}
+	// showCompare asks for the two envs to compare: the current one and, preselected, a protected one (or the
+	// next one in atlas.hcl).
+	showCompare := func() {
+		envs := parseAtlasHCLEnvs(atlasHCL)
+		if len(envs) < 2 {
+			showToast(msg.T("toast.compare_envs"))
+			return
+		}
+		a := max(slices.Index(envs, getCurrentEnvName()), 0)
+		b := (a + 1) % len(envs)
+		for i, env := range envs {
+			if i != a && cfg.conf.Protected(env) {
+				b = i
+				break
+			}
+		}
+		form := tview.NewForm()
+		closeForm := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		form.AddDropDown("Env", envs, a, func(_ string, i int) { a = i }).
+			AddDropDown("Against", envs, b, func(_ string, i int) { b = i })
+		form.AddButton(msg.T("form.compare"), func() {
+			if a == b {
+				form.SetTitle(msg.T("title.compare_same"))
+				return
+			}
+			closeForm()
+			compareEnvs(envs[a], envs[b])
+		})
+		form.AddButton(msg.T("confirm.cancel"), closeForm)
+		form.SetCancelFunc(closeForm)
+		form.SetBorder(true).SetTitle(msg.T("title.compare")).SetTitleAlign(tview.AlignLeft)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(form, 50, 0, true).
+				AddItem(nil, 0, 1, false), 9, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayCompare)
+		app.SetRoot(wrap, true).SetFocus(form)
+	}
+
+	// showChangelog asks for a range of migrations (versions or git refs; From defaults to the latest git tag)
+	// and renders their Markdown changelog (changelogMarkdown) into the Changelog tab, then writes it to
+	// CHANGELOG-db-<from>-<to>.md or copies it to the clipboard.
+	showChangelog := func() {
+		env := getCurrentEnvName()
+		dir := parseAtlasHCLMigrationDir(atlasHCL, env)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		lastTag, _ := gitOutput(ctx, workDir, "describe", "--tags", "--abbrev=0")
+		cancel()
+		form := tview.NewForm()
+		closeForm := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		form.AddInputField("From", lastTag, 40, nil, nil).
+			AddInputField("To", "", 40, nil, nil)
+		from, _ := form.GetFormItemByLabel("From").(*tview.InputField)
+		to, _ := form.GetFormItemByLabel("To").(*tview.InputField)
+		from.SetPlaceholder("version or git tag (empty: the first migration)")
+		to.SetPlaceholder("version or git tag (empty: the newest)")
+		generate := func(copyIt bool) {
+			fromText, toText := from.GetText(), to.GetText()
+			closeForm()
+			if !ui.Fire(evRunStart, overlayNone) {
+				return
+			}
+			showTab(msg.T("tabs.changelog"))
+			outputView.SetText(msg.T("output.reading_migrations", dir))
+			outputView.ScrollToBeginning()
+			driver := sqlDriver()
+			go func() {
+				defer ui.Fire(evRunDone, overlayNone)
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				var md, path string
+				files := sqlFiles(filepath.Join(workDir, dir))
+				lo, err := resolveChangelogBound(ctx, workDir, dir, fromText, files)
+				var hi changelogBound
+				if err == nil {
+					hi, err = resolveChangelogBound(ctx, workDir, dir, toText, files)
+				}
+				var migs []changelogMigration
+				if err == nil {
+					migs, err = changelogMigrations(ctx, workDir, dir, lo, hi)
+				}
+				if err == nil {
+					md = changelogMarkdown(driver, changelogTitle(lo, hi), migs)
+					if !copyIt {
+						path = filepath.Join(workDir, changelogFileName(lo, hi))
+						err = os.WriteFile(path, []byte(md), 0644)
+					}
+				}
+				bus.Post(func() {
+					if err != nil {
+						outputView.SetText(msg.T("output.changelog_failed", tview.Escape(err.Error())))
+						return
+					}
+					if copyIt {
+						copyText(md, msg.T("toast.changelog_copied"))
+					} else {
+						rel, _ := filepath.Rel(workDir, path)
+						showToast(msg.T("toast.changelog_written", rel))
+					}
+					outputView.SetText(tview.Escape(md))
+					outputView.ScrollToBeginning()
+				})
+			}()
+		}
+		form.AddButton(msg.T("form.write_file"), func() { generate(false) }).
+			AddButton(msg.T("form.copy"), func() { generate(true) }).
+			AddButton(msg.T("confirm.cancel"), closeForm)
+		form.SetCancelFunc(closeForm)
+		form.SetBorder(true).SetTitle(msg.T("title.changelog")).SetTitleAlign(tview.AlignLeft)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(form, 60, 0, true).
+				AddItem(nil, 0, 1, false), 9, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayChangelog)
+		app.SetRoot(wrap, true).SetFocus(form)
+	}
+
+	// showBenchmark asks how to clone the current env's database, then applies its pending migrations on the
+	// clone (see runBenchmark) and shows each one's duration in a Benchmark tab.
+	showBenchmark := func() {
+		env := getCurrentEnvName()
+		url, err := resolveEnvURL(atlasHCL, env, "url", getEnv)
+		if err != nil {
+			showToast(msg.T("toast.benchmark_error", err))
+			return
+		}
+		dev, _ := resolveEnvURL(atlasHCL, env, "dev", getEnv)
+		dir := parseAtlasHCLMigrationDir(atlasHCL, env)
+		withData := 0
+		form := tview.NewForm()
+		closeForm := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		if dbDriver(url) == "sqlite" {
+			form.AddTextView("Clone", "a copy of the database file (schema and data)", 0, 1, true, false)
+		} else {
+			form.AddDropDown("Clone", []string{"schema only (dev database)", "schema and data (snapshot into dev)"}, 0, func(_ string, i int) { withData = i })
+		}
+		protected := map[string]string{}
+		for _, e := range parseAtlasHCLEnvs(atlasHCL) {
+			if u, err := resolveEnvURL(atlasHCL, e, "url", getEnv); err == nil && cfg.conf.Protected(e) {
+				protected[e] = u
+			}
+		}
+		form.AddButton(msg.T("confirm.run"), func() {
+			clone, err := chooseBenchmarkClone(url, dev, withData == 1)
+			setup := benchmarkSetup{Env: env, URL: url, Dev: dev, Dir: dir, WorkDir: workDir, Clone: clone, Environ: envForAtlas(), Protected: protected}
+			if err == nil {
+				err = setup.checkDev()
+			}
+			if err != nil {
+				form.SetTitle(" [red]" + tview.Escape(err.Error()) + "[-] ")
+				return
+			}
+			closeForm()
+			if !ui.Fire(evRunStart, overlayNone) {
+				return
+			}
+			showTab(msg.T("tabs.benchmark"))
+			outputView.SetText(msg.T("output.benchmarking", env))
+			outputView.ScrollToBeginning()
+			go func() {
+				defer ui.Fire(evRunDone, overlayNone)
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+				defer cancel()
+				if clone == cloneDevSchema {
+					if out, _, err := runAtlas("migrate", "status", "--env", env, "--format", appliedStatusFormat); err == nil {
+						setup.Applied, _ = appliedVersions(out)
+					}
+				}
+				rows, log, err := runBenchmark(ctx, setup, runAtlas)
+				text := renderBenchmark(env, log, rows, clone != cloneDevSchema || setup.Applied != nil)
+				if err != nil {
+					text += "\n[red]Benchmark failed:[-] " + tview.Escape(err.Error()) + "\n"
+				}
+				bus.Post(func() {
+					outputView.SetText(text)
+					outputView.ScrollToBeginning()
+				})
+			}()
+		})
+		form.AddButton(msg.T("confirm.cancel"), closeForm)
+		form.SetCancelFunc(closeForm)
+		form.SetBorder(true).SetTitle(msg.T("title.benchmark", tview.Escape(env))).SetTitleAlign(tview.AlignLeft)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(form, 70, 0, true).
+				AddItem(nil, 0, 1, false), 7, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayBenchmark)
+		app.SetRoot(wrap, true).SetFocus(form)
+	}
+
+	// showWorkspace lists the workspace projects with their pending migration counts (atlas migrate status in
+	// every project at once); Enter switches to the selected project.
+	showWorkspace := func() {
+		ws := cfg.workspace
+		if ws == nil {
+			showToast(msg.T("toast.no_workspace", config.WorkspaceFile))
+			return
+		}
+		runnerFor := cfg.projectRunner
+		if runnerFor == nil {
+			runnerFor = func(string) commandRunner { return cfg.runner }
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		closeWorkspace := func() {
+			cancel()
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.workspace", tview.Escape(filepath.Base(ws.Workspace.Dir)))).SetTitleAlign(tview.AlignLeft)
+		for i, p := range ws.Workspace.Projects {
+			main := tview.Escape(p.Name) + "  [gray]" + tview.Escape(p.Path) + "[-]"
+			env := ""
+			if i == ws.Current {
+				main = "[::b]" + tview.Escape(p.Name) + "[::-]  [gray]" + tview.Escape(p.Path) + " (current)[-]"
+				env = getCurrentEnvName()
+			}
+			list.AddItem(main, "  [gray]checking pending migrations…[-]", 0, func() {
+				closeWorkspace()
+				switchProject(i)
+			})
+			go func() {
+				env, n, err := projectPending(ctx, ws.Workspace, i, env, runnerFor(ws.Workspace.ProjectDir(i)))
+				secondary := fmt.Sprintf("  env %s • [green]up to date[-]", env)
+				switch {
+				case err != nil:
+					secondary = fmt.Sprintf("  env %s • [red]%s[-]", env, tview.Escape(err.Error()))
+				case n > 0:
+					secondary = fmt.Sprintf("  env %s • [yellow]%d pending[-]", env, n)
+				}
+				bus.Post(func() {
+					main, _ := list.GetItemText(i)
+					list.SetItemText(i, main, secondary)
+				})
+			}()
+		}
+		list.SetCurrentItem(ws.Current)
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+				(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+				closeWorkspace()
+				return nil
+			}
+			return event
+		})
+		ui.Fire(evOverlayOpen, overlayWorkspace)
+		app.SetRoot(list, true).SetFocus(list)
+	}
+
+	// previewExternalSchema runs the data "external_schema" programs of atlas.hcl and shows the schema they
+	// generate (stdout, highlighted as SQL) in their own tab, or their stderr when they fail.
+	previewExternalSchema := func() {
+		src, _ := os.ReadFile(atlasHCL)
+		exts := parseExternalSchemas(src, getEnv)
+		if len(exts) == 0 {
+			showToast(msg.T("toast.no_external_schema"))
+			return
+		}
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		showTab(msg.T("tabs.external_schema"))
+		outputView.SetText(msg.T("output.external_schema"))
+		outputView.ScrollToBeginning()
+		environ := envForAtlas()
+		driver := sqlDriver()
+		go func() {
+			defer ui.Fire(evRunDone, overlayNone)
+			var b strings.Builder
+			for _, e := range exts {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+				out, errOut, err := runExternalSchema(ctx, e, workDir, environ)
+				cancel()
+				fmt.Fprintf(&b, "[::b]data \"external_schema\" %q[::-]\n", e.Name)
+				if len(e.Program) > 0 {
+					fmt.Fprintf(&b, "[gray]> %s[-]\n", tview.Escape(strings.Join(e.Program, " ")))
+				}
+				if err != nil {
+					fmt.Fprintf(&b, "\n[red]Failed: %s[-]\n", tview.Escape(err.Error()))
+					if s := strings.TrimSpace(errOut); s != "" {
+						b.WriteString("\n[red]" + tview.Escape(s) + "[-]\n")
+					}
+				} else {
+					b.WriteString("\n" + tviewText(highlightSQL(driver, out)) + "\n")
+				}
+				b.WriteString("\n")
+			}
+			text := b.String()
+			bus.Post(func() {
+				outputView.SetText(text)
+				outputView.ScrollToBeginning()
+			})
+		}()
+	}
+
+	// showFlags opens the flags panel for the current stage: toggles and inputs for commonly needed atlas flags
+	// that feed the projected command and the next run. Esc or Done closes it; Clear resets the stage's flags.
+	showFlags := func() {
+		group := flagGroup(stageIndex)
+		defs := stageFlagDefs[group]
+		if len(defs) == 0 {
+			showToast(msg.T("flags.none", stageName(stageIndex)))
+			return
+		}
+		vals := stageFlagValues[group]
+		if vals == nil {
+			vals = map[string]string{}
+			stageFlagValues[group] = vals
+		}
+		closeFlags := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateDescriptionAndCommand()
+			updateUI()
+		}
+		form := tview.NewForm()
+		height := 2*len(defs) + 5
+		if defaults := cfg.conf.StageFlags(group, getCurrentEnvName()); len(defaults) > 0 {
+			form.AddTextView("default_flags", tview.Escape(strings.Join(defaults, " "))+"  [gray](a flag set below replaces its default)[-]", 0, 1, true, false)
+			height += 2
+		}
+		for _, d := range defs {
+			f := d
+			switch {
+			case f.Bool:
+				form.AddCheckbox(f.Name, vals[f.Name] == "true", func(checked bool) {
+					vals[f.Name] = ""
+					if checked {
+						vals[f.Name] = "true"
+					}
+				})
+			case len(f.Choices) > 0:
+				options := append([]string{"(atlas default)"}, f.Choices...)
+				current := 0
+				for i, c := range f.Choices {
+					if c == vals[f.Name] {
+						current = i + 1
+					}
+				}
+				form.AddDropDown(f.Name, options, current, func(option string, index int) {
+					vals[f.Name] = ""
+					if index > 0 {
+						vals[f.Name] = option
+					}
+				})
+			default:
+				input := tview.NewInputField().SetLabel(f.Name).SetText(vals[f.Name]).SetPlaceholder(f.Help)
+				input.SetChangedFunc(func(text string) {
+					vals[f.Name] = strings.TrimSpace(text)
+				})
+				form.AddFormItem(input)
+			}
+		}
+		form.AddButton(msg.T("flags.done"), closeFlags)
+		form.AddButton(msg.T("flags.clear"), func() {
+			delete(stageFlagValues, group)
+			closeFlags()
+		})
+		form.SetBorder(true).SetTitle(msg.T("flags.title", stageName(stageIndex))).SetTitleAlign(tview.AlignLeft)
+		form.SetCancelFunc(closeFlags)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(form, 0, 4, true).
+				AddItem(nil, 0, 1, false), height, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayFlags)
+		app.SetRoot(wrap, true).SetFocus(form)
+	}
+
+	// showLintRules edits the lint block that applies to the current env (see lintBlock): a severity per analyzer
+	// and the naming pattern. Save writes it back to atlas.hcl; Esc or Cancel leaves the file alone.
+	showLintRules := func() {
+		env := getCurrentEnvName()
+		src, err := os.ReadFile(atlasHCL)
+		var rules lintRules
+		var scope string
+		if err == nil {
+			rules, scope, err = readLintRules(src, env)
+		}
+		if err != nil {
+			outputView.SetText(msg.T("output.lint_rules_failed", tview.Escape(err.Error())))
+			outputView.ScrollToBeginning()
+			return
+		}
+		closeRules := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		form := tview.NewForm()
+		var help strings.Builder
+		for _, a := range lintAnalyzers {
+			name := a.Name
+			current := 0
+			for i, s := range lintSeverities {
+				if s == rules.Severity[name] {
+					current = i
+				}
+			}
+			form.AddDropDown(name, lintSeverities, current, func(option string, _ int) { rules.Severity[name] = option })
+			fmt.Fprintf(&help, " [::b]%s[::-]  %s\n", name, a.Desc)
+		}
+		form.AddInputField("naming match", rules.NamingMatch, 40, nil, func(text string) { rules.NamingMatch = text })
+		form.AddInputField("naming message", rules.NamingMessage, 40, nil, func(text string) { rules.NamingMessage = text })
+		form.AddButton(msg.T("form.save"), func() {
+			src, err := os.ReadFile(atlasHCL)
+			var out []byte
+			if err == nil {
+				out, err = writeLintRules(src, env, rules)
+			}
+			if err == nil {
+				err = os.WriteFile(atlasHCL, out, 0644)
+			}
+			if err != nil {
+				form.SetTitle(" [red]" + tview.Escape(err.Error()) + "[-] ")
+				return
+			}
+			closeRules()
+			lintPassedEnv = "" // the policy changed: Lint has to pass again before push
+			showToast(msg.T("toast.lint_rules_saved"))
+		})
+		form.AddButton(msg.T("confirm.cancel"), closeRules)
+		form.SetCancelFunc(closeRules)
+		form.SetBorder(true).SetTitle(msg.T("title.lint_rules", scope)).SetTitleAlign(tview.AlignLeft)
+		helpView := tview.NewTextView().SetDynamicColors(true).SetText(help.String())
+		panel := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(form, 2*(len(lintAnalyzers)+2)+3, 0, true).
+			AddItem(helpView, len(lintAnalyzers), 0, false)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(panel, 0, 4, true).
+				AddItem(nil, 0, 1, false), 2*(len(lintAnalyzers)+2)+3+len(lintAnalyzers), 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayLintRules)
+		app.SetRoot(wrap, true).SetFocus(form)
+	}
+
+	// updateLintTab re-renders the Lint tab after acknowledgements changed, keeping its scroll position.
+	updateLintTab := func() {
+		i := tabs.index(stageName(2))
+		if renderLint == nil || i < 0 {
+			return
+		}
+		if i != tabs.Current {
+			tabs.Tabs[i].Text = renderLint()
+			return
+		}
+		row, col := outputView.GetScrollOffset()
+		outputView.SetText(renderLint())
+		outputView.ScrollTo(row, col)
+	}
+	// addNolint asks to add an atlas:nolint directive for f to its migration file, then re-hashes atlas.sum.
+	addNolint := func(f lintFinding) {
+		code := f.Code()
+		if code == "" || f.Line == 0 || !strings.HasSuffix(f.File, ".sql") {
+			showToast(msg.T("toast.no_nolint"))
+			return
+		}
+		env := getCurrentEnvName()
+		text := fmt.Sprintf("Add \"-- atlas:nolint %s\" above line %d of %s and re-hash atlas.sum?\n\nOnly edit migrations no database has applied yet.", code, f.Line, f.File)
+		confirmAction(text, "Add", msg.T("confirm.cancel"), func() {
+			if err := addNolintDirective(filepath.Join(workDir, f.File), f.Line, code); err != nil {
+				outputView.SetText(msg.T("output.nolint_failed", tview.Escape(err.Error())))
+				outputView.ScrollToBeginning()
+				return
+			}
+			if !ui.Fire(evRunStart, overlayNone) {
+				return
+			}
+			go func() {
+				defer ui.Fire(evRunDone, overlayNone)
+				out, errOut, err := runAtlas("migrate", "hash", "--env", env)
+				bus.Post(func() {
+					showTab(stageName(2))
+					if err != nil {
+						outputView.SetText(msg.T("output.nolint_hash_failed", f.File, err, errOut, out))
+					} else {
+						outputView.SetText(msg.T("output.nolint_added", code, f.File, f.Line))
+					}
+					outputView.ScrollToBeginning()
+				})
+			}()
+		}, nil)
+	}
+	// showLintFindings lists the findings of the last Lint run. Enter acknowledges a finding (dimmed in the Lint
+	// output from then on) or takes that back; n adds an atlas:nolint directive for it instead.
+	showLintFindings := func() {
+		if len(lintFindings) == 0 {
+			showToast(msg.T("toast.no_lint_findings"))
+			return
+		}
+		closeFindings := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.lint_findings")).SetTitleAlign(tview.AlignLeft)
+		itemText := func(f lintFinding) (string, string) {
+			main := fmt.Sprintf("%s:%d  %s", tview.Escape(f.File), f.Line, tview.Escape(f.Text()))
+			secondary := "  [gray]" + tview.Escape(strings.TrimSpace(f.Code()+" "+f.Section)) + "[-]"
+			if a, ok := lintAcks[f.ackKey()]; ok {
+				main = "[gray]" + main + "[-]"
+				secondary += "  [green]acknowledged " + a.At.Format("2006-01-02") + "[-]"
+			}
+			return main, secondary
+		}
+		for _, f := range lintFindings {
+			main, secondary := itemText(f)
+			list.AddItem(main, secondary, 0, nil)
+		}
+		list.SetSelectedFunc(func(i int, _, _ string, _ rune) {
+			f := lintFindings[i]
+			if _, ok := lintAcks[f.ackKey()]; ok {
+				delete(lintAcks, f.ackKey())
+			} else {
+				lintAcks[f.ackKey()] = newLintAck(f)
+			}
+			if err := saveLintAcks(lintAcksPath(workDir), lintAcks); err != nil {
+				showToast(msg.T("toast.acks_error", err))
+			}
+			main, secondary := itemText(f)
+			list.SetItemText(i, main, secondary)
+			updateLintTab()
+		})
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch {
+			case event.Key() == tcell.KeyEscape, event.Key() == tcell.KeyCtrlC,
+				event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q'):
+				closeFindings()
+				return nil
+			case event.Key() == tcell.KeyRune && event.Rune() == 'n':
+				f := lintFindings[list.GetCurrentItem()]
+				closeFindings()
+				addNolint(f)
+				return nil
+			}
+			return event
+		})
+		ui.Fire(evOverlayOpen, overlayLintFindings)
+		app.SetRoot(list, true).SetFocus(list)
+	}
+
+	// testAllConnections tests the connection to every env of atlas.hcl (the current one first) in parallel and
+	// shows the resolve / TCP / auth timings, server version and TLS state of each in the Connection tab.
+	testAllConnections := func() {
+		if cfg.noConnect {
+			showToast(msg.T("toast.connect_off"))
+			return
+		}
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		current := getCurrentEnvName()
+		envs := []string{current}
+		for _, env := range parseAtlasHCLEnvs(atlasHCL) {
+			if env != current {
+				envs = append(envs, env)
+			}
+		}
+		showTab(msg.T("tabs.connection"))
+		outputView.SetText(msg.T("output.testing_connections", strings.Join(envs, ", ")))
+		outputView.ScrollToBeginning()
+		go func() {
+			defer ui.Fire(evRunDone, overlayNone)
+			reps := make([]connReport, len(envs))
+			var wg sync.WaitGroup
+			for i, env := range envs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					reps[i] = connReport{Env: env, Step: "url"}
+					rawURL, err := resolveEnvURL(atlasHCL, env, "url", getEnv)
+					if err != nil {
+						reps[i].Err = err
+						return
+					}
+					ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Connect.Duration)
+					defer cancel()
+					reps[i] = testConnection(ctx, env, rawURL, workDir)
+				}()
+			}
+			wg.Wait()
+			statusMu.Lock()
+			for _, r := range reps {
+				connReports[r.Env] = r
+			}
+			statusMu.Unlock()
+			var b strings.Builder
+			for _, r := range reps {
+				b.WriteString(renderConnReport(r) + "\n")
+			}
+			text := b.String() + fmt.Sprintf("[gray]Timeout per env: %s (timeouts.connect).[-]", cfg.conf.Timeouts.Connect.Duration)
+			bus.Post(func() {
+				outputView.SetText(text)
+				outputView.ScrollToBeginning()
+				updateTopRight()
+			})
+		}()
+	}
+
+	// testConnections is the connection action: when the top panel marks the current env's URL malformed or
+	// unreachable it first explains why, then offers the test of all envs.
+	testConnections := func() {
+		if dbURLProblem == "" {
+			testAllConnections()
+			return
+		}
+		text := msg.T("confirm.db_url_problem", getCurrentEnvName(), tview.Escape(dbURLShown), tview.Escape(dbURLProblem))
+		confirmAction(text, msg.T("confirm.test_all"), msg.T("confirm.close"), testAllConnections, nil)
+	}
+
+	// showDiagnostics checks the .env overlay against the project's .env template in a Diagnostics tab.
+	showDiagnostics := func() {
+		showTab(msg.T("tabs.diagnostics"))
+		outputView.SetText(renderDotenv(checkDotenv(workDir, envSnap.Overrides(), os.LookupEnv)))
+		outputView.ScrollToBeginning()
+	}
+
+	// runSnapshotJob runs a snapshot take or restore in the Snapshots tab.
+	runSnapshotJob := func(running string, job func(ctx context.Context) (string, error)) {
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		showTab(msg.T("tabs.snapshots"))
+		outputView.SetText(running)
+		outputView.ScrollToBeginning()
+		go func() {
+			defer ui.Fire(evRunDone, overlayNone)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+			defer cancel()
+			text, err := job(ctx)
+			bus.Post(func() {
+				if err != nil {
+					text = "[red]" + tview.Escape(err.Error()) + "[-]"
+				}
+				outputView.SetText(text)
+				outputView.ScrollToBeginning()
+			})
+		}()
+	}
+
+	// showSnapshots lists the snapshots of the current env's database. Enter on the first item takes one, on a
+	// snapshot restores it after confirmation; d deletes one. Protected envs have none.
+	showSnapshots := func() {
+		env := getCurrentEnvName()
+		if cfg.conf.Protected(env) {
+			showToast(msg.T("toast.snapshots_protected", env))
+			return
+		}
+		if ui.Running() {
+			showToast(msg.T("toast.snapshots_running"))
+			return
+		}
+		rawURL, err := resolveEnvURL(atlasHCL, env, "url", getEnv)
+		if err != nil {
+			showToast(msg.T("toast.snapshots_error", err))
+			return
+		}
+		driver := dbDriver(rawURL)
+		if snapshotExt(driver) == "" {
+			showToast(msg.T("toast.snapshots_unsupported", driver))
+			return
+		}
+		snaps, err := listSnapshots(workDir, env)
+		if err != nil {
+			showToast(msg.T("toast.snapshots_error", err))
+			return
+		}
+		dir := snapshotDir(workDir, env)
+		rel, _ := filepath.Rel(workDir, dir)
+		closeSnapshots := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.snapshots", tview.Escape(env), tview.Escape(rel))).SetTitleAlign(tview.AlignLeft)
+		list.AddItem("[::b]Take a snapshot now[::-]", "  [gray]"+driver+" database from the env's url[-]", 0, func() {
+			closeSnapshots()
+			runSnapshotJob("Taking a snapshot of "+env+"...", func(ctx context.Context) (string, error) {
+				start := time.Now()
+				if err := ignoreSnapshots(workDir); err != nil {
+					return "", err
+				}
+				path, err := takeSnapshot(ctx, rawURL, workDir, dir, envForAtlas())
+				if err != nil {
+					return "", err
+				}
+				info, _ := os.Stat(path)
+				rel, _ := filepath.Rel(workDir, path)
+				return fmt.Sprintf("[green]Snapshot of %s saved to %s[-] (%s in %s).\n\nPress %c to restore it.", env, tview.Escape(rel), formatBytes(info.Size()), time.Since(start).Round(time.Millisecond), actionKey("snapshots")), nil
+			})
+		})
+		for _, s := range snaps {
+			at := s.At.Format("2006-01-02 15:04:05")
+			age := "just now"
+			if d := time.Since(s.At); d >= time.Minute {
+				age = d.Round(time.Minute).String() + " ago"
+			}
+			list.AddItem(at, "  "+formatBytes(s.Size)+" · "+age, 0, func() {
+				closeSnapshots()
+				confirmAction(msg.T("confirm.restore_snapshot", env, at), msg.T("confirm.restore"), msg.T("confirm.cancel"), func() {
+					runSnapshotJob("Restoring "+env+" to the snapshot from "+at+"...", func(ctx context.Context) (string, error) {
+						if err := restoreSnapshot(ctx, rawURL, workDir, s.Path, envForAtlas()); err != nil {
+							return "", err
+						}
+						return fmt.Sprintf("[green]Restored %s to the snapshot from %s.[-]\n\nRun Status to see its migrations.", env, at), nil
+					})
+				}, nil)
+			})
+		}
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+				(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+				closeSnapshots()
+				return nil
+			}
+			if event.Key() == tcell.KeyRune && event.Rune() == 'd' && list.GetCurrentItem() > 0 {
+				s := snaps[list.GetCurrentItem()-1]
+				closeSnapshots()
+				at := s.At.Format("2006-01-02 15:04:05")
+				confirmAction(msg.T("confirm.delete_snapshot", env, at), msg.T("confirm.delete"), msg.T("confirm.cancel"), func() {
+					if err := os.Remove(s.Path); err != nil {
+						showToast(msg.T("toast.snapshots_error", err))
+						return
+					}
+					showToast(msg.T("toast.snapshot_deleted", at))
+				}, nil)
+				return nil
+			}
+			return event
+		})
+		ui.Fire(evOverlayOpen, overlaySnapshots)
+		app.SetRoot(list, true).SetFocus(list)
+	}
+
+	// showPastRuns lists the runs kept in .atlas9/runs, newest first. Enter shows one in the Previous run tab; space
+	// marks one and d diffs the marked run with the selected one, or without a mark the selected one with the
+	// stage's run before it.
+	showPastRuns := func() {
+		runs, err := listPastRuns(runsDir(workDir))
+		switch {
+		case err != nil:
+			showToast(msg.T("toast.runs_error", err))
+			return
+		case len(runs) == 0:
+			showToast(msg.T("toast.no_runs"))
+			return
+		}
+		closeRuns := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		marked := -1
+		itemText := func(i int) (string, string) {
+			r := runs[i]
+			main := r.Start.Format("2006-01-02 15:04:05") + "  " + r.Stage
+			if i == marked {
+				main = "[yellow]●[-] " + main
+			}
+			exit := fmt.Sprintf("exit %d", r.Exit)
+			if r.Exit != 0 {
+				exit = "[red]" + exit + "[-]"
+			}
+			return main, fmt.Sprintf("  %s · %s · %s", tview.Escape(r.Env), r.Duration.Round(time.Millisecond), exit)
+		}
+		show := func(tab, text string) {
+			closeRuns()
+			showTab(tab)
+			outputView.SetText(text)
+			outputView.ScrollToBeginning()
+		}
+		diff := func(older, newer pastRun) {
+			if older.Start.After(newer.Start) {
+				older, newer = newer, older
+			}
+			a, errA := older.Output()
+			b, errB := newer.Output()
+			if err := errors.Join(errA, errB); err != nil {
+				showToast(msg.T("toast.runs_error", err))
+				return
+			}
+			text := older.header() + "\n" + newer.header() + "\n\n"
+			switch d, ok := diffLines(plainText(a), plainText(b)); {
+			case !ok:
+				text += "[yellow]The outputs are too long to diff.[-]"
+			case a == b:
+				text += "[green]No differences.[-]"
+			default:
+				text += d
+			}
+			show(msg.T("tabs.run_diff"), text)
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.runs")).SetTitleAlign(tview.AlignLeft)
+		for i, r := range runs {
+			main, secondary := itemText(i)
+			list.AddItem(main, secondary, 0, func() {
+				out, err := r.Output()
+				if err != nil {
+					showToast(msg.T("toast.runs_error", err))
+					return
+				}
+				show(msg.T("tabs.previous_run"), r.header()+"\n\n"+out)
+			})
+		}
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEscape || event.Key() == tcell.KeyCtrlC ||
+				(event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q')) {
+				closeRuns()
+				return nil
+			}
+			if event.Key() != tcell.KeyRune {
+				return event
+			}
+			i := list.GetCurrentItem()
+			switch event.Rune() {
+			case ' ':
+				prev := marked
+				if marked == i {
+					marked = -1
+				} else {
+					marked = i
+				}
+				for _, j := range []int{prev, i} {
+					if j >= 0 {
+						main, secondary := itemText(j)
+						list.SetItemText(j, main, secondary)
+					}
+				}
+				return nil
+			case 'd':
+				if marked >= 0 && marked != i {
+					diff(runs[marked], runs[i])
+					return nil
+				}
+				for j := i + 1; j < len(runs); j++ {
+					if runs[j].Stage == runs[i].Stage {
+						diff(runs[j], runs[i])
+						return nil
+					}
+				}
+				showToast(msg.T("toast.no_earlier_run", runs[i].Stage))
+				return nil
+			}
+			return event
+		})
+		ui.Fire(evOverlayOpen, overlayRuns)
+		app.SetRoot(list, true).SetFocus(list)
+	}
+
+	// showLinks lists the URLs in the current output (e.g. Atlas Cloud reports); 1-9 or Enter opens one in the browser.
+	showLinks := func() {
+		urls := extractURLs(outputView.GetText(true))
+		if len(urls) == 0 {
+			outputView.SetText(outputView.GetText(false) + msg.T("output.no_links"))
+			return
+		}
+		closeLinks := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		list := tview.NewList().ShowSecondaryText(false)
+		for i, u := range urls {
+			var shortcut rune
+			if i < 9 {
+				shortcut = rune('1' + i)
+			}
+			url := u
+			list.AddItem(url, "", shortcut, func() {
+				closeLinks()
+				if err := openBrowser(url); err != nil {
+					outputView.SetText(outputView.GetText(false) + msg.T("output.open_failed", url, err))
+				}
+			})
+		}
+		list.SetBorder(true).SetTitle(msg.T("title.links")).SetTitleAlign(tview.AlignLeft)
+		list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape, tcell.KeyCtrlC:
+				closeLinks()
+				return nil
+			}
+			if event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q') {
+				closeLinks()
+				return nil
+			}
+			return event
+		})
+		height := len(urls) + 2
+		if height > 20 {
+			height = 20
+		}
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(list, 0, 4, true).
+				AddItem(nil, 0, 1, false), height, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayLinks)
+		app.SetRoot(wrap, true).SetFocus(list)
+	}
+
+	// pushToRegistry publishes the migration directory to the Atlas Cloud registry. Only allowed when logged in
+	// and after Lint succeeded for the current env; the registry URL is shown and copied to the clipboard.
+	pushToRegistry := func() {
+		if ui.Running() {
+			return
+		}
+		env := getCurrentEnvName()
+		if !isLintAvailable() {
+			outputView.SetText(msg.T("output.push_login"))
+			outputView.ScrollToBeginning()
+			return
+		}
+		if lintPassedEnv != env {
+			outputView.SetText(msg.T("output.push_lint", env))
+			outputView.ScrollToBeginning()
+			return
+		}
+		name := filepath.Base(parseAtlasHCLMigrationDir(atlasHCL, env))
+		cmdStr := cmdString("migrate", "push", name, "--env", env)
+		if !ui.Fire(evRunStart, overlayNone) {
+			return
+		}
+		outputView.SetText(msg.T("output.running"))
+		outputView.ScrollToBeginning()
+		go func() {
+			defer ui.Fire(evRunDone, overlayNone)
+			out, errOut, err := runAtlas("migrate", "push", name, "--env", env)
+			bus.Post(func() {
+				if err != nil {
+					outputView.SetText("> " + cmdStr + "\n\n" + msg.T("output.error", err, errOut, out))
+					outputView.ScrollToBeginning()
+					return
+				}
+				text := "> " + cmdStr + "\n\n" + out + errOut
+				if urls := extractURLs(out + errOut); len(urls) > 0 {
+					copyText(urls[0], msg.T("toast.registry_copied"))
+					text += msg.T("output.registry_url", urls[0], actionKey("links"))
+				}
+				outputView.SetText(text)
+				outputView.ScrollToBeginning()
+			})
+		}()
+	}
+
+	// openPullRequest commits the migration files git has not seen yet (what Diff wrote) to a new branch, pushes
+	// it and opens a pull request whose body summarizes the changes with a dry-run on the current env (see
+	// createPullRequest). Only after Lint succeeded for the env, and after a confirmation.
+	openPullRequest := func() {
+		if ui.Running() {
+			return
+		}
+		env := getCurrentEnvName()
+		if lintPassedEnv != env {
+			outputView.SetText(msg.T("output.pr_lint", env))
+			outputView.ScrollToBeginning()
+			return
+		}
+		dir := parseAtlasHCLMigrationDir(atlasHCL, env)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		files, err := pendingMigrationFiles(ctx, workDir, dir)
+		base := ""
+		if err == nil {
+			base, err = gitOutput(ctx, workDir, "rev-parse", "--abbrev-ref", "HEAD")
+		}
+		cancel()
+		if err != nil {
+			showToast(msg.T("toast.pr_error", err))
+			return
+		}
+		var sqls []string
+		for _, f := range files {
+			if filepath.Ext(f) == ".sql" {
+				sqls = append(sqls, f)
+			}
+		}
+		if len(sqls) == 0 {
+			showToast(msg.T("toast.pr_no_migrations", dir))
+			return
+		}
+		if base == "HEAD" {
+			showToast(msg.T("toast.pr_detached"))
+			return
+		}
+		branch, title := prBranchName(sqls), prTitle(sqls)
+		opener := prOpener()
+		if opener == "" {
+			opener = msg.T("confirm.pr_push_only")
+		}
+		text := msg.T("confirm.pull_request", branch, base, strings.Join(files, "\n"), opener)
+		tx := txMode
+		confirmAction(text, msg.T("confirm.open_pr"), msg.T("confirm.cancel"), func() {
+			if !ui.Fire(evRunStart, overlayNone) {
+				return
+			}
+			showTab(msg.T("tabs.pull_request"))
+			outputView.SetText(msg.T("output.pr_dry_run", env))
+			outputView.ScrollToBeginning()
+			driver := sqlDriver()
+			go func() {
+				defer ui.Fire(evRunDone, overlayNone)
+				data := prBodyData{Env: env, Base: base, Branch: branch}
+				var migs []changelogMigration
+				for _, f := range sqls {
+					sql, err := os.ReadFile(filepath.Join(workDir, f))
+					if err != nil {
+						continue
+					}
+					name := filepath.Base(f)
+					data.Migrations = append(data.Migrations, name)
+					migs = append(migs, changelogMigration{Version: migrationVersion(name), File: name, SQL: string(sql)})
+				}
+				data.Summary = changelogMarkdown(driver, "Schema changes", migs)
+				out, errOut, err := runAtlas(append([]string{"migrate", "apply", "--env", env, "--dry-run"}, txModeArgs(tx)...)...)
+				data.DryRun = strings.TrimRight(out, "\n")
+				if err != nil {
+					data.DryRun = fmt.Sprintf("-- dry-run failed: %v\n%s", err, strings.TrimRight(errOut+out, "\n"))
+				}
+				body, err := prBody(workDir, data)
+				var url, log string
+				if err == nil {
+					ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+					url, log, err = createPullRequest(ctx, workDir, prRequest{Base: base, Branch: branch, Title: title, Body: body, Files: files})
+					cancel()
+				}
+				bus.Post(func() {
+					text := tview.Escape(log)
+					switch {
+					case err != nil:
+						text += msg.T("output.pr_failed", tview.Escape(err.Error()))
+					case url != "":
+						copyText(url, msg.T("toast.pr_copied"))
+						text += msg.T("output.pr_opened", url, actionKey("links"))
+					default:
+						text += msg.T("output.pr_pushed_only", tview.Escape(branch))
+					}
+					text += msg.T("output.pr_body", tview.Escape(body))
+					outputView.SetText(text)
+					outputView.ScrollToBeginning()
+				})
+			}()
+		}, nil)
+	}
+
+	// showEnvModal shows the current environment (from .env ENVIRONMENT).
+	// showNewEnvForm collects a new env (driver preset, name, URL or .env variable, dev URL, migration dir) and
+	// appends its block to atlas.hcl, checked with the HCL parser first (appendEnvBlock). Esc or Cancel leaves
+	// it unchanged.
+	showNewEnvForm := func(name string) {
+		form := tview.NewForm()
+		closeForm := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		nameLabel, urlLabel, devLabel, dirLabel := msg.T("form.env_name"), msg.T("form.db_url"), msg.T("form.dev_url"), msg.T("form.migration_dir")
+		input := func(label string) *tview.InputField {
+			field, _ := form.GetFormItemByLabel(label).(*tview.InputField)
+			return field
+		}
+		// A preset fills in the URL templates and the env's lint block; it replaces what an earlier preset
+		// filled in, never what was typed.
+		var preset driverPreset
+		presetNames := []string{}
+		for _, p := range driverPresets {
+			presetNames = append(presetNames, p.Name)
+		}
+		form.AddDropDown(msg.T("form.driver"), append(presetNames, msg.T("form.driver_other")), len(driverPresets), func(_ string, i int) {
+			url, dev := input(urlLabel), input(devLabel)
+			if url == nil { // AddDropDown selects the initial option before the fields exist
+				return
+			}
+			var p driverPreset
+			if i >= 0 && i < len(driverPresets) {
+				p = driverPresets[i]
+			}
+			if url.GetText() == "" || url.GetText() == preset.URL {
+				url.SetText(p.URL)
+			}
+			if dev.GetText() == "" || dev.GetText() == preset.DevURL {
+				dev.SetText(p.DevURL)
+			}
+			preset = p
+		})
+		form.AddInputField(nameLabel, name, 30, nil, nil).
+			AddInputField(urlLabel, "", 60, nil, nil).
+			AddInputField(devLabel, "", 60, nil, nil).
+			AddInputField(dirLabel, "migrations", 30, nil, nil)
+		input(urlLabel).SetPlaceholder(msg.T("form.db_url_placeholder"))
+		input(devLabel).SetPlaceholder(msg.T("form.dev_url_placeholder"))
+		field := func(label string) string {
+			return strings.TrimSpace(input(label).GetText())
+		}
+		form.AddButton(msg.T("form.create"), func() {
+			spec := newEnvSpec{Name: field(nameLabel), URL: field(urlLabel), DevURL: field(devLabel), Dir: field(dirLabel), Lint: preset.Lint}
+			src, err := os.ReadFile(atlasHCL)
+			if err != nil && !os.IsNotExist(err) {
+				form.SetTitle(" [red]" + tview.Escape(err.Error()) + "[-] ")
+				return
+			}
+			out, err := appendEnvBlock(src, spec)
+			if err == nil {
+				err = os.WriteFile(atlasHCL, out, 0644)
+			}
+			if err != nil {
+				form.SetTitle(" [red]" + tview.Escape(err.Error()) + "[-] ")
+				return
+			}
+			closeForm()
+			block, _ := renderEnvBlock(spec)
+			text := msg.T("output.env_added", spec.Name, tview.Escape(string(block)))
+			if name := envURLVar(spec.URL); name != "" && getEnv(name) == "" {
+				text += msg.T("output.env_var_unset", name)
+			}
+			text += msg.T("output.env_switch_hint", spec.Name)
+			outputView.SetText(text)
+			outputView.ScrollToBeginning()
+			go checkDocker()
+		})
+		form.AddButton(msg.T("confirm.cancel"), closeForm)
+		form.SetCancelFunc(closeForm)
+		form.SetBorder(true).SetTitle(msg.T("title.new_env")).SetTitleAlign(tview.AlignLeft)
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(form, 80, 0, true).
+				AddItem(nil, 0, 1, false), 15, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayNewEnv)
+		app.SetRoot(wrap, true).SetFocus(form)
+	}
+
+	// showEnvFix is set below; envFixDismissed is the env "Run anyway" was chosen for.
+	var showEnvFix func(then func())
+	envFixDismissed := ""
+
+	showEnvModal := func() {
+		// Show current environment (from .env ENVIRONMENT)
+		closeEnvModal := func() {
+			refreshEnvModal = nil
+			applyOverlay = nil
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetFocus(stageRowView)
+			updateUI()
+		}
+		envModalText := func() string {
+			currentEnv := getCurrentEnvName()
+			text := msg.T("output.current_env", currentEnv)
+			envs := parseAtlasHCLEnvs(atlasHCL)
+			if len(envs) > 0 {
+				text += msg.T("output.hcl_envs")
+				src, _ := os.ReadFile(atlasHCL)
+				overlay := envSnap.Overrides()
+				for _, env := range envs {
+					text += "\n" + describeRefs(env, envVarRefs(string(src), env, overlay, os.LookupEnv))
+				}
+			}
+			if !containsString(envs, currentEnv) {
+				text += msg.T("output.env_undefined", currentEnv)
+			}
+			return text
+		}
+		buttons := []string{msg.T("confirm.ok"), msg.T("confirm.new_env")}
+		if envs := parseAtlasHCLEnvs(atlasHCL); len(envs) > 0 && !containsString(envs, getCurrentEnvName()) {
+			buttons = append(buttons, msg.T("confirm.fix"))
+		}
+		modal := tview.NewModal().
+			SetText(envModalText()).
+			AddButtons(buttons).
+			SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				closeEnvModal()
+				switch buttonIndex {
+				case 1:
+					showNewEnvForm("")
+				case 2:
+					showEnvFix(nil)
+				}
+			})
+		modal.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape:
+				closeEnvModal()
+				return nil
+			case tcell.KeyCtrlC:
+				closeEnvModal()
+				return nil
+			case tcell.KeyLeft:
+				return tcell.NewEventKey(tcell.KeyUp, 0, event.Modifiers())
+			case tcell.KeyRight:
+				return tcell.NewEventKey(tcell.KeyDown, 0, event.Modifiers())
+			case tcell.KeyUp, tcell.KeyDown:
+				return nil // consume so only ←/→ move between buttons
+			}
+			if event.Key() == tcell.KeyRune && (event.Rune() == 'q' || event.Rune() == 'Q') {
+				closeEnvModal()
+				return nil
+			}
+			return event
+		})
+		refreshEnvModal = func() { modal.SetText(envModalText()) }
+		applyOverlay = modal
+		ui.Fire(evOverlayOpen, overlayEnv)
+		app.SetFocus(modal)
+	}
+
+	// envMismatch reports whether atlas.hcl defines envs but not the current one, so every atlas command would fail.
+	envMismatch := func() bool {
+		envs := parseAtlasHCLEnvs(atlasHCL)
+		return len(envs) > 0 && !containsString(envs, getCurrentEnvName())
+	}
+
+	// showEnvFix offers one-key fixes for a current env atlas.hcl does not define: switch to the closest env
+	// (ENVIRONMENT in .env, or --env for this session when it was given), create the env with the New environment
+	// form, or edit ENVIRONMENT. then (may be nil) continues what was interrupted, with the env fixed or anyway.
+	showEnvFix = func(then func()) {
+		env := getCurrentEnvName()
+		envs := parseAtlasHCLEnvs(atlasHCL)
+		centered := func(p tview.Primitive, height int) tview.Primitive {
+			return tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+					AddItem(nil, 0, 1, false).
+					AddItem(p, 0, 4, true).
+					AddItem(nil, 0, 1, false), height, 0, true).
+				AddItem(nil, 0, 1, false)
+		}
+		closeFix := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		switchTo := func(name string) {
+			if cfg.envFlag != "" {
+				cfg.envFlag = name
+			} else if err := setDotenvKey(envPath, "ENVIRONMENT", name); err != nil {
+				showToast(msg.T("toast.env_write_error", tview.Escape(err.Error())))
+				return
+			} else {
+				envSnap.Load(envPath) // the watcher reloads it too, but then is about to run
+			}
+			updateTopRight()
+			updateDescriptionAndCommand()
+			highlightStageOnly(stageIndex)
+			showToast(msg.T("toast.env_switched", name))
+			if then != nil {
+				then()
+			}
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.env_missing", env)).SetTitleAlign(tview.AlignLeft)
+		where := msg.T("env_fix.sets_dotenv")
+		if cfg.envFlag != "" {
+			where = msg.T("env_fix.sets_flag")
+		}
+		if c := closestEnv(env, envs); c != "" {
+			list.AddItem(msg.T("env_fix.switch", c), msg.T("env_fix.switch_desc", where), 's', func() {
+				closeFix()
+				switchTo(c)
+			})
+		}
+		list.AddItem(msg.T("env_fix.create", env), msg.T("env_fix.create_desc"), 'n', func() {
+			closeFix()
+			showNewEnvForm(env)
+		})
+		list.AddItem(msg.T("env_fix.set"), msg.T("env_fix.set_desc", strings.Join(envs, ", "), where), 'e', func() {
+			input := tview.NewInputField().SetLabel("ENVIRONMENT=").SetText(env)
+			input.SetAutocompleteFunc(func(text string) []string {
+				var out []string
+				for _, e := range envs {
+					if strings.HasPrefix(e, text) {
+						out = append(out, e)
+					}
+				}
+				return out
+			})
+			input.SetBorder(true).SetTitle(msg.T("title.env")).SetTitleAlign(tview.AlignLeft)
+			input.SetDoneFunc(func(key tcell.Key) {
+				if key != tcell.KeyEnter && key != tcell.KeyEscape {
+					return
+				}
+				closeFix()
+				if name := strings.TrimSpace(input.GetText()); key == tcell.KeyEnter && name != "" {
+					switchTo(name)
+				}
+			})
+			app.SetRoot(centered(input, 3), true).SetFocus(input)
+		})
+		if then != nil {
+			list.AddItem(msg.T("env_fix.run_anyway"), msg.T("env_fix.run_anyway_desc", env), 'r', func() {
+				closeFix()
+				envFixDismissed = env
+				then()
+			})
+		}
+		list.SetDoneFunc(closeFix)
+		ui.Fire(evOverlayOpen, overlayEnv)
+		app.SetRoot(centered(list, 2*list.GetItemCount()+2), true).SetFocus(list)
+	}
+
+	// showConfigEditor opens the in-app editor for atlas.hcl (Esc saves, Ctrl+C cancels).
+	showConfigEditor := func() {
+		// Config: in-app editor for atlas.hcl
+		content, err := os.ReadFile(atlasHCL)
+		if err != nil {
+			outputView.SetText(msg.T("output.hcl_read_failed", err))
+			outputView.ScrollToBeginning()
+			return
+		}
+		ta := tview.NewTextArea()
+		ta.SetText(string(content), false)
+		ta.SetOffset(0, 0)
+		ta.SetBorder(true).SetTitle(" atlas.hcl ")
+		ta.SetTitleAlign(tview.AlignLeft)
+		saveAndClose := func() {
+			newContent := ta.GetText()
+			var text string
+			if err := os.WriteFile(atlasHCL, []byte(newContent), 0644); err != nil {
+				text = msg.T("output.hcl_write_failed", err)
+			} else {
+				text = msg.T("output.hcl_saved")
+				go checkDocker()
+			}
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			outputView.SetText(text)
+			outputView.ScrollToBeginning()
+			updateUI()
+		}
+		closeEditorWithoutSave := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		ta.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape:
+				saveAndClose()
+				return nil
+			case tcell.KeyCtrlC:
+				closeEditorWithoutSave()
+				return nil
+			}
+			return event
+		})
+		editorFooter := tview.NewTextView().SetText(msg.T("title.hcl_editor_footer")).SetTextAlign(tview.AlignCenter)
+		editorFooter.SetBorder(false)
+		editorFlex := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(ta, 0, 1, true).
+			AddItem(editorFooter, 1, 0, false)
+		ui.Fire(evOverlayOpen, overlayConfig)
+		app.SetRoot(editorFlex, true).SetFocus(ta)
+	}
+
+	// showHelp shows the help dialog — fixed 80 columns (custom layout so width is respected).
+	showHelp := func() {
+		closeHelp := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		rows := []helpRow{
+			{Keys: msg.T("help.keys")},
+			{"Tab / Shift+Tab", msg.T("help.key_tab")},
+			{"↓ / ↑", msg.T("help.key_scroll")},
+			{"Enter", msg.T("help.key_enter")},
+			{fmt.Sprintf("1–%d", min(stageCount, len(stageDigits))), msg.T("help.key_digits")},
+			{"Ctrl+← / →", msg.T("help.key_tabs")},
+			{"Ctrl+C", msg.T("help.key_ctrl_c")},
+		}
+		for _, action := range config.Actions { // generated from the keymap so rebound keys show as bound
+			k := string(actionKey(action))
+			if k == " " {
+				k = "Space"
+			}
+			if action == "run_stage" {
+				k += fmt.Sprintf(" 1–%d", min(stageCount, len(stageDigits)))
+			}
+			rows = append(rows, helpRow{k, msg.T("action_help." + action)})
+		}
+		rows = append(rows, helpRow{Keys: msg.T("help.stages")})
+		for i, id := range stageIDs {
+			rows = append(rows, helpRow{stageName(i), msg.T("stage_help." + id)})
+		}
+		if seedStage >= 0 {
+			rows = append(rows, helpRow{stageName(seedStage), msg.T("stage_help.seed")})
+		}
+		for _, s := range cfg.conf.Stages {
+			rows = append(rows, helpRow{s.Name, s.Description + " (" + s.Command + ")"})
+		}
+		rows = append(rows, usageRows(usageDoc)...)
+
+		// The key column fits the longest entry; descriptions wrap into the rest of the (at most 110 column) dialog.
+		keyWidth := 0
+		for _, r := range rows {
+			keyWidth = max(keyWidth, len([]rune(r.Keys)))
+		}
+		helpWidth := 110
+		if appScreen != nil {
+			w, _ := appScreen.Size()
+			helpWidth = min(helpWidth, w-4)
+		}
+		textWidth := max(20, helpWidth-keyWidth-4)
+
+		table := tview.NewTable().SetSelectable(false, false)
+		fill := func(query string) {
+			table.Clear()
+			n := 0
+			for _, r := range filterHelpRows(rows, query) {
+				if r.Text == "" {
+					if n > 0 {
+						n++ // blank line before each section
+					}
+					table.SetCell(n, 0, tview.NewTableCell(tview.Escape(r.Keys)).SetTextColor(logoColor).SetAttributes(tcell.AttrBold))
+					n++
+					continue
+				}
+				for i, line := range wrapWords(r.Text, textWidth) {
+					keys := ""
+					if i == 0 {
+						keys = r.Keys
+					}
+					table.SetCell(n, 0, tview.NewTableCell(tview.Escape(keys)).SetTextColor(logoColor))
+					table.SetCell(n, 1, tview.NewTableCell(tview.Escape(line)))
+					n++
+				}
+			}
+			table.ScrollToBeginning()
+		}
+		fill("")
+		filter := tview.NewInputField().SetLabel(msg.T("help.filter")).SetChangedFunc(fill)
+		helpBox := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(filter, 1, 0, true).
+			AddItem(table, 0, 1, false)
+		helpBox.SetBorder(true).SetTitle(msg.T("help.title")).SetTitleAlign(tview.AlignLeft)
+		helpBox.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			switch event.Key() {
+			case tcell.KeyEscape, tcell.KeyEnter, tcell.KeyCtrlC:
+				closeHelp()
+				return nil
+			case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+				table.InputHandler()(event, func(tview.Primitive) {}) // the filter keeps focus; the list scrolls
+				return nil
+			}
+			return event
+		})
+		helpWrap := tview.NewFlex().SetDirection(tview.FlexColumn).
+			AddItem(nil, 0, 1, false).
+			AddItem(helpBox, helpWidth, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayHelp)
+		app.SetRoot(helpWrap, true).SetFocus(filter)
+	}
+
+	// startStage runs r in its stage's tab; Apply first shows its impact table and confirmation. An env atlas.hcl
+	// does not define first gets the env fixes.
+	var startStage func(r stageRun)
+	startStage = func(r stageRun) {
+		if r.Env == getCurrentEnvName() && r.Env != envFixDismissed && envMismatch() {
+			showEnvFix(func() {
+				r.Env = getCurrentEnvName()
+				startStage(r)
+			})
+			return
+		}
+		switch r.Stage {
+		case 4:
+			confirmApplyStage(r, time.Time{})
+			return
+		case seedStage:
+			confirmSeedStage(r)
+			return
+		}
+		run := func() {
+			// Update UI on main thread (do NOT call app.Draw() here — it deadlocks). Event loop will redraw after we return.
+			showTab(stageName(r.Stage))
+			outputView.SetText(msg.T("output.running"))
+			outputView.ScrollToBeginning()
+			runStage(r)
+		}
+		if s, ok := customStage(cfg.conf.Stages, firstCustom, r.Stage); ok && (s.Confirm || cfg.conf.Protected(r.Env)) {
+			command, _ := s.Render(r.Env)
+			confirmAction(msg.T("confirm.run_stage", s.Name, r.Env, command), msg.T("confirm.run"), msg.T("confirm.cancel"), run, nil)
+			return
+		}
+		if r.Stage == 1 && cfg.conf.Migrations.Header {
+			askNote(msg.T("confirm.ticket_title"), func(ticket string) {
+				diffTicket = ticket
+				run()
+			})
+			return
+		}
+		run()
+	}
+	confirmEditedApply = func(args []string, run func()) {
+		if r, ok := applyStageRun(args); ok {
+			stageIndex = 4
+			highlightStage(4)
+			startStage(r)
+			return
+		}
+		urls := map[string]string{}
+		for _, e := range parseAtlasHCLEnvs(atlasHCL) {
+			if u, err := resolveEnvURL(atlasHCL, e, "url", getEnv); err == nil {
+				urls[e] = u
+			}
+		}
+		text := msg.T("confirm.edited_apply", cmdString(args...))
+		if env := targetEnv(args, urls); cfg.conf.Protected(env) {
+			text = msg.T("confirm.edited_apply_protected", cmdString(args...), env)
+		}
+		confirmAction(text, msg.T("confirm.run"), msg.T("confirm.cancel"), run, nil)
+	}
+	// runCurrentStage runs the selected stage and makes it the one the rerun action repeats.
+	runCurrentStage := func() {
+		r := currentStageRun()
+		rerun = func() { startStage(r) }
+		startStage(r)
+	}
+	offerRollback = func(env string, failed error) {
+		name := cfg.conf.Verify.RollbackStage
+		for i, s := range cfg.conf.Stages {
+			if !strings.EqualFold(strings.TrimSpace(s.Name), name) {
+				continue
+			}
+			r := stageRun{Stage: firstCustom + i, Env: env}
+			command, _ := s.Render(env)
+			confirmAction(msg.T("confirm.verify_failed", env, failed, s.Name, command), msg.T("confirm.run"), msg.T("confirm.later"), func() {
+				run := func() {
+					stageIndex = r.Stage
+					highlightStage(r.Stage)
+					showTab(stageName(r.Stage))
+					outputView.SetText(msg.T("output.running"))
+					outputView.ScrollToBeginning()
+					runStage(r)
+				}
+				if !ui.Enqueue(run) {
+					run()
+				}
+			}, nil)
+			return
+		}
+	}
+	// refreshStatus re-runs Status when it is the selected stage (r key and the --refresh ticker).
+	refreshStatus := func() {
+		if stageIndex != 0 {
+			return
+		}
+		runCurrentStage()
+	}
+	stopEditing := func() {
+		historyPos = -1
+		ui.Fire(evEditStop, overlayNone)
+		app.SetFocus(outputView)
+		updateUI()
+	}
+	submitCommand = func() {
+		text := strings.TrimSpace(commandInput.GetText())
+		words, err := splitShellWords(text)
+		if err != nil {
+			cmdProblems = []string{err.Error()}
+			updateUI()
+			return
+		}
+		parts := shellValues(words)
+		if len(parts) == 0 {
+			stopEditing()
+			return
+		}
+		if parts[0] != "atlas" {
+			cmdProblems = []string{msg.T("cmdedit.not_atlas")}
+			updateUI()
+			return
+		}
+		version, envs := atlasVersion, parseAtlasHCLEnvs(atlasHCL) // read here: the env wizard rewrites atlasHCL
+		go func() {
+			problems := checkCommand(parts[1:], atlasHelpFor, envs, version)
+			bus.Post(func() {
+				if !ui.Editing() || strings.TrimSpace(commandInput.GetText()) != text {
+					return // edited or left meanwhile
+				}
+				if len(problems) > 0 {
+					cmdProblems = problems
+					updateUI()
+					return
+				}
+				stopEditing()
+				runCommandFromInput()
+			})
+		}()
+	}
+	// recallHistory shows an earlier (delta -1) or later (+1) command of cmdHistory in the command line; past
+	// the newest one it shows what was typed before.
+	recallHistory := func(delta int) {
+		if len(cmdHistory) == 0 || historyPos < 0 && delta > 0 {
+			return
+		}
+		if historyPos < 0 {
+			historyPos, historyDraft = len(cmdHistory), commandInput.GetText()
+		}
+		historyPos = min(max(historyPos+delta, 0), len(cmdHistory))
+		if historyPos == len(cmdHistory) {
+			commandInput.SetText(historyDraft)
+		} else {
+			commandInput.SetText(cmdHistory[historyPos])
+		}
+	}
+	nextStage := func(delta int) {
+		stageIndex = (stageIndex + delta + stageCount) % stageCount // wrap around
+		highlightStage(stageIndex)
+	}
+	scrollOutput := func(delta int) {
+		row, col := outputView.GetScrollOffset()
+		if row+delta >= 0 {
+			outputView.ScrollTo(row+delta, col)
+		}
+	}
+	// scrollSideways scrolls the output by delta columns while lines are not wrapped.
+	scrollSideways := func(delta int) {
+		if wrapOutput {
+			return
+		}
+		row, col := outputView.GetScrollOffset()
+		outputView.ScrollTo(row, max(0, col+delta))
+	}
+	// toggleWrap switches wrapping of long output lines; the choice is saved with the session.
+	toggleWrap := func() {
+		wrapOutput = !wrapOutput
+		row, _ := outputView.GetScrollOffset()
+		outputView.SetWrap(wrapOutput)
+		outputView.ScrollTo(row, 0)
+		if wrapOutput {
+			showToast(msg.T("toast.wrap_on"))
+		} else {
+			showToast(msg.T("toast.wrap_off"))
+		}
+		updateFooter()
+	}
+
+	// cleanEnv drops everything in the current env's database with atlas schema clean, after a confirmation and
+	// the env name typed back, then offers to re-apply all migrations (Apply, with its own confirmation).
+	cleanEnv := func() {
+		env := getCurrentEnvName()
+		switch {
+		case cfg.conf.Protected(env):
+			showToast(msg.T("toast.clean_protected", env))
+			return
+		case ui.Running():
+			showToast(msg.T("toast.clean_running"))
+			return
+		}
+		clean := func() {
+			if !ui.Fire(evRunStart, overlayNone) {
+				return
+			}
+			args := []string{"schema", "clean", "--env", env, "--auto-approve"}
+			showTab(msg.T("tabs.clean"))
+			outputView.SetText(msg.T("output.running"))
 			outputView.ScrollToBeginning()
-			go runStage()
+			go func() {
+				out, errOut, err := runAtlas(args...)
+				bus.Post(func() {
+					ui.Fire(evRunDone, overlayNone)
+					text := "> " + cmdString(args...) + "\n\n" + out + errOut
+					if err != nil {
+						outputView.SetText(text + msg.T("output.clean_failed", err))
+						return
+					}
+					outputView.SetText(text + msg.T("output.clean_done", env))
+					lintPassedEnv = ""
+					confirmAction(msg.T("confirm.reapply", env), msg.T("confirm.apply"), msg.T("confirm.later"), func() {
+						stageIndex = 4
+						highlightStage(4)
+						startStage(stageRun{Stage: 4, Env: env, TxMode: txMode, Flags: flagArgs(4, env)})
+					}, nil)
+				})
+			}()
+		}
+		confirmAction(msg.T("confirm.clean_env", env), msg.T("confirm.clean"), msg.T("confirm.cancel"), func() {
+			askNote(msg.T("confirm.clean_type_title", env), func(typed string) {
+				if typed != env {
+					showToast(msg.T("toast.clean_mismatch", env))
+					return
+				}
+				clean()
+			})
+		}, nil)
+	}
+
+	// Key tables per mode. Keys without an entry pass through to the focused primitive, so overlays handle
+	// their own keys (Esc/q close them) and edit mode types into the command line.
+	normalKeys := keyTable{
+		specialKey(tcell.KeyEscape):      nil, // do nothing on main screen (use 'q' to quit)
+		specialKey(tcell.KeyTab):         func() { nextStage(1) },
+		specialKey(tcell.KeyBacktab):     func() { nextStage(-1) },
+		specialKey(tcell.KeyDown):        func() { scrollOutput(1) },
+		specialKey(tcell.KeyUp):          func() { scrollOutput(-1) },
+		specialKey(tcell.KeyLeft):        func() { scrollSideways(-8) },
+		specialKey(tcell.KeyRight):       func() { scrollSideways(8) },
+		ctrlKey(tcell.KeyLeft):           func() { switchTab(tabs.Current - 1) },
+		ctrlKey(tcell.KeyRight):          func() { switchTab(tabs.Current + 1) },
+		specialKey(tcell.KeyEnter):       runCurrentStage,
+		specialKey(tcell.KeyCtrlC):       app.Stop,
+		runeKey(actionKey("quit")):       app.Stop,
+		runeKey(actionKey("apply_plan")): applySavedPlan,
+		runeKey(actionKey("refresh")): func() {
+			go recheckStatus(true)
+			refreshStatus()
+		},
+		runeKey(actionKey("tables")):     showTableBrowser,
+		runeKey(actionKey("migrations")): showMigrations,
+		runeKey(actionKey("versions")):   showVersions,
+		runeKey(actionKey("flags")):      showFlags,
+		runeKey(actionKey("tx_mode")): func() {
+			if stageIndex == 3 || stageIndex == 4 {
+				txMode = nextTxMode(txMode)
+				updateDescriptionAndCommand()
+			}
+		},
+		runeKey(actionKey("push")):            pushToRegistry,
+		runeKey(actionKey("links")):           showLinks,
+		runeKey(actionKey("env")):             showEnvModal,
+		runeKey(actionKey("config")):          showConfigEditor,
+		runeKey(actionKey("help")):            showHelp,
+		runeKey(actionKey("workspace")):       showWorkspace,
+		runeKey(actionKey("external_schema")): previewExternalSchema,
+		runeKey(actionKey("lint_rules")):      showLintRules,
+		runeKey(actionKey("lint_findings")):   showLintFindings,
+		runeKey(actionKey("snapshots")):       showSnapshots,
+		runeKey(actionKey("clean")):           cleanEnv,
+		runeKey(actionKey("connection")):      testConnections,
+		runeKey(actionKey("runs")):            showPastRuns,
+		runeKey(actionKey("compare")):         showCompare,
+		runeKey(actionKey("changelog")):       showChangelog,
+		runeKey(actionKey("pull_request")):    openPullRequest,
+		runeKey(actionKey("benchmark")):       showBenchmark,
+		runeKey(actionKey("diagnostics")):     showDiagnostics,
+		runeKey(actionKey("wrap")):            toggleWrap,
+		runeKey(actionKey("rerun")): func() {
+			if rerun == nil {
+				showToast(msg.T("toast.nothing_to_rerun"))
+				return
+			}
+			if !ui.Enqueue(rerun) {
+				rerun()
+			}
+		},
+		runeKey(actionKey("schedule")): func() {
+			switch {
+			case scheduled != nil:
+				unschedule()
+			case stageIndex != 4:
+				showToast(msg.T("toast.schedule_not_apply"))
+			case ui.Running():
+				showToast(msg.T("toast.schedule_running"))
+			default:
+				r := currentStageRun()
+				askScheduleTime(func(at time.Time) { confirmApplyStage(r, at) })
+			}
+		},
+		runeKey(actionKey("edit")): func() {
+			// Enter edit mode (vim-like)
+			if ui.Fire(evEditStart, overlayNone) {
+				app.SetFocus(commandInput)
+				updateUI()
+			}
+		},
+	}
+	for i := range min(stageCount, len(stageDigits)) {
+		normalKeys[runeKey(rune('1'+i))] = func() {
+			stageIndex = i
+			highlightStage(i)
+		}
+	}
+	normalKeys[runeKey(actionKey("run_stage"))] = func() {
+		runLeader = true
+		updateFooter()
+	}
+	// replayMacro feeds keys to the app as if typed. Each key waits until the previous one was handled and no
+	// command runs or is queued, so a macro that runs Status and then Dry-Run replays as recorded.
+	replayMacro := func(keys []*tcell.EventKey) {
+		stop := make(chan struct{})
+		macroStop = stop
+		showToast(msg.T("toast.macro_replaying", len(keys)))
+		onUI := func(f func()) bool {
+			done := make(chan struct{})
+			bus.Post(func() {
+				f()
+				close(done)
+			})
+			select {
+			case <-done:
+				return true
+			case <-stop:
+				return false
+			}
+		}
+		go func() {
+			for _, ev := range keys {
+				for ui.Running() || ui.Queued() {
+					select {
+					case <-stop:
+						return
+					case <-time.After(100 * time.Millisecond):
+					}
+				}
+				ack := make(chan struct{})
+				if !onUI(func() { macroNext, macroAck = ev, ack }) {
+					return
+				}
+				app.QueueEvent(ev)
+				select {
+				case <-ack:
+				case <-stop:
+					return
+				}
+				if !onUI(func() {}) { // the key's handler has returned
+					return
+				}
+			}
+			bus.Post(func() {
+				if macroStop == stop {
+					macroStop = nil
+				}
+			})
+		}()
+	}
+	// playMacro replays a saved macro, or says why it cannot.
+	playMacro := func(name, keys string) {
+		evs, err := parseMacro(keys)
+		if err != nil {
+			showToast(msg.T("toast.macro_error", tview.Escape(name), tview.Escape(err.Error())))
+			return
+		}
+		replayMacro(evs)
+	}
+	// showMacros lists the macro recorded last and the saved ones; with none saved the last one replays at once.
+	showMacros := func() {
+		if len(cfg.conf.Macros) == 0 {
+			if lastMacro == nil {
+				k := string(actionKey("macro"))
+				showToast(msg.T("toast.no_macro", k))
+				return
+			}
+			replayMacro(lastMacro)
+			return
+		}
+		closeMacros := func() {
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+		}
+		list := tview.NewList()
+		list.SetBorder(true).SetTitle(msg.T("title.macros")).SetTitleAlign(tview.AlignLeft)
+		if last := lastMacro; last != nil {
+			list.AddItem("Last recorded", "  [gray]"+tview.Escape(encodeMacro(last))+"[-]", actionKey("macro"), func() {
+				closeMacros()
+				replayMacro(last)
+			})
+		}
+		names := make([]string, 0, len(cfg.conf.Macros))
+		for name := range cfg.conf.Macros {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			var shortcut rune
+			if i < 9 {
+				shortcut = rune('1' + i)
+			}
+			keys := cfg.conf.Macros[name]
+			list.AddItem(name, "  [gray]"+tview.Escape(keys)+"[-]", shortcut, func() {
+				closeMacros()
+				playMacro(name, keys)
+			})
+		}
+		list.SetDoneFunc(closeMacros)
+		ui.Fire(evOverlayOpen, overlayMacros)
+		app.SetRoot(list, true).SetFocus(list)
+	}
+	// askMacroName offers to save a macro just recorded under a name in the project's .atlas9.toml.
+	askMacroName := func(keys string) {
+		input := tview.NewInputField().SetLabel(msg.T("form.name"))
+		input.SetBorder(true).SetTitle(msg.T("title.save_macro", tview.Escape(keys), strings.Repeat(string(actionKey("macro")), 2))).SetTitleAlign(tview.AlignLeft)
+		input.SetDoneFunc(func(key tcell.Key) {
+			if key != tcell.KeyEnter && key != tcell.KeyEscape {
+				return
+			}
+			name := strings.TrimSpace(input.GetText())
+			path := filepath.Join(workDir, config.ProjectFile)
+			if key == tcell.KeyEnter && name != "" {
+				if err := config.SaveMacro(path, name, keys); err != nil {
+					input.SetTitle(" [red]" + tview.Escape(err.Error()) + "[-] ")
+					return
+				}
+			}
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+			if key == tcell.KeyEnter && name != "" {
+				if cfg.conf.Macros == nil {
+					cfg.conf.Macros = map[string]string{}
+				}
+				cfg.conf.Macros[name] = keys
+				showToast(msg.T("toast.macro_saved", tview.Escape(name), config.ProjectFile))
+			}
+		})
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(input, 0, 4, true).
+				AddItem(nil, 0, 1, false), 3, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayNote)
+		app.SetRoot(wrap, true).SetFocus(input)
+	}
+	// setFilter shows only the lines of the current output that match pattern, or with problems set only its
+	// errors and warnings; an empty pattern clears the filter.
+	var clearFilter func()
+	setFilter := func(pattern string, problems bool) error {
+		if pattern == "" && !problems {
+			clearFilter()
+			return nil
+		}
+		f := outFilter
+		if f == nil || outputView.GetText(false) != f.Shown {
+			f = &outputFilter{Full: outputView.GetText(false), Plain: outputView.GetText(true)}
+			f.Row, f.Col = outputView.GetScrollOffset()
+		}
+		text, kept, total, err := filterLines(f.Plain, pattern, problems)
+		if err != nil {
+			return err
+		}
+		f.Pattern, f.Problems, f.Kept, f.Total = pattern, problems, kept, total
+		if f.Kept == 0 {
+			text = msg.T("output.filter_no_match")
+		}
+		outputView.SetText(text)
+		outputView.ScrollToBeginning()
+		f.Shown = outputView.GetText(false)
+		outFilter = f
+		updateFooter()
+		return nil
+	}
+	clearFilter = func() {
+		f := outFilter
+		if f == nil {
+			return
+		}
+		outFilter = nil
+		if outputView.GetText(false) == f.Shown {
+			outputView.SetText(f.Full)
+			outputView.ScrollTo(f.Row, f.Col)
+		}
+		updateFooter()
+	}
+	// askFilter asks for the regex of the output filter, starting from the current one.
+	askFilter := func() {
+		input := tview.NewInputField().SetLabel("/")
+		if f := outFilter; f != nil {
+			input.SetText(f.Pattern)
+		}
+		input.SetBorder(true).SetTitle(msg.T("title.filter")).SetTitleAlign(tview.AlignLeft)
+		input.SetDoneFunc(func(key tcell.Key) {
+			if key != tcell.KeyEnter && key != tcell.KeyEscape {
+				return
+			}
+			ui.Fire(evOverlayClose, overlayNone)
+			app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+			updateUI()
+			if key == tcell.KeyEnter {
+				if err := setFilter(input.GetText(), false); err != nil {
+					showToast(msg.T("toast.filter_error", tview.Escape(err.Error())))
+				}
+			}
+		})
+		wrap := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexColumn).
+				AddItem(nil, 0, 1, false).
+				AddItem(input, 0, 4, true).
+				AddItem(nil, 0, 1, false), 3, 0, true).
+			AddItem(nil, 0, 1, false)
+		ui.Fire(evOverlayOpen, overlayNote)
+		app.SetRoot(wrap, true).SetFocus(input)
+	}
+	normalKeys[runeKey(actionKey("filter"))] = askFilter
+	normalKeys[runeKey(actionKey("filter_problems"))] = func() {
+		if f := outFilter; f != nil && f.Problems {
+			clearFilter()
+			return
+		}
+		setFilter("", true)
+	}
+	// moveSection moves the section cursor of the current tab by delta (from none: to the first or last) and
+	// scrolls to the section.
+	moveSection := func(delta int) {
+		s := currentSections()
+		if s == nil {
+			showToast(msg.T("toast.no_sections"))
+			return
+		}
+		if s.Selected < 0 && delta < 0 {
+			s.Selected = len(s.Sections) - 1
+		} else {
+			s.Selected = max(0, min(len(s.Sections)-1, s.Selected+delta))
+		}
+		text, headers := s.render()
+		outputView.SetText(text)
+		outputView.ScrollTo(headers[s.Selected], 0)
+		updateFooter()
+	}
+	// toggleSection folds or unfolds the section with the cursor; false when no section has it.
+	toggleSection := func() bool {
+		s := currentSections()
+		if s == nil || s.Selected < 0 {
+			return false
+		}
+		s.Sections[s.Selected].Folded = !s.Sections[s.Selected].Folded
+		text, headers := s.render()
+		outputView.SetText(text)
+		outputView.ScrollTo(headers[s.Selected], 0)
+		return true
+	}
+	normalKeys[runeKey(actionKey("next_section"))] = func() { moveSection(1) }
+	normalKeys[runeKey(actionKey("prev_section"))] = func() { moveSection(-1) }
+	normalKeys[specialKey(tcell.KeyEnter)] = func() {
+		if !toggleSection() {
+			runCurrentStage()
+		}
+	}
+	// Space folds sections whatever the keymap binds to it (run_stage by default), which gets it otherwise.
+	onSpace := normalKeys[runeKey(' ')]
+	normalKeys[runeKey(' ')] = func() {
+		if !toggleSection() && onSpace != nil {
+			onSpace()
+		}
+	}
+	normalKeys[specialKey(tcell.KeyEscape)] = func() {
+		if s := currentSections(); s != nil && s.Selected >= 0 {
+			row, col := outputView.GetScrollOffset()
+			s.Selected = -1
+			text, _ := s.render()
+			outputView.SetText(text)
+			outputView.ScrollTo(row, col)
+			updateFooter()
+			return
+		}
+		clearFilter()
+	}
+	// Visual mode: a line selection over the output, extended with the arrow keys and copied with y.
+	drawSelection := func() {
+		outputView.SetText(selection.render()).Highlight(selectCursorRegion).ScrollToHighlight()
+		updateFooter()
+	}
+	endSelection := func() {
+		row, col := outputView.GetScrollOffset()
+		outputView.SetInputCapture(nil)
+		outputView.SetRegions(false).SetText(selection.Full).ScrollTo(row, col)
+		selection = nil
+		ui.Fire(evOverlayClose, overlayNone)
+		updateFooter()
+	}
+	normalKeys[runeKey(actionKey("select"))] = func() {
+		if strings.TrimSpace(outputView.GetText(true)) == "" {
+			showToast(msg.T("toast.no_output_select"))
+			return
+		}
+		row, _ := outputView.GetScrollOffset()
+		selection = newOutputSelection(outputView.GetText(false), outputView.GetText(true), row)
+		outputView.SetRegions(true)
+		outputView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			_, _, _, height := outputView.GetInnerRect()
+			switch event.Key() {
+			case tcell.KeyDown:
+				selection.move(1)
+			case tcell.KeyUp:
+				selection.move(-1)
+			case tcell.KeyPgDn:
+				selection.move(max(1, height-1))
+			case tcell.KeyPgUp:
+				selection.move(-max(1, height-1))
+			case tcell.KeyHome:
+				selection.move(-len(selection.Lines))
+			case tcell.KeyEnd:
+				selection.move(len(selection.Lines))
+			case tcell.KeyEscape:
+				endSelection()
+				return nil
+			case tcell.KeyRune:
+				switch event.Rune() {
+				case 'y':
+					text := selection.text()
+					lo, hi := selection.bounds()
+					endSelection()
+					copyText(text, msg.T("toast.lines_copied", hi-lo+1))
+				case actionKey("select"):
+					endSelection()
+				}
+				return nil
+			default:
+				return nil
+			}
+			drawSelection()
+			return nil
+		})
+		ui.Fire(evOverlayOpen, overlaySelect)
+		app.SetFocus(outputView)
+		drawSelection()
+	}
+	normalKeys[runeKey(actionKey("macro"))] = func() {
+		switch {
+		case !macroRecording:
+			macroRecording, macroRec = true, nil
+		case len(macroRec) == 0: // pressed twice: replay
+			macroRecording = false
+			showMacros()
+		default:
+			macroRecording, lastMacro = false, macroRec
+			askMacroName(encodeMacro(macroRec))
+		}
+		updateFooter()
+	}
+	// While a command runs the main screen works as usual, but nothing new can be started.
+	runningKeys := keyTable{}
+	for k, h := range normalKeys {
+		runningKeys[k] = h
+	}
+	for _, action := range []string{"apply_plan", "tables", "versions", "push", "external_schema", "snapshots", "clean", "connection", "runs", "compare", "changelog", "pull_request", "benchmark", "diagnostics", "filter", "filter_problems", "next_section", "prev_section", "select"} {
+		runningKeys[runeKey(actionKey(action))] = nil
+	}
+	runningKeys[runeKey(actionKey("refresh"))] = func() { go recheckStatus(true) }
+	// Enter while running queues one run of the selected stage, started as soon as the current command finishes.
+	runningKeys[specialKey(tcell.KeyEnter)] = func() {
+		idx := stageIndex
+		ui.Enqueue(func() {
+			if stageIndex != idx {
+				stageIndex = idx
+				highlightStage(idx)
+			}
+			runCurrentStage()
+		})
+	}
+	keys := keymap{
+		modeNormal:  normalKeys,
+		modeRunning: runningKeys,
+		modeEditing: keyTable{
+			specialKey(tcell.KeyEscape): stopEditing,
+			specialKey(tcell.KeyCtrlC):  stopEditing,
+			specialKey(tcell.KeyCtrlX):  showCommandEditor,
+			specialKey(tcell.KeyUp):     func() { recallHistory(-1) },
+			specialKey(tcell.KeyDown):   func() { recallHistory(1) },
+			specialKey(tcell.KeyEnter):  submitCommand,
+		},
+		modeOverlay: keyTable{},
+	}
+
+	// Status auto-refresh: tick once a second to update the countdown and re-run Status when it is due.
+	if cfg.refresh > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stopBus:
+					return
+				case <-ticker.C:
+				}
+				bus.Post(func() {
+					if stageIndex != 0 || tabs.Tabs[tabs.Current].Label != stageName(0) {
+						return
+					}
+					if mode, _ := ui.Mode(); mode == modeNormal && !nextRefresh.IsZero() && !time.Now().Before(nextRefresh) {
+						refreshStatus()
+					}
+					updateFooter()
+				})
+			}
+		}()
+	}
+
+	// Global key capture: route every key through the current mode's key table.
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		lastKey = time.Now()
+		if cfg.recorder != nil {
+			cfg.recorder.key(event)
+		}
+		if macroStop == nil && macroNext != nil && event == macroNext {
+			macroNext = nil // queued by a replay stopped since
+			return nil
+		}
+		if macroStop != nil {
+			if event != macroNext {
+				close(macroStop) // a key typed during a replay stops it
+				macroStop, macroNext = nil, nil
+				showToast(msg.T("toast.macro_stopped"))
+				return nil
+			}
+			close(macroAck)
+			macroNext = nil
+		}
+		mode, _ := ui.Mode()
+		if macroRecording && (keyOf(event) != runeKey(actionKey("macro")) || (mode != modeNormal && mode != modeRunning)) {
+			macroRec = append(macroRec, event)
+			updateFooter()
+		}
+		if runLeader && (mode == modeNormal || mode == modeRunning) {
+			// Second key of the run chord: a stage digit jumps there and runs it (queued while running).
+			runLeader = false
+			updateFooter()
+			if i := int(event.Rune() - '1'); event.Key() == tcell.KeyRune && i >= 0 && i < min(stageCount, len(stageDigits)) {
+				run := func() {
+					stageIndex = i
+					highlightStage(i)
+					runCurrentStage()
+				}
+				if !ui.Enqueue(run) {
+					run()
+				}
+			}
+			return nil
+		}
+		return keys.dispatch(mode, event)
+	})
+
+	app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		appScreen = screen
+		if cfg.recorder != nil {
+			cfg.recorder.frame(screen)
+		}
+		firstDrawOnce.Do(func() { close(firstDraw) })
+	})
+	app.SetRoot(rootWithOverlay, true).SetFocus(outputView)
+	updateUI()
+	// Run status automatically once the first frame is up (skeleton lines until then), unless the config
+	// has problems to show first.
+	switch {
+	case restored: // a workspace project switched back to, or the last session, shows its tabs as they were left
+		if resumed != nil {
+			showToast(msg.T("toast.session_restored", resumed.Saved.Format("Jan 2 15:04")))
+		}
+	case cfg.confErr != nil:
+		outputView.SetText(msg.T("output.config_errors", tview.Escape(cfg.confErr.Error()), config.UserPath(), config.ProjectFile))
+	default:
+		skeleton, block := "", "▒"
+		if asciiMode {
+			block = "."
+		}
+		for _, n := range skeletonLines {
+			skeleton += strings.Repeat(block, n) + "\n"
+		}
+		outputView.SetText("[gray]" + skeleton + "[-]")
+		go func() {
+			select {
+			case <-firstDraw:
+				bus.Post(runCurrentStage)
+			case <-stopBus:
+			}
+		}()
+	}
+	// Check the atlas CLI version once; an outdated CLI gets a modal with the upgrade command.
+	go func() {
+		select {
+		case <-firstDraw:
+		case <-stopBus:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.conf.Timeouts.Login.Duration)
+		defer cancel()
+		res, err := cfg.runner.Run(ctx, []string{"version"}, os.Environ())
+		v := parseAtlasVersion(res.Stdout + res.Stderr)
+		bus.Post(func() {
+			atlasMissing, atlasVersion = err != nil, v
+			updateTopRight()
+			min := cfg.conf.MinAtlasVersion
+			if err != nil || v == "" || min == "" || !newerVersion(v, min) {
+				return
+			}
+			upgrade := atlasUpgradeCommand()
+			if ui.InOverlay() {
+				showToast(msg.T("toast.atlas_outdated", v, min, upgrade))
+				return
+			}
+			text := msg.T("confirm.atlas_outdated", v, min, upgrade)
+			confirmAction(text, msg.T("confirm.copy_command"), msg.T("confirm.close"), func() {
+				copyText(upgrade, msg.T("toast.upgrade_copied"))
+			}, nil)
 		})
 	}()
-	if err := app.Run(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	screen := cfg.screen
+	highContrastOn := cfg.conf.Theme == highContrastTheme
+	if screen == nil && (asciiMode || highContrastOn) {
+		s, err := tcell.NewScreen()
+		if err != nil {
+			return err
+		}
+		screen = s
+	}
+	if screen != nil {
+		if asciiMode {
+			screen = asciiScreen{screen}
+		}
+		if highContrastOn {
+			screen = highContrastScreen{screen}
+		}
+		app.SetScreen(screen)
+	}
+	if cfg.started != nil {
+		cfg.started(app)
 	}
+	err := app.Run()
+	saveSessionState()
+	return err
 }
 
 func hexToTCell(hex string) tcell.Color {
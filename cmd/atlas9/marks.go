@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+
+	"github.com/rivo/tview"
+)
+
+// noColor turns off syntax highlighting and emoji status marks (NO_COLOR convention or --no-color). tcell
+// itself drops all colors when NO_COLOR is set, so main exports it for --no-color too.
+var noColor = os.Getenv("NO_COLOR") != ""
+
+// statusMark renders a header check result: a green ✅ OK / red ❌ FAIL (labelled so color is never the only
+// signal), or [ok] / [fail] with noColor or asciiMode.
+func statusMark(ok bool) string {
+	switch {
+	case (noColor || asciiMode) && ok:
+		return tview.Escape("[ok]")
+	case noColor || asciiMode:
+		return tview.Escape("[fail]")
+	case ok:
+		return "[green]✅ OK[-]"
+	}
+	return "[red]❌ FAIL[-]"
+}
+
+// warnMark is the yellow counterpart of statusMark for checks that passed with a problem, e.g. a malformed
+// database URL: ⚠ BAD, or [bad] with noColor or asciiMode.
+func warnMark() string {
+	if noColor || asciiMode {
+		return tview.Escape("[bad]")
+	}
+	return "[yellow]⚠ BAD[-]"
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricSample is one series of the metrics of a headless run.
+type metricSample struct {
+	Name   string
+	Labels [][2]string // label name and value, in output order
+	Value  float64
+}
+
+// series is s in the Prometheus text format without the value, e.g. atlas9_pending_migrations{env="prod"}.
+func (s metricSample) series() string {
+	if len(s.Labels) == 0 {
+		return s.Name
+	}
+	parts := make([]string, len(s.Labels))
+	for i, l := range s.Labels {
+		parts[i] = l[0] + "=" + strconv.Quote(l[1])
+	}
+	return s.Name + "{" + strings.Join(parts, ",") + "}"
+}
+
+// label returns the value of label name of s.
+func (s metricSample) label(name string) string {
+	for _, l := range s.Labels {
+		if l[0] == name {
+			return l[1]
+		}
+	}
+	return ""
+}
+
+// metricHelp describes the metrics atlas9 writes: type and HELP text by name.
+var metricHelp = map[string][2]string{
+	"atlas9_stage_duration_seconds":     {"gauge", "Duration of the last run of a stage."},
+	"atlas9_stage_success":              {"gauge", "1 if the last run of a stage succeeded, else 0."},
+	"atlas9_apply_total":                {"counter", "Applies run by atlas9 run, by result."},
+	"atlas9_pending_migrations":         {"gauge", "Pending migrations reported by the last Status stage."},
+	"atlas9_last_run_timestamp_seconds": {"gauge", "Unix time of the last atlas9 run."},
+}
+
+// pendingFilesRe reads the pending count from `atlas migrate status` output.
+var pendingFilesRe = regexp.MustCompile(`Pending Files:\s+(\d+)`)
+
+// headlessMetrics returns the metrics of a headless run on env: duration and success per stage, an apply
+// counter increment (value 1) when apply ran, the pending migrations when status ran, and the run's time.
+func headlessMetrics(env string, results []stageResult, now time.Time) []metricSample {
+	var out []metricSample
+	for _, r := range results {
+		labels := [][2]string{{"env", env}, {"stage", r.Stage}}
+		success := 1.0
+		if r.Err != nil {
+			success = 0
+		}
+		out = append(out,
+			metricSample{Name: "atlas9_stage_duration_seconds", Labels: labels, Value: r.Duration.Seconds()},
+			metricSample{Name: "atlas9_stage_success", Labels: labels, Value: success})
+		switch r.Stage {
+		case "apply":
+			result := "success"
+			if r.Err != nil {
+				result = "failure"
+			}
+			out = append(out, metricSample{Name: "atlas9_apply_total", Labels: [][2]string{{"env", env}, {"result", result}}, Value: 1})
+		case "status":
+			if m := pendingFilesRe.FindStringSubmatch(r.Output); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				out = append(out, metricSample{Name: "atlas9_pending_migrations", Labels: [][2]string{{"env", env}}, Value: float64(n)})
+			}
+		}
+	}
+	return append(out, metricSample{Name: "atlas9_last_run_timestamp_seconds", Labels: [][2]string{{"env", env}}, Value: float64(now.Unix())})
+}
+
+// metricsLineRe matches a sample line of the Prometheus text format: the series and its value.
+var metricsLineRe = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*(?:\{.*\})?)\s+(\S+)$`)
+
+// writeMetricsTextfile merges samples into the Prometheus textfile at path (for node_exporter's textfile
+// collector): series of other envs and stages are kept, counters (_total) add to the value in the file and
+// gauges replace it. The file is replaced atomically so the collector never reads half of it.
+func writeMetricsTextfile(path string, samples []metricSample) error {
+	values := map[string]float64{}
+	if f, err := os.Open(path); err == nil {
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			m := metricsLineRe.FindStringSubmatch(strings.TrimSpace(sc.Text()))
+			if m == nil {
+				continue // comments and blank lines
+			}
+			if v, err := strconv.ParseFloat(m[2], 64); err == nil {
+				values[m[1]] = v
+			}
+		}
+		f.Close()
+	}
+	for _, s := range samples {
+		if strings.HasSuffix(s.Name, "_total") {
+			values[s.series()] += s.Value
+		} else {
+			values[s.series()] = s.Value
+		}
+	}
+	series := make([]string, 0, len(values))
+	for k := range values {
+		series = append(series, k)
+	}
+	sort.Strings(series)
+	var b strings.Builder
+	last := ""
+	for _, k := range series {
+		name, _, _ := strings.Cut(k, "{")
+		if name != last {
+			if h, ok := metricHelp[name]; ok {
+				fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, h[1], name, h[0])
+			}
+			last = name
+		}
+		fmt.Fprintf(&b, "%s %s\n", k, strconv.FormatFloat(values[k], 'g', -1, 64))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// statsdName makes s safe as a StatsD name segment (no dots, colons, pipes or spaces).
+func statsdName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', ' ', '#':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// statsdLines formats samples as plain StatsD lines named atlas9.<env>...: stage durations as timers (ms),
+// success and pending migrations as gauges and applies as counters. The run timestamp is left out.
+func statsdLines(samples []metricSample) []string {
+	var out []string
+	for _, s := range samples {
+		prefix := "atlas9." + statsdName(s.label("env"))
+		switch s.Name {
+		case "atlas9_stage_duration_seconds":
+			out = append(out, fmt.Sprintf("%s.%s.duration:%d|ms", prefix, statsdName(s.label("stage")), int64(s.Value*1000)))
+		case "atlas9_stage_success":
+			out = append(out, fmt.Sprintf("%s.%s.success:%g|g", prefix, statsdName(s.label("stage")), s.Value))
+		case "atlas9_apply_total":
+			out = append(out, fmt.Sprintf("%s.applies.%s:%g|c", prefix, s.label("result"), s.Value))
+		case "atlas9_pending_migrations":
+			out = append(out, fmt.Sprintf("%s.pending_migrations:%g|g", prefix, s.Value))
+		}
+	}
+	return out
+}
+
+// sendStatsd sends samples to the StatsD server at addr (host:port) over UDP, one packet per line.
+func sendStatsd(addr string, samples []metricSample) error {
+	conn, err := net.DialTimeout("udp", addr, 2*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, line := range statsdLines(samples) {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
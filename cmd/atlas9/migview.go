@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"ariga.io/atlas/sql/migrate"
+)
+
+// migrationStats summarizes one migration file for the viewer header.
+type migrationStats struct {
+	Statements  int
+	ByKind      map[string]int // CREATE, ALTER, DROP, DML, OTHER
+	Destructive int
+	SumStatus   string // "ok", "not hashed", "modified since hashed", "no atlas.sum"
+}
+
+// statementKinds is the display order of migrationStats.ByKind.
+var statementKinds = []string{"CREATE", "ALTER", "DROP", "DML", "OTHER"}
+
+// statementKind classifies a statement by its first keyword.
+func statementKind(stmt string) string {
+	word, _, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(stmt)), " ")
+	switch word {
+	case "CREATE", "ALTER", "DROP":
+		return word
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "TRUNCATE", "COPY":
+		return "DML"
+	}
+	return "OTHER"
+}
+
+// sqlFiles lists the .sql files in dir in version order.
+func sqlFiles(dir string) []string {
+	var names []string
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".sql" {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readMigration reads name from the migration directory dir and computes its statistics.
+func readMigration(dir, name string) (string, migrationStats, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", migrationStats{}, err
+	}
+	st := migrationStats{ByKind: make(map[string]int), SumStatus: sumStatus(dir, name)}
+	stmts, err := migrate.Stmts(string(data))
+	if err != nil {
+		return string(data), st, err
+	}
+	for _, s := range stmts {
+		st.Statements++
+		st.ByKind[statementKind(s.Text)]++
+		if isDestructive(s.Text) {
+			st.Destructive++
+		}
+	}
+	return string(data), st, nil
+}
+
+// sumStatus compares name's hash in atlas.sum with its current content.
+func sumStatus(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, migrate.HashFileName))
+	if err != nil {
+		return "no atlas.sum"
+	}
+	var recorded migrate.HashFile
+	if err := recorded.UnmarshalText(data); err != nil {
+		return "unreadable atlas.sum"
+	}
+	want, err := recorded.SumByName(name)
+	if err != nil {
+		return "not hashed"
+	}
+	local, err := migrate.NewLocalDir(dir)
+	if err != nil {
+		return "unknown"
+	}
+	current, err := local.Checksum()
+	if err != nil {
+		return "unknown"
+	}
+	if got, _ := current.SumByName(name); got != want {
+		return "modified since hashed"
+	}
+	return "ok"
+}
+
+// header renders the stats as tview-colored lines shown above the SQL.
+func (st migrationStats) header(name string) string {
+	var kinds []string
+	for _, k := range statementKinds {
+		if n := st.ByKind[k]; n > 0 {
+			kinds = append(kinds, fmt.Sprintf("%d %s", n, k))
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[::b]%s[::-]  %d statements", name, st.Statements)
+	if len(kinds) > 0 {
+		b.WriteString(": " + strings.Join(kinds, " · "))
+	}
+	b.WriteString("\n")
+	if st.Destructive > 0 {
+		fmt.Fprintf(&b, "[red]⚠ %d destructive operation(s)[-]\n", st.Destructive)
+	} else {
+		b.WriteString("[green]no destructive operations[-]\n")
+	}
+	switch st.SumStatus {
+	case "ok":
+		b.WriteString("atlas.sum: [green]ok[-]\n")
+	case "not hashed", "modified since hashed":
+		b.WriteString("atlas.sum: [yellow]" + st.SumStatus + "[-] (run atlas migrate hash)\n")
+	default:
+		b.WriteString("atlas.sum: [yellow]" + st.SumStatus + "[-]\n")
+	}
+	return b.String()
+}
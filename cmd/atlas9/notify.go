@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// notifyDesktop shows a desktop notification with notify-send (Linux/BSD) or osascript (macOS) and reports
+// whether one was shown. It runs a command: call it off the UI goroutine.
+func notifyDesktop(title, body string) bool {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", "display notification "+strconv.Quote(body)+" with title "+strconv.Quote(title))
+	case "windows":
+	default:
+		if _, err := exec.LookPath("notify-send"); err == nil {
+			cmd = exec.Command("notify-send", title, body)
+		}
+	}
+	return cmd != nil && cmd.Run() == nil
+}
+
+// notifyTerminal is the fallback of notifyDesktop: OSC 777 (understood by many terminals) and the terminal
+// bell, written to screen's tty. Call it on the UI goroutine, where it cannot interleave with a frame tcell
+// is drawing.
+func notifyTerminal(title, body string, screen tcell.Screen) {
+	if screen == nil {
+		return
+	}
+	if tty, ok := screen.Tty(); ok {
+		fmt.Fprintf(tty, "\x1b]777;notify;%s;%s\x07", title, body)
+	}
+	screen.Beep()
+}
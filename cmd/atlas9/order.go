@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// appliedStatusFormat makes `atlas migrate status` print the applied revisions as JSON.
+const appliedStatusFormat = "{{ json .Applied }}"
+
+// appliedVersions parses `migrate status --format appliedStatusFormat` output into versions.
+func appliedVersions(out string) ([]string, error) {
+	var revs []struct{ Version string }
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &revs); err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(revs))
+	for _, r := range revs {
+		versions = append(versions, r.Version)
+	}
+	return versions, nil
+}
+
+// outOfOrderFiles returns the migration files (files maps version to file name) that were never applied but
+// sort before the latest applied version — typically a branch merged after a newer migration shipped.
+func outOfOrderFiles(files map[string]string, applied []string) []string {
+	if len(applied) == 0 {
+		return nil
+	}
+	done := make(map[string]bool, len(applied))
+	latest := ""
+	for _, v := range applied {
+		done[v] = true
+		if v > latest {
+			latest = v
+		}
+	}
+	var out []string
+	for v, f := range files {
+		if !done[v] && v < latest {
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// outOfOrderGuidance explains how to fix out-of-order files (tview-colored).
+func outOfOrderGuidance(files []string, latest, env string) string {
+	var versions []string
+	for _, f := range files {
+		v := f
+		if i := strings.IndexAny(v, "_."); i > 0 {
+			v = v[:i]
+		}
+		versions = append(versions, v)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]⚠ Out-of-order migrations:[-] %s sort(s) before the latest applied version %s,\n", strings.Join(files, ", "), latest)
+	b.WriteString("so a linear apply will refuse to run them (usually two branches each added a migration).\n\n")
+	b.WriteString("Fix by moving them after the latest version and re-hashing:\n")
+	fmt.Fprintf(&b, "  atlas migrate rebase %s --env %s\n", strings.Join(versions, " "), env)
+	b.WriteString("or, if the order does not matter, apply them anyway with --exec-order non-linear.\n")
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// plan is a reviewed dry-run saved to disk so a later Apply can verify nothing changed since review.
+type plan struct {
+	Env        string    `json:"env"`
+	CreatedAt  time.Time `json:"created_at"`
+	SchemaHash string    `json:"schema_hash"` // hash of the migration directory's atlas.sum
+	PlanHash   string    `json:"plan_hash"`   // hash of the normalized dry-run output
+	SQL        string    `json:"sql"`
+}
+
+// dryRunTimingRe matches the timing/summary lines of dry-run output that change between runs.
+var dryRunTimingRe = regexp.MustCompile(`^--\s*(ok\s*\(.*\)|[\d.]+\s*(ns|µs|us|ms|s|m))\s*$`)
+
+// normalizeDryRun strips run-dependent timing lines and surrounding whitespace from dry-run output.
+func normalizeDryRun(out string) string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || dryRunTimingRe.MatchString(trimmed) {
+			continue
+		}
+		lines = append(lines, trimmed)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrationDirHash hashes atlas.sum in dir ("" when the directory has none yet).
+func migrationDirHash(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "atlas.sum"))
+	if err != nil {
+		return ""
+	}
+	return hashString(string(data))
+}
+
+// newPlan builds a plan from dry-run output for env against the migration directory dir.
+func newPlan(env, dir, dryRunOut string) plan {
+	return plan{
+		Env:        env,
+		CreatedAt:  time.Now(),
+		SchemaHash: migrationDirHash(dir),
+		PlanHash:   hashString(normalizeDryRun(dryRunOut)),
+		SQL:        dryRunOut,
+	}
+}
+
+// planPath is where the plan for env is stored inside the project.
+func planPath(workDir, env string) string {
+	return filepath.Join(workDir, ".atlas9", "plans", env+".json")
+}
+
+func savePlan(path string, p plan) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func loadPlan(path string) (plan, error) {
+	var p plan
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(data, &p)
+	return p, err
+}
+
+// planMismatch explains why current (freshly computed) no longer matches the approved plan, or "" if it matches.
+func planMismatch(approved, current plan) string {
+	switch {
+	case approved.Env != current.Env:
+		return "plan was made for env " + approved.Env + ", current env is " + current.Env
+	case approved.SchemaHash != current.SchemaHash:
+		return "migration directory (atlas.sum) changed since the plan was saved"
+	case approved.PlanHash != current.PlanHash:
+		return "pending SQL differs from the saved plan"
+	}
+	return ""
+}
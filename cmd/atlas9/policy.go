@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// alterDropRe matches a DROP clause of an ALTER statement and captures the word after it.
+var alterDropRe = regexp.MustCompile(`\bDROP\s+([^\s,;(]+)`)
+
+// isDestructive reports whether stmt drops or deletes data: DROP ..., TRUNCATE, DELETE, or an ALTER that drops a
+// column, constraint, index or key (ALTER TABLE t DROP c, DROP COLUMN, DROP INDEX, DROP FOREIGN KEY, ...). Dropping
+// a column's default, NOT NULL, identity or generation expression keeps the data and is not destructive.
+func isDestructive(stmt string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(stmt))
+	if strings.HasPrefix(upper, "DROP ") || strings.HasPrefix(upper, "TRUNCATE") || strings.HasPrefix(upper, "DELETE ") {
+		return true
+	}
+	if !strings.HasPrefix(upper, "ALTER ") {
+		return false
+	}
+	for _, m := range alterDropRe.FindAllStringSubmatch(upper, -1) {
+		switch m[1] {
+		case "DEFAULT", "NOT", "IDENTITY", "EXPRESSION":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// confirmPolicy decides when an apply may skip the confirmation prompt.
+type confirmPolicy struct {
+	// AutoApproveMax auto-approves applies with at most this many statements and no destructive
+	// operations. Negative disables auto-approval (always prompt).
+	AutoApproveMax int
+	// Protected envs are never auto-approved.
+	Protected []string
+}
+
+// autoApprove reports whether the pending statements for env may be applied without a prompt, and why (not).
+func (p confirmPolicy) autoApprove(env string, stmts []string) (bool, string) {
+	if p.AutoApproveMax < 0 {
+		return false, "auto-approve disabled"
+	}
+	if containsString(p.Protected, env) {
+		return false, "env " + env + " is protected"
+	}
+	for _, s := range stmts {
+		if isDestructive(s) {
+			return false, "destructive statement: " + firstLine(s)
+		}
+	}
+	if len(stmts) > p.AutoApproveMax {
+		return false, fmt.Sprintf("%d statements (auto-approve limit %d)", len(stmts), p.AutoApproveMax)
+	}
+	return true, fmt.Sprintf("%d statements, no destructive operations (limit %d)", len(stmts), p.AutoApproveMax)
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestIsDestructive(t *testing.T) {
+	for _, tc := range []struct {
+		stmt string
+		want bool
+	}{
+		{"DROP TABLE users;", true},
+		{"truncate users;", true},
+		{"DELETE FROM users;", true},
+		{"ALTER TABLE users DROP COLUMN name;", true},
+		{"ALTER TABLE `users` DROP `name`;", true},
+		{"alter table users drop name, add column age int;", true},
+		{"ALTER TABLE users ADD COLUMN age int, DROP INDEX idx_name;", true},
+		{"ALTER TABLE users DROP KEY idx_name;", true},
+		{"ALTER TABLE posts DROP FOREIGN KEY fk_user;", true},
+		{"ALTER TABLE posts DROP PRIMARY KEY;", true},
+		{"ALTER TABLE posts DROP CONSTRAINT fk_user;", true},
+		{"ALTER TABLE users ALTER COLUMN name DROP DEFAULT;", false},
+		{"ALTER TABLE users ALTER COLUMN name DROP NOT NULL;", false},
+		{"ALTER TABLE users ALTER COLUMN id DROP IDENTITY IF EXISTS;", false},
+		{"ALTER TABLE users ADD COLUMN dropped_at timestamp;", false},
+		{"CREATE TABLE drops (id int);", false},
+		{"INSERT INTO log VALUES ('DROP TABLE x');", false},
+	} {
+		if got := isDestructive(tc.stmt); got != tc.want {
+			t.Errorf("isDestructive(%q) = %v, want %v", tc.stmt, got, tc.want)
+		}
+	}
+}
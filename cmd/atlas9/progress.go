@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Lines emitted by `atlas migrate apply` in its default text format, e.g.
+//
+//	Migrating to version 20240102 from 20240101 (3 migrations in total):
+//	  -- migrating version 20240101
+//	  -- ok (4.7ms)
+var (
+	applyTotalRe   = regexp.MustCompile(`\((\d+) migrations? in total\)`)
+	applyVersionRe = regexp.MustCompile(`^--\s*migrating version\s+(\S+)`)
+	applyOkRe      = regexp.MustCompile(`^--\s*ok\s*\(([^)]*)\)`)
+)
+
+// migrationStep is one migration file seen in apply output.
+type migrationStep struct {
+	version  string
+	file     string
+	duration string // as printed by atlas, e.g. "4.7ms"; empty while running
+}
+
+// applyProgress tracks `atlas migrate apply` output line by line so the UI can show
+// "Applying 3/7: <file>" with a progress bar instead of waiting for the whole run.
+type applyProgress struct {
+	total int
+	steps []migrationStep
+	files map[string]string // version -> file name from the migration directory
+}
+
+func newApplyProgress(migrationsDir string) *applyProgress {
+	return &applyProgress{files: migrationFilesByVersion(migrationsDir)}
+}
+
+// migrationFilesByVersion maps version prefixes (text before the first "_" or ".") to file names in dir.
+func migrationFilesByVersion(dir string) map[string]string {
+	out := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return out
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".sql" {
+			continue
+		}
+		out[migrationVersion(name)] = name
+	}
+	return out
+}
+
+// Feed consumes one line of apply output and reports whether progress changed.
+func (p *applyProgress) Feed(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if m := applyTotalRe.FindStringSubmatch(trimmed); m != nil {
+		p.total, _ = strconv.Atoi(m[1])
+		return true
+	}
+	if m := applyVersionRe.FindStringSubmatch(trimmed); m != nil {
+		file := p.files[m[1]]
+		if file == "" {
+			file = m[1]
+		}
+		p.steps = append(p.steps, migrationStep{version: m[1], file: file})
+		return true
+	}
+	if m := applyOkRe.FindStringSubmatch(trimmed); m != nil && len(p.steps) > 0 {
+		p.steps[len(p.steps)-1].duration = m[1]
+		return true
+	}
+	return false
+}
+
+// Last returns the file of the latest migration seen, or "".
+func (p *applyProgress) Last() string {
+	if len(p.steps) == 0 {
+		return ""
+	}
+	return p.steps[len(p.steps)-1].file
+}
+
+// Started reports whether any migration has been seen yet.
+func (p *applyProgress) Started() bool { return len(p.steps) > 0 }
+
+// progressBar renders a fixed-width bar like "[#####-----]".
+func progressBar(done, total, width int) string {
+	if total <= 0 {
+		return "[" + strings.Repeat("-", width) + "]"
+	}
+	if done > total {
+		done = total
+	}
+	filled := done * width / total
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", width-filled) + "]"
+}
+
+// Render returns tview-colored text: the current migration, a progress bar and per-migration durations.
+func (p *applyProgress) Render() string {
+	total := p.total
+	if total < len(p.steps) {
+		total = len(p.steps)
+	}
+	done := 0
+	for _, s := range p.steps {
+		if s.duration != "" {
+			done++
+		}
+	}
+	var b strings.Builder
+	if len(p.steps) > 0 {
+		cur := p.steps[len(p.steps)-1]
+		if cur.duration == "" {
+			fmt.Fprintf(&b, "Applying %d/%d: %s\n", len(p.steps), total, cur.file)
+		} else {
+			fmt.Fprintf(&b, "Applied %d/%d\n", done, total)
+		}
+	}
+	fmt.Fprintf(&b, "%s %d/%d\n\n", progressBar(done, total, 30), done, total)
+	for _, s := range p.steps {
+		if s.duration == "" {
+			fmt.Fprintf(&b, "  [yellow]… running[-] %s\n", s.file)
+		} else {
+			fmt.Fprintf(&b, "  [green]✓ done[-]    %s  [gray](%s)[-]\n", s.file, s.duration)
+		}
+	}
+	return b.String()
+}
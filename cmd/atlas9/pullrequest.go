@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// prRequest is a pull request for new migrations: Files are committed to Branch, created from Base.
+type prRequest struct {
+	Base   string
+	Branch string
+	Title  string
+	Body   string
+	Files  []string // relative to the project directory
+}
+
+// prBodyData is what a pull request body template can use.
+type prBodyData struct {
+	Env        string   // env Lint passed for and the dry-run ran on
+	Base       string   // branch the pull request goes into
+	Branch     string   // branch with the migrations
+	Migrations []string // migration file names
+	Summary    string   // Markdown summary of the migrations (see changelogMarkdown)
+	DryRun     string   // atlas migrate apply --dry-run output on Env, or why it failed
+}
+
+// defaultPRTemplate is the body of pull requests without a .atlas9/pr-template.md.
+const defaultPRTemplate = "{{.Summary}}\n" +
+	"<details><summary>Dry-run on <code>{{.Env}}</code></summary>\n\n" +
+	"```sql\n{{.DryRun}}\n```\n\n" +
+	"</details>\n\n" +
+	"Lint passed on `{{.Env}}`.\n"
+
+func prTemplatePath(workDir string) string {
+	return filepath.Join(workDir, ".atlas9", "pr-template.md")
+}
+
+// prBody renders the pull request body from the project's template, else defaultPRTemplate.
+func prBody(workDir string, data prBodyData) (string, error) {
+	text := defaultPRTemplate
+	if custom, err := os.ReadFile(prTemplatePath(workDir)); err == nil {
+		text = string(custom)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	tmpl, err := template.New("pr-template.md").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// pendingMigrationFiles lists the new .sql files of the migration directory dir, the ones git does not track
+// yet, and its atlas.sum when that is new or modified, relative to workDir. Edits to committed migrations are
+// left out: they belong in no pull request of new ones.
+func pendingMigrationFiles(ctx context.Context, workDir, dir string) ([]string, error) {
+	untracked, err := gitOutput(ctx, workDir, "ls-files", "--others", "--exclude-standard", "--", dir)
+	if err != nil {
+		return nil, err
+	}
+	modified, err := gitOutput(ctx, workDir, "ls-files", "--modified", "--", filepath.Join(dir, "atlas.sum"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	seen := map[string]bool{}
+	for _, path := range strings.Split(untracked+"\n"+modified, "\n") {
+		if path == "" || seen[path] || filepath.Ext(path) != ".sql" && filepath.Base(path) != "atlas.sum" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(workDir, path)); err != nil {
+			continue // deleted
+		}
+		seen[path] = true
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// branchUnsafe matches what is replaced in a branch name made from a migration name.
+var branchUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// prBranchName names the branch of the migrations sqls after the newest one, e.g. atlas9/20250131_add_users.
+func prBranchName(sqls []string) string {
+	name := strings.TrimSuffix(filepath.Base(sqls[len(sqls)-1]), ".sql")
+	return "atlas9/" + strings.Trim(branchUnsafe.ReplaceAllString(name, "-"), "-.")
+}
+
+// prTitle is the pull request (and commit) title for the migrations sqls.
+func prTitle(sqls []string) string {
+	if len(sqls) == 1 {
+		return "Add migration " + filepath.Base(sqls[0])
+	}
+	return fmt.Sprintf("Add %d migrations (%s … %s)", len(sqls), filepath.Base(sqls[0]), filepath.Base(sqls[len(sqls)-1]))
+}
+
+// githubToken is the token for the GitHub API when gh is not installed.
+func githubToken() string {
+	if t := os.Getenv("GITHUB_TOKEN"); t != "" {
+		return t
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+// prOpener says how a pull request will be opened: "gh", "the GitHub API" or "" (pushed only).
+func prOpener() string {
+	if _, err := exec.LookPath("gh"); err == nil {
+		return "gh"
+	}
+	if githubToken() != "" {
+		return "the GitHub API"
+	}
+	return ""
+}
+
+// githubRepoRe matches the owner/repo of a GitHub remote URL (https, ssh or scp-like).
+var githubRepoRe = regexp.MustCompile(`github\.com[:/]([^/]+/[^/]+?)(?:\.git)?/?$`)
+
+// githubRepo returns the owner/repo of remote, a git remote URL.
+func githubRepo(remote string) (string, bool) {
+	m := githubRepoRe.FindStringSubmatch(strings.TrimSpace(remote))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// createPullRequest commits pr.Files on top of HEAD to a new branch pr.Branch without touching the checkout
+// (the commit is built in a temporary index), pushes it to origin and opens the pull request with gh, else the
+// GitHub API. The working tree, the index and the current branch stay as they were; the branch is deleted
+// again when the push fails. log is the output of each step; url is the pull request, or the link git push
+// printed when there was no way to open one. A failed step stops there.
+func createPullRequest(ctx context.Context, workDir string, pr prRequest) (url, log string, err error) {
+	var b strings.Builder
+	var env []string // extra environment of git commands (the temporary index)
+	run := func(name string, args ...string) (string, error) {
+		fmt.Fprintf(&b, "> %s %s\n", name, strings.Join(args, " "))
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = workDir
+		if env != nil {
+			cmd.Env = append(os.Environ(), env...)
+		}
+		out, err := cmd.CombinedOutput()
+		if text := strings.TrimSpace(string(out)); text != "" {
+			b.WriteString(text + "\n")
+		}
+		b.WriteString("\n")
+		if err != nil {
+			return "", fmt.Errorf("%s %s: %v", name, args[0], err)
+		}
+		return string(out), nil
+	}
+	tmp, err := os.MkdirTemp("", "atlas9-pr-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(tmp)
+	env = []string{"GIT_INDEX_FILE=" + filepath.Join(tmp, "index")}
+	var tree string
+	for _, args := range [][]string{
+		{"read-tree", "HEAD"},
+		append([]string{"update-index", "--add", "--"}, pr.Files...),
+		{"write-tree"},
+	} {
+		out, err := run("git", args...)
+		if err != nil {
+			return "", b.String(), err
+		}
+		tree = strings.TrimSpace(out) // write-tree's is the last
+	}
+	env = nil
+	out, err := run("git", "commit-tree", tree, "-p", "HEAD", "-m", pr.Title)
+	if err != nil {
+		return "", b.String(), err
+	}
+	commit := strings.TrimSpace(out)
+	ref := "refs/heads/" + pr.Branch
+	if _, err := run("git", "update-ref", ref, commit, ""); err != nil { // "": only when the branch is new
+		return "", b.String(), err
+	}
+	pushed, err := run("git", "push", "-u", "origin", pr.Branch)
+	if err != nil {
+		if _, derr := run("git", "update-ref", "-d", ref, commit); derr != nil {
+			err = errors.Join(err, derr)
+		}
+		return "", b.String(), err
+	}
+	switch prOpener() {
+	case "gh":
+		bodyFile, err := os.CreateTemp("", "atlas9-pr-*.md")
+		if err != nil {
+			return "", b.String(), err
+		}
+		defer os.Remove(bodyFile.Name())
+		_, err = bodyFile.WriteString(pr.Body)
+		if cerr := bodyFile.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", b.String(), err
+		}
+		out, err := run("gh", "pr", "create", "--base", pr.Base, "--head", pr.Branch, "--title", pr.Title, "--body-file", bodyFile.Name())
+		if err != nil {
+			return "", b.String(), err
+		}
+		if urls := extractURLs(out); len(urls) > 0 {
+			url = urls[len(urls)-1]
+		}
+		return url, b.String(), nil
+	case "the GitHub API":
+		remote, err := gitOutput(ctx, workDir, "remote", "get-url", "origin")
+		if err != nil {
+			return "", b.String(), err
+		}
+		repo, ok := githubRepo(remote)
+		if !ok {
+			return "", b.String(), fmt.Errorf("origin (%s) is not a GitHub repository", remote)
+		}
+		fmt.Fprintf(&b, "> POST https://api.github.com/repos/%s/pulls\n", repo)
+		url, err = githubCreatePull(ctx, githubToken(), repo, pr)
+		if err != nil {
+			return "", b.String(), err
+		}
+		b.WriteString(url + "\n")
+		return url, b.String(), nil
+	}
+	if urls := extractURLs(pushed); len(urls) > 0 {
+		url = urls[0]
+	}
+	return url, b.String(), nil
+}
+
+// githubCreatePull opens pr on the GitHub repository repo (owner/name) and returns its URL.
+func githubCreatePull(ctx context.Context, token, repo string, pr prRequest) (string, error) {
+	payload, err := json.Marshal(map[string]string{"title": pr.Title, "head": pr.Branch, "base": pr.Base, "body": pr.Body})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.github.com/repos/"+repo+"/pulls", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("GitHub API: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("GitHub API: %s: %s", resp.Status, result.Message)
+	}
+	return result.HTMLURL, nil
+}
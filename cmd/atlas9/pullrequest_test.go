@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCreatePullRequestKeepsCheckout(t *testing.T) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	bin := t.TempDir() // git only: no gh, and no token, so the branch is only pushed
+	if err := os.Symlink(gitPath, filepath.Join(bin, "git")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	for _, k := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(k, "atlas9")
+	}
+	for _, k := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(k, "atlas9@example.com")
+	}
+	origin, work := t.TempDir(), t.TempDir()
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		out, err := gitOutput(context.Background(), dir, args...)
+		if err != nil {
+			t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+		}
+		return out
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(origin, "init", "--bare", "-q")
+	git(work, "init", "-q", "-b", "main")
+	os.Mkdir(filepath.Join(work, "migrations"), 0755)
+	write("migrations/1_init.sql", "CREATE TABLE t (id int);\n")
+	write("migrations/atlas.sum", "h1:one\n")
+	git(work, "add", ".")
+	git(work, "commit", "-q", "-m", "init")
+	git(work, "remote", "add", "origin", origin)
+	git(work, "push", "-q", "origin", "main")
+
+	write("migrations/1_init.sql", "CREATE TABLE t (id bigint);\n") // an edit to a committed migration
+	write("migrations/2_add.sql", "ALTER TABLE t ADD c int;\n")
+	write("migrations/atlas.sum", "h1:two\n")
+	files, err := pendingMigrationFiles(context.Background(), work, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"migrations/2_add.sql", "migrations/atlas.sum"}; !slices.Equal(files, want) {
+		t.Fatalf("pendingMigrationFiles = %q, want %q", files, want)
+	}
+	status := git(work, "status", "--porcelain")
+	pr := prRequest{Base: "main", Branch: "atlas9/2_add", Title: "Add migration 2_add.sql", Files: files}
+	if _, log, err := createPullRequest(context.Background(), work, pr); err != nil {
+		t.Fatalf("createPullRequest: %v\n%s", err, log)
+	}
+	if branch := git(work, "rev-parse", "--abbrev-ref", "HEAD"); branch != "main" {
+		t.Errorf("checkout moved to %s", branch)
+	}
+	if got := git(work, "status", "--porcelain"); got != status {
+		t.Errorf("working tree changed: %q, was %q", got, status)
+	}
+	if got := git(origin, "ls-tree", "-r", "--name-only", "atlas9/2_add"); got != "migrations/1_init.sql\nmigrations/2_add.sql\nmigrations/atlas.sum" {
+		t.Errorf("pushed branch has %q", got)
+	}
+	if got := git(origin, "show", "atlas9/2_add:migrations/1_init.sql"); got != "CREATE TABLE t (id int);" {
+		t.Errorf("the committed migration's edit was pushed: %q", got)
+	}
+
+	git(work, "remote", "set-url", "origin", filepath.Join(t.TempDir(), "missing"))
+	pr.Branch = "atlas9/failed"
+	if _, _, err := createPullRequest(context.Background(), work, pr); err == nil {
+		t.Fatal("createPullRequest to a missing origin: want an error")
+	}
+	if refs := git(work, "branch", "--list", "atlas9/failed"); refs != "" {
+		t.Errorf("failed push left branch %q", refs)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// sessionRecorder captures a TUI session for --record: the keys pressed, the frames drawn and the atlas
+// commands run. Each format keeps what it can use.
+type sessionRecorder interface {
+	key(ev *tcell.EventKey)
+	frame(screen tcell.Screen)
+	command(args []string, res runResult, err error, took time.Duration)
+	Close() error
+}
+
+// newSessionRecorder creates path and returns an asciicast v2 recorder for a .cast file (frames and keys, for
+// asciinema play and demos), else a plain-text transcript (keys and every command with its output, for
+// incident reviews).
+func newSessionRecorder(path string) (sessionRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".cast" {
+		return &castRecorder{f: f, w: bufio.NewWriter(f), start: time.Now()}, nil
+	}
+	t := &transcriptRecorder{f: f, start: time.Now()}
+	fmt.Fprintf(f, "atlas9 session recorded %s\n\n", t.start.Format(time.RFC3339))
+	return t, nil
+}
+
+// keyInput is what a key sends to a terminal, for asciicast input events ("" for keys without one).
+func keyInput(ev *tcell.EventKey) string {
+	switch ev.Key() {
+	case tcell.KeyRune:
+		return string(ev.Rune())
+	case tcell.KeyEnter:
+		return "\r"
+	case tcell.KeyEscape:
+		return "\x1b"
+	case tcell.KeyTab:
+		return "\t"
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		return "\x7f"
+	case tcell.KeyUp:
+		return "\x1b[A"
+	case tcell.KeyDown:
+		return "\x1b[B"
+	case tcell.KeyRight:
+		return "\x1b[C"
+	case tcell.KeyLeft:
+		return "\x1b[D"
+	}
+	if ev.Key() >= tcell.KeyCtrlA && ev.Key() <= tcell.KeyCtrlZ {
+		return string(rune(ev.Key()))
+	}
+	return ""
+}
+
+// castRecorder writes an asciicast v2 file: a header with the first frame's size, then output events with
+// the screen rows that changed since the previous frame, input events for keys and resize events.
+type castRecorder struct {
+	mu            sync.Mutex
+	f             *os.File
+	w             *bufio.Writer
+	start         time.Time
+	width, height int
+	rows          []string // rows of the previous frame as written
+}
+
+func (c *castRecorder) event(kind, data string) {
+	line, _ := json.Marshal([]any{time.Since(c.start).Seconds(), kind, data})
+	c.w.Write(append(line, '\n'))
+}
+
+func (c *castRecorder) key(ev *tcell.EventKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if in := keyInput(ev); in != "" && c.rows != nil {
+		c.event("i", in)
+	}
+}
+
+func (c *castRecorder) frame(screen tcell.Screen) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	width, height := screen.Size()
+	switch {
+	case c.rows == nil:
+		header, _ := json.Marshal(map[string]any{"version": 2, "width": width, "height": height,
+			"timestamp": c.start.Unix(), "title": "atlas9"})
+		c.w.Write(append(header, '\n'))
+	case width != c.width || height != c.height:
+		c.event("r", fmt.Sprintf("%dx%d", width, height))
+		c.rows = c.rows[:0]
+	}
+	c.width, c.height = width, height
+	var out strings.Builder
+	if len(c.rows) == 0 {
+		out.WriteString("\x1b[2J")
+	}
+	rows := make([]string, height)
+	for y := range height {
+		rows[y] = screenRow(screen, y, width)
+		if y < len(c.rows) && c.rows[y] == rows[y] {
+			continue
+		}
+		fmt.Fprintf(&out, "\x1b[%d;1H%s\x1b[0m\x1b[K", y+1, rows[y])
+	}
+	c.rows = rows
+	if out.Len() > 0 {
+		c.event("o", out.String())
+	}
+}
+
+// screenRow renders row y of screen as text with SGR escapes for its colors and attributes.
+func screenRow(screen tcell.Screen, y, width int) string {
+	var b strings.Builder
+	last := tcell.StyleDefault
+	for x := 0; x < width; {
+		r, comb, style, w := screen.GetContent(x, y)
+		if style != last {
+			b.WriteString(sgr(style))
+			last = style
+		}
+		if r == 0 {
+			r = ' '
+		}
+		b.WriteRune(r)
+		for _, c := range comb {
+			b.WriteRune(c)
+		}
+		x += max(w, 1)
+	}
+	return b.String()
+}
+
+// sgr is the escape sequence that switches a terminal to style (24-bit colors).
+func sgr(style tcell.Style) string {
+	fg, bg, attrs := style.Decompose()
+	codes := []string{"0"}
+	for _, a := range []struct {
+		mask tcell.AttrMask
+		code string
+	}{{tcell.AttrBold, "1"}, {tcell.AttrDim, "2"}, {tcell.AttrItalic, "3"}, {tcell.AttrUnderline, "4"}, {tcell.AttrReverse, "7"}} {
+		if attrs&a.mask != 0 {
+			codes = append(codes, a.code)
+		}
+	}
+	for _, c := range []struct {
+		color tcell.Color
+		base  int
+	}{{fg, 38}, {bg, 48}} {
+		if c.color == tcell.ColorDefault || !c.color.Valid() {
+			continue
+		}
+		r, g, b := c.color.RGB()
+		codes = append(codes, fmt.Sprintf("%d;2;%d;%d;%d", c.base, r, g, b))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m"
+}
+
+func (c *castRecorder) command([]string, runResult, error, time.Duration) {}
+
+func (c *castRecorder) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.w.Flush(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// transcriptRecorder writes the keys pressed and each command with its exit code, duration and output, with
+// the time since the start of the session.
+type transcriptRecorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	start time.Time
+}
+
+func (t *transcriptRecorder) stamp() string {
+	return fmt.Sprintf("[%8.3fs]", time.Since(t.start).Seconds())
+}
+
+func (t *transcriptRecorder) key(ev *tcell.EventKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.f, "%s key %s\n", t.stamp(), ev.Name())
+}
+
+func (t *transcriptRecorder) frame(tcell.Screen) {}
+
+func (t *transcriptRecorder) command(args []string, res runResult, err error, took time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.f, "%s $ %s\n", t.stamp(), cmdString(args...))
+	for _, out := range []string{res.Stdout, res.Stderr} {
+		if out = strings.TrimRight(out, "\n"); out != "" {
+			fmt.Fprintln(t.f, out)
+		}
+	}
+	fmt.Fprintf(t.f, "(exit %d, %s)\n\n", exitCode(err), took.Round(time.Millisecond))
+}
+
+func (t *transcriptRecorder) Close() error {
+	return t.f.Close()
+}
+
+// recordingRunner passes every atlas command to rec after running it with next.
+type recordingRunner struct {
+	next commandRunner
+	rec  sessionRecorder
+}
+
+func (r recordingRunner) Run(ctx context.Context, args []string, env []string) (runResult, error) {
+	start := time.Now()
+	res, err := r.next.Run(ctx, args, env)
+	r.rec.command(args, res, err, time.Since(start))
+	return res, err
+}
+
+func (r recordingRunner) Stream(ctx context.Context, args []string, env []string, onLine func(string)) (runResult, error) {
+	start := time.Now()
+	res, err := runStreaming(ctx, r.next, args, env, onLine)
+	r.rec.command(args, res, err, time.Since(start))
+	return res, err
+}
@@ -0,0 +1,12 @@
+package main
+
+// stageRun is the UI state a stage run depends on, captured when it starts so the rerun action can repeat it
+// unchanged after the user moved on to another stage, env or flag set.
+type stageRun struct {
+	Stage  int
+	Env    string
+	TxMode string
+	Flags  []string
+
+	Scheduled bool // started by a scheduled apply: always notify when it finishes
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// runResult is the captured output of one atlas invocation.
+type runResult struct {
+	Stdout string
+	Stderr string
+}
+
+// commandRunner runs the atlas CLI with args and the given process environment (KEY=VALUE entries).
+// The TUI and headless mode only talk to atlas through it, so tests can swap in a fake.
+type commandRunner interface {
+	Run(ctx context.Context, args []string, env []string) (runResult, error)
+}
+
+// lineStreamer is implemented by runners that can report stdout lines while the command runs.
+type lineStreamer interface {
+	Stream(ctx context.Context, args []string, env []string, onLine func(string)) (runResult, error)
+}
+
+// runStreaming calls onLine for every stdout line, live when r supports it and after the run otherwise.
+func runStreaming(ctx context.Context, r commandRunner, args, env []string, onLine func(string)) (runResult, error) {
+	if s, ok := r.(lineStreamer); ok {
+		return s.Stream(ctx, args, env, onLine)
+	}
+	res, err := r.Run(ctx, args, env)
+	if out := strings.TrimRight(res.Stdout, "\n"); out != "" {
+		for _, line := range strings.Split(out, "\n") {
+			onLine(line)
+		}
+	}
+	return res, err
+}
+
+// execRunner runs the real atlas binary from PATH in dir.
+type execRunner struct {
+	dir string
+}
+
+func (r execRunner) command(ctx context.Context, args, env []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "atlas", args...)
+	cmd.Dir = r.dir
+	cmd.Env = env
+	cmd.Stdin = nil // don't attach terminal stdin; child gets EOF so it never blocks on read
+	return cmd
+}
+
+func (r execRunner) Run(ctx context.Context, args []string, env []string) (runResult, error) {
+	cmd := r.command(ctx, args, env)
+	var out, errOut strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err := cmd.Run()
+	return runResult{Stdout: out.String(), Stderr: errOut.String()}, err
+}
+
+func (r execRunner) Stream(ctx context.Context, args []string, env []string, onLine func(string)) (runResult, error) {
+	cmd := r.command(ctx, args, env)
+	var out, errOut strings.Builder
+	cmd.Stderr = &errOut
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return runResult{}, err
+	}
+	if err := cmd.Start(); err != nil {
+		return runResult{}, err
+	}
+	s := bufio.NewScanner(pipe)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		out.WriteString(line + "\n")
+		onLine(line)
+	}
+	err = cmd.Wait()
+	return runResult{Stdout: out.String(), Stderr: errOut.String()}, err
+}
+
+// exitCodeError is an atlas exit code without the process behind it: the exit code of a run restored from a
+// saved session (and of the test runner's fixtures).
+type exitCodeError int
+
+func (e exitCodeError) Error() string { return "exit status " + strconv.Itoa(int(e)) }
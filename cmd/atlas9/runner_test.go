@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner answers atlas invocations from fixture files in dir, named after the command (see fixtureKey):
+//
+//	<key>.stdout  printed on stdout
+//	<key>.stderr  printed on stderr
+//	<key>.exit    exit code (default 0), returned as an exitCodeError
+//	<key>.delay   pause before each stdout line when streaming, e.g. "50ms"
+//
+// Commands without any fixture succeed with empty output. Calls records every invocation's key.
+type fakeRunner struct {
+	dir   string
+	calls chan string
+}
+
+// fixtureKey names an invocation: arguments without --env and its value, leading dashes stripped, joined
+// by "_" (e.g. "migrate apply --env local --dry-run" → "migrate_apply_dry-run").
+func fixtureKey(args []string) string {
+	var parts []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--env" {
+			i++
+			continue
+		}
+		parts = append(parts, strings.TrimLeft(args[i], "-"))
+	}
+	return strings.Join(parts, "_")
+}
+
+func (f fakeRunner) read(key, ext string) string {
+	data, _ := os.ReadFile(filepath.Join(f.dir, key+"."+ext))
+	return string(data)
+}
+
+func (f fakeRunner) result(key string) (runResult, error) {
+	res := runResult{Stdout: f.read(key, "stdout"), Stderr: f.read(key, "stderr")}
+	if code, _ := strconv.Atoi(strings.TrimSpace(f.read(key, "exit"))); code != 0 {
+		return res, exitCodeError(code)
+	}
+	return res, nil
+}
+
+func (f fakeRunner) Run(ctx context.Context, args []string, env []string) (runResult, error) {
+	key := fixtureKey(args)
+	if f.calls != nil {
+		f.calls <- key
+	}
+	return f.result(key)
+}
+
+func (f fakeRunner) Stream(ctx context.Context, args []string, env []string, onLine func(string)) (runResult, error) {
+	key := fixtureKey(args)
+	if f.calls != nil {
+		f.calls <- key
+	}
+	res, err := f.result(key)
+	delay, _ := time.ParseDuration(strings.TrimSpace(f.read(key, "delay")))
+	if out := strings.TrimRight(res.Stdout, "\n"); out != "" {
+		for _, line := range strings.Split(out, "\n") {
+			select {
+			case <-ctx.Done():
+				return res, ctx.Err()
+			case <-time.After(delay):
+			}
+			onLine(line)
+		}
+	}
+	return res, err
+}
+
+func TestFixtureKey(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"migrate", "status", "--env", "local"}, "migrate_status"},
+		{[]string{"migrate", "apply", "--env", "prod", "--dry-run"}, "migrate_apply_dry-run"},
+		{[]string{"whoami"}, "whoami"},
+	} {
+		if got := fixtureKey(tc.args); got != tc.want {
+			t.Errorf("fixtureKey(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
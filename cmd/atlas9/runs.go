@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// pastRun is a finished stage run kept in .atlas9/runs/<stage>/: <time>.json holds these fields, <time>.txt the
+// output as shown (with color tags).
+type pastRun struct {
+	sessionRun
+	Stage string `json:"stage"` // stage name as shown in the stage strip
+	Path  string `json:"-"`     // the .json file
+}
+
+// maxPastRuns is how many runs are kept per stage; older ones are deleted when a run is saved.
+const maxPastRuns = 20
+
+// pastRunTimeFormat names the files of a run, so they sort by time.
+const pastRunTimeFormat = "20060102-150405.000"
+
+func runsDir(workDir string) string {
+	return filepath.Join(workDir, ".atlas9", "runs")
+}
+
+// savePastRun writes r and its output to dir/<key>/ and deletes the oldest runs of the stage beyond maxPastRuns.
+// key names the stage's directory (the stage id; custom stages need a file-safe one).
+func savePastRun(dir, key string, r pastRun, output string) error {
+	dir = filepath.Join(dir, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	stem := filepath.Join(dir, r.Start.Format(pastRunTimeFormat))
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(stem+".txt", []byte(clipSessionText(output)), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(stem+".json", data, 0644); err != nil {
+		return err
+	}
+	old, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(old) <= maxPastRuns {
+		return err
+	}
+	sort.Strings(old)
+	for _, path := range old[:len(old)-maxPastRuns] {
+		os.Remove(path)
+		os.Remove(strings.TrimSuffix(path, ".json") + ".txt")
+	}
+	return nil
+}
+
+// listPastRuns returns the runs kept in dir for every stage, newest first. Unreadable entries are skipped.
+func listPastRuns(dir string) ([]pastRun, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []pastRun
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var r pastRun
+		if json.Unmarshal(data, &r) != nil {
+			continue
+		}
+		r.Path = path
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.After(out[j].Start) })
+	return out, nil
+}
+
+// Output reads the output of r.
+func (r pastRun) Output() (string, error) {
+	data, err := os.ReadFile(strings.TrimSuffix(r.Path, ".json") + ".txt")
+	return string(data), err
+}
+
+// header describes r above its output, e.g. "Status on local at 2025-01-31 14:02:11 • 1.2s • exit 0".
+func (r pastRun) header() string {
+	exit := fmt.Sprintf("exit %d", r.Exit)
+	if r.Exit != 0 {
+		exit = "[red]" + exit + "[-]"
+	}
+	return fmt.Sprintf("[gray]%s on %s at %s • %s •[-] %s", r.Stage, tview.Escape(r.Env), r.Start.Format("2006-01-02 15:04:05"),
+		r.Duration.Round(time.Millisecond), exit)
+}
+
+// stageRunKey is the directory name of a custom stage's runs: its name, lowercased, with anything but letters
+// and digits replaced.
+func stageRunKey(name string) string {
+	return "stage-" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+}
+
+// plainText drops the tview color tags of text.
+func plainText(text string) string {
+	return tview.NewTextView().SetDynamicColors(true).SetText(text).GetText(true)
+}
+
+// maxDiffCells bounds the line-diff table (lines of a × lines of b); larger outputs are not diffed.
+const maxDiffCells = 4 << 20
+
+// diffLines returns a line diff from a to b for the output pane: unchanged lines indented, removed ones red
+// with "-", added ones green with "+". ok is false when the texts are too long to diff.
+func diffLines(a, b string) (diff string, ok bool) {
+	al, bl := strings.Split(strings.TrimRight(a, "\n"), "\n"), strings.Split(strings.TrimRight(b, "\n"), "\n")
+	if len(al)*len(bl) > maxDiffCells {
+		return "", false
+	}
+	// lcs[i][j] is the length of the longest common subsequence of al[i:] and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(al) || j < len(bl) {
+		switch {
+		case i < len(al) && j < len(bl) && al[i] == bl[j]:
+			fmt.Fprintf(&out, "  %s\n", tview.Escape(al[i]))
+			i, j = i+1, j+1
+		case i < len(al) && (j == len(bl) || lcs[i+1][j] >= lcs[i][j+1]):
+			fmt.Fprintf(&out, "[red]- %s[-]\n", tview.Escape(al[i]))
+			i++
+		default:
+			fmt.Fprintf(&out, "[green]+ %s[-]\n", tview.Escape(bl[j]))
+			j++
+		}
+	}
+	return out.String(), true
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// safeSuggestion is a safer multi-step way to run a risky statement of a dry-run, to copy into a new
+// migration in place of the statement.
+type safeSuggestion struct {
+	Statement string // the risky statement
+	Table     string // unquoted, as approxRowCount takes it
+	Column    string // unquoted column of an add_column suggestion
+	Rows      int64  // approximate rows of Table; -1 when unknown
+	Kind      string // the pattern, a key of the [safer] catalog table: index, foreign_key, not_null or add_column
+	SQL       string
+}
+
+// safeIdent matches a possibly schema-qualified identifier, quoted with spaces or not.
+const safeIdent = `(?:"(?:[^"]|"")+"|` + "`[^`]+`" + `|[^\s(;."` + "`" + `]+)(?:\.(?:"(?:[^"]|"")+"|` + "`[^`]+`" + `|[^\s(;."` + "`" + `]+))?`
+
+var (
+	safeTableRe      = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(` + safeIdent + `)`)
+	safeIndexTableRe = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?(?:` + safeIdent + `\s+)?ON\s+(?:ONLY\s+)?(` + safeIdent + `)`)
+	safeAddColumnRe  = regexp.MustCompile(`(?is)^ADD\s+COLUMN\s+(?:IF\s+NOT\s+EXISTS\s+)?("(?:[^"]|"")+"|` + "`[^`]+`" + `|[^\s,]+)\s+(.+)$`)
+	safeNotNullRe    = regexp.MustCompile(`(?i)\s+NOT\s+NULL\b`)
+	safeDefaultRe    = regexp.MustCompile(`(?is)\s+DEFAULT\s+(.+?)(?:\s+NOT\s+NULL.*)?$`)
+	safeSetNotNullRe = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?("(?:[^"]|"")+"|\S+)\s+SET\s+NOT\s+NULL$`)
+	safeForeignKeyRe = regexp.MustCompile(`(?is)^ADD\s+CONSTRAINT\s+("(?:[^"]|"")+"|\S+)\s+FOREIGN\s+KEY\b`)
+	safeIndexRe      = regexp.MustCompile(`(?i)^(CREATE\s+(?:UNIQUE\s+)?INDEX)\s+`)
+)
+
+// splitClauses splits the clauses of an ALTER TABLE on the commas outside parentheses and quotes.
+func splitClauses(s string) []string {
+	var out []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			out = append(out, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(out, strings.TrimSpace(s[start:]))
+}
+
+// quotePG quotes a Postgres identifier.
+func quotePG(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// safeSuggestions returns safer patterns for the risky statements of dry-run output dryRun: indexes built
+// without CONCURRENTLY, NOT NULL enforced on existing rows and foreign keys validated under lock (Postgres),
+// and columns added NOT NULL with a default (Postgres and MySQL), backfilled in batches instead.
+func safeSuggestions(driver, dryRun string) []safeSuggestion {
+	if driver != "postgres" && driver != "mysql" {
+		return nil
+	}
+	pg := driver == "postgres"
+	var sugs []safeSuggestion
+	for _, stmt := range dryRunStatements(dryRun) {
+		stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+		if m := safeIndexRe.FindStringSubmatch(stmt); pg && m != nil && !strings.Contains(strings.ToUpper(stmt), "CONCURRENTLY") {
+			table := ""
+			if im := safeIndexTableRe.FindStringSubmatch(stmt); im != nil {
+				table = unquoteIdent(im[1])
+			}
+			sugs = append(sugs, safeSuggestion{
+				Statement: stmt, Table: table, Rows: -1, Kind: "index",
+				SQL: "-- CONCURRENTLY cannot run in a transaction: put this in a migration file of its own.\n" +
+					"-- atlas:txmode none\n\n" +
+					m[1] + " CONCURRENTLY " + stmt[len(m[0]):] + ";\n",
+			})
+			continue
+		}
+		tm := safeTableRe.FindStringSubmatch(stmt)
+		if tm == nil {
+			continue
+		}
+		rawTable, table := tm[1], unquoteIdent(tm[1])
+		for _, clause := range splitClauses(stmt[len(tm[0]):]) {
+			var s safeSuggestion
+			switch {
+			case safeForeignKeyRe.MatchString(clause) && pg && !strings.Contains(strings.ToUpper(clause), "NOT VALID"):
+				name := safeForeignKeyRe.FindStringSubmatch(clause)[1]
+				s.Kind = "foreign_key"
+				s.SQL = fmt.Sprintf("-- 1. Only new rows are checked; brief lock.\nALTER TABLE %s %s NOT VALID;\n"+
+					"-- 2. Checks the existing rows without blocking writes.\nALTER TABLE %s VALIDATE CONSTRAINT %s;\n",
+					rawTable, clause, rawTable, name)
+			case safeSetNotNullRe.MatchString(clause) && pg:
+				col := safeSetNotNullRe.FindStringSubmatch(clause)[1]
+				s.Kind = "not_null"
+				s.SQL = notNullViaCheck(rawTable, table, col)
+			case safeAddColumnRe.MatchString(clause) && safeNotNullRe.MatchString(clause) && safeDefaultRe.MatchString(clause):
+				m := safeAddColumnRe.FindStringSubmatch(clause)
+				col, def := m[1], safeDefaultRe.FindStringSubmatch(clause)[1]
+				typ := m[2]
+				if i := safeNotNullRe.FindStringIndex(typ); i != nil {
+					typ = typ[:i[0]]
+				}
+				if i := strings.Index(strings.ToUpper(typ), " DEFAULT "); i >= 0 {
+					typ = typ[:i]
+				}
+				s.Kind, s.Column = "add_column", unquoteIdent(col)
+				s.SQL = addColumnInSteps(pg, rawTable, table, col, strings.TrimSpace(typ), def)
+			default:
+				continue
+			}
+			s.Statement, s.Table, s.Rows = stmt, table, -1
+			sugs = append(sugs, s)
+		}
+	}
+	return sugs
+}
+
+// notNullViaCheck makes column col of table NOT NULL on Postgres without a long exclusive lock: a NOT VALID
+// check validated separately lets SET NOT NULL skip its scan (Postgres 12+).
+func notNullViaCheck(rawTable, table, col string) string {
+	short := table
+	if i := strings.LastIndex(short, "."); i >= 0 {
+		short = short[i+1:]
+	}
+	check := quotePG(short + "_" + unquoteIdent(col) + "_not_null")
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IS NOT NULL) NOT VALID;\n"+
+		"ALTER TABLE %s VALIDATE CONSTRAINT %s;\n"+
+		"ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;\n"+
+		"ALTER TABLE %s DROP CONSTRAINT %s;\n",
+		rawTable, check, col, rawTable, check, rawTable, col, rawTable, check)
+}
+
+// addColumnInSteps adds column col (type typ, default def) to table as nullable, backfills it in batches of
+// 10000 rows and then makes it NOT NULL.
+func addColumnInSteps(pg bool, rawTable, table, col, typ, def string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- 1. Nullable and without a default: no rewrite.\nALTER TABLE %s ADD COLUMN %s %s;\n", rawTable, col, typ)
+	if pg {
+		fmt.Fprintf(&b, "ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;\n", rawTable, col, def)
+		b.WriteString("-- 2. Backfill in batches; repeat until it updates 0 rows (outside this migration for big tables).\n")
+		fmt.Fprintf(&b, "UPDATE %s SET %s = %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s IS NULL LIMIT 10000);\n", rawTable, col, def, rawTable, col)
+		b.WriteString("-- 3. Enforce NOT NULL.\n")
+		b.WriteString(notNullViaCheck(rawTable, table, col))
+		return b.String()
+	}
+	b.WriteString("-- 2. Backfill in batches; repeat until it updates 0 rows (outside this migration for big tables).\n")
+	fmt.Fprintf(&b, "UPDATE %s SET %s = %s WHERE %s IS NULL LIMIT 10000;\n", rawTable, col, def, col)
+	b.WriteString("-- 3. Enforce NOT NULL and set the default (online where the server allows it).\n")
+	fmt.Fprintf(&b, "ALTER TABLE %s MODIFY COLUMN %s %s NOT NULL DEFAULT %s, ALGORITHM=INPLACE, LOCK=NONE;\n", rawTable, col, typ, def)
+	return b.String()
+}
+
+// keepSafeSuggestions drops the suggestions for tables known to have fewer than lintBigTableRows rows, where
+// the plain statement is quick enough.
+func keepSafeSuggestions(sugs []safeSuggestion) []safeSuggestion {
+	var out []safeSuggestion
+	for _, s := range sugs {
+		if s.Rows < 0 || s.Rows >= lintBigTableRows {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeSuggestions(t *testing.T) {
+	for _, tc := range []struct {
+		name, driver, stmt string
+		kind, table, col   string   // "" kind: no suggestion
+		sql                []string // substrings of the suggested SQL
+	}{
+		{name: "index", driver: "postgres", stmt: `CREATE INDEX "users_email_idx" ON "users" ("email");`,
+			kind: "index", table: "users",
+			sql: []string{"-- atlas:txmode none", `CREATE INDEX CONCURRENTLY "users_email_idx" ON "users" ("email");`}},
+		{name: "unique index, schema-qualified", driver: "postgres", stmt: `CREATE UNIQUE INDEX users_email_key ON public.users (email);`,
+			kind: "index", table: "public.users",
+			sql: []string{"CREATE UNIQUE INDEX CONCURRENTLY users_email_key ON public.users (email);"}},
+		{name: "index on a quoted, schema-qualified table", driver: "postgres", stmt: `CREATE INDEX "i" ON "my schema"."my table" ("x");`,
+			kind: "index", table: "my schema.my table",
+			sql: []string{`CREATE INDEX CONCURRENTLY "i" ON "my schema"."my table" ("x");`}},
+		{name: "index already concurrent", driver: "postgres", stmt: `CREATE INDEX CONCURRENTLY i ON users (email);`},
+		{name: "index on MySQL", driver: "mysql", stmt: "CREATE INDEX `i` ON `users` (`email`);"},
+		{name: "foreign key", driver: "postgres", stmt: `ALTER TABLE "orders" ADD CONSTRAINT "orders_user_fk" FOREIGN KEY ("user_id") REFERENCES "users" ("id");`,
+			kind: "foreign_key", table: "orders",
+			sql: []string{`ALTER TABLE "orders" ADD CONSTRAINT "orders_user_fk" FOREIGN KEY ("user_id") REFERENCES "users" ("id") NOT VALID;`,
+				`ALTER TABLE "orders" VALIDATE CONSTRAINT "orders_user_fk";`}},
+		{name: "foreign key already NOT VALID", driver: "postgres", stmt: `ALTER TABLE orders ADD CONSTRAINT fk FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID;`},
+		{name: "set not null, quoted table", driver: "postgres", stmt: `ALTER TABLE "my table" ALTER COLUMN "email" SET NOT NULL;`,
+			kind: "not_null", table: "my table",
+			sql: []string{`ALTER TABLE "my table" ADD CONSTRAINT "my table_email_not_null" CHECK ("email" IS NOT NULL) NOT VALID;`,
+				`ALTER TABLE "my table" ALTER COLUMN "email" SET NOT NULL;`, `ALTER TABLE "my table" DROP CONSTRAINT "my table_email_not_null";`}},
+		{name: "set not null, schema-qualified", driver: "postgres", stmt: `ALTER TABLE public.users ALTER COLUMN email SET NOT NULL;`,
+			kind: "not_null", table: "public.users",
+			sql: []string{`ALTER TABLE public.users ADD CONSTRAINT "users_email_not_null" CHECK (email IS NOT NULL) NOT VALID;`}},
+		{name: "add column on Postgres", driver: "postgres", stmt: `ALTER TABLE "users" ADD COLUMN "active" boolean NOT NULL DEFAULT true;`,
+			kind: "add_column", table: "users", col: "active",
+			sql: []string{`ALTER TABLE "users" ADD COLUMN "active" boolean;`, `ALTER TABLE "users" ALTER COLUMN "active" SET DEFAULT true;`,
+				`WHERE ctid IN (SELECT ctid FROM "users" WHERE "active" IS NULL LIMIT 10000);`, `ALTER TABLE "users" ALTER COLUMN "active" SET NOT NULL;`}},
+		{name: "add column on MySQL", driver: "mysql", stmt: "ALTER TABLE `users` ADD COLUMN `active` bool DEFAULT 1 NOT NULL;",
+			kind: "add_column", table: "users", col: "active",
+			sql: []string{"ALTER TABLE `users` ADD COLUMN `active` bool;", "UPDATE `users` SET `active` = 1 WHERE `active` IS NULL LIMIT 10000;",
+				"ALTER TABLE `users` MODIFY COLUMN `active` bool NOT NULL DEFAULT 1, ALGORITHM=INPLACE, LOCK=NONE;"}},
+		{name: "nullable column", driver: "postgres", stmt: `ALTER TABLE users ADD COLUMN note text DEFAULT '';`},
+		{name: "column without a default", driver: "postgres", stmt: `ALTER TABLE users ADD COLUMN note text NOT NULL;`},
+		{name: "SQLite", driver: "sqlite", stmt: `CREATE INDEX i ON users (email);`},
+	} {
+		sugs := safeSuggestions(tc.driver, "-- Planned Changes:\n-- Create index\n-> "+tc.stmt+"\n")
+		if tc.kind == "" {
+			if len(sugs) != 0 {
+				t.Errorf("%s: got %+v, want no suggestion", tc.name, sugs)
+			}
+			continue
+		}
+		if len(sugs) != 1 {
+			t.Errorf("%s: got %d suggestions, want 1", tc.name, len(sugs))
+			continue
+		}
+		s := sugs[0]
+		if s.Kind != tc.kind || s.Table != tc.table || s.Column != tc.col || s.Rows != -1 || s.Statement != strings.TrimSuffix(tc.stmt, ";") {
+			t.Errorf("%s: got %+v", tc.name, s)
+		}
+		for _, w := range tc.sql {
+			if !strings.Contains(s.SQL, w) {
+				t.Errorf("%s: no %q in\n%s", tc.name, w, s.SQL)
+			}
+		}
+	}
+}
+
+func TestSafeSuggestionsClauses(t *testing.T) {
+	// One suggestion per risky clause; the quick ones and the commas inside parentheses are left alone.
+	dryRun := `-> ALTER TABLE "t" ADD COLUMN "a" int NOT NULL DEFAULT 0, ADD COLUMN "b" numeric(10,2), ALTER COLUMN "c" SET NOT NULL;`
+	sugs := safeSuggestions("postgres", dryRun)
+	if len(sugs) != 2 || sugs[0].Kind != "add_column" || sugs[0].Column != "a" || sugs[1].Kind != "not_null" {
+		t.Errorf("got %+v", sugs)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scheduledApply is an Apply confirmed now to run at At (a maintenance window). The TUI must stay open.
+type scheduledApply struct {
+	At      time.Time
+	Run     stageRun
+	Planned []string // confirmed dry-run statements; the apply aborts if the pending set differs at At
+	Note    string
+	cancel  chan struct{}
+}
+
+// parseScheduleTime reads the time for a scheduled apply relative to now: "02:00" (the next such clock time),
+// "+90m" / "+2h" (a delay), or "2006-01-02 15:04" (local time). The result must lie in the future.
+func parseScheduleTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var at time.Time
+	switch {
+	case strings.HasPrefix(s, "+"):
+		d, err := time.ParseDuration(s[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q: want a delay like +30m", s)
+		}
+		at = now.Add(d)
+	case len(s) <= len("15:04"):
+		clock, err := time.ParseInLocation("15:04", s, now.Location())
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q: want a time like 02:00", s)
+		}
+		at = time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+	default:
+		t, err := time.ParseInLocation("2006-01-02 15:04", s, now.Location())
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%q: want 02:00, +30m or 2006-01-02 15:04", s)
+		}
+		at = t
+	}
+	if !at.After(now) {
+		return time.Time{}, fmt.Errorf("%s is not in the future", at.Format("2006-01-02 15:04"))
+	}
+	return at, nil
+}
+
+// countdown renders the time left until at, e.g. "1h02m05s".
+func countdown(at, now time.Time) string {
+	d := at.Sub(now).Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	h, m, sec := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm%02ds", h, m, sec)
+	}
+	return fmt.Sprintf("%dm%02ds", m, sec)
+}
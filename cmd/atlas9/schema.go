@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// inspectFormat makes `atlas schema inspect` print the inspected realm as JSON.
+const inspectFormat = "{{ json . }}"
+
+// The JSON document printed by `atlas schema inspect --format '{{ json . }}'`.
+type inspectedRealm struct {
+	Schemas []inspectedSchema `json:"schemas"`
+}
+
+type inspectedSchema struct {
+	Name   string           `json:"name"`
+	Tables []inspectedTable `json:"tables"`
+}
+
+type inspectedTable struct {
+	Name        string                `json:"name"`
+	Columns     []inspectedColumn     `json:"columns"`
+	PrimaryKey  *inspectedIndex       `json:"primary_key,omitempty"`
+	Indexes     []inspectedIndex      `json:"indexes"`
+	ForeignKeys []inspectedForeignKey `json:"foreign_keys"`
+}
+
+type inspectedColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Null bool   `json:"null"`
+}
+
+type inspectedIndex struct {
+	Name   string `json:"name"`
+	Unique bool   `json:"unique"`
+	Parts  []struct {
+		Column string `json:"column"`
+		Expr   string `json:"expr"`
+	} `json:"parts"`
+}
+
+type inspectedForeignKey struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	References struct {
+		Table   string   `json:"table"`
+		Columns []string `json:"columns"`
+	} `json:"references"`
+}
+
+// parseInspectJSON decodes schema inspect output.
+func parseInspectJSON(out string) (inspectedRealm, error) {
+	var r inspectedRealm
+	err := json.Unmarshal([]byte(strings.TrimSpace(out)), &r)
+	return r, err
+}
+
+// columns returns the index's column list (expressions shown in parentheses).
+func (ix inspectedIndex) columns() string {
+	var parts []string
+	for _, p := range ix.Parts {
+		if p.Column != "" {
+			parts = append(parts, p.Column)
+		} else {
+			parts = append(parts, "("+p.Expr+")")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describe renders a column as "name  type  NOT NULL".
+func (c inspectedColumn) describe() string {
+	s := fmt.Sprintf("%s  [gray]%s[-]", c.Name, c.Type)
+	if !c.Null {
+		s += "  [yellow]NOT NULL[-]"
+	}
+	return s
+}
+
+// describe renders an index as "name (a, b) UNIQUE".
+func (ix inspectedIndex) describe() string {
+	s := fmt.Sprintf("%s (%s)", ix.Name, ix.columns())
+	if ix.Unique {
+		s += "  [yellow]UNIQUE[-]"
+	}
+	return s
+}
+
+// describe renders a foreign key as "name (a) → other(b)".
+func (fk inspectedForeignKey) describe() string {
+	return fmt.Sprintf("%s (%s) → %s(%s)", fk.Name, strings.Join(fk.Columns, ", "),
+		fk.References.Table, strings.Join(fk.References.Columns, ", "))
+}
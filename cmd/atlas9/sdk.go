@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+)
+
+// sdkRunner answers `migrate hash` and `migrate status` in-process with the Atlas Go SDK (ariga.io/atlas)
+// and the revision table, skipping a process start and a CLI round-trip. Anything else, and any
+// invocation it cannot fully handle (unknown flags, unresolvable URL, missing revision table, ...), goes
+// to next, so the atlas CLI stays the source of truth for errors and for Diff, Lint and Apply.
+type sdkRunner struct {
+	dir            string // project directory containing atlas.hcl
+	next           commandRunner
+	connectTimeout time.Duration
+}
+
+func (r sdkRunner) Run(ctx context.Context, args []string, env []string) (runResult, error) {
+	if res, ok := r.native(ctx, args, env); ok {
+		return res, nil
+	}
+	return r.next.Run(ctx, args, env)
+}
+
+func (r sdkRunner) Stream(ctx context.Context, args []string, env []string, onLine func(string)) (runResult, error) {
+	return runStreaming(ctx, r.next, args, env, onLine)
+}
+
+// native handles args when they are exactly `migrate hash|status --env <name>`; ok is false when the CLI
+// should run instead.
+func (r sdkRunner) native(ctx context.Context, args, environ []string) (res runResult, ok bool) {
+	if len(args) != 4 || args[0] != "migrate" || args[2] != "--env" {
+		return res, false
+	}
+	env := args[3]
+	atlasHCL := filepath.Join(r.dir, "atlas.hcl")
+	src, err := os.ReadFile(atlasHCL)
+	if err != nil {
+		return res, false
+	}
+	revisionsSchema, ok := migrationSettings(string(src), env)
+	if !ok {
+		return res, false
+	}
+	dir, err := migrate.NewLocalDir(filepath.Join(r.dir, parseAtlasHCLMigrationDir(atlasHCL, env)))
+	if err != nil {
+		return res, false
+	}
+	switch args[1] {
+	case "hash":
+		files, err := dir.Files()
+		if err != nil {
+			return res, false
+		}
+		sum, err := migrate.NewHashFile(files)
+		if err != nil {
+			return res, false
+		}
+		return res, migrate.WriteSumFile(dir, sum) == nil
+	case "status":
+		if migrate.Validate(dir) != nil {
+			return res, false // let atlas report the checksum error
+		}
+		files, err := dir.Files()
+		if err != nil {
+			return res, false
+		}
+		url, err := resolveEnvURL(atlasHCL, env, "url", environGetter(environ))
+		if err != nil {
+			return res, false
+		}
+		ctx, cancel := context.WithTimeout(ctx, r.connectTimeout)
+		defer cancel()
+		db, _, err := openTarget(ctx, url)
+		if err != nil {
+			return res, false
+		}
+		defer db.Close()
+		revs, err := readRevisions(ctx, db, revisionsSchema)
+		if err != nil {
+			return res, false
+		}
+		return runResult{Stdout: migrationStatus(files, revs)}, true
+	}
+	return res, false
+}
+
+// environGetter looks keys up in a KEY=VALUE process environment.
+func environGetter(environ []string) func(string) string {
+	return func(key string) string {
+		for i := len(environ) - 1; i >= 0; i-- {
+			if k, v, ok := strings.Cut(environ[i], "="); ok && k == key {
+				return v
+			}
+		}
+		return ""
+	}
+}
+
+// revision is a row of atlas's revision table.
+type revision struct {
+	Version string
+	Applied int
+	Total   int
+	Error   string
+	Stmt    string
+}
+
+// migrationBlockRe finds the migration block of an env body.
+var migrationBlockRe = regexp.MustCompile(`(?m)^\s*migration\s*\{`)
+
+// sqlIdentRe matches a schema name that can go into a query unquoted.
+var sqlIdentRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// migrationSettings reads the migration block of env in src, the atlas.hcl source: the revisions_schema the
+// revision table is in ("" for atlas's default). ok is false when the block asks for what the in-process
+// path does not follow — a dir format other than atlas's own (format, or ?format= on dir), or a
+// revisions_schema that is not a plain name — so the atlas CLI runs instead.
+func migrationSettings(src, env string) (revisionsSchema string, ok bool) {
+	body := atlasHCLEnvBlock(src, env)
+	loc := migrationBlockRe.FindStringIndex(body)
+	if loc == nil {
+		return "", true
+	}
+	block := hclBlock(body[loc[0]:], "migration")
+	if format := strings.Trim(hclAttr(block, "format"), `"`); format != "" && format != "atlas" {
+		return "", false
+	}
+	if strings.Contains(hclAttr(block, "dir"), "format=") {
+		return "", false
+	}
+	expr := hclAttr(block, "revisions_schema")
+	if expr == "" {
+		return "", true
+	}
+	if name := strings.Trim(expr, `"`); expr == `"`+name+`"` && sqlIdentRe.MatchString(name) {
+		return name, true
+	}
+	return "", false
+}
+
+// readRevisions reads atlas_schema_revisions from schema when set (the env's revisions_schema), else first
+// from the connected schema (schema-bound URLs) and then from the atlas_schema_revisions schema atlas creates
+// for database-wide URLs.
+func readRevisions(ctx context.Context, db *sql.DB, schema string) ([]revision, error) {
+	tables := []string{"atlas_schema_revisions", "atlas_schema_revisions.atlas_schema_revisions"}
+	if schema != "" {
+		tables = []string{schema + ".atlas_schema_revisions"}
+	}
+	var err error
+	for _, table := range tables {
+		var rows *sql.Rows
+		rows, err = db.QueryContext(ctx, "SELECT version, applied, total, COALESCE(error, ''), COALESCE(error_stmt, '') FROM "+table+" ORDER BY version")
+		if err != nil {
+			continue
+		}
+		defer rows.Close()
+		var revs []revision
+		for rows.Next() {
+			var r revision
+			if err := rows.Scan(&r.Version, &r.Applied, &r.Total, &r.Error, &r.Stmt); err != nil {
+				return nil, err
+			}
+			revs = append(revs, r)
+		}
+		return revs, rows.Err()
+	}
+	return nil, err
+}
+
+// migrationStatus renders files against applied revisions in the format of `atlas migrate status`.
+func migrationStatus(files []migrate.File, revs []revision) string {
+	current := "No migration applied yet"
+	var last *revision
+	if len(revs) > 0 {
+		last = &revs[len(revs)-1]
+		current = last.Version
+	}
+	executed := 0
+	for _, r := range revs {
+		if r.Applied == r.Total && r.Error == "" {
+			executed++
+		}
+	}
+	var pending []migrate.File
+	for _, f := range files {
+		switch {
+		case last == nil || f.Version() > last.Version:
+			pending = append(pending, f)
+		case f.Version() == last.Version && (last.Applied < last.Total || last.Error != ""):
+			pending = append(pending, f) // partially applied
+		}
+	}
+	status, next := "OK", "Already at latest version"
+	if len(pending) > 0 {
+		status, next = "PENDING", pending[0].Version()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Migration Status: %s\n", status)
+	fmt.Fprintf(&b, "  -- Current Version: %s\n", current)
+	fmt.Fprintf(&b, "  -- Next Version:    %s\n", next)
+	fmt.Fprintf(&b, "  -- Executed Files:  %d\n", executed)
+	fmt.Fprintf(&b, "  -- Pending Files:   %d\n", len(pending))
+	if last != nil && last.Error != "" {
+		fmt.Fprintf(&b, "\nLast migration attempt had errors:\n  -- SQL:   %s\n  -- ERROR: %s\n", last.Stmt, last.Error)
+	}
+	return b.String()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ariga.io/atlas/sql/migrate"
+)
+
+func TestSDKRunner(t *testing.T) {
+	for _, tc := range []struct {
+		migration, schema string
+		ok                bool
+	}{
+		{"", "", true},
+		{"migration {\n    dir = \"file://migrations\"\n  }", "", true},
+		{"migration {\n    dir = \"file://migrations\"\n    format = atlas\n  }", "", true},
+		{"migration {\n    dir = \"file://migrations\"\n    format = golang-migrate\n  }", "", false},
+		{"migration {\n    dir = \"file://migrations?format=flyway\"\n  }", "", false},
+		{"migration {\n    dir = \"file://migrations\"\n    revisions_schema = \"ops\"\n  }", "ops", true},
+		{"migration {\n    revisions_schema = var.schema\n  }", "", false},
+		{"migration {\n    revisions_schema = \"ops; DROP TABLE x\"\n  }", "", false},
+	} {
+		src := "env \"local\" {\n  url = \"postgres://localhost/app\"\n  " + tc.migration + "\n}\n" +
+			"env \"other\" {\n  migration {\n    format = goose\n  }\n}\n"
+		schema, ok := migrationSettings(src, "local")
+		if schema != tc.schema || ok != tc.ok {
+			t.Errorf("migrationSettings(%q) = %q, %v; want %q, %v", tc.migration, schema, ok, tc.schema, tc.ok)
+		}
+	}
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("migrations/1_init.sql", "CREATE TABLE a (id int);\n")
+	write("atlas.hcl", "env \"local\" {\n  migration {\n    dir = \"file://migrations\"\n  }\n}\n"+
+		"env \"goose\" {\n  migration {\n    dir = \"file://migrations\"\n    format = goose\n  }\n}\n")
+	calls := make(chan string, 4)
+	r := sdkRunner{dir: dir, next: fakeRunner{dir: t.TempDir(), calls: calls}, connectTimeout: time.Second}
+	if _, err := r.Run(context.Background(), []string{"migrate", "hash", "--env", "local"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("migrate hash on an atlas-format dir ran %s instead of hashing in-process", <-calls)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "migrations", "atlas.sum")); err != nil {
+		t.Errorf("in-process migrate hash wrote no atlas.sum: %v", err)
+	}
+	if _, err := r.Run(context.Background(), []string{"migrate", "hash", "--env", "goose"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || <-calls != "migrate_hash" {
+		t.Error("migrate hash on a goose-format dir did not go to the atlas CLI")
+	}
+
+	files := []migrate.File{migrate.NewLocalFile("1_a.sql", nil), migrate.NewLocalFile("2_b.sql", nil), migrate.NewLocalFile("3_c.sql", nil)}
+	got := migrationStatus(files, []revision{{Version: "1", Applied: 1, Total: 1}, {Version: "2", Applied: 1, Total: 2, Error: "boom", Stmt: "ALTER x"}})
+	for _, want := range []string{"Migration Status: PENDING", "Current Version: 2", "Next Version:    2", "Executed Files:  1", "Pending Files:   2", "-- ERROR: boom"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("migrationStatus: no %q in\n%s", want, got)
+		}
+	}
+	if got := migrationStatus(files[:1], []revision{{Version: "1", Applied: 1, Total: 1}}); !strings.Contains(got, "Migration Status: OK") {
+		t.Errorf("migrationStatus, all applied:\n%s", got)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// outputSection is the output of one command of a composite stage (Lint runs migrate hash, then migrate lint),
+// shown under a header with the command and its exit code.
+type outputSection struct {
+	Command string
+	Exit    int
+	Body    string // with color tags
+	Folded  bool
+}
+
+// sectionedOutput is a tab's output made of sections, followed by Outro (notes about the whole run).
+type sectionedOutput struct {
+	Sections []outputSection
+	Outro    string
+	Selected int    // section whose header has the cursor; -1 for none
+	Shown    string // the last render; the tab holds something else once it differs
+}
+
+// newSectionedOutput starts the sections folded that succeeded before the last one, so the output of the
+// command that matters shows first; fold states of prev, the same commands rendered before (e.g. the same run
+// re-rendered), are kept.
+func newSectionedOutput(secs []outputSection, outro string, prev *sectionedOutput) *sectionedOutput {
+	same := prev != nil && len(prev.Sections) == len(secs)
+	for i := range secs {
+		if same && prev.Sections[i].Command != secs[i].Command {
+			same = false
+		}
+	}
+	for i := range secs {
+		if same {
+			secs[i].Folded = prev.Sections[i].Folded
+		} else {
+			secs[i].Folded = secs[i].Exit == 0 && i < len(secs)-1
+		}
+	}
+	return &sectionedOutput{Sections: secs, Outro: outro, Selected: -1}
+}
+
+// render returns the text for the output pane and the line of each section's header, and keeps it as Shown.
+func (s *sectionedOutput) render() (string, []int) {
+	var b strings.Builder
+	headers := make([]int, len(s.Sections))
+	line := 0
+	for i, sec := range s.Sections {
+		if i > 0 {
+			b.WriteString("\n")
+			line++
+		}
+		headers[i] = line
+		marker, style := "▾", "[::b]"
+		if sec.Folded {
+			marker = "▸"
+		}
+		if i == s.Selected {
+			style = "[::br]"
+		}
+		exit := "[green]exit 0[-]"
+		if sec.Exit != 0 {
+			exit = fmt.Sprintf("[red]exit %d[-]", sec.Exit)
+		}
+		fmt.Fprintf(&b, "%s%s > %s[::-]  %s", style, marker, tview.Escape(sec.Command), exit)
+		body := strings.Trim(sec.Body, "\n")
+		n := 0
+		if body != "" {
+			n = strings.Count(body, "\n") + 1
+		}
+		switch {
+		case n == 0:
+			b.WriteString("  [gray]no output[-]\n")
+			line++
+		case sec.Folded:
+			fmt.Fprintf(&b, "  [gray]%d line(s) folded[-]\n", n)
+			line++
+		default:
+			b.WriteString("\n" + body + "\n")
+			line += n + 1
+		}
+	}
+	b.WriteString(s.Outro)
+	s.Shown = b.String()
+	return s.Shown, headers
+}
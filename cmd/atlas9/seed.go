@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+
+	"atlas9/internal/config"
+)
+
+// seedDirURL is the --dir value for the seed directory.
+func seedDirURL(s config.Seed) string {
+	if strings.Contains(s.Dir, "://") {
+		return s.Dir
+	}
+	return "file://" + strings.TrimPrefix(s.Dir, "./")
+}
+
+// seedApplyArgs are the atlas args that apply the seed directory to env, recording its versions in the seed
+// revision table so they do not mix with the schema migrations.
+func seedApplyArgs(s config.Seed, env string, dryRun bool) []string {
+	args := []string{"migrate", "apply", "--env", env, "--dir", seedDirURL(s)}
+	if s.RevisionsSchema != "" {
+		args = append(args, "--revisions-schema", s.RevisionsSchema)
+	}
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	return args
+}
+
+// seedHashArgs re-hash the seed directory (its atlas.sum) before it is applied.
+func seedHashArgs(s config.Seed) []string {
+	return []string{"migrate", "hash", "--dir", seedDirURL(s)}
+}
+
+// seedCommand returns the seed shell command for env.
+func seedCommand(s config.Seed, env string) (string, error) {
+	return config.Stage{Name: "seed", Command: s.Command}.Render(env)
+}
@@ -0,0 +1,66 @@
+package main
+
+import "strings"
+
+// selectCursorRegion is the tview region around the selection's cursor line, so the pane can scroll to it
+// whether or not lines wrap.
+const selectCursorRegion = "select-cursor"
+
+// outputSelection is a range of output lines picked in visual mode (select action) to be copied.
+type outputSelection struct {
+	Full           string   // the pane's text before the selection was drawn
+	Lines          []string // Full's lines, with color tags
+	Plain          []string // the same lines without them
+	Anchor, Cursor int      // the line the selection started on and the one it extends to
+}
+
+// newOutputSelection starts a selection of line first of full, the pane's text, whose text without color
+// tags is plain.
+func newOutputSelection(full, plain string, first int) *outputSelection {
+	s := &outputSelection{Full: full, Lines: strings.Split(strings.TrimSuffix(full, "\n"), "\n")}
+	s.Plain = strings.Split(strings.TrimSuffix(plain, "\n"), "\n")
+	if len(s.Plain) != len(s.Lines) {
+		s.Plain = make([]string, len(s.Lines))
+		for i, l := range s.Lines {
+			s.Plain[i] = plainText(l)
+		}
+	}
+	s.Anchor = max(0, min(len(s.Lines)-1, first))
+	s.Cursor = s.Anchor
+	return s
+}
+
+// bounds returns the first and last selected lines.
+func (s *outputSelection) bounds() (lo, hi int) {
+	return min(s.Anchor, s.Cursor), max(s.Anchor, s.Cursor)
+}
+
+// move moves the cursor by delta lines, staying within the output.
+func (s *outputSelection) move(delta int) {
+	s.Cursor = max(0, min(len(s.Lines)-1, s.Cursor+delta))
+}
+
+// render returns the pane's text with the selected lines in reverse video, the cursor line in
+// selectCursorRegion (highlighted, so it looks the same).
+func (s *outputSelection) render() string {
+	lo, hi := s.bounds()
+	var b strings.Builder
+	for i, l := range s.Lines {
+		switch {
+		case i == s.Cursor:
+			b.WriteString(`["` + selectCursorRegion + `"]` + l + `[""]`)
+		case i >= lo && i <= hi:
+			b.WriteString("[::r]" + l + "[::R]")
+		default:
+			b.WriteString(l)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// text returns the selected lines without color tags, as copied.
+func (s *outputSelection) text() string {
+	lo, hi := s.bounds()
+	return strings.Join(s.Plain[lo:hi+1], "\n") + "\n"
+}
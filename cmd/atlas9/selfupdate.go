@@ -0,0 +1,267 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// latestReleaseURL is the GitHub API endpoint for atlas9's latest release.
+const latestReleaseURL = "https://api.github.com/repos/sio2boss/atlas9/releases/latest"
+
+// checksumsAsset is the release asset listing the sha256 of every archive (written by make release).
+const checksumsAsset = "checksums.txt"
+
+// Size limits of what selfUpdate downloads, far above a real release, so a broken or hostile server cannot
+// fill memory.
+const (
+	maxMetadataSize = 1 << 20   // the release JSON and checksums.txt
+	maxArchiveSize  = 256 << 20 // the release archive, and the binary extracted from it
+)
+
+// release is the part of a GitHub release atlas9 uses.
+type release struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the named asset, or "".
+func (r release) assetURL(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// latestRelease fetches the latest atlas9 release from GitHub.
+func latestRelease(ctx context.Context) (release, error) {
+	var r release
+	body, err := httpGet(ctx, latestReleaseURL, maxMetadataSize)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return r, fmt.Errorf("parse release: %w", err)
+	}
+	return r, nil
+}
+
+// httpGet downloads url, refusing bodies larger than limit bytes.
+func httpGet(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return readLimited(resp.Body, limit, "GET "+url)
+}
+
+// readLimited reads r to the end, or fails when it has more than limit bytes; what names r in the error.
+func readLimited(r io.Reader, limit int64, what string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: larger than %d MB", what, limit>>20)
+	}
+	return data, nil
+}
+
+// newerVersion reports whether latest (e.g. "v0.10.0") is a higher vMAJOR.MINOR.PATCH than current.
+func newerVersion(current, latest string) bool {
+	parse := func(v string) []int {
+		var out []int
+		for _, p := range strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3) {
+			n, _ := strconv.Atoi(strings.SplitN(p, "-", 2)[0])
+			out = append(out, n)
+		}
+		for len(out) < 3 {
+			out = append(out, 0)
+		}
+		return out
+	}
+	c, l := parse(current), parse(latest)
+	for i := range c {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// releaseAsset is the archive name make release produces for a platform.
+func releaseAsset(goos, goarch string) string {
+	if goos == "windows" {
+		return "atlas9_" + goos + "_" + goarch + ".zip"
+	}
+	return "atlas9_" + goos + "_" + goarch + ".tar.gz"
+}
+
+// checksumFor finds name's sha256 in a sha256sum-style listing.
+func checksumFor(listing []byte, name string) (string, bool) {
+	sc := bufio.NewScanner(bytes.NewReader(listing))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+// extractBinary returns the atlas9 executable from a release archive.
+func extractBinary(archive []byte, zipped bool) ([]byte, error) {
+	if zipped {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if filepath.Base(f.Name) == "atlas9.exe" {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return readLimited(rc, maxArchiveSize, f.Name)
+			}
+		}
+		return nil, fmt.Errorf("atlas9.exe not found in archive")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("atlas9 not found in archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(h.Name) == "atlas9" {
+			return readLimited(tr, maxArchiveSize, h.Name)
+		}
+	}
+}
+
+// selfUpdate replaces the running executable with the latest release for this platform after verifying its
+// checksum, printing progress to w.
+func selfUpdate(ctx context.Context, w io.Writer) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	if strings.Contains(exe, "/Cellar/") {
+		return fmt.Errorf("%s is managed by Homebrew; run brew upgrade atlas9", exe)
+	}
+	rel, err := latestRelease(ctx)
+	if err != nil {
+		return err
+	}
+	if !newerVersion(version, rel.TagName) {
+		fmt.Fprintf(w, "atlas9 %s is up to date\n", version)
+		return nil
+	}
+	name := releaseAsset(runtime.GOOS, runtime.GOARCH)
+	archiveURL, sumsURL := rel.assetURL(name), rel.assetURL(checksumsAsset)
+	if archiveURL == "" {
+		return fmt.Errorf("release %s has no %s", rel.TagName, name)
+	}
+	if sumsURL == "" {
+		return fmt.Errorf("release %s has no %s; not installing an unverified binary", rel.TagName, checksumsAsset)
+	}
+	fmt.Fprintf(w, "Downloading %s %s...\n", name, rel.TagName)
+	sums, err := httpGet(ctx, sumsURL, maxMetadataSize)
+	if err != nil {
+		return err
+	}
+	want, ok := checksumFor(sums, name)
+	if !ok {
+		return fmt.Errorf("%s has no entry for %s", checksumsAsset, name)
+	}
+	archive, err := httpGet(ctx, archiveURL, maxArchiveSize)
+	if err != nil {
+		return err
+	}
+	got := sha256.Sum256(archive)
+	if hex.EncodeToString(got[:]) != want {
+		return fmt.Errorf("checksum mismatch for %s: got %x, want %s", name, got, want)
+	}
+	bin, err := extractBinary(archive, runtime.GOOS == "windows")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".atlas9-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bin); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := replaceExecutable(exe, tmp.Name(), runtime.GOOS == "windows"); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Updated %s: %s → %s\n", exe, version, rel.TagName)
+	return nil
+}
+
+// replaceExecutable moves the new binary to exe. A running .exe cannot be replaced on Windows, only renamed,
+// so there (renameOld) exe is moved to exe.old first, and moved back when the new binary cannot take its place.
+func replaceExecutable(exe, newBinary string, renameOld bool) error {
+	if !renameOld {
+		return os.Rename(newBinary, exe)
+	}
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(newBinary, exe); err != nil {
+		if rerr := os.Rename(old, exe); rerr != nil {
+			return fmt.Errorf("%w; restoring %s from %s: %v", err, exe, old, rerr)
+		}
+		return err
+	}
+	return nil
+}
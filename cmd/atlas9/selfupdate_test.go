@@ -0,0 +1,102 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSelfUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		current, latest string
+		want            bool
+	}{
+		{"v0.9.0", "v0.10.0", true},
+		{"v0.10.0", "v0.9.9", false},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.3", "v1.2.4", true},
+		{"v1.2", "v1.2.1", true},
+		{"1.2.3", "v2.0.0", true},
+		{"v1.3.0-rc1", "v1.3.0", false},
+		{"dev", "v0.1.0", true},
+	} {
+		if got := newerVersion(tc.current, tc.latest); got != tc.want {
+			t.Errorf("newerVersion(%s, %s) = %v, want %v", tc.current, tc.latest, got, tc.want)
+		}
+	}
+
+	listing := []byte("aaa  atlas9_linux_amd64.tar.gz\nbbb *atlas9_windows_amd64.zip\nmalformed line here\n")
+	for _, tc := range []struct{ name, want string }{
+		{"atlas9_linux_amd64.tar.gz", "aaa"}, {"atlas9_windows_amd64.zip", "bbb"}, {"atlas9_darwin_arm64.tar.gz", ""},
+	} {
+		if got, ok := checksumFor(listing, tc.name); got != tc.want || ok != (tc.want != "") {
+			t.Errorf("checksumFor(%s) = %q, %v; want %q", tc.name, got, ok, tc.want)
+		}
+	}
+
+	var tgz bytes.Buffer
+	gz := gzip.NewWriter(&tgz)
+	tw := tar.NewWriter(gz)
+	for _, f := range []struct{ name, body string }{{"README.md", "readme"}, {"atlas9_linux_amd64/atlas9", "ELF"}} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0755, Size: int64(len(f.body))})
+		tw.Write([]byte(f.body))
+	}
+	tw.Close()
+	gz.Close()
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	w, _ := zw.Create("atlas9_windows_amd64/atlas9.exe")
+	w.Write([]byte("MZ"))
+	zw.Close()
+	for _, tc := range []struct {
+		archive []byte
+		zipped  bool
+		want    string
+	}{
+		{tgz.Bytes(), false, "ELF"},
+		{zipped.Bytes(), true, "MZ"},
+	} {
+		if bin, err := extractBinary(tc.archive, tc.zipped); err != nil || string(bin) != tc.want {
+			t.Errorf("extractBinary(zipped %v) = %q, %v; want %q", tc.zipped, bin, err, tc.want)
+		}
+	}
+	if _, err := extractBinary(zipped.Bytes(), false); err == nil {
+		t.Error("extractBinary of a zip read as tar.gz: want an error")
+	}
+	if _, err := readLimited(strings.NewReader("12345"), 4, "body"); err == nil {
+		t.Error("readLimited over the limit: want an error")
+	}
+	if data, err := readLimited(strings.NewReader("1234"), 4, "body"); err != nil || string(data) != "1234" {
+		t.Errorf("readLimited at the limit = %q, %v", data, err)
+	}
+
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "atlas9.exe")
+	if err := os.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceExecutable(exe, filepath.Join(dir, "missing"), true); err == nil {
+		t.Error("replaceExecutable with a missing new binary: want an error")
+	}
+	if data, err := os.ReadFile(exe); err != nil || string(data) != "old" {
+		t.Errorf("after a failed replace, %s = %q, %v; want the old binary back", exe, data, err)
+	}
+	newBin := filepath.Join(dir, "new")
+	if err := os.WriteFile(newBin, []byte("new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceExecutable(exe, newBin, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "new" {
+		t.Errorf("after replacing, %s = %q, want the new binary", exe, data)
+	}
+	if data, _ := os.ReadFile(exe + ".old"); string(data) != "old" {
+		t.Errorf("%s.old = %q, want the old binary", exe, data)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// savedSession is the UI state of a project written to .atlas9/session.json after every run and on quit, so
+// the next launch in the project (or one after a terminal crash) opens where the last one was: stage, --env
+// override, tx mode and flags, the output tabs with the current one and scroll positions, the last run of each
+// stage, the edit-mode command history and whether output wraps.
+type savedSession struct {
+	Saved   time.Time                    `json:"saved"`
+	Env     string                       `json:"env,omitempty"` // --env of the last session
+	Stage   int                          `json:"stage"`
+	TxMode  string                       `json:"tx_mode,omitempty"`
+	Flags   map[string]map[string]string `json:"flags,omitempty"`
+	Tabs    []outputTab                  `json:"tabs"`
+	Current int                          `json:"current_tab"`
+	Runs    map[int]sessionRun           `json:"runs,omitempty"`
+	History []string                     `json:"history,omitempty"`
+	NoWrap  bool                         `json:"no_wrap,omitempty"` // output lines not wrapped (wrap action)
+}
+
+// sessionRun is a stageStatus as saved: the error is kept as its exit code and message.
+type sessionRun struct {
+	Env      string        `json:"env"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Exit     int           `json:"exit"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// maxSessionTabText caps the text saved per output tab; the end of longer output is kept.
+const maxSessionTabText = 256 << 10
+
+// maxCommandHistory is how many commands the edit-mode history keeps.
+const maxCommandHistory = 50
+
+func sessionPath(workDir string) string {
+	return filepath.Join(workDir, ".atlas9", "session.json")
+}
+
+// loadSession reads the session saved at path; a missing file returns nil and no error.
+func loadSession(path string) (*savedSession, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s savedSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if len(s.Tabs) == 0 {
+		return nil, errors.New("no output tabs")
+	}
+	s.Current = min(max(s.Current, 0), len(s.Tabs)-1)
+	return &s, nil
+}
+
+// saveSession writes s to path through a temporary file, so a crash while writing leaves the previous session.
+func saveSession(path string, s savedSession) error {
+	for i, tab := range s.Tabs {
+		s.Tabs[i].Text = clipSessionText(tab.Text)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// clipSessionText keeps the last maxSessionTabText bytes of text, from a line start so no color tag is cut.
+func clipSessionText(text string) string {
+	if len(text) <= maxSessionTabText {
+		return text
+	}
+	text = text[len(text)-maxSessionTabText:]
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[i+1:]
+	}
+	return "[gray]… earlier output not saved[-]\n" + text
+}
+
+// newSessionRun converts st for saving.
+func newSessionRun(st stageStatus) sessionRun {
+	r := sessionRun{Env: st.Env, Start: st.Start, Duration: st.Duration, Exit: exitCode(st.Err)}
+	if st.Err != nil {
+		r.Error = st.Err.Error()
+	}
+	return r
+}
+
+// newSessionRuns converts the last runs of the stages for saving.
+func newSessionRuns(runs map[int]stageStatus) map[int]sessionRun {
+	out := make(map[int]sessionRun, len(runs))
+	for i, r := range runs {
+		out[i] = newSessionRun(r)
+	}
+	return out
+}
+
+// status is the stageStatus r was saved from, with an error exitCode reads the same code from.
+func (r sessionRun) status() stageStatus {
+	st := stageStatus{Env: r.Env, Start: r.Start, Duration: r.Duration}
+	switch {
+	case r.Exit > 0:
+		st.Err = exitCodeError(r.Exit)
+	case r.Error != "":
+		st.Err = errors.New(r.Error)
+	}
+	return st
+}
+
+// pushHistory appends cmd to the command history, dropping an earlier copy of it and the oldest entries
+// beyond maxCommandHistory.
+func pushHistory(history []string, cmd string) []string {
+	if cmd == "" {
+		return history
+	}
+	out := make([]string, 0, len(history)+1)
+	for _, h := range history {
+		if h != cmd {
+			out = append(out, h)
+		}
+	}
+	out = append(out, cmd)
+	if len(out) > maxCommandHistory {
+		out = out[len(out)-maxCommandHistory:]
+	}
+	return out
+}
+
+// lastEnvPath is where the env of the project's last successful run is kept (see resolveEnvName).
+func lastEnvPath(workDir string) string {
+	return filepath.Join(workDir, ".atlas9", "last_env")
+}
+
+// loadLastEnv returns the env of the project's last successful run, "" if none was recorded.
+func loadLastEnv(workDir string) string {
+	data, _ := os.ReadFile(lastEnvPath(workDir))
+	return strings.TrimSpace(string(data))
+}
+
+// saveLastEnv records env as the project's last successfully used env.
+func saveLastEnv(workDir, env string) error {
+	path := lastEnvPath(workDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(env+"\n"), 0644)
+}
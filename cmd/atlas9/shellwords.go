@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// shellWord is one word of a command line: Raw as typed, quotes and escapes included, and Value as a shell
+// passes it to the command.
+type shellWord struct {
+	Raw, Value string
+}
+
+// splitShellWords splits a command line into words the way a POSIX shell does, without expansions: blanks
+// separate words outside quotes, '…' is literal, "…" takes backslash escapes of $ ` " \ and newlines, and a
+// backslash outside quotes escapes the next character (a backslash-newline continues the line).
+func splitShellWords(s string) ([]shellWord, error) {
+	var words []shellWord
+	var raw, value strings.Builder
+	inWord := false
+	end := func() {
+		if inWord {
+			words = append(words, shellWord{Raw: raw.String(), Value: value.String()})
+		}
+		raw.Reset()
+		value.Reset()
+		inWord = false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			end()
+			continue
+		case c == '\\':
+			if i+1 == len(s) {
+				return nil, errors.New("the command ends with a backslash")
+			}
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+			raw.WriteByte('\\')
+			value.WriteByte(s[i])
+			raw.WriteByte(s[i])
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, errors.New("unterminated ' quote")
+			}
+			raw.WriteString(s[i : i+j+2])
+			value.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+		case c == '"':
+			start := i
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New(`unterminated " quote`)
+			}
+			raw.WriteString(s[start : i+1])
+		default:
+			raw.WriteByte(c)
+			value.WriteByte(c)
+		}
+		inWord = true
+	}
+	end()
+	return words, nil
+}
+
+// shellValues returns the values of words, the args they make.
+func shellValues(words []shellWord) []string {
+	values := make([]string, len(words))
+	for i, w := range words {
+		values[i] = w.Value
+	}
+	return values
+}
+
+// shellQuote returns s as one word for splitShellWords (and a shell): as is when it has no blanks or special
+// characters, else in single quotes.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
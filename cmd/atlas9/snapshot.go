@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshot is a saved copy of an env's database in .atlas9/snapshots/<env>/.
+type snapshot struct {
+	Path string
+	At   time.Time
+	Size int64
+}
+
+// snapshotTimeFormat names snapshot files, so they sort by time.
+const snapshotTimeFormat = "20060102-150405"
+
+// snapshotDir is where the snapshots of env are kept.
+func snapshotDir(workDir, env string) string {
+	return filepath.Join(workDir, ".atlas9", "snapshots", env)
+}
+
+// snapshotExt is the file extension of a snapshot for a database driver ("" when snapshots are not supported).
+func snapshotExt(driver string) string {
+	switch driver {
+	case "postgres":
+		return ".dump" // pg_dump custom format
+	case "mysql":
+		return ".sql"
+	case "sqlite":
+		return ".db"
+	}
+	return ""
+}
+
+// listSnapshots returns the snapshots of env, newest first.
+func listSnapshots(workDir, env string) ([]snapshot, error) {
+	entries, err := os.ReadDir(snapshotDir(workDir, env))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []snapshot
+	for _, e := range entries {
+		stem := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		at, err := time.ParseInLocation(snapshotTimeFormat, stem, time.Local)
+		if err != nil || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, snapshot{Path: filepath.Join(snapshotDir(workDir, env), e.Name()), At: at, Size: info.Size()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].At.After(out[j].At) })
+	return out, nil
+}
+
+// libpqURL drops the query parameters atlas understands but libpq tools (pg_dump, pg_restore) reject.
+func libpqURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Del("search_path")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// mysqlClientArgs returns the host, port and user flags of the mysql tools for a mysql:// URL, the database and
+// the password (passed as MYSQL_PWD so it stays out of the process list).
+func mysqlClientArgs(rawURL string) (args []string, database, password string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", "", err
+	}
+	database = strings.TrimPrefix(u.Path, "/")
+	if database == "" {
+		return nil, "", "", fmt.Errorf("the URL names no database; snapshots need one")
+	}
+	args = []string{"--host", u.Hostname(), "--port", "3306"}
+	if p := u.Port(); p != "" {
+		args[3] = p
+	}
+	if u.User != nil {
+		args = append(args, "--user", u.User.Username())
+		password, _ = u.User.Password()
+	}
+	return args, database, password, nil
+}
+
+// sqlitePath returns the database file of a sqlite:// URL, relative to workDir.
+func sqlitePath(rawURL, workDir string) (string, error) {
+	_, rest, _ := strings.Cut(rawURL, "://")
+	path, _, _ := strings.Cut(rest, "?")
+	if path == "" || strings.HasPrefix(path, ":memory:") || strings.HasPrefix(path, "file::memory:") {
+		return "", fmt.Errorf("in-memory SQLite databases cannot be snapshotted")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workDir, path)
+	}
+	return path, nil
+}
+
+// runTool runs a database client tool and returns its stderr in the error when it fails.
+func runTool(cmd *exec.Cmd) error {
+	var errOut bytes.Buffer
+	cmd.Stderr = &errOut
+	if err := cmd.Run(); err != nil {
+		if stderr := strings.TrimSpace(errOut.String()); stderr != "" {
+			return fmt.Errorf("%s: %v: %s", filepath.Base(cmd.Path), err, stderr)
+		}
+		return fmt.Errorf("%s: %v", filepath.Base(cmd.Path), err)
+	}
+	return nil
+}
+
+// takeSnapshot dumps the database at rawURL to a new file in dir: pg_dump for Postgres, mysqldump for MySQL
+// and a file copy for SQLite. The client tools must be installed. It returns the snapshot's path.
+func takeSnapshot(ctx context.Context, rawURL, workDir, dir string, environ []string) (string, error) {
+	driver := dbDriver(rawURL)
+	ext := snapshotExt(driver)
+	if ext == "" {
+		return "", fmt.Errorf("snapshots are not supported for %q databases", driver)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, time.Now().Format(snapshotTimeFormat)+ext)
+	var err error
+	switch driver {
+	case "postgres":
+		cmd := exec.CommandContext(ctx, "pg_dump", "--format=custom", "--no-owner", "--dbname", libpqURL(rawURL), "--file", path)
+		cmd.Env = environ
+		err = runTool(cmd)
+	case "mysql":
+		args, database, password, aerr := mysqlClientArgs(rawURL)
+		if aerr != nil {
+			return "", aerr
+		}
+		// --add-drop-database makes the dump drop and recreate the database, so a restore also removes what
+		// was created after the snapshot.
+		args = append(args, "--single-transaction", "--routines", "--triggers", "--add-drop-database", "--databases", database, "--result-file", path)
+		cmd := exec.CommandContext(ctx, "mysqldump", args...)
+		cmd.Env = append(append([]string{}, environ...), "MYSQL_PWD="+password)
+		err = runTool(cmd)
+	case "sqlite":
+		src, perr := sqlitePath(rawURL, workDir)
+		if perr != nil {
+			return "", perr
+		}
+		err = copyFile(src, path)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// pgDropSchemas drops every schema of a Postgres database, then recreates an empty public schema, so a restore
+// also removes what was created after the snapshot (tables, schemas, types).
+const pgDropSchemas = `DO $$
+DECLARE s name;
+BEGIN
+	FOR s IN SELECT nspname FROM pg_namespace WHERE nspname !~ '^pg_' AND nspname <> 'information_schema' LOOP
+		EXECUTE format('DROP SCHEMA %I CASCADE', s);
+	END LOOP;
+END $$;
+CREATE SCHEMA public;`
+
+// restoreSnapshot replaces the database at rawURL with the snapshot at path. A Postgres dump is checked with
+// pg_restore --list first, then turned into SQL that psql runs in one transaction after dropping every schema,
+// so the database ends up as it was at the snapshot, or, when the restore fails, as it was before.
+func restoreSnapshot(ctx context.Context, rawURL, workDir, path string, environ []string) error {
+	switch dbDriver(rawURL) {
+	case "postgres":
+		list := exec.CommandContext(ctx, "pg_restore", "--list", path)
+		list.Env = environ
+		list.Stdout = io.Discard
+		if err := runTool(list); err != nil {
+			return fmt.Errorf("%s is not a readable dump: %w", filepath.Base(path), err)
+		}
+		script, err := os.CreateTemp("", "atlas9-restore-*.sql")
+		if err != nil {
+			return err
+		}
+		script.Close()
+		defer os.Remove(script.Name())
+		// --clean --if-exists also covers a dump that creates the public schema itself.
+		sql := exec.CommandContext(ctx, "pg_restore", "--no-owner", "--clean", "--if-exists", "--file", script.Name(), path)
+		sql.Env = environ
+		if err := runTool(sql); err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, "psql", "--no-psqlrc", "--quiet", "--single-transaction", "--set", "ON_ERROR_STOP=1",
+			"--command", pgDropSchemas, "--file", script.Name(), "--dbname", libpqURL(rawURL))
+		cmd.Env = environ
+		cmd.Stdout = io.Discard
+		return runTool(cmd)
+	case "mysql":
+		args, _, password, err := mysqlClientArgs(rawURL)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		cmd := exec.CommandContext(ctx, "mysql", args...)
+		cmd.Env = append(append([]string{}, environ...), "MYSQL_PWD="+password)
+		cmd.Stdin = f
+		return runTool(cmd)
+	case "sqlite":
+		dst, err := sqlitePath(rawURL, workDir)
+		if err != nil {
+			return err
+		}
+		return replaceSQLite(path, dst)
+	}
+	return fmt.Errorf("snapshots are not supported for %q databases", dbDriver(rawURL))
+}
+
+// ignoreSnapshots adds snapshots/ to .atlas9/.gitignore in workDir, so the data dumps are not committed with
+// the project.
+func ignoreSnapshots(workDir string) error {
+	path := filepath.Join(workDir, ".atlas9", ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if l := strings.TrimSpace(line); l == "snapshots/" || l == "/snapshots/" || l == "snapshots" || l == "/snapshots" {
+			return nil
+		}
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(data, "# database dumps taken by atlas9\n/snapshots/\n"...)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// replaceSQLite replaces the SQLite database dst with a copy of src. The copy is written next to dst and renamed
+// over it, so an interrupted restore leaves dst as it was; dst's write-ahead log, shared-memory index and rollback
+// journal are removed first, as SQLite would otherwise replay them into the restored file.
+func replaceSQLite(src, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".restore-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name()) // after the rename, there is nothing left to remove
+	if err := copyFile(src, tmp.Name()); err != nil {
+		return err
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(dst + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// copyFile copies src to dst, replacing dst, and syncs dst to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// formatBytes renders a file size for the snapshot list, e.g. "12.3 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
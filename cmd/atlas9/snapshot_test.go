@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLibpqURL(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"postgres://u:p@db:5432/app?sslmode=disable&search_path=public", "postgres://u:p@db:5432/app?sslmode=disable"},
+		{"postgres://db/app?search_path=public", "postgres://db/app"},
+		{"postgres://db/app", "postgres://db/app"},
+		{"postgres://%zz", "postgres://%zz"}, // unparsable: left as is
+	} {
+		if got := libpqURL(tc.in); got != tc.want {
+			t.Errorf("libpqURL(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestMySQLClientArgs(t *testing.T) {
+	for _, tc := range []struct {
+		url, database, password, err string
+		args                         []string
+	}{
+		{url: "mysql://root:secret@db:3307/app", database: "app", password: "secret",
+			args: []string{"--host", "db", "--port", "3307", "--user", "root"}},
+		{url: "mysql://localhost/app?parseTime=true", database: "app",
+			args: []string{"--host", "localhost", "--port", "3306"}},
+		{url: "mysql://root@localhost:3306/", err: "names no database"},
+	} {
+		args, database, password, err := mysqlClientArgs(tc.url)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("mysqlClientArgs(%q): err = %v, want %q", tc.url, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(args, tc.args) || database != tc.database || password != tc.password {
+			t.Errorf("mysqlClientArgs(%q) = %q, %q, %q, %v", tc.url, args, database, password, err)
+		}
+	}
+}
+
+func TestSQLitePath(t *testing.T) {
+	for _, tc := range []struct{ url, want, err string }{
+		{url: "sqlite://dev.db", want: "/work/dev.db"},
+		{url: "sqlite://data/dev.db?_fk=1", want: "/work/data/dev.db"},
+		{url: "sqlite:///var/db/dev.db", want: "/var/db/dev.db"},
+		{url: "sqlite://:memory:", err: "in-memory"},
+		{url: "sqlite://file::memory:?cache=shared", err: "in-memory"},
+		{url: "sqlite://", err: "in-memory"},
+	} {
+		got, err := sqlitePath(tc.url, "/work")
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("sqlitePath(%q): err = %v, want %q", tc.url, err, tc.err)
+			}
+			continue
+		}
+		if err != nil || got != filepath.FromSlash(tc.want) {
+			t.Errorf("sqlitePath(%q) = %q, %v, want %q", tc.url, got, err, tc.want)
+		}
+	}
+}
+
+func TestListSnapshots(t *testing.T) {
+	work := t.TempDir()
+	if got, err := listSnapshots(work, "dev"); got != nil || err != nil {
+		t.Errorf("no snapshot dir: got %v, %v", got, err)
+	}
+	dir := snapshotDir(work, "dev")
+	if err := os.MkdirAll(filepath.Join(dir, "20250102-030405.db"), 0755); err != nil { // a directory: skipped
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"20250101-120000.dump": "old",
+		"20250301-090000.dump": "newest",
+		"20250201-000000.sql":  "middle",
+		"notes.txt":            "not a snapshot",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := listSnapshots(work, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range got {
+		names = append(names, filepath.Base(s.Path))
+	}
+	if want := []string{"20250301-090000.dump", "20250201-000000.sql", "20250101-120000.dump"}; !slices.Equal(names, want) {
+		t.Fatalf("listSnapshots = %v, want %v", names, want)
+	}
+	if got[0].Size != int64(len("newest")) || !got[0].At.Equal(time.Date(2025, 3, 1, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("newest snapshot = %+v", got[0])
+	}
+}
+
+func TestRestoreSQLiteSnapshot(t *testing.T) {
+	work := t.TempDir()
+	db := filepath.Join(work, "dev.db")
+	for name, content := range map[string]string{"dev.db": "live", "dev.db-wal": "wal", "dev.db-shm": "shm"} {
+		if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := filepath.Join(t.TempDir(), "20250101-120000.db")
+	if err := os.WriteFile(snap, []byte("snapshot"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreSnapshot(context.Background(), "sqlite://dev.db", work, snap, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(db); string(data) != "snapshot" {
+		t.Errorf("database = %q, want the snapshot", data)
+	}
+	entries, _ := os.ReadDir(work)
+	if len(entries) != 1 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("left %v next to the database, want only dev.db", names)
+	}
+
+	// A snapshot that cannot be read leaves the database as it was.
+	if err := restoreSnapshot(context.Background(), "sqlite://dev.db", work, filepath.Join(work, "missing.db"), nil); err == nil {
+		t.Error("restoring a missing snapshot succeeded")
+	}
+	if data, _ := os.ReadFile(db); string(data) != "snapshot" {
+		t.Errorf("after a failed restore, database = %q", data)
+	}
+}
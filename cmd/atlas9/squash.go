@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// squashBackupDir is where the files of a squash are moved before atlas regenerates them as one file.
+func squashBackupDir(workDir string, t time.Time) string {
+	return filepath.Join(workDir, ".atlas9", "squash", t.Format("20060102-150405"))
+}
+
+// moveFiles moves names from srcDir to dstDir (created if needed), stopping at the first error.
+func moveFiles(srcDir, dstDir string, names []string) error {
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if err := os.Rename(filepath.Join(srcDir, n), filepath.Join(dstDir, n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newFiles returns the names in after that are not in before.
+func newFiles(before, after []string) []string {
+	seen := make(map[string]bool, len(before))
+	for _, n := range before {
+		seen[n] = true
+	}
+	var out []string
+	for _, n := range after {
+		if !seen[n] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
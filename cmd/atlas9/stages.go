@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"atlas9/internal/config"
+)
+
+// customStage returns the user-defined stage at stage index i, where first is the index of the first custom
+// stage (they follow the built-in ones).
+func customStage(stages []config.Stage, first, i int) (config.Stage, bool) {
+	i -= first
+	if i < 0 || i >= len(stages) {
+		return config.Stage{}, false
+	}
+	return stages[i], true
+}
+
+// findCustomStage returns the custom stage named name (case-insensitive), for `atlas9 run`.
+func findCustomStage(stages []config.Stage, name string) (config.Stage, bool) {
+	for _, s := range stages {
+		if strings.EqualFold(strings.TrimSpace(s.Name), name) {
+			return s, true
+		}
+	}
+	return config.Stage{}, false
+}
+
+// runShellStage runs a custom stage's command with sh -c in dir, with ATLAS9_ENV set to env, and returns its
+// combined stdout and stderr. onLine (may be nil) gets each output line as it arrives.
+func runShellStage(ctx context.Context, dir string, environ []string, env, command string, onLine func(string)) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(append([]string{}, environ...), "ATLAS9_ENV="+env)
+	cmd.WaitDelay = time.Second // children of sh keep the output open after ctx kills it
+	pr, pw := io.Pipe()
+	cmd.Stdout, cmd.Stderr = pw, pw
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		pw.Close()
+		done <- err
+	}()
+	var out strings.Builder
+	s := bufio.NewScanner(pr)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		out.WriteString(s.Text() + "\n")
+		if onLine != nil {
+			onLine(s.Text())
+		}
+	}
+	io.Copy(io.Discard, pr) // a line over the buffer size stops the scanner; drain so the command can finish
+	return out.String(), <-done
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"sync"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// modeKind is the TUI's top-level interaction mode; it decides which key table handles input.
+type modeKind int
+
+const (
+	modeNormal  modeKind = iota // main screen, keys are commands
+	modeEditing                 // command line has focus (vim-like insert mode)
+	modeRunning                 // main screen while a command is in flight
+	modeOverlay                 // a modal or full-screen overlay owns input
+)
+
+// overlayKind identifies the overlay showing in modeOverlay.
+type overlayKind int
+
+const (
+	overlayNone overlayKind = iota
+	overlayConfirm
+	overlayPreview
+	overlayHelp
+	overlayConfig
+	overlayEnv
+	overlayLinks
+	overlayTables
+	overlayMigrations
+	overlayVersions
+	overlayNote
+	overlayFlags
+	overlayCommand
+	overlaySchedule
+	overlayWorkspace
+	overlayNewEnv
+	overlayLintRules
+	overlayLintFindings
+	overlaySnapshots
+	overlayRuns
+	overlayCompare
+	overlayChangelog
+	overlayBenchmark
+	overlayMacros
+	overlaySelect
+)
+
+// uiEvent is an input to the state machine.
+type uiEvent int
+
+const (
+	evEditStart    uiEvent = iota // 'i' pressed
+	evEditStop                    // Esc / Ctrl+C / Enter in edit mode
+	evRunStart                    // a command is about to start
+	evRunDone                     // the command finished (success or failure)
+	evOverlayOpen                 // an overlay of the given kind was shown
+	evOverlayClose                // the overlay was closed
+)
+
+// uiState is the TUI state machine. Running is tracked separately from the mode the user sees, so an
+// overlay (e.g. the table browser) can be open while nothing or something runs underneath. All changes go
+// through Fire, which is safe to call from worker goroutines.
+type uiState struct {
+	mu      sync.Mutex
+	editing bool
+	running bool
+	overlay overlayKind
+	pending func() // one queued run request, started when the current run finishes
+
+	// OnChange, if set, is called (without the lock held) after every allowed transition.
+	OnChange func()
+	// Schedule starts a queued run request once the running command finishes; it must hand f to the UI
+	// goroutine (Fire(evRunDone) is usually called from a worker).
+	Schedule func(f func())
+}
+
+// Fire applies ev (kind is only used by evOverlayOpen) and reports whether the transition was allowed;
+// e.g. evRunStart is refused while a command is already running, so callers never double-run.
+func (s *uiState) Fire(ev uiEvent, kind overlayKind) bool {
+	ok := s.transition(ev, kind)
+	if ok && s.OnChange != nil {
+		s.OnChange()
+	}
+	if ok && ev == evRunDone {
+		s.mu.Lock()
+		next := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+		if next != nil && s.Schedule != nil {
+			s.Schedule(next)
+		}
+	}
+	return ok
+}
+
+// Enqueue stores f to run after the current command finishes, replacing any earlier queued request.
+// It returns false (and queues nothing) when no command is running; the caller should then run f itself.
+func (s *uiState) Enqueue(f func()) bool {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return false
+	}
+	s.pending = f
+	s.mu.Unlock()
+	if s.OnChange != nil {
+		s.OnChange()
+	}
+	return true
+}
+
+// Queued reports whether a run request is waiting for the current command.
+func (s *uiState) Queued() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending != nil
+}
+
+func (s *uiState) transition(ev uiEvent, kind overlayKind) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch ev {
+	case evEditStart:
+		if s.editing || s.overlay != overlayNone {
+			return false
+		}
+		s.editing = true
+	case evEditStop:
+		if !s.editing {
+			return false
+		}
+		s.editing = false
+	case evRunStart:
+		if s.running {
+			return false
+		}
+		s.running = true
+	case evRunDone:
+		s.running = false
+	case evOverlayOpen:
+		s.editing = false
+		s.overlay = kind
+	case evOverlayClose:
+		if s.overlay == overlayNone {
+			return false
+		}
+		s.overlay = overlayNone
+	}
+	return true
+}
+
+// Mode returns the current mode; overlays take precedence over editing, editing over running.
+func (s *uiState) Mode() (modeKind, overlayKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.overlay != overlayNone:
+		return modeOverlay, s.overlay
+	case s.editing:
+		return modeEditing, overlayNone
+	case s.running:
+		return modeRunning, overlayNone
+	}
+	return modeNormal, overlayNone
+}
+
+// Running reports whether a command is in flight.
+func (s *uiState) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// Editing reports whether the command line is being edited.
+func (s *uiState) Editing() bool {
+	m, _ := s.Mode()
+	return m == modeEditing
+}
+
+// InOverlay reports whether an overlay owns input.
+func (s *uiState) InOverlay() bool {
+	m, _ := s.Mode()
+	return m == modeOverlay
+}
+
+// keyID identifies a key in a key table: special keys by tcell.Key (and whether Ctrl is held), printable keys by
+// rune. dispatch falls back to the lower-cased rune, so Caps Lock does not change lower-case bindings.
+type keyID struct {
+	key  tcell.Key
+	r    rune
+	ctrl bool
+}
+
+func keyOf(event *tcell.EventKey) keyID {
+	if event.Key() == tcell.KeyRune {
+		return keyID{key: tcell.KeyRune, r: event.Rune()}
+	}
+	return keyID{key: event.Key(), ctrl: event.Modifiers()&tcell.ModCtrl != 0}
+}
+
+func runeKey(r rune) keyID         { return keyID{key: tcell.KeyRune, r: r} }
+func specialKey(k tcell.Key) keyID { return keyID{key: k} }
+func ctrlKey(k tcell.Key) keyID    { return keyID{key: k, ctrl: true} }
+
+// keyTable maps keys to handlers for one mode. Keys without an entry pass through to the focused primitive.
+type keyTable map[keyID]func()
+
+// keymap holds one key table per mode.
+type keymap map[modeKind]keyTable
+
+// dispatch runs the handler bound to event in mode's table and consumes the event, or passes it through.
+func (km keymap) dispatch(mode modeKind, event *tcell.EventKey) *tcell.EventKey {
+	id := keyOf(event)
+	h, ok := km[mode][id]
+	if !ok && id.key == tcell.KeyRune && unicode.ToLower(id.r) != id.r {
+		id.r = unicode.ToLower(id.r)
+		h, ok = km[mode][id]
+	}
+	if !ok && id.ctrl {
+		// Ctrl+<special> without its own binding acts like the plain key (tcell reports Ctrl on KeyCtrlC etc.).
+		id.ctrl = false
+		h, ok = km[mode][id]
+	}
+	if ok {
+		if h != nil {
+			h()
+		}
+		return nil
+	}
+	return event
+}
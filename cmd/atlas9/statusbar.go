@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// stageStatus is the outcome of a stage's last run, shown in the status bar above the footer.
+type stageStatus struct {
+	Env      string
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// exitCode returns the exit code of the atlas process behind err: 0 for nil, -1 when there is none (atlas
+// could not be started, a timeout, an in-process check).
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var codeErr exitCodeError
+	if errors.As(err, &codeErr) {
+		return int(codeErr)
+	}
+	return -1
+}
+
+// ago renders how long before now t was, coarsely ("just now", "5m ago", "3h ago", "2d ago").
+func ago(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+// statusBarText renders the status bar for a stage (tview tags): when it last ran, how long it took, its exit
+// code and env. An env other than the current one is flagged, since the output then belongs to another database.
+func statusBarText(stage string, s stageStatus, currentEnv string, now time.Time) string {
+	if s.Start.IsZero() {
+		return "[gray]" + stage + ": not run yet[-]"
+	}
+	result := "[green]exit 0[-]"
+	switch code := exitCode(s.Err); {
+	case code > 0:
+		result = fmt.Sprintf("[red]exit %d[-]", code)
+	case code < 0:
+		result = "[red]failed[-]"
+	}
+	env := "env " + s.Env
+	if s.Env != currentEnv {
+		env = "[yellow]env " + s.Env + " (current: " + currentEnv + ")[-]"
+	}
+	return fmt.Sprintf("[gray]%s: last run %s (%s) • %s[-] • %s • %s", stage, s.Start.Format("15:04:05"), ago(s.Start, now),
+		s.Duration.Round(time.Millisecond), result, env)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "strings"
+
+// outputTab is one command result kept open in the output pane: its text (with color tags) and scroll position.
+type outputTab struct {
+	Label    string
+	Text     string
+	Row, Col int
+}
+
+// outputTabs are the open output tabs in the order they were opened; results replace the tab with the same label.
+type outputTabs struct {
+	Tabs    []outputTab
+	Current int
+}
+
+// index returns the position of the tab labelled label, or -1.
+func (t *outputTabs) index(label string) int {
+	for i, tab := range t.Tabs {
+		if tab.Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// open returns the position of the tab labelled label, adding an empty one if there is none.
+func (t *outputTabs) open(label string) int {
+	if i := t.index(label); i >= 0 {
+		return i
+	}
+	t.Tabs = append(t.Tabs, outputTab{Label: label})
+	return len(t.Tabs) - 1
+}
+
+// title renders the tab strip for the output border, e.g. " Status │ Dry-Run ", with the current tab
+// in bold and the others gray (tview tags).
+func (t *outputTabs) title() string {
+	parts := make([]string, len(t.Tabs))
+	for i, tab := range t.Tabs {
+		label := tab.Label
+		if i == t.Current {
+			label = "[::b]" + label + "[::-]"
+		} else {
+			label = "[gray]" + label + "[-]"
+		}
+		parts[i] = label
+	}
+	return " " + strings.Join(parts, " │ ") + " "
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// highContrastTheme is the config theme that draws through highContrastScreen.
+const highContrastTheme = "high-contrast"
+
+// minContrast is the WCAG AAA contrast ratio a high-contrast cell's foreground must reach against its background.
+const minContrast = 7
+
+// highContrastScreen is a tcell.Screen that boosts the foreground colors the UI draws where they are hard to read
+// against their background: on dark backgrounds grays become white and other colors their brightest, bold variant,
+// so status and risk colors stay distinct on dim displays; on light backgrounds they become black.
+type highContrastScreen struct {
+	tcell.Screen
+}
+
+func (s highContrastScreen) SetContent(x, y int, primary rune, combining []rune, style tcell.Style) {
+	s.Screen.SetContent(x, y, primary, combining, highContrast(style))
+}
+
+// highContrast remaps style's foreground when it falls short of minContrast against the background (the terminal
+// default is taken to be dark): to black on light backgrounds, else to white or a bright primary/secondary color by
+// its dominant hue. Readable pairs, e.g. the filter's black on yellow, are left alone.
+func highContrast(style tcell.Style) tcell.Style {
+	fg, bg, _ := style.Decompose()
+	if fg == tcell.ColorDefault {
+		return style
+	}
+	bgLum := 0.0
+	if bg != tcell.ColorDefault {
+		bgLum = luminance(bg)
+	}
+	if contrast(luminance(fg), bgLum) >= minContrast {
+		return style
+	}
+	if contrast(0, bgLum) > contrast(1, bgLum) {
+		return style.Foreground(tcell.ColorBlack).Bold(true)
+	}
+	r, g, b := fg.RGB()
+	hi, lo := max(r, g, b), min(r, g, b)
+	var c tcell.Color
+	switch {
+	case hi-lo < 48: // gray, white, black
+		c = tcell.ColorWhite
+	case r == hi && g > hi/2 && b <= hi/2:
+		c = tcell.ColorYellow
+	case r == hi && b > hi/2:
+		c = tcell.ColorFuchsia
+	case r == hi:
+		c = tcell.ColorRed
+	case g == hi && b > hi/2:
+		c = tcell.ColorAqua
+	case g == hi:
+		c = tcell.ColorLime
+	default: // blues are hard to read on dark backgrounds
+		c = tcell.ColorAqua
+	}
+	return style.Foreground(c).Bold(true)
+}
+
+// luminance is c's WCAG relative luminance, from 0 (black) to 1 (white).
+func luminance(c tcell.Color) float64 {
+	r, g, b := c.RGB()
+	channel := func(v int32) float64 {
+		s := float64(v) / 255
+		if s <= 0.03928 {
+			return s / 12.92
+		}
+		return math.Pow((s+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(r) + 0.7152*channel(g) + 0.0722*channel(b)
+}
+
+// contrast is the WCAG contrast ratio of two relative luminances, from 1 to 21.
+func contrast(a, b float64) float64 {
+	return (max(a, b) + 0.05) / (min(a, b) + 0.05)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestHighContrast(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		fg, bg tcell.Color
+		want   tcell.Color
+	}{
+		{"filter match", tcell.ColorBlack, tcell.ColorYellow, tcell.ColorBlack},
+		{"white on default", tcell.ColorWhite, tcell.ColorDefault, tcell.ColorWhite},
+		{"gray on default", tcell.ColorGray, tcell.ColorDefault, tcell.ColorWhite},
+		{"green on default", tcell.ColorGreen, tcell.ColorDefault, tcell.ColorLime},
+		{"blue on black", tcell.ColorBlue, tcell.ColorBlack, tcell.ColorAqua},
+		{"gray on white", tcell.ColorGray, tcell.ColorWhite, tcell.ColorBlack},
+		{"yellow on yellow", tcell.ColorOlive, tcell.ColorYellow, tcell.ColorBlack},
+		{"default", tcell.ColorDefault, tcell.ColorYellow, tcell.ColorDefault},
+	} {
+		fg, bg, _ := highContrast(tcell.StyleDefault.Foreground(tc.fg).Background(tc.bg)).Decompose()
+		if fg != tc.want || bg != tc.bg {
+			t.Errorf("%s: got %v on %v, want %v on %v", tc.name, fg, bg, tc.want, tc.bg)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// migrationDirAt copies the migrations of dir up to and including version into a new temporary directory with
+// its own atlas.sum, so atlas can replay the directory as it was at that version (schema inspect --url file://).
+// The caller removes the directory.
+func migrationDirAt(dir, version string) (string, error) {
+	tmp, err := os.MkdirTemp("", "atlas9-schema-at-")
+	if err != nil {
+		return "", err
+	}
+	fail := func(err error) (string, error) {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	for _, name := range sqlFiles(dir) {
+		if migrationVersion(name) > version {
+			break
+		}
+		if err := copyFile(filepath.Join(dir, name), filepath.Join(tmp, name)); err != nil {
+			return fail(err)
+		}
+	}
+	if err := writeSumFile(tmp); err != nil {
+		return fail(err)
+	}
+	return tmp, nil
+}
+
+// reverseMigration returns the SQL that undoes migration file name of dir: the diff from the schema with it to
+// the schema before it, computed by run (an atlas command) on the dev database dev. Undoing the first file
+// diffs to an empty schema.
+func reverseMigration(dir, name, dev string, run func(args ...string) (string, string, error)) (string, error) {
+	version, prev := migrationVersion(name), ""
+	for _, n := range sqlFiles(dir) {
+		if v := migrationVersion(n); v < version {
+			prev = v
+		}
+	}
+	after, err := migrationDirAt(dir, version)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(after)
+	before, err := migrationDirAt(dir, prev) // prev "" copies no file
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(before)
+	out, errOut, err := run("schema", "diff", "--from", "file://"+filepath.ToSlash(after), "--to", "file://"+filepath.ToSlash(before), "--dev-url", dev)
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, firstLine(errOut))
+	}
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const (
+	toastDuration = 4 * time.Second // how long a toast stays on screen
+	maxToasts     = 3               // older toasts are dropped beyond this
+	toastMaxWidth = 60
+)
+
+// toast is one short-lived message.
+type toast struct {
+	text    string
+	expires time.Time
+}
+
+// toastStack holds the toasts drawn in the top-right corner above everything else (see overlayRoot).
+// It is only touched on the UI goroutine.
+type toastStack struct {
+	items []toast
+}
+
+// Add shows text (tview color tags allowed) until toastDuration has passed.
+func (t *toastStack) Add(text string) {
+	t.items = append(t.items, toast{text: text, expires: time.Now().Add(toastDuration)})
+	if len(t.items) > maxToasts {
+		t.items = t.items[len(t.items)-maxToasts:]
+	}
+}
+
+// Prune drops expired toasts.
+func (t *toastStack) Prune() {
+	now := time.Now()
+	kept := t.items[:0]
+	for _, it := range t.items {
+		if now.Before(it.expires) {
+			kept = append(kept, it)
+		}
+	}
+	t.items = kept
+}
+
+// Draw renders the toasts as bordered boxes stacked down the right edge of the screen, newest first.
+func (t *toastStack) Draw(screen tcell.Screen) {
+	width, _ := screen.Size()
+	y := 0
+	for i := len(t.items) - 1; i >= 0; i-- {
+		text := t.items[i].text
+		w := tview.TaggedStringWidth(text) + 4
+		if w > toastMaxWidth {
+			w = toastMaxWidth
+		}
+		if w > width {
+			w = width
+		}
+		box := tview.NewBox().SetBorder(true).SetBorderColor(hexToTCell(logoColorHex))
+		box.SetRect(width-w, y, w, 3)
+		box.Draw(screen)
+		tview.Print(screen, text, width-w+2, y+1, w-4, tview.AlignLeft, tcell.ColorDefault)
+		y += 3
+	}
+}
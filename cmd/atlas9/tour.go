@@ -0,0 +1,77 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"ariga.io/atlas/sql/migrate"
+)
+
+// tourProject is the throwaway project `atlas9 tour` runs the TUI against: SQLite, so neither Docker nor a
+// database server is needed.
+//
+//go:embed tourdata
+var tourProject embed.FS
+
+// tourStep is one callout of the tour. It shows until stage Until has run; the last step has Until -1.
+type tourStep struct {
+	Until int
+	Key   string // message key of the callout text ("tour.<key>")
+}
+
+// tourSteps walk through the stages in order; Status runs when the TUI starts, so the first step already
+// explains its output.
+var tourSteps = []tourStep{
+	{Until: 1, Key: "status"},
+	{Until: 2, Key: "diff"},
+	{Until: 3, Key: "lint"},
+	{Until: 4, Key: "dry_run"},
+	{Until: -1, Key: "done"},
+}
+
+// writeTourProject copies the tour project into a new temporary directory with its atlas.sum and returns the
+// directory. The caller removes it.
+func writeTourProject() (string, error) {
+	dir, err := os.MkdirTemp("", "atlas9-tour-")
+	if err != nil {
+		return "", err
+	}
+	err = fs.WalkDir(tourProject, "tourdata", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel("tourdata", path)
+		target := filepath.Join(dir, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := tourProject.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+	if err == nil {
+		err = writeSumFile(filepath.Join(dir, "migrations"))
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// writeSumFile writes the atlas.sum of the migration directory dir.
+func writeSumFile(dir string) error {
+	local, err := migrate.NewLocalDir(dir)
+	if err != nil {
+		return err
+	}
+	sum, err := local.Checksum()
+	if err != nil {
+		return err
+	}
+	return migrate.WriteSumFile(local, sum)
+}
@@ -0,0 +1,9 @@
+// The atlas9 tour project: a SQLite database next to this file, and an in-memory dev database for Diff and Lint.
+env "local" {
+  src = "file://schema.sql"
+  url = "sqlite://tour.db"
+  dev = "sqlite://file?mode=memory"
+  migration {
+    dir = "file://migrations"
+  }
+}
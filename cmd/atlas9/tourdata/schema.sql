@@ -0,0 +1,15 @@
+-- The desired schema. migrations/ only creates users so far; Diff writes the migration for posts.
+CREATE TABLE users (
+  id integer NOT NULL PRIMARY KEY,
+  email text NOT NULL,
+  name text NULL
+);
+CREATE UNIQUE INDEX users_email ON users (email);
+
+CREATE TABLE posts (
+  id integer NOT NULL PRIMARY KEY,
+  user_id integer NOT NULL,
+  title text NOT NULL,
+  body text NULL,
+  CONSTRAINT posts_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+
+	"atlas9/internal/config"
+)
+
+// tuiHarness drives runTUI on a simulation screen with atlas replaced by fakeRunner.
+type tuiHarness struct {
+	t      *testing.T
+	screen tcell.SimulationScreen
+	app    *tview.Application
+	done   chan error
+}
+
+// startTUI writes fixtures (see fakeRunner) to a temporary directory and starts the TUI against them.
+func startTUI(t *testing.T, fixtures map[string]string, policy confirmPolicy) *tuiHarness {
+	t.Helper()
+	fixtureDir := t.TempDir()
+	for name, content := range fixtures {
+		if err := os.WriteFile(filepath.Join(fixtureDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	screen := tcell.NewSimulationScreen("UTF-8")
+	screen.SetSize(120, 40)
+	h := &tuiHarness{t: t, screen: screen, done: make(chan error, 1)}
+	started := make(chan struct{})
+	conf := config.Default()
+	conf.CheckUpdates = false // no network in tests
+	go func() {
+		h.done <- runTUI(tuiConfig{
+			workDir:   t.TempDir(),
+			envFlag:   "local",
+			noConnect: true,
+			conf:      conf,
+			policy:    policy,
+			runner:    fakeRunner{dir: fixtureDir},
+			screen:    screen,
+			started: func(app *tview.Application) {
+				h.app = app
+				close(started)
+			},
+		})
+	}()
+	<-started
+	t.Cleanup(func() {
+		h.app.Stop()
+		select {
+		case err := <-h.done:
+			if err != nil {
+				t.Errorf("runTUI: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("runTUI did not return after Stop")
+		}
+	})
+	return h
+}
+
+// text returns the screen contents, one line per row. It reads on the UI goroutine so it never races a draw.
+func (h *tuiHarness) text() string {
+	var b strings.Builder
+	h.app.QueueUpdate(func() { screenText(&b, h.screen) })
+	return b.String()
+}
+
+func screenText(b *strings.Builder, screen tcell.SimulationScreen) {
+	cells, width, _ := screen.GetContents()
+	for i, c := range cells {
+		if len(c.Runes) > 0 {
+			b.WriteRune(c.Runes[0])
+		} else {
+			b.WriteByte(' ')
+		}
+		if (i+1)%width == 0 {
+			b.WriteByte('\n')
+		}
+	}
+}
+
+// waitFor polls the screen until it contains want.
+func (h *tuiHarness) waitFor(want string) {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if strings.Contains(h.text(), want) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	h.t.Fatalf("screen never showed %q; last screen:\n%s", want, h.text())
+}
+
+func (h *tuiHarness) key(k tcell.Key) {
+	h.screen.InjectKey(k, 0, tcell.ModNone)
+}
+
+func TestStatusSuccess(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status.stdout": "Migration Status: OK\n  -- Current Version: 20240101\n",
+	}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Migration Status: OK")
+	h.waitFor("Current Version: 20240101")
+}
+
+func TestStatusFailure(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status.stderr": "connection refused\n",
+		"migrate_status.exit":   "1",
+	}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Error: exit status 1")
+	h.waitFor("connection refused")
+}
+
+func TestHashFailureStopsStatus(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_hash.stderr":   "checksum mismatch\n",
+		"migrate_hash.exit":     "1",
+		"migrate_status.stdout": "Migration Status: OK\n",
+	}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Hash failed: exit status 1")
+	if strings.Contains(h.text(), "Migration Status: OK") {
+		t.Error("status ran although hash failed")
+	}
+}
+
+func TestHugeOutput(t *testing.T) {
+	var b strings.Builder
+	for i := 1; i <= 50000; i++ {
+		fmt.Fprintf(&b, "pending migration %05d\n", i)
+	}
+	h := startTUI(t, map[string]string{"migrate_status.stdout": b.String()}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("pending migration 00001")
+	// The UI stays responsive: scrolling moves the first line out of view.
+	for i := 0; i < 3; i++ {
+		h.key(tcell.KeyDown)
+	}
+	h.waitFor("pending migration 00004")
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Contains(h.text(), "pending migration 00001") {
+		if time.Now().After(deadline) {
+			t.Fatal("output did not scroll")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestSlowApplyStreamsProgress(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status.stdout":        "Migration Status: PENDING\n",
+		"migrate_apply_dry-run.stdout": "-- migrating version 1\n-> CREATE TABLE a (id int);\n-- migrating version 2\n-> CREATE TABLE b (id int);\n",
+		"migrate_apply.stdout": "Migrating to version 2 (2 migrations in total):\n" +
+			"  -- migrating version 1\n    -> CREATE TABLE a (id int);\n  -- ok (1ms)\n" +
+			"  -- migrating version 2\n    -> CREATE TABLE b (id int);\n  -- ok (2ms)\n",
+		"migrate_apply.delay": "300ms",
+	}, confirmPolicy{AutoApproveMax: 5})
+	h.waitFor("Migration Status: PENDING")
+	for i := 0; i < 4; i++ { // Status → Apply
+		h.key(tcell.KeyTab)
+	}
+	h.key(tcell.KeyEnter)
+	h.waitFor("Applying 1/2: 1")
+	h.waitFor("Applying 2/2: 2")
+	h.waitFor("Apply completed successfully.")
+	if strings.Contains(h.text(), "different statements") {
+		t.Error("apply flagged as diverging from an identical dry-run")
+	}
+}
+
+func TestVerifyApplied(t *testing.T) {
+	a, b, c := "CREATE TABLE a (id int);", "CREATE TABLE b (id int);", "DROP TABLE c;"
+	for _, tc := range []struct {
+		name               string
+		expected, executed []string
+		want               string // first finding's prefix; "" for none
+	}{
+		{"same", []string{a, b}, []string{a, "CREATE TABLE  b\n(id int);"}, ""},
+		{"extra", []string{a}, []string{a, c}, "extra: DROP TABLE c;"},
+		{"missing", []string{a, b}, []string{a}, "missing: CREATE TABLE b"},
+		{"reordered", []string{a, b}, []string{b, a}, "reordered: statement 1"},
+	} {
+		got := verifyApplied(tc.expected, tc.executed)
+		if tc.want == "" && len(got) > 0 || tc.want != "" && (len(got) == 0 || !strings.HasPrefix(got[0], tc.want)) {
+			t.Errorf("%s: verifyApplied = %q, want first finding %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDiffShowsToast(t *testing.T) {
+	h := startTUI(t, map[string]string{"migrate_status.stdout": "Migration Status: OK\n"}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Migration Status: OK")
+	h.key(tcell.KeyTab) // Status → Diff
+	h.key(tcell.KeyEnter)
+	h.waitFor("diff: no schema changes")
+}
+
+func TestRunShortcut(t *testing.T) {
+	h := startTUI(t, map[string]string{"migrate_status.stdout": "Migration Status: OK\n"}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Migration Status: OK")
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, ' ', tcell.ModNone)
+	h.waitFor("run stage: 1–")
+	h.screen.InjectKey(tcell.KeyRune, '2', tcell.ModNone) // jumps to Diff and runs it
+	h.waitFor("diff: no schema changes")
+}
+
+func TestMacroRecordReplay(t *testing.T) {
+	h := startTUI(t, map[string]string{"migrate_status.stdout": "Migration Status: OK\n"}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Migration Status: OK")
+	h.waitFor("enter:status")
+	for _, r := range "@ 2@" {
+		h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	h.waitFor("Save macro  2 as")
+	h.key(tcell.KeyEnter) // no name: kept for this session
+	h.waitFor("diff: no schema changes")
+	h.screen.InjectKey(tcell.KeyRune, '@', tcell.ModNone)
+	h.screen.InjectKey(tcell.KeyRune, '@', tcell.ModNone)
+	h.waitFor("replaying macro (2 keys)")
+}
+
+func TestMacroStopsAtConfirmation(t *testing.T) {
+	h := startTUI(t, map[string]string{"migrate_status.stdout": "Migration Status: OK\n"}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("exit 0") // clean refuses to start while Status runs
+	h.screen.InjectKey(tcell.KeyRune, '@', tcell.ModNone)
+	h.screen.InjectKey(tcell.KeyRune, 'z', tcell.ModNone) // clean: opens a confirmation
+	h.waitFor("Clean local?")
+	h.key(tcell.KeyEnter) // recorded, but a replay must not answer it
+	h.waitFor("Type local")
+	h.key(tcell.KeyEscape)
+	h.screen.InjectKey(tcell.KeyRune, '@', tcell.ModNone)
+	h.waitFor("Save macro z<Enter><Esc> as")
+	h.key(tcell.KeyEnter)
+	h.screen.InjectKey(tcell.KeyRune, '@', tcell.ModNone)
+	h.screen.InjectKey(tcell.KeyRune, '@', tcell.ModNone)
+	h.waitFor("macro stopped: answer the confirmation yourself")
+	h.waitFor("Clean local?")
+}
+
+func TestOutputFilter(t *testing.T) {
+	h := startTUI(t, map[string]string{"migrate_status.stdout": "Migration Status: PENDING\n  -- Current Version: 1\n  -- Next Version: 2\n"}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Next Version: 2")
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, '/', tcell.ModNone)
+	for _, r := range "next" {
+		h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	h.key(tcell.KeyEnter)
+	h.waitFor("filtered: 1 of 3 lines")
+	if strings.Contains(h.text(), "Current Version") {
+		t.Error("the filter did not hide the lines without a match")
+	}
+	h.key(tcell.KeyEscape)
+	h.waitFor("Current Version: 1")
+}
+
+func TestEditedCommandCheck(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status.stdout": "Migration Status: OK\n",
+		"help.stdout":           "Available Commands:\n  migrate     Manage versioned migration files\n\nFlags:\n  -h, --help   help for atlas\n",
+		"migrate_help.stdout":   "Available Commands:\n  status      Get information about the current migration status.\n\nFlags:\n  -h, --help   help for migrate\n",
+	}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, 'i', tcell.ModNone)
+	h.waitFor("edit mode")
+	h.key(tcell.KeyCtrlU)
+	for _, r := range "atlas migrate stats --env local" {
+		h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	h.key(tcell.KeyEnter)
+	h.waitFor(`atlas migrate has no command "stats" (it has status)`)
+}
+
+func TestEditedApplyConfirms(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status.stdout":        "Migration Status: PENDING\n",
+		"migrate_apply_dry-run.stdout": "-- migrating version 1\n-> DROP TABLE a;\n",
+		"migrate_apply.stdout":         "Migrating to version 1\n",
+	}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, 'i', tcell.ModNone)
+	h.waitFor("edit mode")
+	h.key(tcell.KeyCtrlU)
+	for _, r := range "atlas migrate apply --env local" {
+		h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+	h.key(tcell.KeyEnter)
+	h.waitFor("Apply changes to local?")
+	if strings.Contains(h.text(), "Migrating to version 1") {
+		t.Error("the edited apply ran before it was confirmed")
+	}
+}
+
+func TestOutputSelection(t *testing.T) {
+	h := startTUI(t, map[string]string{"migrate_status.stdout": "Migration Status: OK\n"}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("Migration Status: OK")
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, 'V', tcell.ModNone)
+	h.waitFor("selected lines 1–1 (1)")
+	h.key(tcell.KeyEnd)
+	h.key(tcell.KeyUp)
+	h.waitFor("selected lines 1–")
+	h.key(tcell.KeyEscape)
+	h.waitFor("enter:status")
+	sel := newOutputSelection("a\n[red]b[-]\nc\n", "a\nb\nc\n", 1)
+	sel.move(5)
+	if got := sel.text(); got != "b\nc\n" {
+		t.Errorf("selection text = %q, want lines 2 and 3", got)
+	}
+}
+
+func TestLintSections(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status.stdout": "Migration Status: OK\n",
+		"migrate_lint.stdout":   "  -- analyzing version 2\n    -- L2: Dropping table\n",
+	}, confirmPolicy{AutoApproveMax: -1})
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, ' ', tcell.ModNone)
+	h.screen.InjectKey(tcell.KeyRune, '3', tcell.ModNone)
+	h.waitFor("▾ > atlas migrate lint --env local  exit 0")
+	h.screen.InjectKey(tcell.KeyRune, '[', tcell.ModNone) // from no cursor: the last section
+	h.waitFor("esc:done")
+	h.key(tcell.KeyEnter) // folds instead of running Lint again
+	h.waitFor("▸ > atlas migrate lint --env local  exit 0  2 line(s) folded")
+}
+
+func TestVersionsMigrateSet(t *testing.T) {
+	h := startTUI(t, map[string]string{
+		"migrate_status_format_{{ json .Applied }}.stdout": `[{"Version":"1"},{"Version":"2"}]`,
+		"migrate_status.stdout":                            "Migration Status: OK\n",
+		"migrate_set_2.stdout":                             "Set ok\n",
+	}, confirmPolicy{AutoApproveMax: -1})
+	// Status runs at startup and v is ignored while a command runs, so wait for it to finish: first its output,
+	// then the footer back from "running…".
+	h.waitFor("Migration Status: OK")
+	h.waitFor("enter:status")
+	h.screen.InjectKey(tcell.KeyRune, 'v', tcell.ModNone)
+	h.waitFor("Versions — local")
+	h.key(tcell.KeyEnter) // newest version is selected
+	h.waitFor("Set local to version 2")
+	h.key(tcell.KeyEnter)
+	h.waitFor("Set ok")
+}
@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+// txModes are the values of atlas's --tx-mode; the first is atlas's default.
+var txModes = []string{"file", "all", "none"}
+
+// nextTxMode returns the mode after m in txModes, wrapping around.
+func nextTxMode(m string) string {
+	for i, v := range txModes {
+		if v == m {
+			return txModes[(i+1)%len(txModes)]
+		}
+	}
+	return txModes[0]
+}
+
+// txModeArgs returns the --tx-mode flag for mode, or nil for atlas's default.
+func txModeArgs(mode string) []string {
+	if mode == "" || mode == txModes[0] {
+		return nil
+	}
+	return []string{"--tx-mode", mode}
+}
+
+// txModeSelector renders the modes with the current one highlighted (tview-colored).
+func txModeSelector(mode string) string {
+	parts := make([]string, len(txModes))
+	for i, v := range txModes {
+		if v == mode {
+			parts[i] = "[::r]" + v + "[::-]"
+		} else {
+			parts[i] = "[gray]" + v + "[-]"
+		}
+	}
+	return "tx-mode: " + strings.Join(parts, " ")
+}
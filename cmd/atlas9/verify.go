@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errPendingChanged aborts an Apply whose pending statements changed after they were confirmed.
+var errPendingChanged = errors.New("pending statements changed since confirmation")
+
+// verifyApplied compares the statements an apply executed with those its dry-run showed (both as returned by
+// dryRunStatements) and describes each divergence: statements missing from the apply, extra ones, and a changed
+// order. No findings means the apply ran exactly what was reviewed.
+func verifyApplied(expected, executed []string) []string {
+	norm := func(stmts []string) []string {
+		out := make([]string, len(stmts))
+		for i, s := range stmts {
+			out[i] = strings.Join(strings.Fields(s), " ")
+		}
+		return out
+	}
+	exp, got := norm(expected), norm(executed)
+	remaining := make(map[string]int)
+	for _, s := range got {
+		remaining[s]++
+	}
+	var findings []string
+	var common []string
+	for _, s := range exp {
+		if remaining[s] > 0 {
+			remaining[s]--
+			common = append(common, s)
+		} else {
+			findings = append(findings, "missing: "+shortStatement(s))
+		}
+	}
+	expectedCount := make(map[string]int)
+	for _, s := range exp {
+		expectedCount[s]++
+	}
+	var gotCommon []string
+	for _, s := range got {
+		if expectedCount[s] > 0 {
+			expectedCount[s]--
+			gotCommon = append(gotCommon, s)
+		} else {
+			findings = append(findings, "extra: "+shortStatement(s))
+		}
+	}
+	for i := range common {
+		if common[i] != gotCommon[i] {
+			findings = append(findings, fmt.Sprintf("reordered: statement %d ran %s, the dry-run had %s", i+1,
+				shortStatement(gotCommon[i]), shortStatement(common[i])))
+			break
+		}
+	}
+	return findings
+}
+
+// shortStatement abbreviates a statement for a one-line finding.
+func shortStatement(s string) string {
+	const max = 80
+	if r := []rune(s); len(r) > max {
+		return string(r[:max-1]) + "…"
+	}
+	return s
+}
+
+// divergenceReport renders verifyApplied findings as plain text, one finding per line under a heading.
+func divergenceReport(findings []string) string {
+	var b strings.Builder
+	b.WriteString("Warning: the apply ran different statements than the dry-run reviewed before it")
+	b.WriteString(" (did the schema or migration directory change in between?):\n")
+	for _, f := range findings {
+		b.WriteString("  - " + f + "\n")
+	}
+	return b.String()
+}
+
+// driftReport renders verifyApplied(confirmed, pending) findings for an Apply aborted before it ran: confirmed
+// statements that are no longer pending (someone else applied them) and new ones (a migration landed).
+func driftReport(findings []string) string {
+	var b strings.Builder
+	b.WriteString("Apply aborted: the pending statements changed since you confirmed them:\n")
+	for _, f := range findings {
+		f = strings.Replace(f, "missing:", "no longer pending:", 1)
+		f = strings.Replace(f, "extra:", "newly pending:", 1)
+		b.WriteString("  - " + f + "\n")
+	}
+	b.WriteString("\nNothing was applied. Run Apply again to review and confirm the current set.\n")
+	return b.String()
+}
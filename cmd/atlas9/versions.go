@@ -0,0 +1,31 @@
+package main
+
+import "sort"
+
+// versionRow is one entry of the version picker: a migration version, its file (empty if the revision table
+// has a version with no file) and whether the target database records it as applied.
+type versionRow struct {
+	Version string
+	File    string
+	Applied bool
+}
+
+// versionRows merges the migration directory (files maps version to file name) with the applied versions
+// from `migrate status`, oldest first.
+func versionRows(files map[string]string, applied []string) []versionRow {
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+	var rows []versionRow
+	for v, f := range files {
+		rows = append(rows, versionRow{Version: v, File: f, Applied: done[v]})
+	}
+	for v := range done {
+		if _, ok := files[v]; !ok {
+			rows = append(rows, versionRow{Version: v, Applied: true})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Version < rows[j].Version })
+	return rows
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long a file must be quiet before a change is handled. Editors save through a temp
+// file and rename, producing a burst of Create/Write/Rename events per save.
+const watchDebounce = 200 * time.Millisecond
+
+// watchOps are the fsnotify operations that may change a watched file's content (a rename or remove is
+// usually followed by a Create of the new file).
+const watchOps = fsnotify.Write | fsnotify.Create | fsnotify.Rename | fsnotify.Remove
+
+// fileWatch debounces fsnotify events per path and drops changes that left the content as it was, so the
+// UI refreshes once per real change.
+type fileWatch struct {
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+	hashes map[string]string
+}
+
+func newFileWatch() *fileWatch {
+	return &fileWatch{timers: make(map[string]*time.Timer), hashes: make(map[string]string)}
+}
+
+// Prime records path's current content, so only later edits count as changes.
+func (w *fileWatch) Prime(path string) {
+	w.mu.Lock()
+	w.hashes[path] = fileHash(path)
+	w.mu.Unlock()
+}
+
+// Event calls onChange (on its own goroutine) once path has been quiet for watchDebounce, if its content
+// differs from the last time onChange ran.
+func (w *fileWatch) Event(path string, onChange func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.timers[path]; ok {
+		t.Stop()
+	}
+	w.timers[path] = time.AfterFunc(watchDebounce, func() {
+		h := fileHash(path)
+		w.mu.Lock()
+		changed := w.hashes[path] != h
+		w.hashes[path] = h
+		delete(w.timers, path)
+		w.mu.Unlock()
+		if changed {
+			onChange()
+		}
+	})
+}
+
+// fileHash returns the SHA-256 of path's content, or "" when it cannot be read (e.g. mid-rename). For a
+// directory it hashes the names and contents of the files in it and its subdirectories.
+func fileHash(path string) string {
+	h := sha256.New()
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		_ = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return skipHidden(p, path, d)
+			}
+			data, _ := os.ReadFile(p)
+			rel, _ := filepath.Rel(path, p)
+			fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+			h.Write(data)
+			return nil
+		})
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sourceDirs returns the directories to watch for edits to the schema sources at paths (files or directories):
+// the parent of each file and each directory source with every directory under it. fsnotify watches are not
+// recursive, so sources in subdirectories of the project need their own.
+func sourceDirs(paths []string) []string {
+	var dirs []string
+	add := func(dir string) {
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, p := range paths {
+		if info, err := os.Stat(p); err != nil || !info.IsDir() {
+			add(filepath.Dir(p)) // also for a missing file, so its creation is seen
+			continue
+		}
+		_ = filepath.WalkDir(p, func(dir string, d fs.DirEntry, err error) error {
+			if err == nil && d.IsDir() {
+				if err := skipHidden(dir, p, d); err != nil {
+					return err
+				}
+				add(dir)
+			}
+			return nil
+		})
+	}
+	return dirs
+}
+
+// skipHidden returns filepath.SkipDir for a hidden directory (.git, .atlas9) below root, so walks leave it out.
+func skipHidden(path, root string, d fs.DirEntry) error {
+	if d != nil && d.IsDir() && path != root && strings.HasPrefix(d.Name(), ".") {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// sourceOf returns the schema source among paths that name is or lies under, and whether there is one.
+func sourceOf(paths []string, name string) (string, bool) {
+	for _, p := range paths {
+		if name == p || strings.HasPrefix(name, p+string(filepath.Separator)) {
+			return p, true
+		}
+	}
+	return "", false
+}
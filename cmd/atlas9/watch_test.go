@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSourceWatch(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"schema/tables/users.sql", "schema/.cache/x", "db/schema.hcl"} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f), []byte("-- "+f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schema, file := filepath.Join(dir, "schema"), filepath.Join(dir, "db", "schema.hcl")
+	srcs := []string{schema, file, filepath.Join(dir, "new", "later.sql")}
+	want := []string{schema, filepath.Join(schema, "tables"), filepath.Join(dir, "db"), filepath.Join(dir, "new")}
+	if got := sourceDirs(srcs); !slices.Equal(got, want) {
+		t.Errorf("sourceDirs = %v, want %v", got, want)
+	}
+	for _, tc := range []struct {
+		name, want string
+		ok         bool
+	}{
+		{filepath.Join(schema, "tables", "users.sql"), schema, true},
+		{file, file, true},
+		{filepath.Join(dir, "schema2", "a.sql"), "", false},
+		{filepath.Join(dir, "db", "other.hcl"), "", false},
+	} {
+		if got, ok := sourceOf(srcs, tc.name); got != tc.want || ok != tc.ok {
+			t.Errorf("sourceOf(%s) = %q, %v, want %q, %v", tc.name, got, ok, tc.want, tc.ok)
+		}
+	}
+	before := fileHash(schema)
+	if err := os.WriteFile(filepath.Join(dir, "schema/.cache/x"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fileHash(schema) != before {
+		t.Error("fileHash: an edit in a hidden directory changed the hash")
+	}
+	if err := os.WriteFile(filepath.Join(schema, "tables", "users.sql"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fileHash(schema) == before {
+		t.Error("fileHash: an edit in a subdirectory did not change the hash")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"atlas9/internal/config"
+)
+
+// workspaceSession carries a multi-project workspace across runTUI calls. runTUI shows one project; switching
+// stops it so main can start the TUI again in the next project's directory, with that project's UI state
+// restored from States.
+type workspaceSession struct {
+	Workspace *config.Workspace
+	Current   int                  // project shown, index into Workspace.Projects
+	Next      int                  // project to show after runTUI returns; -1 quits
+	States    map[int]projectState // UI state of projects switched away from
+}
+
+// projectState is what a project's TUI looked like when the user switched to another project.
+type projectState struct {
+	Stage    int
+	TxMode   string
+	Flags    map[string]map[string]string
+	Tabs     outputTabs
+	LastRuns map[int]stageStatus
+}
+
+// pendingCountFormat makes `atlas migrate status` print the number of pending migrations.
+const pendingCountFormat = "{{ len .Pending }}"
+
+// projectPending runs `atlas migrate status` for project i of ws with runner (which runs atlas in the project's
+// directory) and returns its pending migration count on env. An empty env is resolved like at startup, from the
+// project's .env and preferences; the env used is returned.
+func projectPending(ctx context.Context, ws *config.Workspace, i int, env string, runner commandRunner) (string, int, error) {
+	dir := ws.ProjectDir(i)
+	parsed, _ := parseEnvFile(filepath.Join(dir, ".env"))
+	if env == "" {
+		conf, _ := config.Load(dir)
+		last := loadLastEnv(dir)
+		if conf.Protected(last) {
+			last = ""
+		}
+		env = resolveEnvName("", last, conf.DefaultEnv, func(key string) string {
+			if v, ok := parsed[key]; ok {
+				return v
+			}
+			return os.Getenv(key)
+		})
+	}
+	res, err := runner.Run(ctx, []string{"migrate", "status", "--env", env, "--format", pendingCountFormat}, mergeEnviron(os.Environ(), parsed))
+	if err != nil {
+		stderr, _, _ := strings.Cut(strings.TrimSpace(res.Stderr), "\n")
+		return env, 0, fmt.Errorf("%v: %s", err, stderr)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(res.Stdout))
+	if err != nil {
+		return env, 0, fmt.Errorf("unexpected migrate status output %q", strings.TrimSpace(res.Stdout))
+	}
+	return env, n, nil
+}
@@ -0,0 +1,505 @@
+// Package config loads atlas9 preferences from TOML files.
+//
+// Two files are read, later ones overriding earlier ones key by key:
+//
+//  1. the user config, $XDG_CONFIG_HOME/atlas9/config.toml (usually ~/.config/atlas9/config.toml)
+//  2. the project config, .atlas9.toml in the project directory
+//
+// Command-line flags override both. Missing files are not an error.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"atlas9/internal/i18n"
+)
+
+// ProjectFile is the name of the project-level config file.
+const ProjectFile = ".atlas9.toml"
+
+// versionRe matches the versions accepted by MinAtlasVersion.
+var versionRe = regexp.MustCompile(`^v\d+\.\d+\.\d+$`)
+
+// macroNameRe matches macro names, which SaveMacro writes as bare TOML keys.
+var macroNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ProductionEnvs are the env names taken for production: protected unless protected_envs says otherwise.
+var ProductionEnvs = []string{"prod", "production", "live"}
+
+// Themes are the accepted values of Config.Theme.
+var Themes = []string{"default", "high-contrast"}
+
+// Actions are the rebindable main-screen actions (keys of Config.Keymap).
+var Actions = []string{"apply_plan", "tables", "migrations", "versions", "push", "tx_mode", "flags", "links", "env", "config", "help", "edit", "rerun", "schedule", "workspace", "external_schema", "lint_rules", "lint_findings", "snapshots", "clean", "connection", "runs", "compare", "changelog", "pull_request", "benchmark", "diagnostics", "run_stage", "macro", "filter", "filter_problems", "next_section", "prev_section", "wrap", "select", "refresh", "quit"}
+
+// ReservedKeys are main-screen keys no keymap entry can take: 1–9 jump to a stage (and follow run_stage).
+const ReservedKeys = "123456789"
+
+// Config is the merged atlas9 configuration.
+type Config struct {
+	Theme         string            `toml:"theme"`
+	Language      string            `toml:"language"`       // message catalog; "" follows LC_ALL / LC_MESSAGES / LANG
+	DefaultEnv    string            `toml:"default_env"`    // used when neither --env, ENVIRONMENT nor the last used env is set
+	ProtectedEnvs []string          `toml:"protected_envs"` // never auto-approved, shown in red; default ProductionEnvs
+	Keymap        map[string]string `toml:"keymap"`         // action -> single key
+	Timeouts      Timeouts          `toml:"timeouts"`
+	Confirm       Confirm           `toml:"confirm"`
+	Hooks         Hooks             `toml:"hooks"`
+	Verify        Verify            `toml:"verify"`
+	Migrations    Migrations        `toml:"migrations"`
+	Seed          Seed              `toml:"seed"`
+	Stages        []Stage           `toml:"stage"`         // extra stages after Apply (and Seed), in order
+	Macros        map[string]string `toml:"macros"`        // name -> recorded keys, e.g. "e<Down><Enter> 1 4"
+	Formats       map[string]string `toml:"formats"`       // stage (see FormatStages) -> Go template for atlas --format
+	DefaultFlags  []DefaultFlags    `toml:"default_flags"` // extra atlas flags per stage and env
+	CheckUpdates  bool              `toml:"check_updates"` // look for a newer atlas9 release at startup
+	// MinAtlasVersion is the oldest atlas CLI atlas9 is known to work with (flags it passes may be missing in
+	// older releases); "" turns the check off.
+	MinAtlasVersion string `toml:"min_atlas_version"`
+}
+
+// Timeouts are durations written as Go duration strings ("5s", "2m").
+type Timeouts struct {
+	Docker      Duration `toml:"docker"`       // docker info check
+	Login       Duration `toml:"login"`        // atlas whoami check
+	Connect     Duration `toml:"connect"`      // direct database connections
+	Refresh     Duration `toml:"refresh"`      // Status auto-refresh interval (0 = off)
+	NotifyAfter Duration `toml:"notify_after"` // desktop notification threshold (0 = off)
+}
+
+// Confirm is the Apply confirmation policy.
+type Confirm struct {
+	// AutoApproveMax auto-approves applies with at most this many non-destructive statements; -1 disables.
+	AutoApproveMax int `toml:"auto_approve_max"`
+	// AskNote asks for a note (ticket, change reason) after each Apply confirmation.
+	AskNote bool `toml:"ask_note"`
+	// Connect connects to the target before the Apply confirmation, for the risk table's row counts and the
+	// long-running transaction check.
+	Connect bool `toml:"connect"`
+}
+
+// DefaultHeaderTemplate is the migration header written when Migrations.Header is on and no template is set.
+const DefaultHeaderTemplate = `-- author: {{.Author}}{{with .Email}} <{{.}}>{{end}}
+-- date: {{.Date}}
+{{- with .Ticket}}
+-- ticket: {{.}}{{end}}
+-- generated by atlas9 {{.Version}}`
+
+// Migrations configures the migration files Diff creates.
+type Migrations struct {
+	// Header prepends a comment header rendered from HeaderTemplate to each file Diff creates; Diff first asks
+	// for a ticket.
+	Header bool `toml:"header"`
+	// HeaderTemplate is a text/template with .Author and .Email (git config), .Date, .Ticket, .Version (atlas9)
+	// and .File. Lines it renders that are not SQL comments are made into ones.
+	HeaderTemplate string `toml:"header_template"`
+}
+
+// BuiltinStages are the names of atlas9's own stages; custom stages cannot reuse them.
+var BuiltinStages = []string{"status", "diff", "lint", "dry-run", "apply", "seed"}
+
+// FormatStages are the stages whose atlas command takes a Formats template. Their own summaries (out-of-order
+// check, lint findings, the dry-run preview) read atlas's default output, so a format set for a stage replaces them.
+var FormatStages = []string{"status", "lint", "dry-run"}
+
+// FlagStages are the stages DefaultFlags can be set for; "apply" covers Dry-Run too, so the preview runs with
+// the flags Apply will.
+var FlagStages = []string{"diff", "lint", "apply"}
+
+// DefaultFlags are atlas flags added to a stage's command, before the ones set in the flags panel (f), which
+// replace a default flag of the same name.
+type DefaultFlags struct {
+	Stage string   `toml:"stage"` // one of FlagStages
+	Env   string   `toml:"env"`   // "" for every env
+	Flags []string `toml:"flags"` // e.g. ["--lock-timeout", "5s"]
+}
+
+// Seed configures the Seed stage, shown after Apply when Dir or Command is set. It never runs on protected envs.
+type Seed struct {
+	// Dir is a migration directory of seed data (e.g. "seed"), applied with atlas migrate apply. Its versions
+	// are recorded in their own revision table, in RevisionsSchema, apart from the schema migrations.
+	Dir             string `toml:"dir"`
+	RevisionsSchema string `toml:"revisions_schema"`
+	// Command is a shell command to run instead of a seed directory; {{env}} expands to the selected env.
+	Command string `toml:"command"`
+}
+
+// Enabled reports whether a seed mechanism is configured.
+func (s Seed) Enabled() bool { return s.Dir != "" || s.Command != "" }
+
+// MaxStages is how many custom stages fit the stage strip (number keys 6–9).
+const MaxStages = 4
+
+// Stage is a user-defined stage (a [[stage]] table), shown after Apply in the stage strip.
+type Stage struct {
+	Name        string `toml:"name"`
+	Description string `toml:"description"`
+	// Command runs with sh -c in the project directory; {{env}} expands to the selected env.
+	Command string `toml:"command"`
+	// Confirm asks before running, like Apply. Protected envs are always asked.
+	Confirm bool `toml:"confirm"`
+}
+
+// Render returns s.Command with {{env}} expanded to env.
+func (s Stage) Render(env string) (string, error) {
+	t, err := template.New(s.Name).Funcs(template.FuncMap{"env": func() string { return env }}).Parse(s.Command)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Hooks are shell commands run (with sh -c, in the project directory) around Apply.
+type Hooks struct {
+	PreApply  []string `toml:"pre_apply"`  // a failing command aborts the apply
+	PostApply []string `toml:"post_apply"` // run after a successful apply
+}
+
+// Verify configures the checks run after a successful Apply (e.g. a health endpoint, a smoke-test query).
+type Verify struct {
+	Checks []Check `toml:"check"`
+	// RollbackStage names a custom stage (e.g. one running atlas migrate down) offered when a check fails.
+	RollbackStage string `toml:"rollback_stage"`
+}
+
+// Check is one verification check: a shell Command or an SQL query run on the env's database.
+type Check struct {
+	Name string   `toml:"name"`
+	Envs []string `toml:"envs"` // envs the check runs on; empty for all
+	// Command runs with sh -c in the project directory; {{env}} expands to the selected env. A non-zero exit fails.
+	Command string `toml:"command"`
+	// SQL fails when the query errors or its first value is false, 0 or NULL.
+	SQL     string   `toml:"sql"`
+	Timeout Duration `toml:"timeout"` // 0 uses timeouts.connect
+}
+
+// AppliesTo reports whether the check runs on env.
+func (c Check) AppliesTo(env string) bool {
+	return len(c.Envs) == 0 || contains(c.Envs, env)
+}
+
+// For returns the checks that run on env.
+func (v Verify) For(env string) []Check {
+	var out []Check
+	for _, c := range v.Checks {
+		if c.AppliesTo(env) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Duration is a time.Duration read from a TOML string.
+type Duration struct{ time.Duration }
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
+		Theme:         "default",
+		ProtectedEnvs: slices.Clone(ProductionEnvs),
+		Keymap: map[string]string{
+			"apply_plan": "a", "tables": "t", "migrations": "m", "versions": "v", "push": "u", "tx_mode": "x", "flags": "f", "links": "o", "env": "e",
+			"config": "c", "help": "h", "edit": "i", "rerun": ".", "schedule": "s", "workspace": "w", "external_schema": "g", "lint_rules": "l", "lint_findings": "k", "snapshots": "n", "clean": "z", "connection": "b", "runs": "p", "compare": "d", "changelog": "j", "pull_request": "P", "benchmark": "B", "diagnostics": "D", "run_stage": " ", "macro": "@", "filter": "/", "filter_problems": "!", "next_section": "]", "prev_section": "[", "wrap": "W", "select": "V", "refresh": "r", "quit": "q",
+		},
+		Timeouts: Timeouts{
+			Docker:      Duration{3 * time.Second},
+			Login:       Duration{5 * time.Second},
+			Connect:     Duration{5 * time.Second},
+			NotifyAfter: Duration{30 * time.Second},
+		},
+		Confirm:         Confirm{AutoApproveMax: -1},
+		Migrations:      Migrations{HeaderTemplate: DefaultHeaderTemplate},
+		Seed:            Seed{RevisionsSchema: "atlas_seed"},
+		CheckUpdates:    true,
+		MinAtlasVersion: "v0.25.0",
+	}
+}
+
+// UserPath returns the user config path ("" if the config directory is unknown).
+func UserPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "atlas9", "config.toml")
+}
+
+// Load merges the user config and the project config in projectDir over Default, then validates the result.
+// On error the returned Config still holds everything that could be read.
+func Load(projectDir string) (Config, error) {
+	c := Default()
+	var errs []error
+	for _, path := range []string{UserPath(), filepath.Join(projectDir, ProjectFile)} {
+		if path == "" {
+			continue
+		}
+		if err := c.mergeFile(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, c.Validate()...)
+	return c, errors.Join(errs...)
+}
+
+// mergeFile decodes path over c; keys absent from the file keep their current values.
+func (c *Config) mergeFile(path string) error {
+	md, err := toml.DecodeFile(path, c)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+	}
+	return nil
+}
+
+// Validate reports every invalid setting.
+func (c Config) Validate() []error {
+	var errs []error
+	if !contains(Themes, c.Theme) {
+		errs = append(errs, fmt.Errorf("theme %q: want one of %v", c.Theme, Themes))
+	}
+	if c.Language != "" && !contains(i18n.Available(), c.Language) {
+		errs = append(errs, fmt.Errorf("language %q: want one of %v", c.Language, i18n.Available()))
+	}
+	actions := make([]string, 0, len(c.Keymap))
+	for action := range c.Keymap {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	used := make(map[string]string)
+	for _, action := range actions {
+		key := c.Keymap[action]
+		if !contains(Actions, action) {
+			errs = append(errs, fmt.Errorf("keymap: unknown action %q (want one of %v)", action, Actions))
+			continue
+		}
+		if len([]rune(key)) != 1 {
+			errs = append(errs, fmt.Errorf("keymap.%s: %q must be a single character", action, key))
+			continue
+		}
+		if strings.Contains(ReservedKeys, key) {
+			errs = append(errs, fmt.Errorf("keymap.%s: %q is reserved (1–9 jump to a stage)", action, key))
+			continue
+		}
+		if action == "select" && key == "y" {
+			errs = append(errs, errors.New(`keymap.select: "y" copies the selection`))
+			continue
+		}
+		if other, ok := used[key]; ok {
+			errs = append(errs, fmt.Errorf("keymap: %q is bound to both %s and %s", key, other, action))
+		}
+		used[key] = action
+	}
+	for _, t := range []struct {
+		name string
+		d    Duration
+	}{
+		{"docker", c.Timeouts.Docker}, {"login", c.Timeouts.Login}, {"connect", c.Timeouts.Connect},
+		{"refresh", c.Timeouts.Refresh}, {"notify_after", c.Timeouts.NotifyAfter},
+	} {
+		if t.d.Duration < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s: must not be negative", t.name))
+		}
+	}
+	for _, env := range c.ProtectedEnvs {
+		if env == "" {
+			errs = append(errs, errors.New("protected_envs: empty env name"))
+		}
+	}
+	if c.MinAtlasVersion != "" && !versionRe.MatchString(c.MinAtlasVersion) {
+		errs = append(errs, fmt.Errorf("min_atlas_version: %q is not a version like v0.25.0", c.MinAtlasVersion))
+	}
+	if _, err := template.New("header").Parse(c.Migrations.HeaderTemplate); err != nil {
+		errs = append(errs, fmt.Errorf("migrations.header_template: %v", err))
+	}
+	if c.Seed.Dir != "" && c.Seed.Command != "" {
+		errs = append(errs, errors.New("seed: set dir or command, not both"))
+	}
+	if _, err := (Stage{Name: "seed", Command: c.Seed.Command}).Render("env"); err != nil {
+		errs = append(errs, fmt.Errorf("seed.command: %v", err))
+	}
+	if len(c.Stages) > MaxStages {
+		errs = append(errs, fmt.Errorf("stage: at most %d custom stages, got %d", MaxStages, len(c.Stages)))
+	}
+	names := map[string]bool{}
+	for _, name := range BuiltinStages {
+		names[name] = true
+	}
+	for i, s := range c.Stages {
+		key := strings.ToLower(strings.TrimSpace(s.Name))
+		switch {
+		case key == "":
+			errs = append(errs, fmt.Errorf("stage %d: name is required", i+1))
+		case names[key]:
+			errs = append(errs, fmt.Errorf("stage %q: name already used", s.Name))
+		}
+		names[key] = true
+		if strings.TrimSpace(s.Command) == "" {
+			errs = append(errs, fmt.Errorf("stage %q: command is required", s.Name))
+		} else if _, err := s.Render("env"); err != nil {
+			errs = append(errs, fmt.Errorf("stage %q: command: %v", s.Name, err))
+		}
+	}
+	for i, chk := range c.Verify.Checks {
+		name := chk.Name
+		if strings.TrimSpace(name) == "" {
+			errs = append(errs, fmt.Errorf("verify.check %d: name is required", i+1))
+			name = strconv.Itoa(i + 1)
+		}
+		switch {
+		case (strings.TrimSpace(chk.Command) == "") == (strings.TrimSpace(chk.SQL) == ""):
+			errs = append(errs, fmt.Errorf("verify.check %q: set one of command and sql", name))
+		case chk.Command != "":
+			if _, err := (Stage{Name: name, Command: chk.Command}).Render("env"); err != nil {
+				errs = append(errs, fmt.Errorf("verify.check %q: command: %v", name, err))
+			}
+		}
+		if chk.Timeout.Duration < 0 {
+			errs = append(errs, fmt.Errorf("verify.check %q: timeout must not be negative", name))
+		}
+	}
+	if r := c.Verify.RollbackStage; r != "" && !slices.ContainsFunc(c.Stages, func(s Stage) bool { return strings.EqualFold(strings.TrimSpace(s.Name), r) }) {
+		errs = append(errs, fmt.Errorf("verify.rollback_stage: no [[stage]] named %q", r))
+	}
+	for name, keys := range c.Macros {
+		switch {
+		case !macroNameRe.MatchString(name):
+			errs = append(errs, fmt.Errorf("macros: name %q: use letters, digits, - and _", name))
+		case keys == "":
+			errs = append(errs, fmt.Errorf("macros.%s: no keys", name))
+		}
+	}
+	for stage, format := range c.Formats {
+		switch {
+		case !contains(FormatStages, stage):
+			errs = append(errs, fmt.Errorf("formats: stage %q: want one of %v", stage, FormatStages))
+		case strings.TrimSpace(format) == "":
+			errs = append(errs, fmt.Errorf("formats.%s: empty template", stage))
+		}
+	}
+	for i, d := range c.DefaultFlags {
+		switch {
+		case !contains(FlagStages, d.Stage):
+			errs = append(errs, fmt.Errorf("default_flags %d: stage %q: want one of %v", i+1, d.Stage, FlagStages))
+		case len(d.Flags) == 0:
+			errs = append(errs, fmt.Errorf("default_flags %d: no flags", i+1))
+		case !strings.HasPrefix(d.Flags[0], "-"):
+			errs = append(errs, fmt.Errorf("default_flags %d: %q is not a flag", i+1, d.Flags[0]))
+		}
+	}
+	if c.Confirm.AutoApproveMax < -1 {
+		errs = append(errs, fmt.Errorf("confirm.auto_approve_max: %d (use -1 to disable)", c.Confirm.AutoApproveMax))
+	}
+	return errs
+}
+
+// Protected reports whether env is listed in ProtectedEnvs.
+func (c Config) Protected(env string) bool {
+	return contains(c.ProtectedEnvs, env)
+}
+
+// FormatArgs returns the --format flag of stage's atlas command, or nil when Formats sets none.
+func (c Config) FormatArgs(stage string) []string {
+	if format := c.Formats[stage]; format != "" {
+		return []string{"--format", format}
+	}
+	return nil
+}
+
+// StageFlags returns the DefaultFlags of stage on env, in the order they are set.
+func (c Config) StageFlags(stage, env string) []string {
+	var flags []string
+	for _, d := range c.DefaultFlags {
+		if d.Stage == stage && (d.Env == "" || d.Env == env) {
+			flags = append(flags, d.Flags...)
+		}
+	}
+	return flags
+}
+
+// SaveMacro sets macros.<name> to keys in the config file at path and leaves the rest of the file as written:
+// the line of a macro of that name is replaced, else the macro is added to the [macros] table, which is
+// appended when the file has none. The file is only written when the result still parses.
+func SaveMacro(path, name, keys string) error {
+	if !macroNameRe.MatchString(name) {
+		return fmt.Errorf("macro name %q: use letters, digits, - and _", name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	line := name + " = " + strconv.Quote(keys)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	in, at, replaced := false, -1, false
+	for i, l := range lines {
+		t := strings.TrimSpace(l)
+		if strings.HasPrefix(t, "[") {
+			if in = t == "[macros]"; in {
+				at = i + 1
+			}
+			continue
+		}
+		if k, _, ok := strings.Cut(t, "="); in && ok && strings.TrimSpace(k) == name {
+			lines[i], replaced = line, true
+			break
+		}
+		if in && t != "" {
+			at = i + 1
+		}
+	}
+	switch {
+	case replaced:
+	case at >= 0:
+		lines = slices.Insert(lines, at, line)
+	case len(lines) > 0:
+		lines = append(lines, "", "[macros]", line)
+	default:
+		lines = []string{"[macros]", line}
+	}
+	out := strings.Join(lines, "\n") + "\n"
+	if _, err := toml.Decode(out, &Config{}); err != nil {
+		return fmt.Errorf("%s: would not parse after adding the macro: %w", path, err)
+	}
+	return os.WriteFile(path, []byte(out), 0644)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadProject loads the config of a project whose .atlas9.toml is project, with no user config.
+func loadProject(t *testing.T, project string) (Config, error) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ProjectFile), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(dir)
+}
+
+func TestProtectedDefault(t *testing.T) {
+	c := Default()
+	for _, tc := range []struct {
+		env  string
+		want bool
+	}{
+		{"prod", true}, {"production", true}, {"live", true}, {"dev", false}, {"staging", false},
+	} {
+		if got := c.Protected(tc.env); got != tc.want {
+			t.Errorf("Default().Protected(%q) = %v, want %v", tc.env, got, tc.want)
+		}
+	}
+	c, err := loadProject(t, "protected_envs = [\"staging\"]\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Protected("staging") || c.Protected("prod") {
+		t.Errorf("protected_envs = [\"staging\"]: want only staging protected, got %v", c.ProtectedEnvs)
+	}
+	c, err = loadProject(t, "protected_envs = []\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Protected("prod") {
+		t.Errorf("protected_envs = []: prod still protected")
+	}
+}
+
+func TestLoadMergeOrder(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	user := UserPath()
+	if err := os.MkdirAll(filepath.Dir(user), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(user, []byte("theme = \"high-contrast\"\ndefault_env = \"dev\"\n[keymap]\ntables = \"T\"\nquit = \"Q\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ProjectFile), []byte("default_env = \"local\"\n[keymap]\nquit = \"X\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ name, got, want string }{
+		{"theme (user over default)", c.Theme, "high-contrast"},
+		{"default_env (project over user)", c.DefaultEnv, "local"},
+		{"keymap.tables (user)", c.Keymap["tables"], "T"},
+		{"keymap.quit (project over user)", c.Keymap["quit"], "X"},
+		{"keymap.help (default)", c.Keymap["help"], "h"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestValidateKeymap(t *testing.T) {
+	if errs := Default().Validate(); len(errs) > 0 {
+		t.Fatalf("Default().Validate() = %v", errs)
+	}
+	for _, tc := range []struct{ keymap, want string }{
+		{`tables = "T"`, ""},
+		{`wrap = "w"` + "\n" + `workspace = "W"`, ""},
+		{`unknown = "u"`, `unknown action "unknown"`},
+		{`tables = "tt"`, "must be a single character"},
+		{`tables = ""`, "must be a single character"},
+		{`tables = "m"`, `"m" is bound to both migrations and tables`},
+		{`quit = " "`, `" " is bound to both quit and run_stage`},
+		{`tables = "]"`, `"]" is bound to both next_section and tables`},
+		{`tables = "2"`, `keymap.tables: "2" is reserved`},
+		{`select = "y"`, `keymap.select: "y" copies the selection`},
+	} {
+		_, err := loadProject(t, "[keymap]\n"+tc.keymap+"\n")
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("keymap %s: %v", tc.keymap, err)
+		case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+			t.Errorf("keymap %s: error %v, want one with %q", tc.keymap, err, tc.want)
+		}
+	}
+}
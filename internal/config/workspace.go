@@ -0,0 +1,95 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// WorkspaceFile lists the Atlas projects of a monorepo. atlas9 looks for it in the working directory and its
+// parents.
+const WorkspaceFile = "atlas9-workspace.toml"
+
+// Workspace is a parsed WorkspaceFile.
+type Workspace struct {
+	Dir      string    `toml:"-"` // directory holding the file; project paths are relative to it
+	Projects []Project `toml:"project"`
+}
+
+// Project is one Atlas project (a directory with atlas.hcl) in a workspace.
+type Project struct {
+	Name string `toml:"name"` // defaults to the last element of Path
+	Path string `toml:"path"`
+}
+
+// FindWorkspace reads the WorkspaceFile in dir or the nearest parent that has one. It returns nil and no error
+// when there is none.
+func FindWorkspace(dir string) (*Workspace, error) {
+	for {
+		path := filepath.Join(dir, WorkspaceFile)
+		if _, err := os.Stat(path); err == nil {
+			return loadWorkspace(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func loadWorkspace(path string) (*Workspace, error) {
+	var ws Workspace
+	if _, err := toml.DecodeFile(path, &ws); err != nil {
+		return nil, err
+	}
+	ws.Dir = filepath.Dir(path)
+	if len(ws.Projects) == 0 {
+		return nil, fmt.Errorf("%s: no [[project]] entries", path)
+	}
+	var errs []error
+	seen := map[string]bool{}
+	for i := range ws.Projects {
+		p := &ws.Projects[i]
+		if p.Path == "" {
+			errs = append(errs, fmt.Errorf("%s: project %d: path is required", path, i+1))
+			continue
+		}
+		if p.Name == "" {
+			p.Name = filepath.Base(p.Path)
+		}
+		if seen[p.Name] {
+			errs = append(errs, fmt.Errorf("%s: project name %q is used twice", path, p.Name))
+		}
+		seen[p.Name] = true
+		if fi, err := os.Stat(ws.ProjectDir(i)); err != nil || !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("%s: project %s: %s is not a directory", path, p.Name, p.Path))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// ProjectDir returns the absolute directory of project i.
+func (w *Workspace) ProjectDir(i int) string {
+	return filepath.Join(w.Dir, filepath.FromSlash(w.Projects[i].Path))
+}
+
+// ProjectAt returns the project whose directory contains dir (the deepest one); ok is false when none does,
+// e.g. when atlas9 is started at the workspace root.
+func (w *Workspace) ProjectAt(dir string) (i int, ok bool) {
+	best, bestLen := 0, -1
+	for i := range w.Projects {
+		pd := w.ProjectDir(i)
+		if (dir == pd || strings.HasPrefix(dir, pd+string(filepath.Separator))) && len(pd) > bestLen {
+			best, bestLen = i, len(pd)
+		}
+	}
+	return best, bestLen >= 0
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeWorkspace creates a workspace at a temporary root with project directories dirs and the WorkspaceFile
+// content, and returns the root.
+func writeWorkspace(t *testing.T, content string, dirs ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, d := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, WorkspaceFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestFindWorkspace(t *testing.T) {
+	root := writeWorkspace(t, `
+[[project]]
+path = "services/billing"
+
+[[project]]
+name = "users"
+path = "services/users"
+
+[[project]]
+path = "services/users/legacy"
+`, "services/billing/migrations", "services/users/legacy", "docs")
+	ws, err := FindWorkspace(filepath.Join(root, "services", "billing", "migrations"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws == nil || ws.Dir != root {
+		t.Fatalf("FindWorkspace from a subdirectory: got %+v, want the workspace at %s", ws, root)
+	}
+	if got := []string{ws.Projects[0].Name, ws.Projects[1].Name, ws.Projects[2].Name}; strings.Join(got, ",") != "billing,users,legacy" {
+		t.Errorf("project names = %v", got)
+	}
+	for _, tc := range []struct {
+		dir    string
+		want   int
+		wantOK bool
+	}{
+		{"services/billing", 0, true},
+		{"services/billing/migrations", 0, true},
+		{"services/users", 1, true},
+		{"services/users/legacy", 2, true}, // the deepest project
+		{"services/users-old", 0, false},   // a prefix of a project's name is not inside it
+		{"docs", 0, false},
+		{".", 0, false},
+	} {
+		i, ok := ws.ProjectAt(filepath.Join(root, tc.dir))
+		if i != tc.want || ok != tc.wantOK {
+			t.Errorf("ProjectAt(%s) = %d, %v; want %d, %v", tc.dir, i, ok, tc.want, tc.wantOK)
+		}
+	}
+
+	if ws, err := FindWorkspace(t.TempDir()); ws != nil || err != nil {
+		t.Errorf("FindWorkspace outside a workspace = %v, %v; want nil, nil", ws, err)
+	}
+	for _, tc := range []struct{ content, want string }{
+		{"", "no [[project]] entries"},
+		{"[[project]]\nname = \"x\"\n", "path is required"},
+		{"[[project]]\npath = \"missing\"\n", "is not a directory"},
+		{"[[project]]\npath = \"a\"\n[[project]]\nname = \"a\"\npath = \"b\"\n", `"a" is used twice`},
+	} {
+		root := writeWorkspace(t, tc.content, "a", "b")
+		if _, err := FindWorkspace(root); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("FindWorkspace(%q): error %v, want one with %q", tc.content, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package i18n holds atlas9's user-facing strings in per-language TOML catalogs.
+//
+// Catalogs live in locales/<lang>.toml and are embedded in the binary. Tables group related strings; a key is
+// addressed as "table.key" (e.g. "footer.enter_status"). Strings missing from a translation fall back to English,
+// so a partial catalog is still usable.
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Fallback is the language every catalog falls back to; its catalog must contain every key.
+const Fallback = "en"
+
+//go:embed locales/*.toml
+var locales embed.FS
+
+// Catalog looks up translated strings.
+type Catalog struct {
+	lang     string
+	messages map[string]string
+	fallback map[string]string
+}
+
+// Available returns the languages with a catalog, sorted.
+func Available() []string {
+	entries, _ := locales.ReadDir("locales")
+	var langs []string
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".toml"))
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Load returns the catalog for lang over the English one. An empty lang, or one without a catalog, yields English
+// and, for the latter, an error.
+func Load(lang string) (*Catalog, error) {
+	fallback, err := read(Fallback)
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{lang: Fallback, messages: fallback, fallback: fallback}
+	if lang == "" || lang == Fallback {
+		return c, nil
+	}
+	messages, err := read(lang)
+	if err != nil {
+		return c, fmt.Errorf("language %q: want one of %v", lang, Available())
+	}
+	c.lang, c.messages = lang, messages
+	return c, nil
+}
+
+// English returns the built-in English catalog.
+func English() *Catalog {
+	c, err := Load(Fallback)
+	if err != nil {
+		panic(err) // the embedded catalog is broken
+	}
+	return c
+}
+
+// read decodes locales/<lang>.toml into a flat "table.key" map.
+func read(lang string) (map[string]string, error) {
+	data, err := locales.ReadFile(path.Join("locales", lang+".toml"))
+	if err != nil {
+		return nil, err
+	}
+	var tables map[string]map[string]string
+	if err := toml.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("locales/%s.toml: %w", lang, err)
+	}
+	messages := make(map[string]string)
+	for table, keys := range tables {
+		for key, msg := range keys {
+			messages[table+"."+key] = msg
+		}
+	}
+	return messages, nil
+}
+
+// Lang returns the catalog language: configured if set, else the language of the first set locale variable
+// (LC_ALL, LC_MESSAGES, LANG), e.g. "de" for "de_DE.UTF-8". The C and POSIX locales are English.
+func Lang(configured string, getenv func(string) string) string {
+	if configured != "" {
+		return configured
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		if v == "C" || v == "POSIX" || strings.HasPrefix(v, "C.") {
+			return Fallback
+		}
+		if i := strings.IndexAny(v, "_.@"); i >= 0 {
+			v = v[:i]
+		}
+		return strings.ToLower(v)
+	}
+	return Fallback
+}
+
+// Lang returns the catalog's language.
+func (c *Catalog) Lang() string { return c.lang }
+
+// T returns the message for key, formatted with args (fmt.Sprintf verbs) when any are given. Unknown keys are
+// returned as-is so a missing string is visible rather than blank.
+func (c *Catalog) T(key string, args ...any) string {
+	msg, ok := c.messages[key]
+	if !ok {
+		if msg, ok = c.fallback[key]; !ok {
+			return key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
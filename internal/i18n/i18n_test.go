@@ -0,0 +1,66 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLang(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		configured string
+		env        map[string]string
+		want       string
+	}{
+		{"configured wins", "de", map[string]string{"LC_ALL": "fr_FR.UTF-8"}, "de"},
+		{"LC_ALL first", "", map[string]string{"LC_ALL": "fr_FR.UTF-8", "LC_MESSAGES": "de_DE", "LANG": "es_ES"}, "fr"},
+		{"LC_MESSAGES over LANG", "", map[string]string{"LC_MESSAGES": "de_DE.UTF-8", "LANG": "es_ES"}, "de"},
+		{"LANG", "", map[string]string{"LANG": "de_DE.UTF-8"}, "de"},
+		{"modifier", "", map[string]string{"LANG": "sr@latin"}, "sr"},
+		{"upper case", "", map[string]string{"LANG": "PT_BR"}, "pt"},
+		{"C", "", map[string]string{"LC_ALL": "C", "LANG": "de_DE"}, Fallback},
+		{"C.UTF-8", "", map[string]string{"LANG": "C.UTF-8"}, Fallback},
+		{"POSIX", "", map[string]string{"LC_MESSAGES": "POSIX"}, Fallback},
+		{"unset", "", nil, Fallback},
+	} {
+		if got := Lang(tc.configured, func(k string) string { return tc.env[k] }); got != tc.want {
+			t.Errorf("%s: Lang = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	en := English()
+	if en.Lang() != Fallback {
+		t.Errorf("English().Lang() = %q", en.Lang())
+	}
+	if got := en.T("confirm.cancel"); got != "Cancel" {
+		t.Errorf("T(confirm.cancel) = %q", got)
+	}
+	if got := en.T("toast.lines_copied", 3); got != "3 line(s) copied to clipboard" {
+		t.Errorf("T with args = %q", got)
+	}
+	if got := en.T("toast.no_such_key"); got != "toast.no_such_key" {
+		t.Errorf("missing key: T = %q, want the key", got)
+	}
+
+	// A partial translation falls back to English for the keys it lacks.
+	de := &Catalog{lang: "de", messages: map[string]string{"confirm.cancel": "Abbrechen"}, fallback: en.fallback}
+	if got := de.T("confirm.cancel"); got != "Abbrechen" {
+		t.Errorf("translated: T = %q", got)
+	}
+	if got := de.T("confirm.run"); got != "Run" {
+		t.Errorf("fallback: T = %q, want the English", got)
+	}
+	if got := de.T("toast.no_such_key"); got != "toast.no_such_key" {
+		t.Errorf("missing in both: T = %q, want the key", got)
+	}
+
+	c, err := Load("xx")
+	if err == nil || !strings.Contains(err.Error(), `"xx"`) {
+		t.Errorf("Load(xx): err = %v", err)
+	}
+	if c == nil || c.Lang() != Fallback || c.T("confirm.cancel") != "Cancel" {
+		t.Errorf("Load(xx) should yield English")
+	}
+}